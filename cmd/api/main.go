@@ -1,22 +1,58 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/vuduongtp/go-core/config"
 	"github.com/vuduongtp/go-core/docs"
 	_ "github.com/vuduongtp/go-core/docs"
+	"github.com/vuduongtp/go-core/internal/api/account"
+	"github.com/vuduongtp/go-core/internal/api/apikey"
 	"github.com/vuduongtp/go-core/internal/api/auth"
 	"github.com/vuduongtp/go-core/internal/api/country"
+	"github.com/vuduongtp/go-core/internal/api/oauth"
+	"github.com/vuduongtp/go-core/internal/api/organization"
+	"github.com/vuduongtp/go-core/internal/api/password"
+	rbacapi "github.com/vuduongtp/go-core/internal/api/rbac"
+	"github.com/vuduongtp/go-core/internal/api/registration"
+	"github.com/vuduongtp/go-core/internal/api/session"
+	"github.com/vuduongtp/go-core/internal/api/twofactor"
 	"github.com/vuduongtp/go-core/internal/api/user"
+	apikeydb "github.com/vuduongtp/go-core/internal/db/apikey"
+	emailverificationdb "github.com/vuduongtp/go-core/internal/db/emailverification"
+	linkedidentitydb "github.com/vuduongtp/go-core/internal/db/linkedidentity"
+	membershipdb "github.com/vuduongtp/go-core/internal/db/membership"
+	mfachallengedb "github.com/vuduongtp/go-core/internal/db/mfachallenge"
+	organizationdb "github.com/vuduongtp/go-core/internal/db/organization"
+	passwordhistorydb "github.com/vuduongtp/go-core/internal/db/passwordhistory"
+	passwordresetdb "github.com/vuduongtp/go-core/internal/db/passwordreset"
+	recoverycodedb "github.com/vuduongtp/go-core/internal/db/recoverycode"
+	refreshtokendb "github.com/vuduongtp/go-core/internal/db/refreshtoken"
+	sessiondb "github.com/vuduongtp/go-core/internal/db/session"
 	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/mail"
 	"github.com/vuduongtp/go-core/internal/rbac"
 	dbutil "github.com/vuduongtp/go-core/internal/util/db"
+	passwordutil "github.com/vuduongtp/go-core/internal/util/password"
+	rbacutil "github.com/vuduongtp/go-core/pkg/rbac"
 	"github.com/vuduongtp/go-core/pkg/server"
+	apikeymw "github.com/vuduongtp/go-core/pkg/server/middleware/apikey"
 	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/tenant"
+	"github.com/vuduongtp/go-core/pkg/util/background"
 	"github.com/vuduongtp/go-core/pkg/util/crypter"
+	"github.com/vuduongtp/go-core/pkg/util/email"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
 	"github.com/vuduongtp/go-core/pkg/util/logger"
+	oauthutil "github.com/vuduongtp/go-core/pkg/util/oauth"
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
 	swaggerutil "github.com/vuduongtp/go-core/pkg/util/swagger"
+	"github.com/vuduongtp/go-core/pkg/util/totp"
+
+	"github.com/labstack/echo/v4"
 )
 
 //	@title			GoCore Example API
@@ -38,6 +74,10 @@ import (
 // @securityDefinitions.apikey	BearerToken
 // @in							header
 // @name						Authorization
+
+// @securityDefinitions.apikey	APIKey
+// @in							header
+// @name						X-API-Key
 func main() {
 	cfg, err := config.Load()
 	checkErr(err)
@@ -50,16 +90,23 @@ func main() {
 	sqlDB, err := db.DB()
 	defer sqlDB.Close()
 
+	passwordPolicy := newPasswordPolicy(cfg)
+
 	// Initialize HTTP server
 	e := server.New(&server.Config{
-		Stage:        cfg.Stage,
-		Port:         cfg.Port,
-		ReadTimeout:  cfg.ReadTimeout,
-		WriteTimeout: cfg.WriteTimeout,
-		AllowOrigins: cfg.AllowOrigins,
-		Debug:        cfg.Debug,
+		Stage:          cfg.Stage,
+		Port:           cfg.Port,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		AllowOrigins:   cfg.AllowOrigins,
+		Debug:          cfg.Debug,
+		PasswordPolicy: passwordPolicy,
+		TrustedProxies: cfg.TrustedProxies,
 	})
 
+	// Permissions required by the routes, see rbacutil.Require
+	routes := rbacutil.NewRoutes(e)
+
 	// Static page for Swagger API specs
 	if cfg.IsEnableAIPDocs {
 		docs.SwaggerInfo.Host = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
@@ -68,28 +115,171 @@ func main() {
 
 	// Initialize DB interfaces
 	userDB := userdb.NewDB()
+	refreshTokenDB := refreshtokendb.NewDB()
+	sessionDB := sessiondb.NewDB()
+	passwordHistoryDB := passwordhistorydb.NewDB()
+	mfaChallengeDB := mfachallengedb.NewDB()
+	recoveryCodeDB := recoverycodedb.NewDB()
+	passwordResetDB := passwordresetdb.NewDB()
+	emailVerificationDB := emailverificationdb.NewDB()
+	linkedIdentityDB := linkedidentitydb.NewDB()
+	apiKeyDB := apikeydb.NewDB()
+	organizationDB := organizationdb.NewDB()
+	membershipDB := membershipdb.NewDB()
 	countryDB := country.NewDB()
 
 	// Initialize services
-	crypterSvc := crypter.New()
-	rbacSvc := rbac.New(cfg.Debug)
-	jwtSvc := jwt.New(cfg.JwtAlgorithm, cfg.JwtSecret, cfg.JwtDuration)
-	authSvc := auth.New(db, userDB, jwtSvc, crypterSvc)
-	userSvc := user.New(db, userDB, rbacSvc, crypterSvc)
-	countrySvc := country.New(db, countryDB, rbacSvc)
+	crypterSvc := crypter.NewWithConfig(crypter.Config{
+		Algorithm: cfg.PasswordHashAlgorithm,
+		Bcrypt:    crypter.BcryptHasher{Cost: cfg.PasswordBcryptCost},
+	})
+	passwordChecker := passwordutil.New(passwordHistoryDB, crypterSvc, passwordPolicy)
+	// Work going on after the responses, e.g. sending emails
+	bgWorker := background.New()
+	mailer := mail.New(email.New(email.Config{Sender: cfg.EmailSender, Region: cfg.EmailRegion, WebURL: cfg.WebURL}), cfg.WebURL)
+	rbacSvc, err := rbac.New(db, cfg.Debug, time.Duration(cfg.RbacWatchInterval)*time.Second)
+	checkErr(err)
+	var jwtKeys []jwt.Key
+	if cfg.JwtKeyDir != "" {
+		jwtKeys, err = jwt.LoadKeys(cfg.JwtKeyDir)
+		checkErr(err)
+	}
+	jwtSvc := jwt.NewWithConfig(jwt.Config{
+		Algorithm:       cfg.JwtAlgorithm,
+		Secret:          cfg.JwtSecret,
+		Keys:            jwtKeys,
+		SigningKeyID:    cfg.JwtSigningKeyID,
+		Duration:        cfg.JwtDuration,
+		Issuer:          cfg.JwtIssuer,
+		Audience:        cfg.JwtAudience,
+		Leeway:          cfg.JwtLeeway,
+		AuthUserFunc:    auth.NewAuthUser,
+		RevocationStore: jwt.NewGormRevocationStore(db),
+		SubjectCheck:    auth.NewSubjectCheck(db, userDB),
+		SubjectCheckTTL: cfg.JwtSubjectCheckTTL,
+	})
+	lockoutStore := lockout.NewGormStore(db)
+	accountLockout := lockout.New(lockoutStore, lockout.Policy{
+		MaxFailures: cfg.LoginMaxFailures,
+		Duration:    time.Duration(cfg.LoginLockDuration) * time.Second,
+		MaxDuration: time.Duration(cfg.LoginMaxLockDuration) * time.Second,
+	})
+	ipLockout := lockout.New(lockoutStore, lockout.Policy{
+		MaxFailures: cfg.LoginIPMaxFailures,
+		Duration:    time.Duration(cfg.LoginLockDuration) * time.Second,
+		MaxDuration: time.Duration(cfg.LoginMaxLockDuration) * time.Second,
+	})
+	totpCipher, err := crypter.NewCipher(cfg.TOTPEncryptionKey)
+	checkErr(err)
+	twoFactorSvc := twofactor.New(db, userDB, recoveryCodeDB, totp.New(cfg.TOTPIssuer), crypterSvc, totpCipher)
+	authSvc := auth.New(db, userDB, refreshTokenDB, sessionDB, mfaChallengeDB, jwtSvc, crypterSvc, twoFactorSvc, accountLockout, ipLockout)
+	userSvc := user.New(db, userDB, membershipDB, rbacSvc, crypterSvc, passwordChecker, accountLockout, authSvc)
+	countrySvc := country.New(db, countryDB)
+	passwordSvc := password.New(db, userDB, passwordResetDB, refreshTokenDB, mailer, bgWorker, crypterSvc, passwordChecker)
+	registrationSvc := registration.New(db, userDB, emailVerificationDB, mailer, bgWorker, crypterSvc)
+	sessionSvc := session.New(db, sessionDB, refreshTokenDB, jwtSvc, rbacSvc)
+	accountSvc := account.New(db, userDB, refreshTokenDB, rbacSvc, jwtSvc, mailer, bgWorker)
+	rbacAPISvc := rbacapi.New(db, userDB, membershipDB, rbacSvc, routes)
+	apiKeySvc := apikey.New(db, userDB, apiKeyDB, crypterSvc)
+	organizationSvc := organization.New(db, organizationDB, membershipDB, userDB, rbacSvc, authSvc)
+	oauthSvc := oauth.New(db, userDB, linkedIdentityDB, apiKeyDB, recoveryCodeDB, refreshTokenDB, authSvc, crypterSvc)
+	oauthFlows := oauthutil.NewWithConfig(oauthutil.Config{
+		Providers:    oauthProviders(cfg),
+		CookieSecure: cfg.OAuthCookieSecure,
+	})
 
 	// Initialize root API
-	auth.NewHTTP(authSvc, e)
+	auth.NewHTTP(authSvc, authSvc, e, jwtSvc.MWFunc())
+	password.NewHTTP(passwordSvc, e)
+	registration.NewHTTP(registrationSvc, e)
+	oauth.NewHTTP(oauthSvc, oauthFlows, e)
+	e.GET("/.well-known/jwks.json", jwtSvc.JWKSHandler)
 
 	// Initialize v1 API
 	v1Router := e.Group("/v1")
-	v1Router.Use(jwtSvc.MWFunc())
+	// Either bearer token or API key
+	v1Router.Use(apikeymw.New(apiKeySvc.Authenticate, jwtSvc.MWFunc()).MWFunc())
+	// Active organization by the org_id claim or the X-Org-ID header
+	v1Router.Use(tenant.New(
+		func(c echo.Context) int { return authSvc.User(c).OrgID },
+		func(c echo.Context, id int) error {
+			return organizationSvc.Resolve(c.Request().Context(), authSvc.User(c), id)
+		},
+	).MWFunc())
+	// Permissions required by the routes, checked with the role in the active organization
+	v1Router.Use(rbac.Middleware(rbacSvc, authSvc))
 
 	user.NewHTTP(userSvc, authSvc, v1Router.Group("/users"))
+	twofactor.NewHTTP(twoFactorSvc, authSvc, v1Router.Group("/users/me/2fa"))
+	session.NewHTTP(sessionSvc, authSvc, v1Router.Group("/users"))
+	account.NewHTTP(accountSvc, authSvc, v1Router.Group("/users"))
+	apikey.NewHTTP(apiKeySvc, authSvc, v1Router.Group("/users/me/api-keys"))
 	country.NewHTTP(countrySvc, authSvc, v1Router.Group("/countries"))
+	rbacapi.NewHTTP(rbacAPISvc, authSvc, v1Router.Group("/rbac"))
+	organization.NewHTTP(organizationSvc, authSvc, v1Router.Group("/organizations"))
 
 	// Start the HTTP server
-	server.Start(e, cfg.Stage == "development")
+	server.Start(e, cfg.Stage == "development", bgWorker.Shutdown)
+}
+
+// oauthProviders returns the social login providers having client ID configured
+func oauthProviders(cfg *config.Configuration) []oauthutil.Provider {
+	callbackURL := func(name string) string {
+		return fmt.Sprintf("%s/oauth/%s/callback", cfg.OAuthRedirectBaseURL, name)
+	}
+
+	var providers []oauthutil.Provider
+	if cfg.OAuthGoogleClientID != "" {
+		p, err := oauthutil.NewGoogle(context.Background(), oauthutil.OIDCConfig{
+			ClientID:     cfg.OAuthGoogleClientID,
+			ClientSecret: cfg.OAuthGoogleClientSecret,
+			RedirectURL:  callbackURL("google"),
+		})
+		checkErr(err)
+		providers = append(providers, p)
+	}
+	if cfg.OAuthGitHubClientID != "" {
+		providers = append(providers, oauthutil.NewGitHub(oauthutil.GitHubConfig{
+			ClientID:     cfg.OAuthGitHubClientID,
+			ClientSecret: cfg.OAuthGitHubClientSecret,
+			RedirectURL:  callbackURL("github"),
+		}))
+	}
+	if cfg.OAuthOIDCClientID != "" {
+		p, err := oauthutil.NewOIDC(context.Background(), oauthutil.OIDCConfig{
+			Name:         cfg.OAuthOIDCName,
+			Issuer:       cfg.OAuthOIDCIssuer,
+			ClientID:     cfg.OAuthOIDCClientID,
+			ClientSecret: cfg.OAuthOIDCClientSecret,
+			RedirectURL:  callbackURL(cfg.OAuthOIDCName),
+		})
+		checkErr(err)
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// newPasswordPolicy returns the password policy, the breached password list may be a file or a directory of range files
+func newPasswordPolicy(cfg *config.Configuration) *passwordpolicy.Policy {
+	policyCfg := passwordpolicy.Config{
+		MinLength:     cfg.PasswordMinLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+		HistorySize:   cfg.PasswordHistorySize,
+	}
+	if cfg.PasswordBreachedPath != "" {
+		fi, err := os.Stat(cfg.PasswordBreachedPath)
+		checkErr(err)
+		if fi.IsDir() {
+			policyCfg.Breached, err = passwordpolicy.NewDirSource(cfg.PasswordBreachedPath)
+		} else {
+			policyCfg.Breached, err = passwordpolicy.NewFileSource(cfg.PasswordBreachedPath)
+		}
+		checkErr(err)
+	}
+	return passwordpolicy.NewWithConfig(policyCfg)
 }
 
 func checkErr(err error) {
@@ -22,8 +22,60 @@ type Configuration struct {
 	JwtSecret       string   `env:"JWT_SECRET"`
 	JwtDuration     int      `env:"JWT_DURATION"`
 	JwtAlgorithm    string   `env:"JWT_ALGORITHM"`
+	JwtKeyDir       string   `env:"JWT_KEY_DIR"`
+	JwtSigningKeyID string   `env:"JWT_SIGNING_KEY_ID"`
+	JwtIssuer       string   `env:"JWT_ISSUER"`
+	JwtAudience     string   `env:"JWT_AUDIENCE"`
+	JwtLeeway       int      `env:"JWT_LEEWAY"`
 	IsEnableAIPDocs bool     `env:"IS_ENABLE_API_DOCS"`
 	APIDocsPath     string   `env:"API_DOCS_PATH"`
+
+	// Seconds for which the blocked status of the users is cached by the jwt middleware
+	JwtSubjectCheckTTL int `env:"JWT_SUBJECT_CHECK_TTL"`
+	// Seconds between the checks for RBAC policy changes made by other instances, 0 to disable
+	RbacWatchInterval int `env:"RBAC_WATCH_INTERVAL"`
+	// CIDR ranges of the reverse proxies trusted to set X-Forwarded-For, the client IP is the direct peer if empty
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	// Login lockout, durations are in seconds
+	LoginMaxFailures     int `env:"LOGIN_MAX_FAILURES"`
+	LoginIPMaxFailures   int `env:"LOGIN_IP_MAX_FAILURES"`
+	LoginLockDuration    int `env:"LOGIN_LOCK_DURATION"`
+	LoginMaxLockDuration int `env:"LOGIN_MAX_LOCK_DURATION"`
+	// Issuer of TOTP secrets, shown in authenticator apps
+	TOTPIssuer string `env:"TOTP_ISSUER"`
+	// Key encrypting the TOTP secrets at rest, 32 random bytes encoded in base64
+	TOTPEncryptionKey string `env:"TOTP_ENCRYPTION_KEY"`
+	// Algorithm of new password hashes: bcrypt, argon2id or scrypt. Passwords are rehashed on login when it changes
+	PasswordHashAlgorithm string `env:"PASSWORD_HASH_ALGORITHM"`
+	PasswordBcryptCost    int    `env:"PASSWORD_BCRYPT_COST"`
+	// Password policy, the breached password list is either a sorted "HASH:COUNT" file or a directory of "<PREFIX>.txt" range files
+	PasswordMinLength     int    `env:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper  bool   `env:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower  bool   `env:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit  bool   `env:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol bool   `env:"PASSWORD_REQUIRE_SYMBOL"`
+	PasswordHistorySize   int    `env:"PASSWORD_HISTORY_SIZE"`
+	PasswordBreachedPath  string `env:"PASSWORD_BREACHED_PATH"`
+
+	// Emails are sent via AWS SES, links in emails point to the web app
+	EmailSender string `env:"EMAIL_SENDER"`
+	EmailRegion string `env:"EMAIL_REGION"`
+	WebURL      string `env:"WEB_URL"`
+
+	// Social login, a provider is enabled when its client ID is set.
+	// The callback URLs are <OAUTH_REDIRECT_BASE_URL>/oauth/<provider>/callback
+	OAuthRedirectBaseURL    string `env:"OAUTH_REDIRECT_BASE_URL"`
+	OAuthCookieSecure       bool   `env:"OAUTH_COOKIE_SECURE"`
+	OAuthGoogleClientID     string `env:"OAUTH_GOOGLE_CLIENT_ID"`
+	OAuthGoogleClientSecret string `env:"OAUTH_GOOGLE_CLIENT_SECRET"`
+	OAuthGitHubClientID     string `env:"OAUTH_GITHUB_CLIENT_ID"`
+	OAuthGitHubClientSecret string `env:"OAUTH_GITHUB_CLIENT_SECRET"`
+	// Any other OpenID Connect provider, e.g: Keycloak, Okta
+	OAuthOIDCName         string `env:"OAUTH_OIDC_NAME"`
+	OAuthOIDCIssuer       string `env:"OAUTH_OIDC_ISSUER"`
+	OAuthOIDCClientID     string `env:"OAUTH_OIDC_CLIENT_ID"`
+	OAuthOIDCClientSecret string `env:"OAUTH_OIDC_CLIENT_SECRET"`
 }
 
 // Load returns Configuration struct
@@ -0,0 +1,136 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrUserNotFound    = server.NewHTTPError(http.StatusBadRequest, "USER_NOTFOUND", "User not found")
+	ErrCannotBlockSelf = server.NewHTTPError(http.StatusBadRequest, "CANNOT_BLOCK_SELF", "You cannot block your own account")
+	ErrAlreadyBlocked  = server.NewHTTPError(http.StatusBadRequest, "USER_ALREADY_BLOCKED", "User is already blocked")
+	ErrNotBlocked      = server.NewHTTPError(http.StatusBadRequest, "USER_NOT_BLOCKED", "User is not blocked")
+)
+
+// Block blocks the user account, recording the reason and the authenticated user as the one who blocked it.
+// All refresh tokens of the user are revoked, and the access tokens are rejected by the jwt middleware.
+func (s *Account) Block(ctx context.Context, authUsr *model.AuthUser, id int, data BlockData) (*model.User, error) {
+	rec, err := s.manageable(ctx, authUsr, id)
+	if err != nil {
+		return nil, err
+	}
+	if authUsr.ID == id {
+		return nil, ErrCannotBlockSelf
+	}
+	if rec.Blocked {
+		return nil, ErrAlreadyBlocked
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"blocked":        true,
+		"blocked_reason": data.Reason,
+		"blocked_at":     now,
+		"blocked_by":     authUsr.ID,
+	}
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := s.udb.Update(ctx, tx, updates, id); err != nil {
+			return err
+		}
+		return s.rtdb.RevokeByUserID(ctx, tx, id)
+	})
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error blocking user").SetInternal(err)
+	}
+	s.jwt.InvalidateSubject(strconv.Itoa(id))
+
+	logger.LogSecurityEvent(ctx, "user_blocked", map[string]interface{}{
+		"user_id":  id,
+		"actor_id": authUsr.ID,
+		"reason":   data.Reason,
+	})
+
+	rec.Blocked, rec.BlockedReason, rec.BlockedAt, rec.BlockedBy = true, data.Reason, &now, &authUsr.ID
+	s.notify(ctx, rec, func(ctx context.Context, usr *model.User) error {
+		return s.mailer.SendAccountBlocked(ctx, usr, data.Reason)
+	})
+
+	return rec, nil
+}
+
+// Unblock unblocks the user account, the user has to login again
+func (s *Account) Unblock(ctx context.Context, authUsr *model.AuthUser, id int) (*model.User, error) {
+	rec, err := s.manageable(ctx, authUsr, id)
+	if err != nil {
+		return nil, err
+	}
+	if !rec.Blocked {
+		return nil, ErrNotBlocked
+	}
+
+	updates := map[string]interface{}{
+		"blocked":        false,
+		"blocked_reason": "",
+		"blocked_at":     nil,
+		"blocked_by":     nil,
+	}
+	if err := s.udb.Update(ctx, s.db, updates, id); err != nil {
+		return nil, server.NewHTTPInternalError("Error unblocking user").SetInternal(err)
+	}
+	s.jwt.InvalidateSubject(strconv.Itoa(id))
+
+	logger.LogSecurityEvent(ctx, "user_unblocked", map[string]interface{}{
+		"user_id":        id,
+		"actor_id":       authUsr.ID,
+		"blocked_reason": rec.BlockedReason,
+	})
+
+	rec.Blocked, rec.BlockedReason, rec.BlockedAt, rec.BlockedBy = false, "", nil, nil
+	s.notify(ctx, rec, s.mailer.SendAccountUnblocked)
+
+	return rec, nil
+}
+
+// manageable returns the user to block or unblock, only superadmins may block or unblock superadmins.
+// The permission to block users is checked by the routes, see NewHTTP. As the account is shared by all organizations,
+// the permission must also be granted outside of the active organization if any
+func (s *Account) manageable(ctx context.Context, authUsr *model.AuthUser, id int) (*model.User, error) {
+	rec := new(model.User)
+	if err := s.udb.View(ctx, s.db, rec, id); err != nil {
+		return nil, ErrUserNotFound.SetInternal(err)
+	}
+	if _, inOrg := dbutil.TenantFromContext(ctx); inOrg && !authUsr.Global().Enforce(s.rbac, model.ObjectUser, model.ActionBlock) {
+		return nil, rbac.ErrForbiddenAction
+	}
+	if rec.Role == model.RoleSuperAdmin && authUsr.Role != model.RoleSuperAdmin {
+		return nil, rbac.ErrForbiddenAction
+	}
+
+	return rec, nil
+}
+
+// notify sends the notification email to a copy of the user in background, failures are only logged as the change has been made
+func (s *Account) notify(ctx context.Context, usr *model.User, send func(context.Context, *model.User) error) {
+	if usr.Email == "" {
+		return
+	}
+	cp := *usr
+	s.bg.Go(ctx, func(ctx context.Context) error {
+		if err := send(ctx, &cp); err != nil {
+			return fmt.Errorf("error sending account notification to user %d: %w", cp.ID, err)
+		}
+		return nil
+	})
+}
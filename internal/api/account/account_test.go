@@ -0,0 +1,292 @@
+package account_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/api/account"
+	"github.com/vuduongtp/go-core/internal/api/auth"
+	mfachallengedb "github.com/vuduongtp/go-core/internal/db/mfachallenge"
+	refreshtokendb "github.com/vuduongtp/go-core/internal/db/refreshtoken"
+	sessiondb "github.com/vuduongtp/go-core/internal/db/session"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+const (
+	password = "Sup3r-Secret!"
+	// orgID is the organization of the fixture user
+	orgID = 1
+)
+
+// permissions of the builtin roles used by the tests
+var permissions = mock.Enforcer{
+	model.RoleAdmin + " " + model.ObjectUser + " " + model.ActionBlock: true,
+}
+
+// invalidator records the subjects whose access tokens are invalidated
+type invalidator struct {
+	subjects []string
+}
+
+func (i *invalidator) InvalidateSubject(sub string) {
+	i.subjects = append(i.subjects, sub)
+}
+
+// mailer records the notifications sent
+type mailer struct {
+	blocked   []string
+	unblocked []string
+}
+
+func (m *mailer) SendAccountBlocked(_ context.Context, u *model.User, reason string) error {
+	m.blocked = append(m.blocked, u.Username+": "+reason)
+	return nil
+}
+
+func (m *mailer) SendAccountUnblocked(_ context.Context, u *model.User) error {
+	m.unblocked = append(m.unblocked, u.Username)
+	return nil
+}
+
+type fixture struct {
+	db     *gorm.DB
+	svc    *account.Account
+	auth   *auth.Auth
+	jwt    *invalidator
+	mailer *mailer
+	user   *model.User
+	admin  *model.AuthUser
+}
+
+func newFixture(t *testing.T) *fixture {
+	db := mock.DB(t, &model.User{}, &model.Membership{}, &model.Session{}, &model.RefreshToken{}, &model.MFAChallenge{})
+	cr := mock.Crypter()
+	hashedPwd, err := cr.HashPassword(password)
+	assert.Nil(t, err)
+	usr := &model.User{Username: "johndoe", Email: "john@mail.com", Password: hashedPwd, Role: model.RoleUser}
+	assert.Nil(t, db.Create(usr).Error)
+	assert.Nil(t, db.Create(&model.Membership{OrgID: orgID, UserID: usr.ID, Role: model.RoleUser}).Error)
+	assert.Nil(t, db.Create(&model.User{Username: "root", Email: "root@mail.com", Password: hashedPwd, Role: model.RoleSuperAdmin}).Error)
+
+	udb := userdb.NewDB()
+	inv, m := &invalidator{}, &mailer{}
+	lo := lockout.New(lockout.NewMemoryStore(), lockout.DefaultPolicy)
+	return &fixture{
+		db:     db,
+		svc:    account.New(db, udb, refreshtokendb.NewDB(), permissions, inv, m, mock.Background{}),
+		auth:   auth.New(db, udb, refreshtokendb.NewDB(), sessiondb.NewDB(), mfachallengedb.NewDB(), jwt.New("HS256", "account-test-secret", 900), cr, nil, lo, lo),
+		jwt:    inv,
+		mailer: m,
+		user:   usr,
+		admin:  &model.AuthUser{ID: 100, Username: "admin", Role: model.RoleAdmin, GlobalRole: model.RoleAdmin},
+	}
+}
+
+func (f *fixture) login(username string) (*model.AuthToken, error) {
+	return f.auth.Authenticate(context.Background(), auth.Credentials{Username: username, Password: password})
+}
+
+// userID returns the ID of the user of the given username
+func (f *fixture) userID(t *testing.T, username string) int {
+	t.Helper()
+	usr := &model.User{}
+	assert.Nil(t, f.db.Where("username = ?", username).First(usr).Error)
+	return usr.ID
+}
+
+func TestBlock(t *testing.T) {
+	cases := []struct {
+		name     string
+		authUsr  func(f *fixture) *model.AuthUser
+		username string
+		// org tells whether the organization of the user is the active one
+		org bool
+		// blocked tells whether the user is blocked beforehand
+		blocked bool
+		wantErr error
+	}{
+		{
+			name:     "Success",
+			username: "johndoe",
+		},
+		{
+			name: "Within the organization",
+			authUsr: func(f *fixture) *model.AuthUser {
+				authUsr := *f.admin
+				authUsr.OrgID = orgID
+				return &authUsr
+			},
+			username: "johndoe",
+			org:      true,
+		},
+		{
+			name: "By organization admin",
+			authUsr: func(_ *fixture) *model.AuthUser {
+				return &model.AuthUser{ID: 101, Username: "orgadmin", Role: model.RoleAdmin, GlobalRole: model.RoleUser, OrgID: orgID}
+			},
+			username: "johndoe",
+			org:      true,
+			wantErr:  rbac.ErrForbiddenAction,
+		},
+		{
+			name:     "Already blocked",
+			username: "johndoe",
+			blocked:  true,
+			wantErr:  account.ErrAlreadyBlocked,
+		},
+		{
+			name: "Own account",
+			authUsr: func(f *fixture) *model.AuthUser {
+				return &model.AuthUser{ID: f.user.ID, Username: f.user.Username, Role: model.RoleAdmin}
+			},
+			username: "johndoe",
+			wantErr:  account.ErrCannotBlockSelf,
+		},
+		{
+			name:     "Superadmin by admin",
+			username: "root",
+			wantErr:  rbac.ErrForbiddenAction,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			ctx := context.Background()
+			if tt.org {
+				ctx = dbutil.WithTenant(ctx, orgID)
+			}
+			authUsr := f.admin
+			if tt.authUsr != nil {
+				authUsr = tt.authUsr(f)
+			}
+			id := f.userID(t, tt.username)
+			token, err := f.login(tt.username)
+			assert.Nil(t, err)
+			if tt.blocked {
+				assert.Nil(t, f.db.Model(&model.User{}).Where("id = ?", id).Update("blocked", true).Error)
+			}
+
+			rec, err := f.svc.Block(ctx, authUsr, id, account.BlockData{Reason: "spam"})
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				assert.Empty(t, f.jwt.subjects)
+				assert.Empty(t, f.mailer.blocked)
+				if !tt.blocked {
+					_, err = f.auth.RefreshToken(ctx, auth.RefreshTokenData{RefreshToken: token.RefreshToken})
+					assert.Nil(t, err, "the tokens are kept")
+				}
+				return
+			}
+
+			// the block is persisted
+			usr := &model.User{}
+			assert.Nil(t, f.db.First(usr, id).Error)
+			assert.True(t, usr.Blocked)
+			assert.Equal(t, "spam", usr.BlockedReason)
+			assert.NotNil(t, usr.BlockedAt)
+			if assert.NotNil(t, usr.BlockedBy) {
+				assert.Equal(t, authUsr.ID, *usr.BlockedBy)
+			}
+			assert.True(t, rec.Blocked)
+
+			// the access tokens are rejected, the refresh tokens are revoked & no login
+			assert.Equal(t, []string{strconv.Itoa(id)}, f.jwt.subjects)
+			var active int64
+			assert.Nil(t, f.db.Model(&model.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", id).Count(&active).Error)
+			assert.Zero(t, active)
+			_, err = f.auth.RefreshToken(ctx, auth.RefreshTokenData{RefreshToken: token.RefreshToken})
+			assert.NotNil(t, err)
+			_, err = f.login(tt.username)
+			assert.ErrorIs(t, err, auth.ErrUserBlocked)
+
+			assert.Equal(t, []string{"johndoe: spam"}, f.mailer.blocked)
+		})
+	}
+}
+
+func TestUnblock(t *testing.T) {
+	cases := []struct {
+		name     string
+		authUsr  *model.AuthUser
+		username string
+		// org tells whether the organization of the user is the active one
+		org bool
+		// blocked tells whether the user is blocked beforehand
+		blocked bool
+		wantErr error
+	}{
+		{
+			name:     "Success",
+			username: "johndoe",
+			blocked:  true,
+		},
+		{
+			name:     "Not blocked",
+			username: "johndoe",
+			wantErr:  account.ErrNotBlocked,
+		},
+		{
+			name:     "Superadmin by admin",
+			username: "root",
+			blocked:  true,
+			wantErr:  rbac.ErrForbiddenAction,
+		},
+		{
+			name:     "By organization admin",
+			authUsr:  &model.AuthUser{ID: 101, Username: "orgadmin", Role: model.RoleAdmin, GlobalRole: model.RoleUser, OrgID: orgID},
+			username: "johndoe",
+			org:      true,
+			blocked:  true,
+			wantErr:  rbac.ErrForbiddenAction,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			ctx := context.Background()
+			if tt.org {
+				ctx = dbutil.WithTenant(ctx, orgID)
+			}
+			authUsr := f.admin
+			if tt.authUsr != nil {
+				authUsr = tt.authUsr
+			}
+			id := f.userID(t, tt.username)
+			if tt.blocked {
+				updates := map[string]interface{}{"blocked": true, "blocked_reason": "spam", "blocked_at": time.Now(), "blocked_by": f.admin.ID}
+				assert.Nil(t, f.db.Model(&model.User{}).Where("id = ?", id).Updates(updates).Error)
+			}
+
+			_, err := f.svc.Unblock(ctx, authUsr, id)
+			assert.ErrorIs(t, err, tt.wantErr)
+			usr := &model.User{}
+			assert.Nil(t, f.db.First(usr, id).Error)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.blocked, usr.Blocked)
+				assert.Empty(t, f.mailer.unblocked)
+				return
+			}
+
+			// the access is restored
+			assert.False(t, usr.Blocked)
+			assert.Empty(t, usr.BlockedReason)
+			assert.Nil(t, usr.BlockedAt)
+			assert.Nil(t, usr.BlockedBy)
+			assert.Equal(t, []string{strconv.Itoa(id)}, f.jwt.subjects)
+			_, err = f.login(tt.username)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{tt.username}, f.mailer.unblocked)
+		})
+	}
+}
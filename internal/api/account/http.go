@@ -0,0 +1,98 @@
+package account
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents account lifecycle http service
+type HTTP struct {
+	svc  Service
+	auth model.Auth
+}
+
+// Service represents account lifecycle application interface
+type Service interface {
+	Block(context.Context, *model.AuthUser, int, BlockData) (*model.User, error)
+	Unblock(context.Context, *model.AuthUser, int) (*model.User, error)
+}
+
+// NewHTTP creates new account lifecycle http service, the routes are registered on the users group
+func NewHTTP(svc Service, auth model.Auth, eg *echo.Group) {
+	h := HTTP{svc, auth}
+
+	eg.POST("/:id/block", h.block, rbac.Require(model.ObjectUser, model.ActionBlock))
+	eg.POST("/:id/unblock", h.unblock, rbac.Require(model.ObjectUser, model.ActionBlock))
+}
+
+// BlockData contains block request
+type BlockData struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+// @Security		BearerToken
+// @Summary		Blocks an user
+// @Description	Blocks the user account with the given reason. The user is signed out of all devices and notified by email
+// @Accept			json
+// @Produce		json
+// @Tags			users
+// @ID				usersBlock
+// @Param			id						path		int					true	"User ID"
+// @Param			request					body		account.BlockData	true	"BlockData"
+// @Success		200						{object}	model.User
+// @Failure		400						{object}	SwaggErrDetailsResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "block"}
+// @Router			/v1/users/{id}/block	[post]
+func (h *HTTP) block(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	r := BlockData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Block(c.Request().Context(), h.auth.User(c), id, r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Unblocks an user
+// @Description	Unblocks the user account and notifies the user by email
+// @Accept			json
+// @Produce		json
+// @Tags			users
+// @ID				usersUnblock
+// @Param			id							path		int	true	"User ID"
+// @Success		200							{object}	model.User
+// @Failure		400							{object}	SwaggErrDetailsResp
+// @Failure		401							{object}	SwaggErrDetailsResp
+// @Failure		403							{object}	SwaggErrDetailsResp
+// @Failure		500							{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "block"}
+// @Router			/v1/users/{id}/unblock	[post]
+func (h *HTTP) unblock(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.Unblock(c.Request().Context(), h.auth.User(c), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
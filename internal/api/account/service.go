@@ -0,0 +1,63 @@
+package account
+
+import (
+	"context"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/util/background"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// New creates new account lifecycle application service, the permissions are checked by the routes, see NewHTTP.
+// The notification emails are sent by the background worker bg
+func New(db *gorm.DB, udb UserDB, rtdb RefreshTokenDB, rbacSvc rbac.Intf, jwt JWT, mailer Mailer, bg Background) *Account {
+	return &Account{
+		db:     db,
+		udb:    udb,
+		rtdb:   rtdb,
+		rbac:   rbacSvc,
+		jwt:    jwt,
+		mailer: mailer,
+		bg:     bg,
+	}
+}
+
+// Account represents account lifecycle application service
+type Account struct {
+	db     *gorm.DB
+	udb    UserDB
+	rtdb   RefreshTokenDB
+	rbac   rbac.Intf
+	jwt    JWT
+	mailer Mailer
+	bg     Background
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	dbutil.Intf
+}
+
+// RefreshTokenDB represents refresh token repository interface
+type RefreshTokenDB interface {
+	RevokeByUserID(context.Context, *gorm.DB, int) error
+}
+
+// JWT represents the interface to apply the changes of the users to their access tokens
+type JWT interface {
+	InvalidateSubject(string)
+}
+
+// Mailer represents email sending interface
+type Mailer interface {
+	SendAccountBlocked(context.Context, *model.User, string) error
+	SendAccountUnblocked(context.Context, *model.User) error
+}
+
+// Background represents background worker interface
+type Background interface {
+	Go(context.Context, background.Task) bool
+}
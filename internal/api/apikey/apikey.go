@@ -0,0 +1,204 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+)
+
+// Custom errors
+var (
+	ErrAPIKeyNotFound    = server.NewHTTPError(http.StatusBadRequest, "API_KEY_NOTFOUND", "API key not found")
+	ErrInvalidScope      = server.NewHTTPValidationError("Scopes must be in object:action format")
+	ErrInvalidExpiry     = server.NewHTTPValidationError("Expiry time must be in the future")
+	ErrAPIKeyNotAllowed  = server.NewHTTPError(http.StatusForbidden, "API_KEY_NOT_ALLOWED", "API keys cannot be managed using an API key")
+	ErrImpersonated      = server.NewHTTPError(http.StatusForbidden, "IMPERSONATION_NOT_ALLOWED", "API keys cannot be managed while impersonating another user")
+	ErrInvalidAPIKeyUser = server.NewHTTPError(http.StatusUnauthorized, "INVALID_API_KEY", "The user of the API key is blocked or not verified")
+)
+
+// KeyPrefix is prepended to the generated keys so that they are easy to recognize. e.g: by secret scanners
+const KeyPrefix = "gck_"
+
+// TouchInterval is the precision of APIKey.LastUsedAt, to avoid writing on every request
+const TouchInterval = time.Minute
+
+// Create creates new API key for the authenticated user, returns the raw key which is not stored
+func (s *APIKey) Create(ctx context.Context, authUsr *model.AuthUser, data CreationData) (*model.APIKey, string, error) {
+	if err := s.checkManageable(authUsr); err != nil {
+		return nil, "", err
+	}
+	if err := validateScopes(data.Scopes); err != nil {
+		return nil, "", err
+	}
+	if data.ExpiresAt != nil && !data.ExpiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidExpiry
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		return nil, "", server.NewHTTPInternalError("Error generating API key").SetInternal(err)
+	}
+	rec := &model.APIKey{
+		UserID:    authUsr.ID,
+		Name:      data.Name,
+		Prefix:    key[:len(KeyPrefix)+8],
+		KeyHash:   s.cr.HashToken(key),
+		Scopes:    data.Scopes,
+		ExpiresAt: data.ExpiresAt,
+	}
+	if err := s.akdb.Create(ctx, s.db, rec); err != nil {
+		return nil, "", server.NewHTTPInternalError("Error creating API key").SetInternal(err)
+	}
+
+	logger.LogSecurityEvent(ctx, "api_key_created", map[string]interface{}{
+		"user_id":    authUsr.ID,
+		"api_key_id": rec.ID,
+		"scopes":     rec.Scopes,
+	})
+
+	return rec, key, nil
+}
+
+// List returns all API keys of the authenticated user
+func (s *APIKey) List(ctx context.Context, authUsr *model.AuthUser) ([]*model.APIKey, error) {
+	if err := s.checkManageable(authUsr); err != nil {
+		return nil, err
+	}
+
+	data, err := s.akdb.ListByUserID(ctx, s.db, authUsr.ID)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error listing API keys").SetInternal(err)
+	}
+
+	return data, nil
+}
+
+// View returns single API key of the authenticated user
+func (s *APIKey) View(ctx context.Context, authUsr *model.AuthUser, id int) (*model.APIKey, error) {
+	if err := s.checkManageable(authUsr); err != nil {
+		return nil, err
+	}
+
+	return s.find(ctx, authUsr.ID, id)
+}
+
+// Update updates name & scopes of an API key of the authenticated user
+func (s *APIKey) Update(ctx context.Context, authUsr *model.AuthUser, id int, data UpdateData) (*model.APIKey, error) {
+	if err := s.checkManageable(authUsr); err != nil {
+		return nil, err
+	}
+	if data.Scopes != nil {
+		if err := validateScopes(data.Scopes); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.find(ctx, authUsr.ID, id); err != nil {
+		return nil, err
+	}
+
+	updates := &model.APIKey{Scopes: data.Scopes}
+	if data.Name != nil {
+		updates.Name = *data.Name
+	}
+	if err := s.akdb.Update(ctx, s.db, updates, id); err != nil {
+		return nil, server.NewHTTPInternalError("Error updating API key").SetInternal(err)
+	}
+
+	return s.find(ctx, authUsr.ID, id)
+}
+
+// Delete revokes an API key of the authenticated user
+func (s *APIKey) Delete(ctx context.Context, authUsr *model.AuthUser, id int) error {
+	if err := s.checkManageable(authUsr); err != nil {
+		return err
+	}
+	if _, err := s.find(ctx, authUsr.ID, id); err != nil {
+		return err
+	}
+
+	if err := s.akdb.Delete(ctx, s.db, id); err != nil {
+		return server.NewHTTPInternalError("Error deleting API key").SetInternal(err)
+	}
+
+	logger.LogSecurityEvent(ctx, "api_key_deleted", map[string]interface{}{
+		"user_id":    authUsr.ID,
+		"api_key_id": id,
+	})
+
+	return nil
+}
+
+// Authenticate returns the authenticated user of the given raw key, see apikey.Config.Authenticate.
+// The user is loaded on every request so that role changes & blocking take effect immediately.
+func (s *APIKey) Authenticate(ctx context.Context, key string) (interface{}, error) {
+	rec, err := s.akdb.FindByKey(ctx, s.db, s.cr.HashToken(key))
+	if err != nil || rec == nil || !rec.IsValid(time.Now()) {
+		return nil, ErrAPIKeyNotFound.SetInternal(err)
+	}
+
+	usr := new(model.User)
+	if err := s.udb.View(ctx, s.db, usr, rec.UserID); err != nil {
+		return nil, ErrAPIKeyNotFound.SetInternal(err)
+	}
+	if usr.Blocked || usr.Pending {
+		return nil, ErrInvalidAPIKeyUser
+	}
+
+	if err := s.akdb.Touch(ctx, s.db, rec.ID, TouchInterval); err != nil {
+		logger.LogErrorf(ctx, "error updating api key last used time: %+v", err.Error())
+	}
+
+	return &model.AuthUser{
+		ID:         usr.ID,
+		Username:   usr.Username,
+		Email:      usr.Email,
+		Role:       usr.Role,
+		GlobalRole: usr.Role,
+		APIKeyID:   rec.ID,
+		Scopes:     rec.Scopes,
+	}, nil
+}
+
+// find returns the API key of the given user
+func (s *APIKey) find(ctx context.Context, uid, id int) (*model.APIKey, error) {
+	rec := new(model.APIKey)
+	if err := s.akdb.View(ctx, s.db, rec, "id = ? AND user_id = ?", id, uid); err != nil {
+		return nil, ErrAPIKeyNotFound.SetInternal(err)
+	}
+	return rec, nil
+}
+
+// checkManageable prevents a leaked key from being used to create more keys, and support staff from using the keys of the impersonated user
+func (s *APIKey) checkManageable(authUsr *model.AuthUser) error {
+	if authUsr.APIKeyID != 0 {
+		return ErrAPIKeyNotAllowed
+	}
+	if authUsr.IsImpersonated() {
+		return ErrImpersonated
+	}
+	return nil
+}
+
+func validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if _, _, ok := model.ParseScope(scope); !ok {
+			return ErrInvalidScope
+		}
+	}
+	return nil
+}
+
+// generateKey returns new random key with KeyPrefix
+func generateKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return KeyPrefix + hex.EncodeToString(b), nil
+}
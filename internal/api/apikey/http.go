@@ -0,0 +1,207 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents API key http service
+type HTTP struct {
+	svc  Service
+	auth model.Auth
+}
+
+// Service represents API key application interface
+type Service interface {
+	Create(context.Context, *model.AuthUser, CreationData) (*model.APIKey, string, error)
+	List(context.Context, *model.AuthUser) ([]*model.APIKey, error)
+	View(context.Context, *model.AuthUser, int) (*model.APIKey, error)
+	Update(context.Context, *model.AuthUser, int, UpdateData) (*model.APIKey, error)
+	Delete(context.Context, *model.AuthUser, int) error
+}
+
+// NewHTTP creates new API key http service
+func NewHTTP(svc Service, auth model.Auth, eg *echo.Group) {
+	h := HTTP{svc, auth}
+
+	eg.POST("", h.create)
+	eg.GET("", h.list)
+	eg.GET("/:id", h.view)
+	eg.PATCH("/:id", h.update)
+	eg.DELETE("/:id", h.delete)
+}
+
+// CreationData contains API key data from json request
+type CreationData struct {
+	// example: nightly-report
+	Name string `json:"name" validate:"required,max=100"`
+	// Permissions of the key within the user's role, in object:action format
+	// example: ["country:view_all", "user:view"]
+	Scopes []string `json:"scopes" validate:"required,min=1"`
+	// The key never expires if not set
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// UpdateData contains API key data from json request
+type UpdateData struct {
+	// example: nightly-report
+	Name *string `json:"name,omitempty" validate:"omitempty,max=100"`
+	// example: ["country:view_all"]
+	Scopes []string `json:"scopes,omitempty" validate:"omitempty,min=1"`
+}
+
+// CreationResp contains the created API key along with its raw value
+type CreationResp struct {
+	*model.APIKey
+	// The key to be sent in the X-API-Key header, it is only returned once
+	Key string `json:"key"`
+}
+
+// ListResp contains list of API keys
+type ListResp struct {
+	Data []*model.APIKey `json:"data"`
+}
+
+// @Security		BearerToken
+// @Summary		Creates new API key
+// @Description	Creates new personal API key, the key is returned only once. Requests sent with the key in the X-API-Key header act as the user, within the scopes of the key
+// @Accept			json
+// @Produce		json
+// @Tags			api-keys
+// @ID				apiKeysCreate
+// @Param			request					body		apikey.CreationData	true	"CreationData"
+// @Success		200						{object}	apikey.CreationResp
+// @Failure		400						{object}	SwaggErrDetailsResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/api-keys	[post]
+func (h *HTTP) create(c echo.Context) error {
+	r := CreationData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	r.Name = strings.TrimSpace(r.Name)
+
+	rec, key, err := h.svc.Create(c.Request().Context(), h.auth.User(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, CreationResp{rec, key})
+}
+
+// @Security		BearerToken
+// @Summary		Returns list of API keys
+// @Description	Returns all API keys of the current user
+// @Accept			json
+// @Produce		json
+// @Tags			api-keys
+// @ID				apiKeysList
+// @Success		200						{object}	apikey.ListResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/api-keys	[get]
+func (h *HTTP) list(c echo.Context) error {
+	resp, err := h.svc.List(c.Request().Context(), h.auth.User(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ListResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Returns a single API key
+// @Description	Returns a single API key of the current user
+// @Accept			json
+// @Produce		json
+// @Tags			api-keys
+// @ID				apiKeysView
+// @Param			id							path		int	true	"API key ID"
+// @Success		200							{object}	model.APIKey
+// @Failure		400							{object}	SwaggErrDetailsResp
+// @Failure		401							{object}	SwaggErrDetailsResp
+// @Failure		403							{object}	SwaggErrDetailsResp
+// @Failure		500							{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/api-keys/{id}	[get]
+func (h *HTTP) view(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.View(c.Request().Context(), h.auth.User(c), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Updates an API key
+// @Description	Updates name & scopes of an API key of the current user
+// @Accept			json
+// @Produce		json
+// @Tags			api-keys
+// @ID				apiKeysUpdate
+// @Param			id							path		int					true	"API key ID"
+// @Param			request						body		apikey.UpdateData	true	"UpdateData"
+// @Success		200							{object}	model.APIKey
+// @Failure		400							{object}	SwaggErrDetailsResp
+// @Failure		401							{object}	SwaggErrDetailsResp
+// @Failure		403							{object}	SwaggErrDetailsResp
+// @Failure		500							{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/api-keys/{id}	[patch]
+func (h *HTTP) update(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	r := UpdateData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	r.Name = httputil.TrimSpacePointer(r.Name)
+
+	resp, err := h.svc.Update(c.Request().Context(), h.auth.User(c), id, r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Revokes an API key
+// @Description	Revokes an API key of the current user, requests using the key are rejected right away
+// @Accept			json
+// @Produce		json
+// @Tags			api-keys
+// @ID				apiKeysDelete
+// @Param			id							path		int	true	"API key ID"
+// @Success		200							{object}	SwaggOKResp
+// @Failure		400							{object}	SwaggErrDetailsResp
+// @Failure		401							{object}	SwaggErrDetailsResp
+// @Failure		403							{object}	SwaggErrDetailsResp
+// @Failure		500							{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/api-keys/{id}	[delete]
+func (h *HTTP) delete(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.svc.Delete(c.Request().Context(), h.auth.User(c), id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
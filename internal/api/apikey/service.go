@@ -0,0 +1,47 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// New creates new API key application service
+func New(db *gorm.DB, udb UserDB, akdb APIKeyDB, cr Crypter) *APIKey {
+	return &APIKey{
+		db:   db,
+		udb:  udb,
+		akdb: akdb,
+		cr:   cr,
+	}
+}
+
+// APIKey represents API key application service
+type APIKey struct {
+	db   *gorm.DB
+	udb  UserDB
+	akdb APIKeyDB
+	cr   Crypter
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	dbutil.Intf
+}
+
+// APIKeyDB represents API key repository interface
+type APIKeyDB interface {
+	dbutil.Intf
+	FindByKey(context.Context, *gorm.DB, string) (*model.APIKey, error)
+	ListByUserID(context.Context, *gorm.DB, int) ([]*model.APIKey, error)
+	Touch(context.Context, *gorm.DB, int, time.Duration) error
+}
+
+// Crypter represents security interface
+type Crypter interface {
+	HashToken(string) string
+}
@@ -2,78 +2,549 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/labstack/echo/v4"
 	"github.com/vuduongtp/go-core/internal/model"
 	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+	"gorm.io/gorm"
 )
 
 // Custom errors
 var (
 	ErrInvalidCredentials  = server.NewHTTPError(http.StatusUnauthorized, "INVALID_CREDENTIALS", "Username or password is incorrect")
 	ErrUserBlocked         = server.NewHTTPError(http.StatusUnauthorized, "USER_BLOCKED", "Your account has been blocked and may not login")
+	ErrEmailNotVerified    = server.NewHTTPError(http.StatusUnauthorized, "EMAIL_NOT_VERIFIED", "Please verify your email before logging in")
 	ErrInvalidRefreshToken = server.NewHTTPError(http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", "Invalid refresh token")
+	ErrInvalidMFAToken     = server.NewHTTPError(http.StatusUnauthorized, "INVALID_MFA_TOKEN", "Invalid or expired MFA token")
+	ErrInvalid2FACode      = server.NewHTTPError(http.StatusUnauthorized, "INVALID_2FA_CODE", "Invalid two-factor authentication code")
+	ErrAPIKeyNotAllowed    = server.NewHTTPError(http.StatusForbidden, "API_KEY_NOT_ALLOWED", "This action is not allowed using an API key")
+	ErrImpersonated        = server.NewHTTPError(http.StatusForbidden, "IMPERSONATION_NOT_ALLOWED", "This action is not allowed while impersonating another user")
 )
 
+// MFAChallengeDuration is the time given to pass the second factor after the password has been verified
+const MFAChallengeDuration = 5 * time.Minute
+
+// RefreshTokenDuration is the validity period of refresh tokens, each rotation issues a new token valid for the whole period
+const RefreshTokenDuration = 30 * 24 * time.Hour
+
+// ImpersonationTokenDuration is the validity period of impersonation tokens, they cannot be refreshed
+const ImpersonationTokenDuration = 15 * time.Minute
+
+// ErrAccountLocked returns the error of too many failed logins, the client may retry after the given duration
+func ErrAccountLocked(retryAfter time.Duration) *server.HTTPError {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	return server.NewHTTPError(http.StatusTooManyRequests, "ACCOUNT_LOCKED", "Too many failed login attempts, please try again later").
+		SetHeader(echo.HeaderRetryAfter, strconv.Itoa(secs))
+}
+
 // LoginUser logs in the given user, returns access token
 func (s *Auth) LoginUser(ctx context.Context, u *model.User) (*model.AuthToken, error) {
-	claims := map[string]interface{}{
-		"id":       u.ID,
-		"username": u.Username,
-		"email":    u.Email,
-		"role":     u.Role,
+	if err := s.udb.Update(ctx, s.db, map[string]interface{}{"last_login": time.Now()}, u.ID); err != nil {
+		return nil, server.NewHTTPInternalError("Error updating user").SetInternal(err)
 	}
-	token, expiresin, err := s.jwt.GenerateToken(claims, nil)
+
+	// every login starts a new session with its own refresh token family
+	ci := httputil.ClientInfoFromContext(ctx)
+	sess := &model.Session{
+		UserID:    u.ID,
+		Family:    s.cr.UID(),
+		UserAgent: truncate(ci.UserAgent, 500),
+		IP:        ci.IP,
+		LastUsed:  time.Now(),
+	}
+
+	var resp *model.AuthToken
+	err := dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := s.sdb.Create(ctx, tx, sess); err != nil {
+			return err
+		}
+		var err error
+		resp, err = s.issueToken(ctx, tx, u, sess)
+		return err
+	})
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error creating session").SetInternal(err)
+	}
+
+	return resp, nil
+}
+
+// Impersonate issues a short-lived access token of the given user to the actor.
+// The actor is kept in the `act` claim, no session or refresh token is created.
+// Given the membership of the user, the token is pinned to its organization and carries the role of the membership, not the global one.
+func (s *Auth) Impersonate(ctx context.Context, actor *model.AuthUser, u *model.User, m *model.Membership) (*model.AuthToken, error) {
+	claims := &jwt.Claims{
+		UserID:   u.ID,
+		Username: u.Username,
+		Email:    u.Email,
+		Role:     u.Role,
+		Actor: &jwt.Actor{
+			Subject:  strconv.Itoa(actor.ID),
+			UserID:   actor.ID,
+			Username: actor.Username,
+		},
+	}
+	if m != nil {
+		claims.Role, claims.OrgID = m.Role, m.OrgID
+	}
+	claims.Subject = strconv.Itoa(u.ID)
+	expire := time.Now().Add(ImpersonationTokenDuration)
+	token, expiresin, err := s.jwt.GenerateToken(claims, &expire)
 	if err != nil {
 		return nil, server.NewHTTPInternalError("Error generating token").SetInternal(err)
 	}
 
-	refreshToken := s.cr.UID()
-	err = s.udb.Update(ctx, s.db, map[string]interface{}{"refresh_token": refreshToken, "last_login": time.Now()}, u.ID)
+	logger.LogSecurityEvent(ctx, "impersonation_started", map[string]interface{}{
+		"actor_id": actor.ID,
+		"user_id":  u.ID,
+		"org_id":   claims.OrgID,
+		"token_id": claims.ID,
+	})
+	return &model.AuthToken{AccessToken: token, TokenType: "bearer", ExpiresIn: expiresin}, nil
+}
+
+// OrganizationToken issues an access token of the user pinned to the given organization, see the tenant middleware.
+// It expires with the access token in use, no session or refresh token is created.
+func (s *Auth) OrganizationToken(ctx context.Context, authUsr *model.AuthUser, orgID int) (*model.AuthToken, error) {
+	claims := &jwt.Claims{
+		UserID:    authUsr.ID,
+		Username:  authUsr.Username,
+		Email:     authUsr.Email,
+		Role:      authUsr.Role,
+		SessionID: authUsr.SessionID,
+		OrgID:     orgID,
+	}
+	if authUsr.IsImpersonated() {
+		claims.Actor = &jwt.Actor{
+			Subject:  strconv.Itoa(authUsr.ActorID),
+			UserID:   authUsr.ActorID,
+			Username: authUsr.ActorUsername,
+		}
+	}
+	claims.Subject = strconv.Itoa(authUsr.ID)
+	var expire *time.Time
+	if !authUsr.TokenExpiresAt.IsZero() {
+		expire = &authUsr.TokenExpiresAt
+	}
+	token, expiresin, err := s.jwt.GenerateToken(claims, expire)
 	if err != nil {
-		return nil, server.NewHTTPInternalError("Error updating user").SetInternal(err)
+		return nil, server.NewHTTPInternalError("Error generating token").SetInternal(err)
 	}
 
-	return &model.AuthToken{AccessToken: token, TokenType: "bearer", ExpiresIn: expiresin, RefreshToken: refreshToken}, nil
+	return &model.AuthToken{AccessToken: token, TokenType: "bearer", ExpiresIn: expiresin}, nil
 }
 
-// Authenticate tries to authenticate the user provided by given credentials
+// Authenticate tries to authenticate the user provided by given credentials.
+// Both the account and the client IP are locked out temporarily after too many failures.
+// Users with two-factor authentication enabled get an MFA challenge token instead, see LoginTwoFactor.
 func (s *Auth) Authenticate(ctx context.Context, data Credentials) (*model.AuthToken, error) {
+	accountKey, ipKey := lockKeys(ctx, data.Username)
+	if err := s.checkLockout(ctx, accountKey, ipKey); err != nil {
+		return nil, err
+	}
+
 	usr, err := s.udb.FindByUsername(ctx, s.db, data.Username)
 	if err != nil || usr == nil {
-		return nil, ErrInvalidCredentials.SetInternal(err)
+		return nil, s.failLogin(ctx, accountKey, ipKey, ErrInvalidCredentials.SetInternal(err))
 	}
 	if !s.cr.CompareHashAndPassword(usr.Password, data.Password) {
-		return nil, ErrInvalidCredentials
+		return nil, s.failLogin(ctx, accountKey, ipKey, ErrInvalidCredentials)
 	}
+	s.rehashPassword(ctx, usr, data.Password)
 	if usr.Blocked {
 		return nil, ErrUserBlocked
 	}
+	if usr.Pending {
+		return nil, ErrEmailNotVerified
+	}
+	if usr.TOTPEnabled {
+		return s.Challenge(ctx, usr)
+	}
+	if err := s.accountLockout.Reset(ctx, accountKey); err != nil {
+		return nil, server.NewHTTPInternalError("Error resetting login attempts").SetInternal(err)
+	}
 
 	return s.LoginUser(ctx, usr)
 }
 
-// RefreshToken returns the new access token with expired time extended
+// LoginTwoFactor exchanges the MFA challenge token and the second factor for the access token.
+// Failed codes count towards the login lockout of the account.
+func (s *Auth) LoginTwoFactor(ctx context.Context, data TwoFactorData) (*model.AuthToken, error) {
+	rec, err := s.mcdb.FindByToken(ctx, s.db, s.cr.HashToken(data.MFAToken))
+	if err != nil || rec == nil || !rec.IsValid(time.Now()) {
+		return nil, ErrInvalidMFAToken.SetInternal(err)
+	}
+
+	usr := new(model.User)
+	if err := s.udb.View(ctx, s.db, usr, rec.UserID); err != nil {
+		return nil, ErrInvalidMFAToken.SetInternal(err)
+	}
+	if usr.Blocked {
+		return nil, ErrUserBlocked
+	}
+
+	accountKey, ipKey := lockKeys(ctx, usr.Username)
+	if err := s.checkLockout(ctx, accountKey, ipKey); err != nil {
+		return nil, err
+	}
+
+	// the challenge is completed along with the consumption of the code, so that neither is lost without the other
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		ok, err := s.mcdb.MarkUsed(ctx, tx, rec.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidMFAToken
+		}
+		ok, err = s.tfa.Verify(ctx, tx, usr, data.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalid2FACode
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, ErrInvalidMFAToken):
+		return nil, ErrInvalidMFAToken
+	case errors.Is(err, ErrInvalid2FACode):
+		return nil, s.failLogin(ctx, accountKey, ipKey, ErrInvalid2FACode)
+	case err != nil:
+		return nil, server.NewHTTPInternalError("Error verifying code").SetInternal(err)
+	}
+	if err := s.accountLockout.Reset(ctx, accountKey); err != nil {
+		return nil, server.NewHTTPInternalError("Error resetting login attempts").SetInternal(err)
+	}
+
+	return s.LoginUser(ctx, usr)
+}
+
+// RefreshToken rotates the given refresh token, returns the new access token with expired time extended
 func (s *Auth) RefreshToken(ctx context.Context, data RefreshTokenData) (*model.AuthToken, error) {
-	usr, err := s.udb.FindByRefreshToken(ctx, s.db, data.RefreshToken)
-	if err != nil || usr == nil {
+	rec, err := s.rtdb.FindByToken(ctx, s.db, s.cr.HashToken(data.RefreshToken))
+	if err != nil || rec == nil {
 		return nil, ErrInvalidRefreshToken.SetInternal(err)
 	}
-	return s.LoginUser(ctx, usr)
+	if rec.IsRevoked() {
+		return nil, ErrInvalidRefreshToken
+	}
+	if rec.IsUsed() {
+		return nil, s.revokeReusedToken(ctx, rec)
+	}
+	if rec.IsExpired(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	usr := new(model.User)
+	if err := s.udb.View(ctx, s.db, usr, rec.UserID); err != nil {
+		return nil, ErrInvalidRefreshToken.SetInternal(err)
+	}
+	if usr.Blocked {
+		return nil, ErrUserBlocked
+	}
+
+	var resp *model.AuthToken
+	reused := false
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		ok, err := s.rtdb.MarkUsed(ctx, tx, rec.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// another request has rotated the same token concurrently
+			reused = true
+			return nil
+		}
+		sess, err := s.session(ctx, tx, rec)
+		if err != nil {
+			return err
+		}
+		resp, err = s.issueToken(ctx, tx, usr, sess)
+		return err
+	})
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error rotating refresh token").SetInternal(err)
+	}
+	if reused {
+		return nil, s.revokeReusedToken(ctx, rec)
+	}
+
+	return resp, nil
 }
 
-// User returns user data stored in jwt token
+// Logout revokes the access token in use, and the refresh token family if given
+func (s *Auth) Logout(ctx context.Context, authUsr *model.AuthUser, data LogoutData) error {
+	var rec *model.RefreshToken
+	if data.RefreshToken != "" {
+		var err error
+		rec, err = s.rtdb.FindByToken(ctx, s.db, s.cr.HashToken(data.RefreshToken))
+		if err != nil || rec == nil || rec.UserID != authUsr.ID {
+			return ErrInvalidRefreshToken.SetInternal(err)
+		}
+	}
+
+	if authUsr.TokenID != "" {
+		if err := s.jwt.RevokeToken(ctx, authUsr.TokenID, authUsr.TokenExpiresAt); err != nil {
+			return server.NewHTTPInternalError("Error revoking token").SetInternal(err)
+		}
+	}
+	if rec != nil {
+		if err := s.rtdb.RevokeFamily(ctx, s.db, rec.Family); err != nil {
+			return server.NewHTTPInternalError("Error revoking refresh token").SetInternal(err)
+		}
+	}
+
+	return nil
+}
+
+// LogoutAll revokes all access tokens and refresh tokens of the authenticated user, not allowed from an impersonated session
+func (s *Auth) LogoutAll(ctx context.Context, authUsr *model.AuthUser) error {
+	if authUsr.APIKeyID != 0 {
+		return ErrAPIKeyNotAllowed
+	}
+	if authUsr.IsImpersonated() {
+		return ErrImpersonated
+	}
+	if err := s.rtdb.RevokeByUserID(ctx, s.db, authUsr.ID); err != nil {
+		return server.NewHTTPInternalError("Error revoking refresh token").SetInternal(err)
+	}
+	if err := s.jwt.RevokeSubject(ctx, strconv.Itoa(authUsr.ID)); err != nil {
+		return server.NewHTTPInternalError("Error revoking token").SetInternal(err)
+	}
+
+	return nil
+}
+
+// User returns the authenticated user stored in the context by the jwt middleware, with the client IP of the request
 func (s *Auth) User(c echo.Context) *model.AuthUser {
-	id, _ := c.Get("id").(float64)
-	user, _ := c.Get("username").(string)
-	email, _ := c.Get("email").(string)
-	role, _ := c.Get("role").(string)
-	return &model.AuthUser{
-		ID:       int(id),
-		Username: user,
-		Email:    email,
-		Role:     role,
+	usr, ok := c.Get(jwt.AuthUserKey).(*model.AuthUser)
+	if !ok || usr == nil {
+		usr = &model.AuthUser{}
+	}
+	usr.IP = c.RealIP()
+	return usr
+}
+
+// NewAuthUser converts the verified jwt claims into the authenticated user, see jwt.Config.AuthUserFunc
+func NewAuthUser(claims *jwt.Claims) interface{} {
+	usr := &model.AuthUser{
+		ID:        claims.UserID,
+		Username:  claims.Username,
+		Email:     claims.Email,
+		Role:      claims.Role,
+		TokenID:   claims.ID,
+		SessionID: claims.SessionID,
+	}
+	if claims.ExpiresAt != nil {
+		usr.TokenExpiresAt = claims.ExpiresAt.Time
+	}
+	if claims.Actor != nil {
+		usr.ActorID = claims.Actor.UserID
+		usr.ActorUsername = claims.Actor.Username
+	}
+	// the role is replaced by the one of the membership when the organization is resolved,
+	// the impersonation tokens pinned to an organization carry the role of the membership only
+	usr.OrgID = claims.OrgID
+	if claims.OrgID == 0 || claims.Actor == nil {
+		usr.GlobalRole = claims.Role
+	}
+	return usr
+}
+
+// NewSubjectCheck returns the check rejecting the tokens of blocked or deleted users, see jwt.Config.SubjectCheck
+func NewSubjectCheck(db *gorm.DB, udb UserDB) jwt.SubjectCheck {
+	return func(ctx context.Context, sub string) (bool, error) {
+		id, err := strconv.Atoi(sub)
+		if err != nil {
+			return false, nil
+		}
+		usr := new(model.User)
+		if err := udb.View(ctx, db, usr, id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		return !usr.Blocked, nil
+	}
+}
+
+// issueToken generates new access token and refresh token for the session, the refresh token is added to the session's family
+func (s *Auth) issueToken(ctx context.Context, db *gorm.DB, u *model.User, sess *model.Session) (*model.AuthToken, error) {
+	claims := &jwt.Claims{
+		UserID:    u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		Role:      u.Role,
+		SessionID: sess.ID,
+	}
+	claims.Subject = strconv.Itoa(u.ID)
+	token, expiresin, err := s.jwt.GenerateToken(claims, nil)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error generating token").SetInternal(err)
+	}
+
+	refreshToken := s.cr.UID()
+	rec := &model.RefreshToken{
+		UserID:    u.ID,
+		Family:    sess.Family,
+		Token:     s.cr.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(RefreshTokenDuration),
+	}
+	if err := s.rtdb.Create(ctx, db, rec); err != nil {
+		return nil, server.NewHTTPInternalError("Error creating refresh token").SetInternal(err)
+	}
+
+	// keep track of the latest access token so that it can be revoked along with the session
+	updates := map[string]interface{}{
+		"last_used":        time.Now(),
+		"token_id":         claims.ID,
+		"token_expires_at": claims.ExpiresAt.Time,
+	}
+	if err := s.sdb.Update(ctx, db, updates, sess.ID); err != nil {
+		return nil, server.NewHTTPInternalError("Error updating session").SetInternal(err)
+	}
+
+	return &model.AuthToken{AccessToken: token, TokenType: "bearer", ExpiresIn: expiresin, RefreshToken: refreshToken}, nil
+}
+
+// session returns the session of the refresh token.
+// Sessions are created for the families started before sessions were recorded.
+func (s *Auth) session(ctx context.Context, db *gorm.DB, rec *model.RefreshToken) (*model.Session, error) {
+	sess, err := s.sdb.FindByFamily(ctx, db, rec.Family)
+	if err == nil {
+		return sess, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	ci := httputil.ClientInfoFromContext(ctx)
+	sess = &model.Session{
+		UserID:    rec.UserID,
+		Family:    rec.Family,
+		UserAgent: truncate(ci.UserAgent, 500),
+		IP:        ci.IP,
+		LastUsed:  time.Now(),
+	}
+	if err := s.sdb.Create(ctx, db, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// revokeReusedToken revokes the whole family of a refresh token that has been presented again after rotation.
+// A rotated token must never come back, so either the client or an attacker is holding a stolen copy.
+func (s *Auth) revokeReusedToken(ctx context.Context, rec *model.RefreshToken) error {
+	logger.LogSecurityEvent(ctx, "refresh_token_reuse", map[string]interface{}{
+		"user_id":          rec.UserID,
+		"refresh_token_id": rec.ID,
+		"family":           rec.Family,
+	})
+	if err := s.rtdb.RevokeFamily(ctx, s.db, rec.Family); err != nil {
+		return server.NewHTTPInternalError("Error revoking refresh token").SetInternal(err)
+	}
+	return ErrInvalidRefreshToken
+}
+
+// Challenge issues a short-lived MFA challenge token for the user who has passed the first factor, see LoginTwoFactor
+func (s *Auth) Challenge(ctx context.Context, u *model.User) (*model.AuthToken, error) {
+	token := s.cr.UID()
+	rec := &model.MFAChallenge{
+		UserID:    u.ID,
+		Token:     s.cr.HashToken(token),
+		ExpiresAt: time.Now().Add(MFAChallengeDuration),
+	}
+	if err := s.mcdb.Create(ctx, s.db, rec); err != nil {
+		return nil, server.NewHTTPInternalError("Error creating MFA challenge").SetInternal(err)
+	}
+
+	return &model.AuthToken{MFARequired: true, MFAToken: token, ExpiresIn: int(MFAChallengeDuration.Seconds())}, nil
+}
+
+// rehashPassword upgrades the hash of the verified password if its algorithm or parameters are out of date.
+// Failures are logged only, the login goes on with the current hash.
+func (s *Auth) rehashPassword(ctx context.Context, u *model.User, password string) {
+	if !s.cr.NeedsRehash(u.Password) {
+		return
+	}
+	hashedPwd, err := s.cr.HashPassword(password)
+	if err == nil {
+		// the hash must not be replaced if the password has been changed in the meantime
+		err = s.udb.Update(ctx, s.db, map[string]interface{}{"password": hashedPwd}, "id = ? AND password = ?", u.ID, u.Password)
+	}
+	if err != nil {
+		logger.LogErrorf(ctx, "error rehashing password: %+v", err.Error())
+		return
+	}
+	u.Password = hashedPwd
+}
+
+// lockKeys returns the lockout keys of the account and the client IP, the latter is empty if the IP is unknown
+func lockKeys(ctx context.Context, username string) (string, string) {
+	ipKey := ""
+	if ip := httputil.ClientInfoFromContext(ctx).IP; ip != "" {
+		ipKey = model.LockKeyIP(ip)
+	}
+	return model.LockKeyAccount(username), ipKey
+}
+
+// checkLockout returns ErrAccountLocked if either the account or the client IP is locked
+func (s *Auth) checkLockout(ctx context.Context, accountKey, ipKey string) error {
+	d, err := s.accountLockout.Check(ctx, accountKey)
+	if err == nil && d == 0 && ipKey != "" {
+		d, err = s.ipLockout.Check(ctx, ipKey)
+	}
+	if err != nil {
+		return server.NewHTTPInternalError("Error checking login attempts").SetInternal(err)
+	}
+	if d > 0 {
+		return ErrAccountLocked(d)
+	}
+	return nil
+}
+
+// failLogin records a failed login of the account & the client IP.
+// The given error is returned unless this failure locks either of them.
+func (s *Auth) failLogin(ctx context.Context, accountKey, ipKey string, loginErr error) error {
+	d, err := s.accountLockout.Fail(ctx, accountKey)
+	if err != nil {
+		return server.NewHTTPInternalError("Error recording login attempt").SetInternal(err)
+	}
+	if ipKey != "" {
+		ipd, err := s.ipLockout.Fail(ctx, ipKey)
+		if err != nil {
+			return server.NewHTTPInternalError("Error recording login attempt").SetInternal(err)
+		}
+		if ipd > d {
+			d = ipd
+		}
+	}
+	if d > 0 {
+		return ErrAccountLocked(d)
+	}
+	return loginErr
+}
+
+// truncate cuts the string to the given number of bytes, without splitting multi-byte characters
+func truncate(str string, n int) string {
+	if len(str) <= n {
+		return str
+	}
+	for n > 0 && !utf8.RuneStart(str[n]) {
+		n--
 	}
+	return str[:n]
 }
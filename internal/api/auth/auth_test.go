@@ -0,0 +1,165 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/api/auth"
+	mfachallengedb "github.com/vuduongtp/go-core/internal/db/mfachallenge"
+	refreshtokendb "github.com/vuduongtp/go-core/internal/db/refreshtoken"
+	sessiondb "github.com/vuduongtp/go-core/internal/db/session"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type fixture struct {
+	db   *gorm.DB
+	auth *auth.Auth
+	jwt  *jwt.Service
+	user *model.User
+}
+
+func newFixture(t *testing.T, tfa auth.TwoFactor) *fixture {
+	db := mock.DB(t, &model.User{}, &model.Session{}, &model.RefreshToken{}, &model.MFAChallenge{})
+	usr := &model.User{Username: "johndoe", Password: "-", Role: model.RoleUser}
+	assert.Nil(t, db.Create(usr).Error)
+
+	lo := lockout.New(lockout.NewMemoryStore(), lockout.DefaultPolicy)
+	j := jwt.New("HS256", "auth-test-secret", 900)
+	return &fixture{
+		db:   db,
+		auth: auth.New(db, userdb.NewDB(), refreshtokendb.NewDB(), sessiondb.NewDB(), mfachallengedb.NewDB(), j, mock.Crypter(), tfa, lo, lo),
+		jwt:  j,
+		user: usr,
+	}
+}
+
+// family returns the refresh tokens of the family of the latest issued token, the first being the latest
+func (f *fixture) family(t *testing.T) []*model.RefreshToken {
+	t.Helper()
+	latest := &model.RefreshToken{}
+	assert.Nil(t, f.db.Order("id DESC").First(latest).Error)
+	var recs []*model.RefreshToken
+	assert.Nil(t, f.db.Where("family = ?", latest.Family).Order("id DESC").Find(&recs).Error)
+	return recs
+}
+
+func TestRefreshToken(t *testing.T) {
+	cases := []struct {
+		name string
+		// prepare returns the refresh token to present, given the one issued by the login
+		prepare func(t *testing.T, f *fixture, token string) string
+		wantErr error
+		// wantRevoked tells whether the whole family is revoked
+		wantRevoked bool
+	}{
+		{
+			name:    "Success",
+			prepare: func(_ *testing.T, _ *fixture, token string) string { return token },
+		},
+		{
+			name:    "Unknown token",
+			prepare: func(_ *testing.T, _ *fixture, _ string) string { return "unknown" },
+			wantErr: auth.ErrInvalidRefreshToken,
+		},
+		{
+			name: "Rotated token reused",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				_, err := f.auth.RefreshToken(context.Background(), auth.RefreshTokenData{RefreshToken: token})
+				assert.Nil(t, err)
+				return token
+			},
+			wantErr:     auth.ErrInvalidRefreshToken,
+			wantRevoked: true,
+		},
+		{
+			name: "Expired token",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				assert.Nil(t, f.db.Model(&model.RefreshToken{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Second)).Error)
+				return token
+			},
+			wantErr: auth.ErrInvalidRefreshToken,
+		},
+		{
+			name: "Revoked token",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				assert.Nil(t, f.db.Model(&model.RefreshToken{}).Where("1 = 1").Update("revoked_at", time.Now()).Error)
+				return token
+			},
+			wantErr:     auth.ErrInvalidRefreshToken,
+			wantRevoked: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, nil)
+			ctx := context.Background()
+			login, err := f.auth.LoginUser(ctx, f.user)
+			assert.Nil(t, err)
+			token := tt.prepare(t, f, login.RefreshToken)
+
+			resp, err := f.auth.RefreshToken(ctx, auth.RefreshTokenData{RefreshToken: token})
+			assert.ErrorIs(t, err, tt.wantErr)
+			family := f.family(t)
+			if tt.wantErr == nil {
+				assert.NotEqual(t, token, resp.RefreshToken, "rotated")
+				assert.True(t, family[1].IsUsed())
+				assert.WithinDuration(t, time.Now().Add(auth.RefreshTokenDuration), family[0].ExpiresAt, time.Minute)
+
+				// the rotated token cannot be used any more, the reuse revokes the token issued by the rotation
+				_, err = f.auth.RefreshToken(ctx, auth.RefreshTokenData{RefreshToken: token})
+				assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+				_, err = f.auth.RefreshToken(ctx, auth.RefreshTokenData{RefreshToken: resp.RefreshToken})
+				assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+				return
+			}
+			for _, rec := range family {
+				assert.Equal(t, tt.wantRevoked, rec.IsRevoked())
+			}
+		})
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	actor := &model.AuthUser{ID: 100, Username: "admin", Role: model.RoleAdmin}
+	cases := []struct {
+		name       string
+		membership *model.Membership
+		wantUser   *model.AuthUser
+	}{
+		{
+			name:     "Global",
+			wantUser: &model.AuthUser{Role: model.RoleAdmin, GlobalRole: model.RoleAdmin},
+		},
+		{
+			name:       "Within an organization",
+			membership: &model.Membership{OrgID: 1, Role: model.RoleUser},
+			// the global role is not granted
+			wantUser: &model.AuthUser{Role: model.RoleUser, OrgID: 1},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, nil)
+			target := &model.User{Base: model.Base{ID: 2}, Username: "janedoe", Role: model.RoleAdmin}
+
+			resp, err := f.auth.Impersonate(context.Background(), actor, target, tt.membership)
+			assert.Nil(t, err)
+			token, err := f.jwt.ParseToken(resp.AccessToken)
+			assert.Nil(t, err)
+			usr := auth.NewAuthUser(token.Claims.(*jwt.Claims)).(*model.AuthUser)
+			assert.Equal(t, target.ID, usr.ID)
+			assert.Equal(t, actor.ID, usr.ActorID)
+			assert.Equal(t, tt.wantUser.Role, usr.Role)
+			assert.Equal(t, tt.wantUser.OrgID, usr.OrgID)
+			assert.Equal(t, tt.wantUser.GlobalRole, usr.Global().Role)
+		})
+	}
+}
@@ -5,27 +5,35 @@ import (
 	"net/http"
 
 	"github.com/vuduongtp/go-core/internal/model"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
 
 	"github.com/labstack/echo/v4"
 )
 
 // HTTP represents auth http service
 type HTTP struct {
-	svc Service
+	svc  Service
+	auth model.Auth
 }
 
 // Service represents auth service interface
 type Service interface {
 	Authenticate(context.Context, Credentials) (*model.AuthToken, error)
+	LoginTwoFactor(context.Context, TwoFactorData) (*model.AuthToken, error)
 	RefreshToken(context.Context, RefreshTokenData) (*model.AuthToken, error)
+	Logout(context.Context, *model.AuthUser, LogoutData) error
+	LogoutAll(context.Context, *model.AuthUser) error
 }
 
-// NewHTTP creates new auth http service
-func NewHTTP(svc Service, e *echo.Echo) {
-	h := HTTP{svc}
+// NewHTTP creates new auth http service, authMW protects the endpoints that require an authenticated user
+func NewHTTP(svc Service, auth model.Auth, e *echo.Echo, authMW echo.MiddlewareFunc) {
+	h := HTTP{svc, auth}
 
 	e.POST("/login", h.login)
+	e.POST("/login/2fa", h.loginTwoFactor)
 	e.POST("/refresh-token", h.refreshToken)
+	e.POST("/logout", h.logout, authMW)
+	e.POST("/logout-all", h.logoutAll, authMW)
 }
 
 // Credentials represents login request data
@@ -34,13 +42,27 @@ type Credentials struct {
 	Password string `json:"password" validate:"required" example:"superadmin123!@#"`
 }
 
+// TwoFactorData represents the second step of the login for users with two-factor authentication enabled
+type TwoFactorData struct {
+	// The MFA challenge token returned by /login
+	MFAToken string `json:"mfa_token" validate:"required"`
+	// TOTP code from the authenticator app, or a recovery code
+	Code string `json:"code" validate:"required" example:"123456"`
+}
+
 // RefreshTokenData represents refresh token request data
 type RefreshTokenData struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// LogoutData represents logout request data
+type LogoutData struct {
+	// The refresh token to be revoked along with the access token, optional
+	RefreshToken string `json:"refresh_token"`
+}
+
 // @Summary		Logs in user by username and password
-// @Description	Logs in user by username and password
+// @Description	Logs in user by username and password. Users with two-factor authentication enabled get `mfa_token` to be exchanged on /login/2fa
 // @Accept			json
 // @Produce		json
 // @Tags			auth
@@ -48,6 +70,7 @@ type RefreshTokenData struct {
 // @Param			request	body		auth.Credentials	true	"Credentials"
 // @Success		200		{object}	model.AuthToken
 // @Failure		401		{object}	SwaggErrDetailsResp
+// @Failure		429		{object}	SwaggErrDetailsResp
 // @Failure		500		{object}	SwaggErrDetailsResp
 // @Router			/login [post]
 func (h *HTTP) login(c echo.Context) error {
@@ -55,7 +78,32 @@ func (h *HTTP) login(c echo.Context) error {
 	if err := c.Bind(&r); err != nil {
 		return err
 	}
-	resp, err := h.svc.Authenticate(c.Request().Context(), r)
+	resp, err := h.svc.Authenticate(httputil.ReqContext(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Summary		Completes the login with the second factor
+// @Description	Exchanges the MFA challenge token and a TOTP code or a recovery code for the access token
+// @Accept			json
+// @Produce		json
+// @Tags			auth
+// @ID				authLoginTwoFactor
+// @Param			request	body		auth.TwoFactorData	true	"TwoFactorData"
+// @Success		200		{object}	model.AuthToken
+// @Failure		401		{object}	SwaggErrDetailsResp
+// @Failure		429		{object}	SwaggErrDetailsResp
+// @Failure		500		{object}	SwaggErrDetailsResp
+// @Router			/login/2fa [post]
+func (h *HTTP) loginTwoFactor(c echo.Context) error {
+	r := TwoFactorData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.LoginTwoFactor(httputil.ReqContext(c), r)
 	if err != nil {
 		return err
 	}
@@ -79,10 +127,54 @@ func (h *HTTP) refreshToken(c echo.Context) error {
 	if err := c.Bind(&r); err != nil {
 		return err
 	}
-	resp, err := h.svc.RefreshToken(c.Request().Context(), r)
+	resp, err := h.svc.RefreshToken(httputil.ReqContext(c), r)
 	if err != nil {
 		return err
 	}
 
 	return c.JSON(http.StatusOK, resp)
 }
+
+// @Security		BearerToken
+// @Summary		Logs out the current session
+// @Description	Revokes the access token in use, and the refresh token if given
+// @Accept			json
+// @Produce		json
+// @Tags			auth
+// @ID				authLogout
+// @Param			request	body		auth.LogoutData	false	"LogoutData"
+// @Success		200		{object}	SwaggOKResp
+// @Failure		401		{object}	SwaggErrDetailsResp
+// @Failure		500		{object}	SwaggErrDetailsResp
+// @Router			/logout [post]
+func (h *HTTP) logout(c echo.Context) error {
+	r := LogoutData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := h.svc.Logout(c.Request().Context(), h.auth.User(c), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Security		BearerToken
+// @Summary		Logs out all sessions
+// @Description	Revokes all access tokens and refresh tokens of the authenticated user
+// @Accept			json
+// @Produce		json
+// @Tags			auth
+// @ID				authLogoutAll
+// @Success		200		{object}	SwaggOKResp
+// @Failure		401		{object}	SwaggErrDetailsResp
+// @Failure		403		{object}	SwaggErrDetailsResp
+// @Failure		500		{object}	SwaggErrDetailsResp
+// @Router			/logout-all [post]
+func (h *HTTP) logoutAll(c echo.Context) error {
+	if err := h.svc.LogoutAll(c.Request().Context(), h.auth.User(c)); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
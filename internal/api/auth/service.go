@@ -5,43 +5,96 @@ import (
 	"time"
 
 	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
 	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
 
 	"gorm.io/gorm"
 )
 
 // New creates new auth service
-func New(db *gorm.DB, udb UserDB, jwt JWT, cr Crypter) *Auth {
+// accountLockout & ipLockout track failed logins per username and per client IP respectively
+func New(db *gorm.DB, udb UserDB, rtdb RefreshTokenDB, sdb SessionDB, mcdb MFAChallengeDB, jwt JWT, cr Crypter, tfa TwoFactor, accountLockout, ipLockout Lockout) *Auth {
 	return &Auth{
-		db:  db,
-		udb: udb,
-		jwt: jwt,
-		cr:  cr,
+		db:             db,
+		udb:            udb,
+		rtdb:           rtdb,
+		sdb:            sdb,
+		mcdb:           mcdb,
+		jwt:            jwt,
+		cr:             cr,
+		tfa:            tfa,
+		accountLockout: accountLockout,
+		ipLockout:      ipLockout,
 	}
 }
 
 // Auth represents auth application service
 type Auth struct {
-	db  *gorm.DB
-	udb UserDB
-	jwt JWT
-	cr  Crypter
+	db   *gorm.DB
+	udb  UserDB
+	rtdb RefreshTokenDB
+	sdb  SessionDB
+	mcdb MFAChallengeDB
+	jwt  JWT
+	cr   Crypter
+	tfa  TwoFactor
+
+	accountLockout Lockout
+	ipLockout      Lockout
 }
 
 // UserDB represents user repository interface
 type UserDB interface {
 	dbutil.Intf
 	FindByUsername(context.Context, *gorm.DB, string) (*model.User, error)
-	FindByRefreshToken(context.Context, *gorm.DB, string) (*model.User, error)
+}
+
+// RefreshTokenDB represents refresh token repository interface
+type RefreshTokenDB interface {
+	dbutil.Intf
+	FindByToken(context.Context, *gorm.DB, string) (*model.RefreshToken, error)
+	MarkUsed(context.Context, *gorm.DB, int) (bool, error)
+	RevokeFamily(context.Context, *gorm.DB, string) error
+	RevokeByUserID(context.Context, *gorm.DB, int) error
+}
+
+// SessionDB represents session repository interface
+type SessionDB interface {
+	dbutil.Intf
+	FindByFamily(context.Context, *gorm.DB, string) (*model.Session, error)
+}
+
+// MFAChallengeDB represents MFA challenge repository interface
+type MFAChallengeDB interface {
+	dbutil.Intf
+	FindByToken(context.Context, *gorm.DB, string) (*model.MFAChallenge, error)
+	MarkUsed(context.Context, *gorm.DB, int) (bool, error)
 }
 
 // JWT represents token generator (jwt) interface
 type JWT interface {
-	GenerateToken(map[string]interface{}, *time.Time) (string, int, error)
+	GenerateToken(*jwt.Claims, *time.Time) (string, int, error)
+	RevokeToken(context.Context, string, time.Time) error
+	RevokeSubject(context.Context, string) error
 }
 
 // Crypter represents security interface
 type Crypter interface {
 	CompareHashAndPassword(string, string) bool
+	NeedsRehash(string) bool
+	HashPassword(string) (string, error)
 	UID() string
+	HashToken(string) string
+}
+
+// TwoFactor represents second factor verification interface
+type TwoFactor interface {
+	Verify(context.Context, *gorm.DB, *model.User, string) (bool, error)
+}
+
+// Lockout represents failed login tracking interface
+type Lockout interface {
+	Check(context.Context, string) (time.Duration, error)
+	Fail(context.Context, string) (time.Duration, error)
+	Reset(context.Context, string) error
 }
@@ -2,10 +2,10 @@ package country
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/vuduongtp/go-core/internal/model"
-	"github.com/vuduongtp/go-core/pkg/rbac"
 	"github.com/vuduongtp/go-core/pkg/server"
 	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
 	structutil "github.com/vuduongtp/go-core/pkg/util/struct"
@@ -15,14 +15,11 @@ import (
 var (
 	ErrCountryNotFound    = server.NewHTTPError(http.StatusBadRequest, "COUNTRY_NOTFOUND", "Country not found")
 	ErrCountryNameExisted = server.NewHTTPValidationError("Country name already exists")
+	ErrInvalidCursor      = server.NewHTTPValidationError("Invalid cursor")
 )
 
 // Create creates a new country
 func (s *Country) Create(ctx context.Context, authUsr *model.AuthUser, data CreationData) (*model.Country, error) {
-	if err := s.enforce(authUsr, model.ActionCreateAll); err != nil {
-		return nil, err
-	}
-
 	if existed, err := s.cdb.Exist(ctx, s.db, map[string]interface{}{"name": data.Name}); err != nil || existed {
 		return nil, ErrCountryNameExisted.SetInternal(err)
 	}
@@ -41,12 +38,8 @@ func (s *Country) Create(ctx context.Context, authUsr *model.AuthUser, data Crea
 
 // View returns single country
 func (s *Country) View(ctx context.Context, authUsr *model.AuthUser, id int) (*model.Country, error) {
-	if err := s.enforce(authUsr, model.ActionViewAll); err != nil {
-		return nil, err
-	}
-
-	rec := new(model.Country)
-	if err := s.cdb.View(ctx, s.db, rec, id); err != nil {
+	rec, err := s.cdb.View(ctx, s.db, id)
+	if err != nil {
 		return nil, ErrCountryNotFound.SetInternal(err)
 	}
 
@@ -55,12 +48,11 @@ func (s *Country) View(ctx context.Context, authUsr *model.AuthUser, id int) (*m
 
 // List returns list of countrys
 func (s *Country) List(ctx context.Context, authUsr *model.AuthUser, lq *dbutil.ListQueryCondition, count *int64) ([]*model.Country, error) {
-	if err := s.enforce(authUsr, model.ActionViewAll); err != nil {
-		return nil, err
+	data, err := s.cdb.List(ctx, s.db, lq, count)
+	if errors.Is(err, dbutil.ErrInvalidCursor) {
+		return nil, ErrInvalidCursor.SetInternal(err)
 	}
-
-	var data []*model.Country
-	if err := s.cdb.List(ctx, s.db, &data, lq, count); err != nil {
+	if err != nil {
 		return nil, server.NewHTTPInternalError("Error listing country").SetInternal(err)
 	}
 
@@ -69,10 +61,6 @@ func (s *Country) List(ctx context.Context, authUsr *model.AuthUser, lq *dbutil.
 
 // Update updates country information
 func (s *Country) Update(ctx context.Context, authUsr *model.AuthUser, id int, data UpdateData) (*model.Country, error) {
-	if err := s.enforce(authUsr, model.ActionUpdateAll); err != nil {
-		return nil, err
-	}
-
 	if existed, err := s.cdb.Exist(ctx, s.db, map[string]interface{}{"name": data.Name, "id__notexact": id}); err != nil || existed {
 		return nil, ErrCountryNameExisted.SetInternal(err)
 	}
@@ -83,8 +71,8 @@ func (s *Country) Update(ctx context.Context, authUsr *model.AuthUser, id int, d
 		return nil, server.NewHTTPInternalError("Error updating country").SetInternal(err)
 	}
 
-	rec := new(model.Country)
-	if err := s.cdb.View(ctx, s.db, rec, id); err != nil {
+	rec, err := s.cdb.View(ctx, s.db, id)
+	if err != nil {
 		return nil, ErrCountryNotFound.SetInternal(err)
 	}
 
@@ -93,10 +81,6 @@ func (s *Country) Update(ctx context.Context, authUsr *model.AuthUser, id int, d
 
 // Delete deletes a country
 func (s *Country) Delete(ctx context.Context, authUsr *model.AuthUser, id int) error {
-	if err := s.enforce(authUsr, model.ActionDeleteAll); err != nil {
-		return err
-	}
-
 	if existed, err := s.cdb.Exist(ctx, s.db, id); err != nil || !existed {
 		return ErrCountryNotFound.SetInternal(err)
 	}
@@ -107,11 +91,3 @@ func (s *Country) Delete(ctx context.Context, authUsr *model.AuthUser, id int) e
 
 	return nil
 }
-
-// enforce checks user permission to perform the action
-func (s *Country) enforce(authUsr *model.AuthUser, action string) error {
-	if !s.rbac.Enforce(authUsr.Role, model.ObjectCountry, action) {
-		return rbac.ErrForbiddenAction
-	}
-	return nil
-}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
 	"github.com/vuduongtp/go-core/pkg/server"
 	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
 	httputil "github.com/vuduongtp/go-core/pkg/util/http"
@@ -29,15 +30,18 @@ type Service interface {
 	Delete(context.Context, *model.AuthUser, int) error
 }
 
+// sortFields holds the fields the countries may be sorted by
+var sortFields = httputil.MustSortFieldsOf(model.Country{})
+
 // NewHTTP creates new country http service
 func NewHTTP(svc Service, auth model.Auth, eg *echo.Group) {
 	h := HTTP{svc, auth}
 
-	eg.POST("", h.create)
-	eg.GET("/:id", h.view)
-	eg.GET("", h.list)
-	eg.PATCH("/:id", h.update)
-	eg.DELETE("/:id", h.delete)
+	eg.POST("", h.create, rbac.Require(model.ObjectCountry, model.ActionCreateAll))
+	eg.GET("/:id", h.view, rbac.Require(model.ObjectCountry, model.ActionViewAll))
+	eg.GET("", h.list, rbac.Require(model.ObjectCountry, model.ActionViewAll))
+	eg.PATCH("/:id", h.update, rbac.Require(model.ObjectCountry, model.ActionUpdateAll))
+	eg.DELETE("/:id", h.delete, rbac.Require(model.ObjectCountry, model.ActionDeleteAll))
 }
 
 // CreationData contains country data from json request
@@ -64,8 +68,13 @@ type UpdateData struct {
 type ListResp struct {
 	// example: [{"id": 1, "created_at": "2020-01-14T10:03:41Z", "updated_at": "2020-01-14T10:03:41Z", "name": "Singapore", "code": "SG", "phone_code": "+65"}]
 	Data []*model.Country `json:"data"`
+	// Total number of countries, not returned in the cursor mode
 	// example: 1
-	TotalCount int64 `json:"total_count"`
+	TotalCount *int64 `json:"total_count,omitempty"`
+	// Cursor of the next page in the cursor mode, empty if there is none
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Cursor of the previous page in the cursor mode, empty if there is none
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 // @Security		BearerToken
@@ -80,6 +89,7 @@ type ListResp struct {
 // @Failure		401				{object}	SwaggErrDetailsResp
 // @Failure		403				{object}	SwaggErrDetailsResp
 // @Failure		500				{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "country", "action": "create_all"}
 // @Router			/v1/countries	[post]
 func (h *HTTP) create(c echo.Context) error {
 	r := CreationData{}
@@ -114,6 +124,7 @@ func (h *HTTP) create(c echo.Context) error {
 // @Failure		401					{object}	SwaggErrDetailsResp
 // @Failure		403					{object}	SwaggErrDetailsResp
 // @Failure		500					{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "country", "action": "view_all"}
 // @Router			/v1/countries/{id}	[get]
 func (h *HTTP) view(c echo.Context) error {
 	id, err := httputil.ReqID(c)
@@ -141,19 +152,27 @@ func (h *HTTP) view(c echo.Context) error {
 // @Failure		401				{object}	SwaggErrDetailsResp
 // @Failure		403				{object}	SwaggErrDetailsResp
 // @Failure		500				{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "country", "action": "view_all"}
 // @Router			/v1/countries	[get]
 func (h *HTTP) list(c echo.Context) error {
-	lq, err := httputil.ReqListQuery(c)
+	lq, err := httputil.ReqListQuery(c, sortFields)
 	if err != nil {
 		return err
 	}
-	var count int64 = 0
-	resp, err := h.svc.List(c.Request().Context(), h.auth.User(c), lq, &count)
+	// the total count is not queried in the cursor mode
+	var count *int64
+	if lq.Cursor == nil {
+		count = new(int64)
+	}
+	resp, err := h.svc.List(c.Request().Context(), h.auth.User(c), lq, count)
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, ListResp{resp, count})
+	if lq.Cursor != nil {
+		return c.JSON(http.StatusOK, ListResp{Data: resp, NextCursor: lq.Cursor.Next.Encode(), PrevCursor: lq.Cursor.Prev.Encode()})
+	}
+	return c.JSON(http.StatusOK, ListResp{Data: resp, TotalCount: count})
 }
 
 // @Security		BearerToken
@@ -171,6 +190,7 @@ func (h *HTTP) list(c echo.Context) error {
 // @Failure		403					{object}	SwaggErrDetailsResp
 // @Failure		404					{object}	SwaggErrDetailsResp
 // @Failure		500					{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "country", "action": "update_all"}
 // @Router			/v1/countries/{id}	[patch]
 func (h *HTTP) update(c echo.Context) error {
 	id, err := httputil.ReqID(c)
@@ -207,6 +227,7 @@ func (h *HTTP) update(c echo.Context) error {
 // @Failure		403					{object}	SwaggErrDetailsResp
 // @Failure		404					{object}	SwaggErrDetailsResp
 // @Failure		500					{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "country", "action": "delete_all"}
 // @Router			/v1/countries/{id}	[delete]
 func (h *HTTP) delete(c echo.Context) error {
 	id, err := httputil.ReqID(c)
@@ -1,30 +1,39 @@
 package country
 
 import (
+	"context"
+
 	"github.com/vuduongtp/go-core/internal/model"
-	"github.com/vuduongtp/go-core/pkg/rbac"
 	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
 
 	"gorm.io/gorm"
 )
 
-// New creates new country application service
-func New(db *gorm.DB, cdb dbutil.Intf, rbacSvc rbac.Intf) *Country {
+// New creates new country application service, the permissions are checked by the routes, see NewHTTP
+func New(db *gorm.DB, cdb MyDB) *Country {
 	return &Country{
-		db:   db,
-		cdb:  cdb,
-		rbac: rbacSvc,
+		db:  db,
+		cdb: cdb,
 	}
 }
 
 // Country represents country application service
 type Country struct {
-	db   *gorm.DB
-	cdb  dbutil.Intf
-	rbac rbac.Intf
+	db  *gorm.DB
+	cdb MyDB
+}
+
+// MyDB represents country repository interface, see dbutil.Repo
+type MyDB interface {
+	Create(context.Context, *gorm.DB, *model.Country) error
+	View(context.Context, *gorm.DB, ...interface{}) (*model.Country, error)
+	List(context.Context, *gorm.DB, *dbutil.ListQueryCondition, *int64) ([]*model.Country, error)
+	Update(context.Context, *gorm.DB, interface{}, ...interface{}) error
+	Delete(context.Context, *gorm.DB, ...interface{}) error
+	Exist(context.Context, *gorm.DB, ...interface{}) (bool, error)
 }
 
 // NewDB returns a new country database instance
-func NewDB() *dbutil.DB {
-	return dbutil.NewDB(model.Country{})
+func NewDB() *dbutil.Repo[model.Country] {
+	return dbutil.NewRepo[model.Country]()
 }
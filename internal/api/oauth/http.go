@@ -0,0 +1,77 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+	oauthutil "github.com/vuduongtp/go-core/pkg/util/oauth"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents social login http service
+type HTTP struct {
+	svc   Service
+	flows Flows
+}
+
+// Service represents social login application interface
+type Service interface {
+	Login(context.Context, *oauthutil.Identity) (*model.AuthToken, error)
+}
+
+// Flows represents the authorization code flows with the providers
+type Flows interface {
+	Start(echo.Context) error
+	Callback(echo.Context) (*oauthutil.Identity, error)
+}
+
+// NewHTTP creates new social login http service
+func NewHTTP(svc Service, flows Flows, e *echo.Echo) {
+	h := HTTP{svc, flows}
+
+	e.GET("/oauth/:provider/start", h.start)
+	e.GET("/oauth/:provider/callback", h.callback)
+}
+
+// @Summary		Starts social login
+// @Description	Redirects to the consent page of the provider. The login state is kept in a short-lived cookie until the callback
+// @Tags			auth
+// @ID				oauthStart
+// @Param			provider					path	string	true	"Provider name. e.g: google, github"
+// @Success		302
+// @Failure		404							{object}	SwaggErrDetailsResp
+// @Router			/oauth/{provider}/start	[get]
+func (h *HTTP) start(c echo.Context) error {
+	return h.flows.Start(c)
+}
+
+// @Summary		Completes social login
+// @Description	Handles the redirect from the provider and logs in the linked user. Unknown identities are linked by verified email, creating the user if needed.
+// @Description	If the user has enabled 2FA, the response contains `mfa_token` to be used with /login/2fa instead of the tokens
+// @Produce		json
+// @Tags			auth
+// @ID				oauthCallback
+// @Param			provider						path		string	true	"Provider name. e.g: google, github"
+// @Param			code							query		string	false	"Authorization code"
+// @Param			state							query		string	true	"Login state"
+// @Success		200								{object}	model.AuthToken
+// @Failure		400								{object}	SwaggErrDetailsResp
+// @Failure		401								{object}	SwaggErrDetailsResp
+// @Failure		500								{object}	SwaggErrDetailsResp
+// @Router			/oauth/{provider}/callback	[get]
+func (h *HTTP) callback(c echo.Context) error {
+	identity, err := h.flows.Callback(c)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.svc.Login(httputil.ReqContext(c), identity)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
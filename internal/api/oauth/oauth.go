@@ -0,0 +1,212 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/api/auth"
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+	oauthutil "github.com/vuduongtp/go-core/pkg/util/oauth"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrEmailNotVerified = server.NewHTTPError(http.StatusUnauthorized, "OAUTH_EMAIL_NOT_VERIFIED", "The email of your account at the provider is missing or not verified")
+)
+
+var invalidUsernameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Login logs in the user of the identity asserted by the provider.
+// Unknown identities are linked to the user having the same email, or to a new user, only if the provider has verified the email.
+// A pending user having the email is taken over, see takeOver.
+// Users with 2FA enabled still have to pass the second factor, see auth.LoginTwoFactor.
+func (s *OAuth) Login(ctx context.Context, identity *oauthutil.Identity) (*model.AuthToken, error) {
+	usr, created, err := s.findOrLinkUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if usr.Blocked {
+		return nil, auth.ErrUserBlocked
+	}
+
+	logger.LogSecurityEvent(ctx, "oauth_login", map[string]interface{}{
+		"user_id":  usr.ID,
+		"provider": identity.Provider,
+		"created":  created,
+	})
+
+	if usr.TOTPEnabled {
+		return s.auth.Challenge(ctx, usr)
+	}
+	return s.auth.LoginUser(ctx, usr)
+}
+
+// findOrLinkUser returns the user linked to the identity, linking one first if needed
+func (s *OAuth) findOrLinkUser(ctx context.Context, identity *oauthutil.Identity) (*model.User, bool, error) {
+	rec, err := s.lidb.FindBySubject(ctx, s.db, identity.Provider, identity.Subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, server.NewHTTPInternalError("Error finding linked identity").SetInternal(err)
+	}
+	if err == nil && rec != nil {
+		usr := new(model.User)
+		if err := s.udb.View(ctx, s.db, usr, rec.UserID); err != nil {
+			return nil, false, server.NewHTTPInternalError("Error finding user").SetInternal(err)
+		}
+		if usr.Pending {
+			err := dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+				return s.takeOver(ctx, tx, usr)
+			})
+			if err != nil {
+				return nil, false, server.NewHTTPInternalError("Error verifying user").SetInternal(err)
+			}
+			logger.LogSecurityEvent(ctx, "oauth_pending_user_taken_over", map[string]interface{}{"user_id": usr.ID})
+		}
+		return usr, false, nil
+	}
+
+	email := strings.TrimSpace(identity.Email)
+	if email == "" || !identity.EmailVerified {
+		return nil, false, ErrEmailNotVerified
+	}
+
+	usr, err := s.udb.FindByEmail(ctx, s.db, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, server.NewHTTPInternalError("Error finding user").SetInternal(err)
+	}
+	created := usr == nil
+	takenOver := !created && usr.Pending
+
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		if takenOver {
+			if err := s.takeOver(ctx, tx, usr); err != nil {
+				return err
+			}
+		}
+		if created {
+			username, err := s.uniqueUsername(ctx, tx, identity)
+			if err != nil {
+				return err
+			}
+			// the user may set a password later using the forgot password flow
+			password, err := s.cr.HashPassword(s.cr.UID())
+			if err != nil {
+				return err
+			}
+			firstName, lastName := splitName(identity.Name)
+			usr = &model.User{
+				FirstName:       firstName,
+				LastName:        lastName,
+				Email:           email,
+				Username:        username,
+				Password:        password,
+				Role:            model.RoleUser,
+				EmailVerifiedAt: timePtr(time.Now()),
+			}
+			if err := s.udb.Create(ctx, tx, usr); err != nil {
+				return err
+			}
+		}
+		return s.lidb.Create(ctx, tx, &model.LinkedIdentity{
+			UserID:   usr.ID,
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    email,
+		})
+	})
+	if err != nil {
+		return nil, false, server.NewHTTPInternalError("Error linking identity").SetInternal(err)
+	}
+	if takenOver {
+		logger.LogSecurityEvent(ctx, "oauth_pending_user_taken_over", map[string]interface{}{"user_id": usr.ID})
+	}
+
+	return usr, created, nil
+}
+
+// takeOver verifies the email of the pending user, as asserted by the provider.
+// Anyone could have registered the email, so the credentials set before the verification are discarded:
+// the password, the two-factor authentication, the API keys and the refresh tokens.
+func (s *OAuth) takeOver(ctx context.Context, tx *gorm.DB, usr *model.User) error {
+	// the user may set a password later using the forgot password flow
+	password, err := s.cr.HashPassword(s.cr.UID())
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"password":          password,
+		"pending":           false,
+		"email_verified_at": time.Now(),
+		"totp_enabled":      false,
+		"totp_secret":       "",
+		"totp_last_counter": 0,
+	}
+	if err := s.udb.Update(ctx, tx, updates, usr.ID); err != nil {
+		return err
+	}
+	if err := s.rcdb.DeletePermanently(ctx, tx, "user_id = ?", usr.ID); err != nil {
+		return err
+	}
+	if err := s.akdb.Delete(ctx, tx, "user_id = ?", usr.ID); err != nil {
+		return err
+	}
+	if err := s.rtdb.RevokeByUserID(ctx, tx, usr.ID); err != nil {
+		return err
+	}
+
+	usr.Password = password
+	usr.Pending = false
+	usr.TOTPEnabled = false
+	usr.TOTPSecret = ""
+	return nil
+}
+
+// uniqueUsername derives an available username from the preferred username or the email of the identity
+func (s *OAuth) uniqueUsername(ctx context.Context, tx *gorm.DB, identity *oauthutil.Identity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	base = strings.Trim(invalidUsernameChars.ReplaceAllString(strings.ToLower(base), ""), "._-")
+	for len(base) < 3 {
+		base += "0"
+	}
+
+	username := base
+	for i := 1; ; i++ {
+		existed, err := s.udb.Exist(ctx, tx, map[string]interface{}{"username": username})
+		if err != nil {
+			return "", err
+		}
+		if !existed {
+			return username, nil
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// splitName splits the full name of the identity into first & last name
+func splitName(name string) (string, string) {
+	fields := strings.Fields(name)
+	switch len(fields) {
+	case 0:
+		return "", ""
+	case 1:
+		return fields[0], ""
+	}
+	return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,223 @@
+package oauth_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/api/auth"
+	"github.com/vuduongtp/go-core/internal/api/oauth"
+	apikeydb "github.com/vuduongtp/go-core/internal/db/apikey"
+	linkedidentitydb "github.com/vuduongtp/go-core/internal/db/linkedidentity"
+	recoverycodedb "github.com/vuduongtp/go-core/internal/db/recoverycode"
+	refreshtokendb "github.com/vuduongtp/go-core/internal/db/refreshtoken"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/util/crypter"
+	oauthutil "github.com/vuduongtp/go-core/pkg/util/oauth"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// authStub issues the token of the user ID, or the challenge if 2FA is enabled
+type authStub struct{}
+
+func (authStub) LoginUser(_ context.Context, u *model.User) (*model.AuthToken, error) {
+	return &model.AuthToken{AccessToken: strconv.Itoa(u.ID)}, nil
+}
+
+func (authStub) Challenge(_ context.Context, u *model.User) (*model.AuthToken, error) {
+	return &model.AuthToken{AccessToken: "challenge"}, nil
+}
+
+type fixture struct {
+	db  *gorm.DB
+	svc *oauth.OAuth
+	cr  *crypter.Service
+}
+
+func newFixture(t *testing.T) *fixture {
+	db := mock.DB(t, &model.User{}, &model.LinkedIdentity{}, &model.APIKey{}, &model.RecoveryCode{}, &model.RefreshToken{})
+	cr := mock.Crypter()
+	svc := oauth.New(db, userdb.NewDB(), linkedidentitydb.NewDB(), apikeydb.NewDB(), recoverycodedb.NewDB(), refreshtokendb.NewDB(), authStub{}, cr)
+	return &fixture{db: db, svc: svc, cr: cr}
+}
+
+// createUser creates the user with the given email & password, which is verified unless pending
+func (f *fixture) createUser(t *testing.T, usr *model.User, password string) *model.User {
+	t.Helper()
+	hashedPwd, err := f.cr.HashPassword(password)
+	assert.Nil(t, err)
+	usr.Password = hashedPwd
+	if !usr.Pending {
+		usr.EmailVerifiedAt = timePtr(time.Now())
+	}
+	assert.Nil(t, f.db.Create(usr).Error)
+	return usr
+}
+
+// count returns the number of records of the model matching the conditions
+func (f *fixture) count(t *testing.T, model interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+	var count int64
+	assert.Nil(t, f.db.Model(model).Where(query, args...).Count(&count).Error)
+	return count
+}
+
+func identity(email string, verified bool) *oauthutil.Identity {
+	return &oauthutil.Identity{Provider: "google", Subject: "g-john", Email: email, EmailVerified: verified, Name: "John Doe"}
+}
+
+func TestLogin(t *testing.T) {
+	cases := []struct {
+		name string
+		// prepare creates the existing user if any
+		prepare  func(t *testing.T, f *fixture) *model.User
+		identity *oauthutil.Identity
+		wantErr  error
+		// wantToken is the access token, the ID of the existing user if empty
+		wantToken string
+		// wantLinked tells whether the identity is linked after the login
+		wantLinked bool
+	}{
+		{
+			name:       "New user",
+			identity:   identity("john@mail.com", true),
+			wantLinked: true,
+		},
+		{
+			name:     "New user with unverified email",
+			identity: identity("john@mail.com", false),
+			wantErr:  oauth.ErrEmailNotVerified,
+		},
+		{
+			name:     "New user without email",
+			identity: identity("", true),
+			wantErr:  oauth.ErrEmailNotVerified,
+		},
+		{
+			name: "Verified user",
+			prepare: func(t *testing.T, f *fixture) *model.User {
+				return f.createUser(t, &model.User{Username: "owner", Email: "john@mail.com"}, "owner-password")
+			},
+			identity:   identity("john@mail.com", true),
+			wantLinked: true,
+		},
+		{
+			name: "Verified user with unverified email",
+			prepare: func(t *testing.T, f *fixture) *model.User {
+				return f.createUser(t, &model.User{Username: "owner", Email: "john@mail.com"}, "owner-password")
+			},
+			identity: identity("john@mail.com", false),
+			wantErr:  oauth.ErrEmailNotVerified,
+		},
+		{
+			name: "Linked user with unverified email",
+			prepare: func(t *testing.T, f *fixture) *model.User {
+				usr := f.createUser(t, &model.User{Username: "owner", Email: "john@mail.com"}, "owner-password")
+				assert.Nil(t, f.db.Create(&model.LinkedIdentity{UserID: usr.ID, Provider: "google", Subject: "g-john", Email: usr.Email}).Error)
+				return usr
+			},
+			// the email was verified when linked
+			identity:   identity("other@mail.com", false),
+			wantLinked: true,
+		},
+		{
+			name: "Blocked user",
+			prepare: func(t *testing.T, f *fixture) *model.User {
+				return f.createUser(t, &model.User{Username: "owner", Email: "john@mail.com", Blocked: true}, "owner-password")
+			},
+			identity:   identity("john@mail.com", true),
+			wantErr:    auth.ErrUserBlocked,
+			wantLinked: true,
+		},
+		{
+			name: "User with 2FA enabled",
+			prepare: func(t *testing.T, f *fixture) *model.User {
+				return f.createUser(t, &model.User{Username: "owner", Email: "john@mail.com", TOTPEnabled: true, TOTPSecret: "SECRET"}, "owner-password")
+			},
+			identity:   identity("john@mail.com", true),
+			wantToken:  "challenge",
+			wantLinked: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			var existing *model.User
+			if tt.prepare != nil {
+				existing = tt.prepare(t, f)
+			}
+
+			token, err := f.svc.Login(context.Background(), tt.identity)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantLinked {
+				assert.Equal(t, int64(1), f.count(t, &model.LinkedIdentity{}, "subject = ?", "g-john"))
+			} else {
+				assert.Zero(t, f.count(t, &model.LinkedIdentity{}, "1 = 1"), "not linked")
+			}
+			if existing == nil {
+				if tt.wantErr != nil {
+					assert.Zero(t, f.count(t, &model.User{}, "1 = 1"), "no user created")
+					return
+				}
+				usr := &model.User{}
+				assert.Nil(t, f.db.Where("email = ?", tt.identity.Email).First(usr).Error)
+				assert.Equal(t, strconv.Itoa(usr.ID), token.AccessToken)
+				assert.Equal(t, "john", usr.Username)
+				assert.NotNil(t, usr.EmailVerifiedAt)
+				return
+			}
+			if tt.wantErr == nil {
+				wantToken := tt.wantToken
+				if wantToken == "" {
+					wantToken = strconv.Itoa(existing.ID)
+				}
+				assert.Equal(t, wantToken, token.AccessToken)
+			}
+
+			// the credentials of the verified user are kept
+			usr := &model.User{}
+			assert.Nil(t, f.db.First(usr, existing.ID).Error)
+			assert.True(t, f.cr.CompareHashAndPassword(usr.Password, "owner-password"))
+			assert.Equal(t, existing.TOTPEnabled, usr.TOTPEnabled)
+		})
+	}
+}
+
+func TestLoginTakeOverPendingUser(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	// registered by someone else with the email of the victim, who only logs in with the provider
+	pending := f.createUser(t, &model.User{Username: "attacker", Email: "victim@mail.com", Pending: true, TOTPEnabled: true, TOTPSecret: "SECRET"}, "attacker-password")
+	assert.Nil(t, f.db.Create(&model.APIKey{UserID: pending.ID, Name: "key", Prefix: "gc_x", KeyHash: "hash"}).Error)
+	assert.Nil(t, f.db.Create(&model.RecoveryCode{UserID: pending.ID, Code: "code"}).Error)
+
+	// not taken over without the verified email
+	_, err := f.svc.Login(ctx, identity("victim@mail.com", false))
+	assert.ErrorIs(t, err, oauth.ErrEmailNotVerified)
+	usr := &model.User{}
+	assert.Nil(t, f.db.First(usr, pending.ID).Error)
+	assert.True(t, usr.Pending)
+
+	token, err := f.svc.Login(ctx, identity("victim@mail.com", true))
+	assert.Nil(t, err)
+	assert.Equal(t, strconv.Itoa(pending.ID), token.AccessToken, "no 2FA challenge of the attacker")
+
+	assert.Nil(t, f.db.First(usr, pending.ID).Error)
+	assert.False(t, usr.Pending)
+	assert.NotNil(t, usr.EmailVerifiedAt)
+	assert.False(t, f.cr.CompareHashAndPassword(usr.Password, "attacker-password"), "password discarded")
+	assert.False(t, usr.TOTPEnabled)
+	assert.Empty(t, usr.TOTPSecret)
+	assert.Zero(t, f.count(t, &model.APIKey{}, "user_id = ?", pending.ID), "API keys discarded")
+	assert.Zero(t, f.count(t, &model.RecoveryCode{}, "user_id = ?", pending.ID), "recovery codes discarded")
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
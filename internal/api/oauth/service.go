@@ -0,0 +1,76 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// New creates new social login application service
+// akdb, rcdb & rtdb are used to discard the credentials of the pending users taken over
+func New(db *gorm.DB, udb UserDB, lidb IdentityDB, akdb APIKeyDB, rcdb RecoveryCodeDB, rtdb RefreshTokenDB, auth Auth, cr Crypter) *OAuth {
+	return &OAuth{
+		db:   db,
+		udb:  udb,
+		lidb: lidb,
+		akdb: akdb,
+		rcdb: rcdb,
+		rtdb: rtdb,
+		auth: auth,
+		cr:   cr,
+	}
+}
+
+// OAuth represents social login application service
+type OAuth struct {
+	db   *gorm.DB
+	udb  UserDB
+	lidb IdentityDB
+	akdb APIKeyDB
+	rcdb RecoveryCodeDB
+	rtdb RefreshTokenDB
+	auth Auth
+	cr   Crypter
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	dbutil.Intf
+	FindByEmail(context.Context, *gorm.DB, string) (*model.User, error)
+}
+
+// IdentityDB represents linked identity repository interface
+type IdentityDB interface {
+	dbutil.Intf
+	FindBySubject(context.Context, *gorm.DB, string, string) (*model.LinkedIdentity, error)
+}
+
+// APIKeyDB represents API key repository interface
+type APIKeyDB interface {
+	dbutil.Intf
+}
+
+// RecoveryCodeDB represents recovery code repository interface
+type RecoveryCodeDB interface {
+	dbutil.Intf
+}
+
+// RefreshTokenDB represents refresh token repository interface
+type RefreshTokenDB interface {
+	RevokeByUserID(context.Context, *gorm.DB, int) error
+}
+
+// Auth represents authentication interface, the tokens are issued the same way as the password login
+type Auth interface {
+	LoginUser(context.Context, *model.User) (*model.AuthToken, error)
+	Challenge(context.Context, *model.User) (*model.AuthToken, error)
+}
+
+// Crypter represents security interface
+type Crypter interface {
+	HashPassword(string) (string, error)
+	UID() string
+}
@@ -0,0 +1,228 @@
+package organization
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/server"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents organization http service
+type HTTP struct {
+	svc  Service
+	auth model.Auth
+}
+
+// Service represents organization application interface
+type Service interface {
+	Create(context.Context, *model.AuthUser, CreationData) (*model.Organization, error)
+	List(context.Context, *model.AuthUser) ([]*model.Organization, error)
+	Token(context.Context, *model.AuthUser, int) (*model.AuthToken, error)
+	ListMembers(context.Context, *model.AuthUser, int) ([]*model.Membership, error)
+	AddMember(context.Context, *model.AuthUser, int, MemberData) (*model.Membership, error)
+	RemoveMember(context.Context, *model.AuthUser, int, int) error
+}
+
+// NewHTTP creates new organization http service
+func NewHTTP(svc Service, auth model.Auth, eg *echo.Group) {
+	h := HTTP{svc, auth}
+
+	eg.POST("", h.create)
+	eg.GET("", h.list)
+	eg.POST("/:id/token", h.token)
+	eg.GET("/:id/members", h.listMembers)
+	eg.POST("/:id/members", h.addMember)
+	eg.DELETE("/:id/members/:uid", h.removeMember)
+}
+
+// CreationData contains organization data from json request
+type CreationData struct {
+	Name string `json:"name" validate:"required,max=255" example:"Acme"`
+	Slug string `json:"slug" validate:"required,max=100,alphanum" example:"acme"`
+}
+
+// MemberData contains membership data from json request
+type MemberData struct {
+	UserID int    `json:"user_id" validate:"required"`
+	Role   string `json:"role" validate:"required,max=100" example:"user"`
+}
+
+// ListResp contains list of organizations
+type ListResp struct {
+	Data []*model.Organization `json:"data"`
+}
+
+// MembersResp contains list of members
+type MembersResp struct {
+	Data []*model.Membership `json:"data"`
+}
+
+// @Security		BearerToken
+// @Summary		Creates new organization
+// @Description	Creates a new organization, the current user becomes its admin
+// @Accept			json
+// @Produce		json
+// @Tags			organizations
+// @ID				organizationsCreate
+// @Param			request				body		organization.CreationData	true	"CreationData"
+// @Success		200					{object}	model.Organization
+// @Failure		400					{object}	SwaggErrDetailsResp
+// @Failure		401					{object}	SwaggErrDetailsResp
+// @Failure		403					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @Router			/v1/organizations	[post]
+func (h *HTTP) create(c echo.Context) error {
+	r := CreationData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Create(c.Request().Context(), h.auth.User(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Returns the organizations
+// @Description	Returns the organizations of the current user, users with permission to view all organizations get all of them
+// @Accept			json
+// @Produce		json
+// @Tags			organizations
+// @ID				organizationsList
+// @Success		200					{object}	organization.ListResp
+// @Failure		401					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @Router			/v1/organizations	[get]
+func (h *HTTP) list(c echo.Context) error {
+	resp, err := h.svc.List(c.Request().Context(), h.auth.User(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ListResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Issues an organization token
+// @Description	Issues an access token pinned to the organization by the `org_id` claim, instead of sending the X-Org-ID header.
+// @Description	The token expires with the current one and has no refresh token
+// @Accept			json
+// @Produce		json
+// @Tags			organizations
+// @ID				organizationsToken
+// @Param			id							path		int	true	"Organization ID"
+// @Success		200							{object}	model.AuthToken
+// @Failure		400							{object}	SwaggErrDetailsResp
+// @Failure		401							{object}	SwaggErrDetailsResp
+// @Failure		403							{object}	SwaggErrDetailsResp
+// @Failure		500							{object}	SwaggErrDetailsResp
+// @Router			/v1/organizations/{id}/token	[post]
+func (h *HTTP) token(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.Token(c.Request().Context(), h.auth.User(c), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Returns the members of an organization
+// @Description	Returns the members of the organization, which must be selected by the X-Org-ID header or the access token
+// @Accept			json
+// @Produce		json
+// @Tags			organizations
+// @ID				organizationsListMembers
+// @Param			id								path		int	true	"Organization ID"
+// @Success		200								{object}	organization.MembersResp
+// @Failure		400								{object}	SwaggErrDetailsResp
+// @Failure		401								{object}	SwaggErrDetailsResp
+// @Failure		403								{object}	SwaggErrDetailsResp
+// @Failure		500								{object}	SwaggErrDetailsResp
+// @Router			/v1/organizations/{id}/members	[get]
+func (h *HTTP) listMembers(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.ListMembers(c.Request().Context(), h.auth.User(c), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, MembersResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Adds a member to an organization
+// @Description	Adds an existing user to the organization with the given role, the organization must be the selected one. The permission to add members must also be granted outside of the organization
+// @Accept			json
+// @Produce		json
+// @Tags			organizations
+// @ID				organizationsAddMember
+// @Param			id								path		int						true	"Organization ID"
+// @Param			request							body		organization.MemberData	true	"MemberData"
+// @Success		200								{object}	model.Membership
+// @Failure		400								{object}	SwaggErrDetailsResp
+// @Failure		401								{object}	SwaggErrDetailsResp
+// @Failure		403								{object}	SwaggErrDetailsResp
+// @Failure		500								{object}	SwaggErrDetailsResp
+// @Router			/v1/organizations/{id}/members	[post]
+func (h *HTTP) addMember(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	r := MemberData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.AddMember(c.Request().Context(), h.auth.User(c), id, r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Removes a member from an organization
+// @Description	Removes the user from the organization, the user account is kept. The organization must be the selected one
+// @Accept			json
+// @Produce		json
+// @Tags			organizations
+// @ID				organizationsRemoveMember
+// @Param			id										path		int	true	"Organization ID"
+// @Param			uid										path		int	true	"User ID"
+// @Success		200										{object}	SwaggOKResp
+// @Failure		400										{object}	SwaggErrDetailsResp
+// @Failure		401										{object}	SwaggErrDetailsResp
+// @Failure		403										{object}	SwaggErrDetailsResp
+// @Failure		500										{object}	SwaggErrDetailsResp
+// @Router			/v1/organizations/{id}/members/{uid}	[delete]
+func (h *HTTP) removeMember(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(c.Param("uid"))
+	if err != nil {
+		return server.NewHTTPValidationError("Invalid user ID")
+	}
+	if err := h.svc.RemoveMember(c.Request().Context(), h.auth.User(c), id, uid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,195 @@
+package organization
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrSlugExisted    = server.NewHTTPValidationError("Slug already existed")
+	ErrNotMember      = server.NewHTTPError(http.StatusForbidden, "NOT_MEMBER", "You are not a member of this organization")
+	ErrTenantRequired = server.NewHTTPError(http.StatusBadRequest, "ORGANIZATION_REQUIRED", "The organization must be selected by the X-Org-ID header or the access token")
+	ErrUserNotFound   = server.NewHTTPError(http.StatusBadRequest, "USER_NOTFOUND", "User not found")
+	ErrMemberExisted  = server.NewHTTPValidationError("User is already a member")
+	ErrMemberNotFound = server.NewHTTPError(http.StatusBadRequest, "MEMBER_NOTFOUND", "Member not found")
+	ErrInvalidRole    = server.NewHTTPValidationError("Invalid role")
+)
+
+// Create creates a new organization, the authenticated user becomes its admin
+func (s *Organization) Create(ctx context.Context, authUsr *model.AuthUser, data CreationData) (*model.Organization, error) {
+	if err := s.enforce(authUsr, model.ObjectOrganization, model.ActionCreateAll); err != nil {
+		return nil, err
+	}
+
+	if existed, err := s.odb.Exist(ctx, s.db, map[string]interface{}{"slug": data.Slug}); err != nil || existed {
+		return nil, ErrSlugExisted.SetInternal(err)
+	}
+
+	rec := &model.Organization{Name: data.Name, Slug: data.Slug}
+	err := dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := s.odb.Create(ctx, tx, rec); err != nil {
+			return err
+		}
+		return s.mdb.Create(dbutil.WithTenant(ctx, rec.ID), tx, &model.Membership{UserID: authUsr.ID, Role: model.RoleAdmin})
+	})
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error creating organization").SetInternal(err)
+	}
+
+	logger.LogSecurityEvent(ctx, "organization_created", map[string]interface{}{
+		"org_id":   rec.ID,
+		"actor_id": authUsr.ID,
+	})
+
+	return rec, nil
+}
+
+// List returns the organizations of the authenticated user, or all organizations with the permission to view them all
+func (s *Organization) List(ctx context.Context, authUsr *model.AuthUser) ([]*model.Organization, error) {
+	if !authUsr.Enforce(s.rbac, model.ObjectOrganization, model.ActionViewAll) {
+		data, err := s.odb.ListByUserID(ctx, s.db, authUsr.ID)
+		if err != nil {
+			return nil, server.NewHTTPInternalError("Error listing organizations").SetInternal(err)
+		}
+		return data, nil
+	}
+
+	var data []*model.Organization
+	if err := s.odb.List(ctx, s.db, &data, nil, nil); err != nil {
+		return nil, server.NewHTTPInternalError("Error listing organizations").SetInternal(err)
+	}
+	return data, nil
+}
+
+// Token issues an access token pinned to the organization, so that the X-Org-ID header is not needed
+func (s *Organization) Token(ctx context.Context, authUsr *model.AuthUser, id int) (*model.AuthToken, error) {
+	if authUsr.APIKeyID != 0 {
+		return nil, rbac.ErrForbiddenAction
+	}
+	if _, err := s.mdb.FindByUser(ctx, s.db, id, authUsr.ID); err != nil {
+		return nil, ErrNotMember.SetInternal(err)
+	}
+	return s.auth.OrganizationToken(ctx, authUsr, id)
+}
+
+// Resolve applies the organization to the authenticated user, whose role becomes the one of its membership
+// unless being superadmin.
+// It is called by the tenant middleware, see tenant.ResolveFunc
+func (s *Organization) Resolve(ctx context.Context, authUsr *model.AuthUser, id int) error {
+	m, err := s.mdb.FindByUser(ctx, s.db, id, authUsr.ID)
+	if err != nil {
+		return ErrNotMember.SetInternal(err)
+	}
+	authUsr.OrgID = id
+	// superadmin is a global role, it is kept within the organizations
+	if authUsr.Role != model.RoleSuperAdmin {
+		authUsr.Role = m.Role
+	}
+	return nil
+}
+
+// ListMembers returns the members of the organization, which must be the active one
+func (s *Organization) ListMembers(ctx context.Context, authUsr *model.AuthUser, id int) ([]*model.Membership, error) {
+	if err := s.active(authUsr, id); err != nil {
+		return nil, err
+	}
+	if err := s.enforce(authUsr, model.ObjectMembership, model.ActionViewAll); err != nil {
+		return nil, err
+	}
+
+	var data []*model.Membership
+	if err := s.mdb.List(ctx, s.db, &data, nil, nil); err != nil {
+		return nil, server.NewHTTPInternalError("Error listing members").SetInternal(err)
+	}
+	return data, nil
+}
+
+// AddMember adds an existing user to the organization with the given role.
+// The user does not consent to join, so the permission must also be granted outside of the organization, see model.AuthUser.Global
+func (s *Organization) AddMember(ctx context.Context, authUsr *model.AuthUser, id int, data MemberData) (*model.Membership, error) {
+	if err := s.active(authUsr, id); err != nil {
+		return nil, err
+	}
+	if err := s.enforce(authUsr, model.ObjectMembership, model.ActionCreateAll); err != nil {
+		return nil, err
+	}
+	if err := s.enforce(authUsr.Global(), model.ObjectMembership, model.ActionCreateAll); err != nil {
+		return nil, err
+	}
+	// superadmin is a global role, it cannot be granted within an organization
+	if data.Role == model.RoleSuperAdmin || !s.rbac.RoleExists(data.Role) {
+		return nil, ErrInvalidRole
+	}
+	// the user is not a member of the organization yet
+	if existed, err := s.udb.Exist(dbutil.WithoutTenant(ctx), s.db, data.UserID); err != nil || !existed {
+		return nil, ErrUserNotFound.SetInternal(err)
+	}
+	if existed, err := s.mdb.Exist(ctx, s.db, map[string]interface{}{"user_id": data.UserID}); err != nil || existed {
+		return nil, ErrMemberExisted.SetInternal(err)
+	}
+
+	rec := &model.Membership{UserID: data.UserID, Role: data.Role}
+	if err := s.mdb.Create(ctx, s.db, rec); err != nil {
+		return nil, server.NewHTTPInternalError("Error adding member").SetInternal(err)
+	}
+
+	logger.LogSecurityEvent(ctx, "organization_member_added", map[string]interface{}{
+		"org_id":   id,
+		"user_id":  data.UserID,
+		"role":     data.Role,
+		"actor_id": authUsr.ID,
+	})
+
+	return rec, nil
+}
+
+// RemoveMember removes the user from the organization, the user account is kept
+func (s *Organization) RemoveMember(ctx context.Context, authUsr *model.AuthUser, id, uid int) error {
+	if err := s.active(authUsr, id); err != nil {
+		return err
+	}
+	if err := s.enforce(authUsr, model.ObjectMembership, model.ActionDeleteAll); err != nil {
+		return err
+	}
+	if existed, err := s.mdb.Exist(ctx, s.db, map[string]interface{}{"user_id": uid}); err != nil || !existed {
+		return ErrMemberNotFound.SetInternal(err)
+	}
+
+	// memberships are deleted permanently so that the user may be added again
+	if err := s.mdb.DeletePermanently(ctx, s.db, map[string]interface{}{"user_id": uid}); err != nil {
+		return server.NewHTTPInternalError("Error removing member").SetInternal(err)
+	}
+
+	logger.LogSecurityEvent(ctx, "organization_member_removed", map[string]interface{}{
+		"org_id":   id,
+		"user_id":  uid,
+		"actor_id": authUsr.ID,
+	})
+
+	return nil
+}
+
+// active checks that the organization is the active one, the members are managed within their organization
+func (s *Organization) active(authUsr *model.AuthUser, id int) error {
+	if authUsr.OrgID != id {
+		return ErrTenantRequired
+	}
+	return nil
+}
+
+// enforce checks user permission to perform the action on the object
+func (s *Organization) enforce(authUsr *model.AuthUser, object, action string) error {
+	if !authUsr.Enforce(s.rbac, object, action) {
+		return rbac.ErrForbiddenAction
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package organization_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vuduongtp/go-core/internal/api/organization"
+	membershipdb "github.com/vuduongtp/go-core/internal/db/membership"
+	organizationdb "github.com/vuduongtp/go-core/internal/db/organization"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// orgID is the organization of the fixture
+const orgID = 1
+
+// permissions of the builtin roles used by the tests
+var permissions = mock.Enforcer{
+	model.RoleAdmin + " " + model.ObjectMembership + " " + model.ActionCreateAll: true,
+	model.RoleUser + " " + model.ObjectUser + " " + model.ActionView:             true,
+}
+
+type fixture struct {
+	db   *gorm.DB
+	svc  *organization.Organization
+	user *model.User
+}
+
+func newFixture(t *testing.T) *fixture {
+	db := mock.DB(t, &model.User{}, &model.Organization{}, &model.Membership{})
+	usr := &model.User{Username: "johndoe", Email: "john@mail.com", Password: "-", Role: model.RoleUser}
+	assert.Nil(t, db.Create(usr).Error)
+	assert.Nil(t, db.Create(&model.Organization{Base: model.Base{ID: orgID}, Name: "Acme", Slug: "acme"}).Error)
+
+	svc := organization.New(db, organizationdb.NewDB(), membershipdb.NewDB(), userdb.NewDB(), permissions, nil)
+	return &fixture{db: db, svc: svc, user: usr}
+}
+
+// members returns the number of memberships of the user in the organization of the fixture
+func (f *fixture) members(t *testing.T, uid int) int64 {
+	t.Helper()
+	var count int64
+	assert.Nil(t, f.db.Model(&model.Membership{}).Where("org_id = ? AND user_id = ?", orgID, uid).Count(&count).Error)
+	return count
+}
+
+func TestAddMember(t *testing.T) {
+	admin := &model.AuthUser{ID: 100, Username: "admin", Role: model.RoleAdmin, GlobalRole: model.RoleAdmin, OrgID: orgID}
+	cases := []struct {
+		name    string
+		authUsr *model.AuthUser
+		// member returns the data of the member to add
+		member func(f *fixture) organization.MemberData
+		// prepare adds the existing memberships if any
+		prepare func(t *testing.T, f *fixture)
+		wantErr error
+	}{
+		{
+			name:    "Success",
+			authUsr: admin,
+			member: func(f *fixture) organization.MemberData {
+				return organization.MemberData{UserID: f.user.ID, Role: model.RoleUser}
+			},
+		},
+		{
+			name: "By organization admin",
+			// the organization admins are users outside of it, they cannot add the users without their consent
+			authUsr: &model.AuthUser{ID: 101, Username: "orgadmin", Role: model.RoleAdmin, GlobalRole: model.RoleUser, OrgID: orgID},
+			member: func(f *fixture) organization.MemberData {
+				return organization.MemberData{UserID: f.user.ID, Role: model.RoleUser}
+			},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name:    "Organization not selected",
+			authUsr: &model.AuthUser{ID: 100, Username: "admin", Role: model.RoleAdmin, GlobalRole: model.RoleAdmin},
+			member: func(f *fixture) organization.MemberData {
+				return organization.MemberData{UserID: f.user.ID, Role: model.RoleUser}
+			},
+			wantErr: organization.ErrTenantRequired,
+		},
+		{
+			name:    "Superadmin role",
+			authUsr: admin,
+			member: func(f *fixture) organization.MemberData {
+				return organization.MemberData{UserID: f.user.ID, Role: model.RoleSuperAdmin}
+			},
+			wantErr: organization.ErrInvalidRole,
+		},
+		{
+			name:    "Unknown user",
+			authUsr: admin,
+			member: func(_ *fixture) organization.MemberData {
+				return organization.MemberData{UserID: 1000, Role: model.RoleUser}
+			},
+			wantErr: organization.ErrUserNotFound,
+		},
+		{
+			name:    "Already a member",
+			authUsr: admin,
+			member: func(f *fixture) organization.MemberData {
+				return organization.MemberData{UserID: f.user.ID, Role: model.RoleUser}
+			},
+			prepare: func(t *testing.T, f *fixture) {
+				assert.Nil(t, f.db.Create(&model.Membership{OrgID: orgID, UserID: f.user.ID, Role: model.RoleUser}).Error)
+			},
+			wantErr: organization.ErrMemberExisted,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if tt.prepare != nil {
+				tt.prepare(t, f)
+			}
+			before := f.members(t, f.user.ID)
+
+			rec, err := f.svc.AddMember(dbutil.WithTenant(context.Background(), orgID), tt.authUsr, orgID, tt.member(f))
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				assert.Equal(t, before, f.members(t, f.user.ID))
+				return
+			}
+			assert.Equal(t, orgID, rec.OrgID)
+			assert.Equal(t, model.RoleUser, rec.Role)
+			assert.Equal(t, int64(1), f.members(t, f.user.ID))
+		})
+	}
+}
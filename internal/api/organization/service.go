@@ -0,0 +1,54 @@
+package organization
+
+import (
+	"context"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// New creates new organization application service
+func New(db *gorm.DB, odb OrganizationDB, mdb MembershipDB, udb UserDB, rbacSvc RBAC, auth Auth) *Organization {
+	return &Organization{db: db, odb: odb, mdb: mdb, udb: udb, rbac: rbacSvc, auth: auth}
+}
+
+// Organization represents organization application service
+type Organization struct {
+	db   *gorm.DB
+	odb  OrganizationDB
+	mdb  MembershipDB
+	udb  UserDB
+	rbac RBAC
+	auth Auth
+}
+
+// OrganizationDB represents organization repository interface
+type OrganizationDB interface {
+	dbutil.Intf
+	ListByUserID(context.Context, *gorm.DB, int) ([]*model.Organization, error)
+}
+
+// MembershipDB represents membership repository interface, the queries are restricted to the active organization
+type MembershipDB interface {
+	dbutil.Intf
+	FindByUser(context.Context, *gorm.DB, int, int) (*model.Membership, error)
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	dbutil.Intf
+}
+
+// RBAC represents the access control interface
+type RBAC interface {
+	rbac.Intf
+	RoleExists(string) bool
+}
+
+// Auth represents the token issuing interface
+type Auth interface {
+	OrganizationToken(context.Context, *model.AuthUser, int) (*model.AuthToken, error)
+}
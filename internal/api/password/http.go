@@ -0,0 +1,86 @@
+package password
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents password reset http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents password reset application interface
+type Service interface {
+	Forgot(context.Context, ForgotData) error
+	Reset(context.Context, ResetData) error
+}
+
+// NewHTTP creates new password reset http service
+func NewHTTP(svc Service, e *echo.Echo) {
+	h := HTTP{svc}
+
+	e.POST("/password/forgot", h.forgot)
+	e.POST("/password/reset", h.reset)
+}
+
+// ForgotData contains forgot password request
+type ForgotData struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetData contains password reset request
+type ResetData struct {
+	// The token from the password reset email
+	Token              string `json:"token" validate:"required"`
+	NewPassword        string `json:"new_password" validate:"required,password"`
+	NewPasswordConfirm string `json:"new_password_confirm" validate:"required,eqfield=NewPassword"`
+}
+
+// @Summary		Requests a password reset
+// @Description	Emails a password reset link to the user of the given email. The response is the same whether the email is registered or not
+// @Accept			json
+// @Produce		json
+// @Tags			password
+// @ID				passwordForgot
+// @Param			request				body		password.ForgotData	true	"ForgotData"
+// @Success		200					{object}	SwaggOKResp
+// @Failure		400					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @Router			/password/forgot	[post]
+func (h *HTTP) forgot(c echo.Context) error {
+	r := ForgotData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := h.svc.Forgot(c.Request().Context(), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Summary		Resets password
+// @Description	Sets the new password using the token from the password reset email, all sessions are logged out
+// @Accept			json
+// @Produce		json
+// @Tags			password
+// @ID				passwordReset
+// @Param			request				body		password.ResetData	true	"ResetData"
+// @Success		200					{object}	SwaggOKResp
+// @Failure		400					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @Router			/password/reset	[post]
+func (h *HTTP) reset(c echo.Context) error {
+	r := ResetData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := h.svc.Reset(c.Request().Context(), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,122 @@
+package password
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrInvalidResetToken = server.NewHTTPError(http.StatusBadRequest, "INVALID_RESET_TOKEN", "Invalid or expired password reset token")
+)
+
+// ResetTokenDuration is the validity period of password reset tokens
+const ResetTokenDuration = time.Hour
+
+// Forgot emails a password reset link to the user of the given email.
+// No error is returned for unknown emails, so that the endpoint cannot be used to discover registered emails.
+// The link is created & sent in background, so that the response time does not tell either.
+func (s *Password) Forgot(ctx context.Context, data ForgotData) error {
+	usr, err := s.udb.FindByEmail(ctx, s.db, strings.TrimSpace(data.Email))
+	if err != nil || usr == nil || usr.Blocked {
+		return nil
+	}
+
+	s.bg.Go(ctx, func(ctx context.Context) error {
+		return s.sendReset(ctx, usr)
+	})
+
+	return nil
+}
+
+// sendReset creates a new password reset token of the user and emails the link
+func (s *Password) sendReset(ctx context.Context, usr *model.User) error {
+	token := s.cr.UID()
+	err := dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		// only the latest link is usable
+		if err := s.prdb.InvalidateByUserID(ctx, tx, usr.ID); err != nil {
+			return err
+		}
+		return s.prdb.Create(ctx, tx, &model.PasswordReset{
+			UserID:    usr.ID,
+			Token:     s.cr.HashToken(token),
+			ExpiresAt: time.Now().Add(ResetTokenDuration),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("error creating password reset token: %w", err)
+	}
+
+	if err := s.mailer.SendPasswordReset(ctx, usr, token, ResetTokenDuration); err != nil {
+		return fmt.Errorf("error sending password reset email: %w", err)
+	}
+
+	return nil
+}
+
+// Reset sets the new password of the user owning the reset token.
+// All reset tokens & refresh tokens of the user are revoked, so that other sessions have to login again.
+func (s *Password) Reset(ctx context.Context, data ResetData) error {
+	rec, err := s.prdb.FindByToken(ctx, s.db, s.cr.HashToken(data.Token))
+	if err != nil || rec == nil || !rec.IsValid(time.Now()) {
+		return ErrInvalidResetToken.SetInternal(err)
+	}
+
+	usr := new(model.User)
+	if err := s.udb.View(ctx, s.db, usr, rec.UserID); err != nil {
+		return ErrInvalidResetToken.SetInternal(err)
+	}
+
+	// checked before using the token, so that another password can be chosen with the same link
+	if err := s.pwd.Check(ctx, s.db, usr, data.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPwd, err := s.cr.HashPassword(data.NewPassword)
+	if err != nil {
+		return server.NewHTTPInternalError("Error hashing password").SetInternal(err)
+	}
+
+	invalid := false
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		ok, err := s.prdb.MarkUsed(ctx, tx, rec.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// the same token has been used concurrently
+			invalid = true
+			return nil
+		}
+		// the other links sent to the user cannot be used any more
+		if err := s.prdb.InvalidateByUserID(ctx, tx, rec.UserID); err != nil {
+			return err
+		}
+		if err := s.udb.Update(ctx, tx, map[string]interface{}{"password": hashedPwd}, rec.UserID); err != nil {
+			return err
+		}
+		if err := s.pwd.Record(ctx, tx, usr); err != nil {
+			return err
+		}
+		return s.rtdb.RevokeByUserID(ctx, tx, rec.UserID)
+	})
+	if err != nil {
+		return server.NewHTTPInternalError("Error resetting password").SetInternal(err)
+	}
+	if invalid {
+		return ErrInvalidResetToken
+	}
+
+	logger.LogSecurityEvent(ctx, "password_reset", map[string]interface{}{"user_id": rec.UserID})
+	return nil
+}
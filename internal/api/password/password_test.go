@@ -0,0 +1,206 @@
+package password_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/api/password"
+	passwordhistorydb "github.com/vuduongtp/go-core/internal/db/passwordhistory"
+	passwordresetdb "github.com/vuduongtp/go-core/internal/db/passwordreset"
+	refreshtokendb "github.com/vuduongtp/go-core/internal/db/refreshtoken"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/mail"
+	"github.com/vuduongtp/go-core/internal/model"
+	passwordutil "github.com/vuduongtp/go-core/internal/util/password"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/util/crypter"
+	"github.com/vuduongtp/go-core/pkg/util/email"
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+const (
+	oldPassword = "Old-Secret-1"
+	newPassword = "New-Secret-2"
+)
+
+var resetURL = regexp.MustCompile(`https://app\.example\.com/reset-password\?\S+`)
+
+type fixture struct {
+	db     *gorm.DB
+	svc    *password.Password
+	sender *email.FakeSender
+	cr     *crypter.Service
+	user   *model.User
+}
+
+func newFixture(t *testing.T) *fixture {
+	db := mock.DB(t, &model.User{}, &model.PasswordReset{}, &model.RefreshToken{}, &model.PasswordHistory{})
+	cr := mock.Crypter()
+	hashedPwd, err := cr.HashPassword(oldPassword)
+	assert.Nil(t, err)
+	usr := &model.User{Username: "johndoe", Email: "johndoe@example.com", Password: hashedPwd, Role: model.RoleUser}
+	assert.Nil(t, db.Create(usr).Error)
+	assert.Nil(t, db.Create(&model.User{Username: "blocked", Email: "blocked@example.com", Password: hashedPwd, Role: model.RoleUser, Blocked: true}).Error)
+
+	sender := email.NewFakeSender()
+	policy := passwordpolicy.NewWithConfig(passwordpolicy.Config{MinLength: 10, HistorySize: 2})
+	svc := password.New(db, userdb.NewDB(), passwordresetdb.NewDB(), refreshtokendb.NewDB(),
+		mail.New(sender, "https://app.example.com"), mock.Background{}, cr, passwordutil.New(passwordhistorydb.NewDB(), cr, policy))
+	return &fixture{db: db, svc: svc, sender: sender, cr: cr, user: usr}
+}
+
+// forgot requests a reset link of the user, returns the token of the link
+func (f *fixture) forgot(t *testing.T) string {
+	t.Helper()
+	assert.Nil(t, f.svc.Forgot(context.Background(), password.ForgotData{Email: f.user.Email}))
+	link, err := url.Parse(resetURL.FindString(f.sender.Last().TextBody))
+	assert.Nil(t, err)
+	token := link.Query().Get("token")
+	assert.NotEmpty(t, token)
+	return token
+}
+
+func (f *fixture) reset(token, pwd string) error {
+	return f.svc.Reset(context.Background(), password.ResetData{Token: token, NewPassword: pwd, NewPasswordConfirm: pwd})
+}
+
+func TestForgot(t *testing.T) {
+	cases := []struct {
+		name     string
+		email    string
+		wantSent bool
+	}{
+		{name: "Registered email", email: " johndoe@example.com ", wantSent: true},
+		{name: "Unknown email", email: "nobody@example.com"},
+		{name: "Blocked user", email: "blocked@example.com"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			// the response does not tell whether the email is registered
+			assert.Nil(t, f.svc.Forgot(context.Background(), password.ForgotData{Email: tt.email}))
+
+			var count int64
+			assert.Nil(t, f.db.Model(&model.PasswordReset{}).Count(&count).Error)
+			if !tt.wantSent {
+				assert.Empty(t, f.sender.Sent())
+				assert.Zero(t, count)
+				return
+			}
+			assert.Len(t, f.sender.Sent(), 1)
+			assert.Equal(t, []string{"johndoe@example.com"}, f.sender.Last().To)
+			assert.Regexp(t, resetURL, f.sender.Last().TextBody)
+			assert.Equal(t, int64(1), count)
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	cases := []struct {
+		name string
+		// prepare returns the token to reset with, given the token of the latest link
+		prepare     func(t *testing.T, f *fixture, token string) string
+		newPassword string
+		// wantErrType is the type of the returned error, empty if no error
+		wantErrType string
+		// wantRetry tells whether the latest link can still be used after the failure
+		wantRetry bool
+	}{
+		{
+			name:        "Success",
+			prepare:     func(_ *testing.T, _ *fixture, token string) string { return token },
+			newPassword: newPassword,
+		},
+		{
+			name:        "Unknown token",
+			prepare:     func(_ *testing.T, _ *fixture, _ string) string { return "unknown" },
+			newPassword: newPassword,
+			wantErrType: password.ErrInvalidResetToken.Type,
+			wantRetry:   true,
+		},
+		{
+			name: "Used token",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				assert.Nil(t, f.reset(token, "Other-Secret-3"))
+				return token
+			},
+			newPassword: newPassword,
+			wantErrType: password.ErrInvalidResetToken.Type,
+		},
+		{
+			name: "Expired token",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				assert.Nil(t, f.db.Model(&model.PasswordReset{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Second)).Error)
+				return token
+			},
+			newPassword: newPassword,
+			wantErrType: password.ErrInvalidResetToken.Type,
+		},
+		{
+			name: "Superseded by a new link",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				f.forgot(t)
+				return token
+			},
+			newPassword: newPassword,
+			wantErrType: password.ErrInvalidResetToken.Type,
+		},
+		{
+			name:        "Weak password",
+			prepare:     func(_ *testing.T, _ *fixture, token string) string { return token },
+			newPassword: "short",
+			wantErrType: server.ValidationErrorType,
+			wantRetry:   true,
+		},
+		{
+			name:        "Current password reused",
+			prepare:     func(_ *testing.T, _ *fixture, token string) string { return token },
+			newPassword: oldPassword,
+			wantErrType: server.ValidationErrorType,
+			wantRetry:   true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			assert.Nil(t, f.db.Create(&model.RefreshToken{UserID: f.user.ID, Family: "family", Token: "hashed", ExpiresAt: time.Now().Add(time.Hour)}).Error)
+			latest := f.forgot(t)
+			// another link sent concurrently, which is not superseded by the latest one
+			other := f.cr.UID()
+			assert.Nil(t, f.db.Create(&model.PasswordReset{UserID: f.user.ID, Token: f.cr.HashToken(other), ExpiresAt: time.Now().Add(time.Hour)}).Error)
+
+			err := f.reset(tt.prepare(t, f, latest), tt.newPassword)
+			if tt.wantErrType != "" {
+				herr, ok := err.(*server.HTTPError)
+				assert.True(t, ok, err)
+				if ok {
+					assert.Equal(t, tt.wantErrType, herr.Type)
+				}
+				assert.Equal(t, tt.wantRetry, f.reset(latest, newPassword) == nil)
+				return
+			}
+			assert.Nil(t, err)
+			usr := &model.User{}
+			assert.Nil(t, f.db.First(usr, f.user.ID).Error)
+			assert.True(t, f.cr.CompareHashAndPassword(usr.Password, newPassword))
+
+			// the other sessions have to login again
+			rt := &model.RefreshToken{}
+			assert.Nil(t, f.db.First(rt, "user_id = ?", f.user.ID).Error)
+			assert.True(t, rt.IsRevoked())
+
+			// the link is single use, and the other links of the user cannot be used any more
+			assert.ErrorIs(t, f.reset(latest, "Other-Secret-3"), password.ErrInvalidResetToken)
+			assert.ErrorIs(t, f.reset(other, "Other-Secret-3"), password.ErrInvalidResetToken)
+			assert.Nil(t, f.db.First(usr, f.user.ID).Error)
+			assert.True(t, f.cr.CompareHashAndPassword(usr.Password, newPassword), "the password is not changed again")
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package password
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/util/background"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// New creates new password reset application service, the emails are sent by the background worker bg
+// and the new passwords are checked by pwd
+func New(db *gorm.DB, udb UserDB, prdb PasswordResetDB, rtdb RefreshTokenDB, mailer Mailer, bg Background, cr Crypter, pwd PasswordChecker) *Password {
+	return &Password{
+		db:     db,
+		udb:    udb,
+		prdb:   prdb,
+		rtdb:   rtdb,
+		mailer: mailer,
+		bg:     bg,
+		cr:     cr,
+		pwd:    pwd,
+	}
+}
+
+// Password represents password reset application service
+type Password struct {
+	db     *gorm.DB
+	udb    UserDB
+	prdb   PasswordResetDB
+	rtdb   RefreshTokenDB
+	mailer Mailer
+	bg     Background
+	cr     Crypter
+	pwd    PasswordChecker
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	dbutil.Intf
+	FindByEmail(context.Context, *gorm.DB, string) (*model.User, error)
+}
+
+// PasswordResetDB represents password reset repository interface
+type PasswordResetDB interface {
+	dbutil.Intf
+	FindByToken(context.Context, *gorm.DB, string) (*model.PasswordReset, error)
+	MarkUsed(context.Context, *gorm.DB, int) (bool, error)
+	InvalidateByUserID(context.Context, *gorm.DB, int) error
+}
+
+// RefreshTokenDB represents refresh token repository interface
+type RefreshTokenDB interface {
+	RevokeByUserID(context.Context, *gorm.DB, int) error
+}
+
+// Mailer represents email sending interface
+type Mailer interface {
+	SendPasswordReset(context.Context, *model.User, string, time.Duration) error
+}
+
+// Background represents background worker interface
+type Background interface {
+	Go(context.Context, background.Task) bool
+}
+
+// Crypter represents security interface
+type Crypter interface {
+	HashPassword(string) (string, error)
+	UID() string
+	HashToken(string) string
+}
+
+// PasswordChecker represents the new password checking interface, see passwordutil.Checker
+type PasswordChecker interface {
+	Check(context.Context, *gorm.DB, *model.User, string) error
+	Record(context.Context, *gorm.DB, *model.User) error
+}
@@ -0,0 +1,362 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	rbacutil "github.com/vuduongtp/go-core/pkg/rbac"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents RBAC management http service
+type HTTP struct {
+	svc  Service
+	auth model.Auth
+}
+
+// Service represents RBAC management application interface
+type Service interface {
+	ListRoles(context.Context, *model.AuthUser) ([]*model.Role, error)
+	CreateRole(context.Context, *model.AuthUser, CreateRoleData) (*model.Role, error)
+	DeleteRole(context.Context, *model.AuthUser, string) error
+	ListPolicies(context.Context, *model.AuthUser) ([]*model.Policy, error)
+	AddPolicy(context.Context, *model.AuthUser, PolicyData) (*model.Policy, error)
+	RemovePolicy(context.Context, *model.AuthUser, PolicyData) error
+	ListInheritance(context.Context, *model.AuthUser) ([]*model.RoleInheritance, error)
+	AddInheritance(context.Context, *model.AuthUser, InheritanceData) (*model.RoleInheritance, error)
+	RemoveInheritance(context.Context, *model.AuthUser, InheritanceData) error
+	ListRoutes(context.Context, *model.AuthUser) ([]*model.RoutePermission, error)
+}
+
+// NewHTTP creates new RBAC management http service
+func NewHTTP(svc Service, auth model.Auth, eg *echo.Group) {
+	h := HTTP{svc, auth}
+
+	eg.GET("/roles", h.listRoles, rbacutil.Require(model.ObjectRBAC, model.ActionViewAll))
+	eg.POST("/roles", h.createRole, rbacutil.Require(model.ObjectRBAC, model.ActionCreateAll))
+	eg.DELETE("/roles/:role", h.deleteRole, rbacutil.Require(model.ObjectRBAC, model.ActionDeleteAll))
+	eg.GET("/policies", h.listPolicies, rbacutil.Require(model.ObjectRBAC, model.ActionViewAll))
+	eg.POST("/policies", h.addPolicy, rbacutil.Require(model.ObjectRBAC, model.ActionCreateAll))
+	eg.DELETE("/policies", h.removePolicy, rbacutil.Require(model.ObjectRBAC, model.ActionDeleteAll))
+	eg.GET("/inheritance", h.listInheritance, rbacutil.Require(model.ObjectRBAC, model.ActionViewAll))
+	eg.POST("/inheritance", h.addInheritance, rbacutil.Require(model.ObjectRBAC, model.ActionCreateAll))
+	eg.DELETE("/inheritance", h.removeInheritance, rbacutil.Require(model.ObjectRBAC, model.ActionDeleteAll))
+	eg.GET("/routes", h.listRoutes, rbacutil.Require(model.ObjectRBAC, model.ActionViewAll))
+}
+
+// PermissionData contains a permission of the role creation request
+type PermissionData struct {
+	Object string `json:"object" validate:"required,max=100" example:"user"`
+	Action string `json:"action" validate:"required,max=100" example:"view_all"`
+	// Condition on the request & resource attributes, see rbac.Conditions
+	Condition string `json:"condition" validate:"max=100" example:"attr(r.attrs, \"role\") == \"user\""`
+}
+
+// CreateRoleData contains role creation request
+type CreateRoleData struct {
+	Name string `json:"name" validate:"required,max=100" example:"support"`
+	// Organization ID the permissions & inheritance apply to, all organizations if empty
+	Domain string `json:"domain" validate:"max=100" example:"*"`
+	// Roles whose permissions are inherited
+	Inherits    []string          `json:"inherits" validate:"dive,required,max=100"`
+	Permissions []*PermissionData `json:"permissions" validate:"dive"`
+}
+
+// PolicyData contains policy request, read from the query string for deletion too.
+// The domain is an organization ID, or all organizations if empty. The condition is optional, see rbac.Conditions
+type PolicyData struct {
+	Role      string `json:"role" query:"role" validate:"required,max=100" example:"support"`
+	Domain    string `json:"domain" query:"domain" validate:"max=100" example:"*"`
+	Object    string `json:"object" query:"object" validate:"required,max=100" example:"user"`
+	Action    string `json:"action" query:"action" validate:"required,max=100" example:"view_all"`
+	Condition string `json:"condition" query:"condition" validate:"max=100" example:"ipInRange(attr(r.attrs, \"ip\"), \"10.0.0.0/8\")"`
+}
+
+// InheritanceData contains role inheritance request, read from the query string for deletion too.
+// The domain is an organization ID, or all organizations if empty
+type InheritanceData struct {
+	Role   string `json:"role" query:"role" validate:"required,max=100" example:"support"`
+	Parent string `json:"parent" query:"parent" validate:"required,max=100" example:"user"`
+	Domain string `json:"domain" query:"domain" validate:"max=100" example:"*"`
+}
+
+// RolesResp contains list of roles
+type RolesResp struct {
+	Data []*model.Role `json:"data"`
+}
+
+// PoliciesResp contains list of policies
+type PoliciesResp struct {
+	Data []*model.Policy `json:"data"`
+}
+
+// InheritanceResp contains list of role inheritance rules
+type InheritanceResp struct {
+	Data []*model.RoleInheritance `json:"data"`
+}
+
+// RoutesResp contains list of route permissions
+type RoutesResp struct {
+	Data []*model.RoutePermission `json:"data"`
+}
+
+// @Security		BearerToken
+// @Summary		Returns the roles
+// @Description	Returns all roles with their own permissions & the roles they inherit from
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacListRoles
+// @Success		200				{object}	rbac.RolesResp
+// @Failure		401				{object}	SwaggErrDetailsResp
+// @Failure		403				{object}	SwaggErrDetailsResp
+// @Failure		500				{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "view_all"}
+// @Router			/v1/rbac/roles	[get]
+func (h *HTTP) listRoles(c echo.Context) error {
+	resp, err := h.svc.ListRoles(c.Request().Context(), h.auth.User(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, RolesResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Creates new role
+// @Description	Creates a role with the given permissions & parent roles, it can be assigned to users right away
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacCreateRole
+// @Param			request			body		rbac.CreateRoleData	true	"CreateRoleData"
+// @Success		200				{object}	model.Role
+// @Failure		400				{object}	SwaggErrDetailsResp
+// @Failure		401				{object}	SwaggErrDetailsResp
+// @Failure		403				{object}	SwaggErrDetailsResp
+// @Failure		500				{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "create_all"}
+// @Router			/v1/rbac/roles	[post]
+func (h *HTTP) createRole(c echo.Context) error {
+	r := CreateRoleData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.CreateRole(c.Request().Context(), h.auth.User(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Deletes a role
+// @Description	Deletes a role with its permissions & inheritance rules. Built-in roles and roles assigned to users cannot be deleted
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacDeleteRole
+// @Param			role					path		string	true	"Role name"
+// @Success		200						{object}	SwaggOKResp
+// @Failure		400						{object}	SwaggErrDetailsResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "delete_all"}
+// @Router			/v1/rbac/roles/{role}	[delete]
+func (h *HTTP) deleteRole(c echo.Context) error {
+	if err := h.svc.DeleteRole(c.Request().Context(), h.auth.User(c), c.Param("role")); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Security		BearerToken
+// @Summary		Returns the policies
+// @Description	Returns the permissions granted to all roles
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacListPolicies
+// @Success		200					{object}	rbac.PoliciesResp
+// @Failure		401					{object}	SwaggErrDetailsResp
+// @Failure		403					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "view_all"}
+// @Router			/v1/rbac/policies	[get]
+func (h *HTTP) listPolicies(c echo.Context) error {
+	resp, err := h.svc.ListPolicies(c.Request().Context(), h.auth.User(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, PoliciesResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Adds a policy
+// @Description	Grants a permission to an existing role, the policies of the superadmin role cannot be changed.
+// @Description	The permission may be restricted by a condition on the attributes of the request (`ip`, `time`) and the resource (e.g. `role` of the users),
+// @Description	using the functions attr, ipInRange & timeBetween. e.g: `attr(r.attrs, "role") == "user"`
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacAddPolicy
+// @Param			request				body		rbac.PolicyData	true	"PolicyData"
+// @Success		200					{object}	model.Policy
+// @Failure		400					{object}	SwaggErrDetailsResp
+// @Failure		401					{object}	SwaggErrDetailsResp
+// @Failure		403					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "create_all"}
+// @Router			/v1/rbac/policies	[post]
+func (h *HTTP) addPolicy(c echo.Context) error {
+	r := PolicyData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.AddPolicy(c.Request().Context(), h.auth.User(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Removes a policy
+// @Description	Revokes a permission from a role, the policies of the superadmin role cannot be changed
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacRemovePolicy
+// @Param			role				query		string	true	"Role name"
+// @Param			domain				query		string	false	"Organization ID, all organizations if empty"
+// @Param			object				query		string	true	"Object"
+// @Param			action				query		string	true	"Action"
+// @Param			condition			query		string	false	"Condition, the policy without condition if empty"
+// @Success		200					{object}	SwaggOKResp
+// @Failure		400					{object}	SwaggErrDetailsResp
+// @Failure		401					{object}	SwaggErrDetailsResp
+// @Failure		403					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "delete_all"}
+// @Router			/v1/rbac/policies	[delete]
+func (h *HTTP) removePolicy(c echo.Context) error {
+	r := PolicyData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := h.svc.RemovePolicy(c.Request().Context(), h.auth.User(c), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Security		BearerToken
+// @Summary		Returns the role inheritance rules
+// @Description	Returns the rules of roles inheriting the permissions of their parent roles
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacListInheritance
+// @Success		200						{object}	rbac.InheritanceResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "view_all"}
+// @Router			/v1/rbac/inheritance	[get]
+func (h *HTTP) listInheritance(c echo.Context) error {
+	resp, err := h.svc.ListInheritance(c.Request().Context(), h.auth.User(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, InheritanceResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Adds a role inheritance rule
+// @Description	Makes a role inherit the permissions of its parent role, inheritance cycles are rejected
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacAddInheritance
+// @Param			request					body		rbac.InheritanceData	true	"InheritanceData"
+// @Success		200						{object}	model.RoleInheritance
+// @Failure		400						{object}	SwaggErrDetailsResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "create_all"}
+// @Router			/v1/rbac/inheritance	[post]
+func (h *HTTP) addInheritance(c echo.Context) error {
+	r := InheritanceData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.AddInheritance(c.Request().Context(), h.auth.User(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Removes a role inheritance rule
+// @Description	Removes a role inheritance rule, the rules of the superadmin role cannot be changed
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacRemoveInheritance
+// @Param			role					query		string	true	"Role name"
+// @Param			parent					query		string	true	"Parent role name"
+// @Param			domain					query		string	false	"Organization ID, all organizations if empty"
+// @Success		200						{object}	SwaggOKResp
+// @Failure		400						{object}	SwaggErrDetailsResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "delete_all"}
+// @Router			/v1/rbac/inheritance	[delete]
+func (h *HTTP) removeInheritance(c echo.Context) error {
+	r := InheritanceData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := h.svc.RemoveInheritance(c.Request().Context(), h.auth.User(c), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Security		BearerToken
+// @Summary		Returns the route permissions
+// @Description	Returns the permission required by each API route, with the roles granted it in all organizations.
+// @Description	The roles granted it by a policy with condition are included, they may call the route depending on the attributes of the request
+// @Description	The owned routes may also be called by the owners of the resources without the permission, e.g. the users on their own account
+// @Accept			json
+// @Produce		json
+// @Tags			rbac
+// @ID				rbacListRoutes
+// @Success		200				{object}	rbac.RoutesResp
+// @Failure		401				{object}	SwaggErrDetailsResp
+// @Failure		403				{object}	SwaggErrDetailsResp
+// @Failure		500				{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "rbac", "action": "view_all"}
+// @Router			/v1/rbac/routes	[get]
+func (h *HTTP) listRoutes(c echo.Context) error {
+	resp, err := h.svc.ListRoutes(c.Request().Context(), h.auth.User(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, RoutesResp{resp})
+}
@@ -0,0 +1,349 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	rbacutil "github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+
+	"github.com/samber/lo"
+)
+
+// Custom errors
+var (
+	ErrInvalidName         = server.NewHTTPValidationError("Names may only contain letters, digits and the characters _ . : * -")
+	ErrEmptyRole           = server.NewHTTPValidationError("Role must have permissions or inherit from another role")
+	ErrRoleExisted         = server.NewHTTPValidationError("Role already existed")
+	ErrRoleNotFound        = server.NewHTTPError(http.StatusBadRequest, "ROLE_NOTFOUND", "Role not found")
+	ErrBuiltinRole         = server.NewHTTPError(http.StatusBadRequest, "BUILTIN_ROLE", "Built-in roles cannot be deleted")
+	ErrProtectedRole       = server.NewHTTPError(http.StatusBadRequest, "PROTECTED_ROLE", "The superadmin role cannot be changed")
+	ErrRoleInUse           = server.NewHTTPError(http.StatusBadRequest, "ROLE_IN_USE", "Role is assigned to users")
+	ErrPolicyExisted       = server.NewHTTPValidationError("Policy already existed")
+	ErrPolicyNotFound      = server.NewHTTPError(http.StatusBadRequest, "POLICY_NOTFOUND", "Policy not found")
+	ErrInheritanceExisted  = server.NewHTTPValidationError("Role inheritance already existed")
+	ErrInheritanceNotFound = server.NewHTTPError(http.StatusBadRequest, "INHERITANCE_NOTFOUND", "Role inheritance not found")
+	ErrInheritanceCycle    = server.NewHTTPValidationError("Role cannot inherit from itself, directly or indirectly")
+	ErrInvalidCondition    = server.NewHTTPValidationError("Invalid condition")
+)
+
+// namePattern restricts the names of roles, objects & actions, commas would break the loading of the policies
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.:*-]+$`)
+
+// ListRoles returns all roles with their own permissions & the roles they inherit from
+func (s *RBAC) ListRoles(ctx context.Context, authUsr *model.AuthUser) ([]*model.Role, error) {
+	roles := s.rbac.Roles()
+	data := make([]*model.Role, 0, len(roles))
+	for _, name := range roles {
+		data = append(data, s.role(name))
+	}
+
+	return data, nil
+}
+
+// CreateRole creates a new role with the given permissions & parent roles
+func (s *RBAC) CreateRole(ctx context.Context, authUsr *model.AuthUser, data CreateRoleData) (*model.Role, error) {
+	if !namePattern.MatchString(data.Name) || !validDomain(data.Domain) {
+		return nil, ErrInvalidName
+	}
+	if len(data.Inherits) == 0 && len(data.Permissions) == 0 {
+		return nil, ErrEmptyRole
+	}
+	if s.rbac.RoleExists(data.Name) {
+		return nil, ErrRoleExisted
+	}
+	for _, parent := range data.Inherits {
+		if !s.rbac.RoleExists(parent) {
+			return nil, ErrRoleNotFound
+		}
+	}
+	for _, p := range data.Permissions {
+		if !namePattern.MatchString(p.Object) || !namePattern.MatchString(p.Action) {
+			return nil, ErrInvalidName
+		}
+		if err := rbacutil.ValidateCondition(p.Condition); err != nil {
+			return nil, ErrInvalidCondition.SetInternal(err)
+		}
+	}
+
+	// the rules are added in batches, which must not contain duplicates
+	domain := domainOrAny(data.Domain)
+	policies := make([][]string, 0, len(data.Permissions))
+	for _, p := range lo.UniqBy(data.Permissions, func(p *PermissionData) PermissionData { return *p }) {
+		policies = append(policies, []string{data.Name, domain, p.Object, p.Action, p.Condition})
+	}
+	inheritance := make([][]string, 0, len(data.Inherits))
+	for _, parent := range lo.Uniq(data.Inherits) {
+		inheritance = append(inheritance, []string{data.Name, parent, domain})
+	}
+	if len(policies) > 0 {
+		if _, err := s.rbac.AddPolicies(policies); err != nil {
+			return nil, server.NewHTTPInternalError("Error creating role").SetInternal(err)
+		}
+	}
+	if len(inheritance) > 0 {
+		if _, err := s.rbac.AddGroupingPolicies(inheritance); err != nil {
+			return nil, server.NewHTTPInternalError("Error creating role").SetInternal(err)
+		}
+	}
+
+	logger.LogSecurityEvent(ctx, "rbac_role_created", map[string]interface{}{
+		"role":     data.Name,
+		"domain":   domain,
+		"actor_id": authUsr.ID,
+	})
+
+	return s.role(data.Name), nil
+}
+
+// DeleteRole deletes a role with its permissions & inheritance rules, the built-in roles and the roles assigned to users cannot be deleted
+func (s *RBAC) DeleteRole(ctx context.Context, authUsr *model.AuthUser, name string) error {
+	for _, role := range model.BuiltinRoles {
+		if role == name {
+			return ErrBuiltinRole
+		}
+	}
+	if !s.rbac.RoleExists(name) {
+		return ErrRoleNotFound
+	}
+	// the role may be assigned globally or within any organization
+	ctx = dbutil.WithoutTenant(ctx)
+	if existed, err := s.udb.Exist(ctx, s.db, map[string]interface{}{"role": name}); err != nil || existed {
+		return ErrRoleInUse.SetInternal(err)
+	}
+	if existed, err := s.mdb.Exist(ctx, s.db, map[string]interface{}{"role": name}); err != nil || existed {
+		return ErrRoleInUse.SetInternal(err)
+	}
+
+	if _, err := s.rbac.RemoveFilteredPolicy(0, name); err != nil {
+		return server.NewHTTPInternalError("Error deleting role").SetInternal(err)
+	}
+	if _, err := s.rbac.RemoveFilteredGroupingPolicy(0, name); err != nil {
+		return server.NewHTTPInternalError("Error deleting role").SetInternal(err)
+	}
+	if _, err := s.rbac.RemoveFilteredGroupingPolicy(1, name); err != nil {
+		return server.NewHTTPInternalError("Error deleting role").SetInternal(err)
+	}
+
+	logger.LogSecurityEvent(ctx, "rbac_role_deleted", map[string]interface{}{
+		"role":     name,
+		"actor_id": authUsr.ID,
+	})
+
+	return nil
+}
+
+// ListPolicies returns the permissions granted to all roles
+func (s *RBAC) ListPolicies(ctx context.Context, authUsr *model.AuthUser) ([]*model.Policy, error) {
+	rules := s.rbac.GetPolicy()
+	data := make([]*model.Policy, 0, len(rules))
+	for _, rule := range rules {
+		data = append(data, &model.Policy{Role: rule[0], Domain: rule[1], Object: rule[2], Action: rule[3], Condition: rule[4]})
+	}
+
+	return data, nil
+}
+
+// AddPolicy grants a permission to an existing role
+func (s *RBAC) AddPolicy(ctx context.Context, authUsr *model.AuthUser, data PolicyData) (*model.Policy, error) {
+	if data.Role == model.RoleSuperAdmin {
+		return nil, ErrProtectedRole
+	}
+	if !namePattern.MatchString(data.Object) || !namePattern.MatchString(data.Action) || !validDomain(data.Domain) {
+		return nil, ErrInvalidName
+	}
+	if err := rbacutil.ValidateCondition(data.Condition); err != nil {
+		return nil, ErrInvalidCondition.SetInternal(err)
+	}
+	if !s.rbac.RoleExists(data.Role) {
+		return nil, ErrRoleNotFound
+	}
+	domain := domainOrAny(data.Domain)
+	added, err := s.rbac.AddPolicy(data.Role, domain, data.Object, data.Action, data.Condition)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error adding policy").SetInternal(err)
+	}
+	if !added {
+		return nil, ErrPolicyExisted
+	}
+
+	logger.LogSecurityEvent(ctx, "rbac_policy_added", map[string]interface{}{
+		"role":      data.Role,
+		"domain":    domain,
+		"object":    data.Object,
+		"action":    data.Action,
+		"condition": data.Condition,
+		"actor_id":  authUsr.ID,
+	})
+
+	return &model.Policy{Role: data.Role, Domain: domain, Object: data.Object, Action: data.Action, Condition: data.Condition}, nil
+}
+
+// RemovePolicy revokes a permission from a role
+func (s *RBAC) RemovePolicy(ctx context.Context, authUsr *model.AuthUser, data PolicyData) error {
+	if data.Role == model.RoleSuperAdmin {
+		return ErrProtectedRole
+	}
+	domain := domainOrAny(data.Domain)
+	removed, err := s.rbac.RemovePolicy(data.Role, domain, data.Object, data.Action, data.Condition)
+	if err != nil {
+		return server.NewHTTPInternalError("Error removing policy").SetInternal(err)
+	}
+	if !removed {
+		return ErrPolicyNotFound
+	}
+
+	logger.LogSecurityEvent(ctx, "rbac_policy_removed", map[string]interface{}{
+		"role":      data.Role,
+		"domain":    domain,
+		"object":    data.Object,
+		"action":    data.Action,
+		"condition": data.Condition,
+		"actor_id":  authUsr.ID,
+	})
+
+	return nil
+}
+
+// ListInheritance returns all role inheritance rules
+func (s *RBAC) ListInheritance(ctx context.Context, authUsr *model.AuthUser) ([]*model.RoleInheritance, error) {
+	rules := s.rbac.GetGroupingPolicy()
+	data := make([]*model.RoleInheritance, 0, len(rules))
+	for _, rule := range rules {
+		data = append(data, &model.RoleInheritance{Role: rule[0], Parent: rule[1], Domain: rule[2]})
+	}
+
+	return data, nil
+}
+
+// AddInheritance makes a role inherit the permissions of its parent role
+func (s *RBAC) AddInheritance(ctx context.Context, authUsr *model.AuthUser, data InheritanceData) (*model.RoleInheritance, error) {
+	if data.Role == model.RoleSuperAdmin {
+		return nil, ErrProtectedRole
+	}
+	if !validDomain(data.Domain) {
+		return nil, ErrInvalidName
+	}
+	if !s.rbac.RoleExists(data.Role) || !s.rbac.RoleExists(data.Parent) {
+		return nil, ErrRoleNotFound
+	}
+	if data.Role == data.Parent {
+		return nil, ErrInheritanceCycle
+	}
+	domain := domainOrAny(data.Domain)
+	// the rules of both the domain and all domains apply
+	for _, dom := range []string{domain, rbacutil.DomainAny} {
+		roles, err := s.rbac.GetImplicitRolesForUser(data.Parent, dom)
+		if err != nil {
+			return nil, server.NewHTTPInternalError("Error adding role inheritance").SetInternal(err)
+		}
+		for _, role := range roles {
+			if role == data.Role {
+				return nil, ErrInheritanceCycle
+			}
+		}
+	}
+	added, err := s.rbac.AddGroupingPolicy(data.Role, data.Parent, domain)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error adding role inheritance").SetInternal(err)
+	}
+	if !added {
+		return nil, ErrInheritanceExisted
+	}
+
+	logger.LogSecurityEvent(ctx, "rbac_inheritance_added", map[string]interface{}{
+		"role":     data.Role,
+		"parent":   data.Parent,
+		"domain":   domain,
+		"actor_id": authUsr.ID,
+	})
+
+	return &model.RoleInheritance{Role: data.Role, Parent: data.Parent, Domain: domain}, nil
+}
+
+// RemoveInheritance removes a role inheritance rule
+func (s *RBAC) RemoveInheritance(ctx context.Context, authUsr *model.AuthUser, data InheritanceData) error {
+	if data.Role == model.RoleSuperAdmin {
+		return ErrProtectedRole
+	}
+	domain := domainOrAny(data.Domain)
+	removed, err := s.rbac.RemoveGroupingPolicy(data.Role, data.Parent, domain)
+	if err != nil {
+		return server.NewHTTPInternalError("Error removing role inheritance").SetInternal(err)
+	}
+	if !removed {
+		return ErrInheritanceNotFound
+	}
+
+	logger.LogSecurityEvent(ctx, "rbac_inheritance_removed", map[string]interface{}{
+		"role":     data.Role,
+		"parent":   data.Parent,
+		"domain":   domain,
+		"actor_id": authUsr.ID,
+	})
+
+	return nil
+}
+
+// ListRoutes returns the permission required by each API route, with the roles granted it in all organizations
+func (s *RBAC) ListRoutes(ctx context.Context, authUsr *model.AuthUser) ([]*model.RoutePermission, error) {
+	// the permissions of each role, including the inherited ones
+	perms := make(map[string][][]string)
+	for _, role := range s.rbac.Roles() {
+		rules, err := s.rbac.GetImplicitPermissionsForUser(role, rbacutil.DomainAny)
+		if err != nil {
+			return nil, server.NewHTTPInternalError("Error listing route permissions").SetInternal(err)
+		}
+		perms[role] = rules
+	}
+
+	routes := s.routes.List()
+	data := make([]*model.RoutePermission, 0, len(routes))
+	for _, r := range routes {
+		rec := &model.RoutePermission{Method: r.Method, Path: r.Path, Object: r.Object, Action: r.Action, Owned: r.Owned, Roles: []string{}}
+		for _, role := range s.rbac.Roles() {
+			if lo.ContainsBy(perms[role], func(rule []string) bool {
+				return (rule[2] == r.Object || rule[2] == model.ObjectAny) && (rule[3] == r.Action || rule[3] == model.ActionAny)
+			}) {
+				rec.Roles = append(rec.Roles, role)
+			}
+		}
+		data = append(data, rec)
+	}
+
+	return data, nil
+}
+
+// role returns the role of the given name with its own permissions & parent roles in all domains
+func (s *RBAC) role(name string) *model.Role {
+	rec := &model.Role{Name: name, Inherits: []string{}, Permissions: []*model.Permission{}}
+	for _, rule := range s.rbac.GetFilteredPolicy(0, name) {
+		rec.Permissions = append(rec.Permissions, &model.Permission{Domain: rule[1], Object: rule[2], Action: rule[3], Condition: rule[4]})
+	}
+	for _, rule := range s.rbac.GetFilteredGroupingPolicy(0, name) {
+		rec.Inherits = append(rec.Inherits, rule[1])
+	}
+	return rec
+}
+
+// validDomain reports whether the domain is empty, i.e. all domains, or an organization ID
+func validDomain(domain string) bool {
+	if domain == "" || domain == rbacutil.DomainAny {
+		return true
+	}
+	id, err := strconv.Atoi(domain)
+	return err == nil && id > 0
+}
+
+// domainOrAny returns the given domain, or the domain of all organizations if empty
+func domainOrAny(domain string) string {
+	if domain == "" {
+		return rbacutil.DomainAny
+	}
+	return domain
+}
@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	rbacutil "github.com/vuduongtp/go-core/pkg/rbac"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// New creates new RBAC management application service, the permissions are checked by the routes, see NewHTTP
+func New(db *gorm.DB, udb UserDB, mdb MembershipDB, enforcer Enforcer, routes Routes) *RBAC {
+	return &RBAC{db: db, udb: udb, mdb: mdb, rbac: enforcer, routes: routes}
+}
+
+// RBAC represents RBAC management application service
+type RBAC struct {
+	db     *gorm.DB
+	udb    UserDB
+	mdb    MembershipDB
+	rbac   Enforcer
+	routes Routes
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	dbutil.Intf
+}
+
+// MembershipDB represents organization membership repository interface
+type MembershipDB interface {
+	dbutil.Intf
+}
+
+// Enforcer represents the policy management interface, the changes are saved by the adapter of the enforcer
+type Enforcer interface {
+	Roles() []string
+	RoleExists(string) bool
+	GetPolicy() [][]string
+	GetFilteredPolicy(int, ...string) [][]string
+	AddPolicy(...interface{}) (bool, error)
+	AddPolicies([][]string) (bool, error)
+	RemovePolicy(...interface{}) (bool, error)
+	RemoveFilteredPolicy(int, ...string) (bool, error)
+	GetGroupingPolicy() [][]string
+	GetFilteredGroupingPolicy(int, ...string) [][]string
+	AddGroupingPolicy(...interface{}) (bool, error)
+	AddGroupingPolicies([][]string) (bool, error)
+	RemoveGroupingPolicy(...interface{}) (bool, error)
+	RemoveFilteredGroupingPolicy(int, ...string) (bool, error)
+	GetImplicitRolesForUser(string, ...string) ([]string, error)
+	GetImplicitPermissionsForUser(string, ...string) ([][]string, error)
+}
+
+// Routes represents the record of the permissions required by the routes, see rbacutil.Require
+type Routes interface {
+	List() []rbacutil.RoutePermission
+}
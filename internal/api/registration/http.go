@@ -0,0 +1,127 @@
+package registration
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents registration http service
+type HTTP struct {
+	svc Service
+}
+
+// Service represents registration application interface
+type Service interface {
+	Register(context.Context, RegisterData) error
+	VerifyEmail(context.Context, VerifyEmailData) error
+	ResendVerification(context.Context, ResendVerificationData) error
+}
+
+// NewHTTP creates new registration http service
+func NewHTTP(svc Service, e *echo.Echo) {
+	h := HTTP{svc}
+
+	e.POST("/register", h.register)
+	e.POST("/verify-email", h.verifyEmail)
+	e.POST("/verify-email/resend", h.resendVerification)
+}
+
+// RegisterData contains sign-up data from json request
+type RegisterData struct {
+	Username  string `json:"username" validate:"required,min=3"`
+	Password  string `json:"password" validate:"required,password"`
+	FirstName string `json:"first_name" validate:"required"`
+	LastName  string `json:"last_name" validate:"required"`
+	Email     string `json:"email" validate:"required,email"`
+	Mobile    string `json:"mobile" validate:"omitempty,mobile"`
+}
+
+// VerifyEmailData contains email verification request
+type VerifyEmailData struct {
+	// The token from the verification email
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationData contains request to resend the verification email
+type ResendVerificationData struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// @Summary		Registers new user
+// @Description	Creates a pending user account and emails the verification link, the account cannot login until the email is verified.
+// @Description	The response is the same whether the email or the username is registered or not, the owner of the email is notified instead
+// @Accept			json
+// @Produce		json
+// @Tags			registration
+// @ID				registrationRegister
+// @Param			request		body		registration.RegisterData	true	"RegisterData"
+// @Success		200			{object}	SwaggOKResp
+// @Failure		400			{object}	SwaggErrDetailsResp
+// @Failure		500			{object}	SwaggErrDetailsResp
+// @Router			/register	[post]
+func (h *HTTP) register(c echo.Context) error {
+	r := RegisterData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	r.Username = strings.TrimSpace(r.Username)
+	r.Email = strings.TrimSpace(r.Email)
+	r.FirstName = strings.TrimSpace(r.FirstName)
+	r.LastName = strings.TrimSpace(r.LastName)
+	r.Mobile = strings.TrimSpace(strings.Replace(r.Mobile, " ", "", -1))
+
+	if err := h.svc.Register(c.Request().Context(), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Summary		Verifies email
+// @Description	Activates the pending user account using the token from the verification email
+// @Accept			json
+// @Produce		json
+// @Tags			registration
+// @ID				registrationVerifyEmail
+// @Param			request			body		registration.VerifyEmailData	true	"VerifyEmailData"
+// @Success		200				{object}	SwaggOKResp
+// @Failure		400				{object}	SwaggErrDetailsResp
+// @Failure		500				{object}	SwaggErrDetailsResp
+// @Router			/verify-email	[post]
+func (h *HTTP) verifyEmail(c echo.Context) error {
+	r := VerifyEmailData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := h.svc.VerifyEmail(c.Request().Context(), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Summary		Resends verification email
+// @Description	Emails a new verification link to the pending user of the given email. The response is the same whether the email is registered or not
+// @Accept			json
+// @Produce		json
+// @Tags			registration
+// @ID				registrationResendVerification
+// @Param			request					body		registration.ResendVerificationData	true	"ResendVerificationData"
+// @Success		200						{object}	SwaggOKResp
+// @Failure		400						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @Router			/verify-email/resend	[post]
+func (h *HTTP) resendVerification(c echo.Context) error {
+	r := ResendVerificationData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := h.svc.ResendVerification(c.Request().Context(), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,176 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrInvalidVerificationToken = server.NewHTTPError(http.StatusBadRequest, "INVALID_VERIFICATION_TOKEN", "Invalid or expired email verification token")
+)
+
+// VerificationTokenDuration is the validity period of email verification tokens
+const VerificationTokenDuration = 24 * time.Hour
+
+// Register creates a pending user account with the user role and emails the verification link.
+// If the email or the username is registered already, no error is returned & the owner of the email is notified instead,
+// so that the endpoint cannot be used to discover registered emails or usernames. The emails are sent in background for the same reason.
+func (s *Registration) Register(ctx context.Context, data RegisterData) error {
+	// hashed in all cases, so that the response time does not tell whether the account is registered
+	hashedPwd, err := s.cr.HashPassword(data.Password)
+	if err != nil {
+		return server.NewHTTPInternalError("Error hashing password").SetInternal(err)
+	}
+
+	if existed, err := s.existed(ctx, data); err != nil || existed {
+		if err != nil {
+			return server.NewHTTPInternalError("Error checking user").SetInternal(err)
+		}
+		return nil
+	}
+
+	rec := &model.User{
+		FirstName: data.FirstName,
+		LastName:  data.LastName,
+		Email:     data.Email,
+		Mobile:    data.Mobile,
+		Username:  data.Username,
+		Password:  hashedPwd,
+		Role:      model.RoleUser,
+		Pending:   true,
+	}
+
+	var token string
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := s.udb.Create(ctx, tx, rec); err != nil {
+			return err
+		}
+		var err error
+		token, err = s.issueToken(ctx, tx, rec.ID)
+		return err
+	})
+	if err != nil {
+		// the email or the username has been registered concurrently, violating the unique index
+		if existed, eerr := s.existed(ctx, data); eerr == nil && existed {
+			return nil
+		}
+		return server.NewHTTPInternalError("Error creating user").SetInternal(err)
+	}
+
+	s.bg.Go(ctx, func(ctx context.Context) error {
+		return s.mailer.SendEmailVerification(ctx, rec, token, VerificationTokenDuration)
+	})
+
+	return nil
+}
+
+// existed reports whether the email or the username of the registration is registered already.
+// If so, the owner of the registered email is notified, or the registrant is told by email that the username is taken.
+func (s *Registration) existed(ctx context.Context, data RegisterData) (bool, error) {
+	usr, err := s.udb.FindByEmail(ctx, s.db, data.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if usr != nil {
+		logger.LogSecurityEvent(ctx, "registration_email_existed", map[string]interface{}{"user_id": usr.ID})
+		s.bg.Go(ctx, func(ctx context.Context) error {
+			return s.mailer.SendAccountExists(ctx, usr)
+		})
+		return true, nil
+	}
+
+	existed, err := s.udb.Exist(ctx, s.db, map[string]interface{}{"username": data.Username})
+	if err != nil || !existed {
+		return false, err
+	}
+	registrant := &model.User{FirstName: data.FirstName, LastName: data.LastName, Email: data.Email, Username: data.Username}
+	s.bg.Go(ctx, func(ctx context.Context) error {
+		return s.mailer.SendUsernameTaken(ctx, registrant)
+	})
+	return true, nil
+}
+
+// VerifyEmail activates the pending user owning the verification token
+func (s *Registration) VerifyEmail(ctx context.Context, data VerifyEmailData) error {
+	rec, err := s.evdb.FindByToken(ctx, s.db, s.cr.HashToken(data.Token))
+	if err != nil || rec == nil || !rec.IsValid(time.Now()) {
+		return ErrInvalidVerificationToken.SetInternal(err)
+	}
+
+	invalid := false
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		ok, err := s.evdb.MarkUsed(ctx, tx, rec.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// the same token has been used concurrently
+			invalid = true
+			return nil
+		}
+		updates := map[string]interface{}{"pending": false, "email_verified_at": time.Now()}
+		return s.udb.Update(ctx, tx, updates, rec.UserID)
+	})
+	if err != nil {
+		return server.NewHTTPInternalError("Error verifying email").SetInternal(err)
+	}
+	if invalid {
+		return ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+// ResendVerification emails a new verification link to the pending user of the given email, previous links are invalidated.
+// No error is returned for unknown or verified emails, so that the endpoint cannot be used to discover registered emails.
+// The link is created & sent in background, so that the response time does not tell either.
+func (s *Registration) ResendVerification(ctx context.Context, data ResendVerificationData) error {
+	usr, err := s.udb.FindByEmail(ctx, s.db, strings.TrimSpace(data.Email))
+	if err != nil || usr == nil || !usr.Pending {
+		return nil
+	}
+
+	s.bg.Go(ctx, func(ctx context.Context) error {
+		var token string
+		err := dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+			if err := s.evdb.InvalidateByUserID(ctx, tx, usr.ID); err != nil {
+				return err
+			}
+			var err error
+			token, err = s.issueToken(ctx, tx, usr.ID)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error creating verification token: %w", err)
+		}
+		return s.mailer.SendEmailVerification(ctx, usr, token, VerificationTokenDuration)
+	})
+
+	return nil
+}
+
+// issueToken creates new verification token for the user, returns the raw token
+func (s *Registration) issueToken(ctx context.Context, tx *gorm.DB, uid int) (string, error) {
+	token := s.cr.UID()
+	rec := &model.EmailVerification{
+		UserID:    uid,
+		Token:     s.cr.HashToken(token),
+		ExpiresAt: time.Now().Add(VerificationTokenDuration),
+	}
+	if err := s.evdb.Create(ctx, tx, rec); err != nil {
+		return "", err
+	}
+	return token, nil
+}
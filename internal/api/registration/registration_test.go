@@ -0,0 +1,304 @@
+package registration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/api/auth"
+	"github.com/vuduongtp/go-core/internal/api/registration"
+	emailverificationdb "github.com/vuduongtp/go-core/internal/db/emailverification"
+	mfachallengedb "github.com/vuduongtp/go-core/internal/db/mfachallenge"
+	refreshtokendb "github.com/vuduongtp/go-core/internal/db/refreshtoken"
+	sessiondb "github.com/vuduongtp/go-core/internal/db/session"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+const password = "Sup3r-Secret!"
+
+// mailer records the emails sent
+type mailer struct {
+	tokens []string
+	exists []*model.User
+	taken  []*model.User
+}
+
+func (m *mailer) SendEmailVerification(_ context.Context, _ *model.User, token string, _ time.Duration) error {
+	m.tokens = append(m.tokens, token)
+	return nil
+}
+
+func (m *mailer) SendAccountExists(_ context.Context, u *model.User) error {
+	m.exists = append(m.exists, u)
+	return nil
+}
+
+func (m *mailer) SendUsernameTaken(_ context.Context, u *model.User) error {
+	m.taken = append(m.taken, u)
+	return nil
+}
+
+// token returns the token of the latest verification email
+func (m *mailer) token(t *testing.T) string {
+	t.Helper()
+	if !assert.NotEmpty(t, m.tokens, "verification email not sent") {
+		return ""
+	}
+	return m.tokens[len(m.tokens)-1]
+}
+
+// staleUserDB does not find the users by email for the given number of times,
+// as if they were registered concurrently after the lookup
+type staleUserDB struct {
+	*userdb.DB
+	misses int
+}
+
+func (d *staleUserDB) FindByEmail(ctx context.Context, db *gorm.DB, email string) (*model.User, error) {
+	if d.misses > 0 {
+		d.misses--
+		return nil, gorm.ErrRecordNotFound
+	}
+	return d.DB.FindByEmail(ctx, db, email)
+}
+
+type fixture struct {
+	db     *gorm.DB
+	svc    *registration.Registration
+	auth   *auth.Auth
+	mailer *mailer
+}
+
+func newFixture(t *testing.T, udb registration.UserDB) *fixture {
+	db := mock.DB(t, &model.User{}, &model.EmailVerification{}, &model.Session{}, &model.RefreshToken{}, &model.MFAChallenge{})
+	cr := mock.Crypter()
+	m := &mailer{}
+	lo := lockout.New(lockout.NewMemoryStore(), lockout.DefaultPolicy)
+	if udb == nil {
+		udb = userdb.NewDB()
+	}
+	return &fixture{
+		db:     db,
+		svc:    registration.New(db, udb, emailverificationdb.NewDB(), m, mock.Background{}, cr),
+		auth:   auth.New(db, userdb.NewDB(), refreshtokendb.NewDB(), sessiondb.NewDB(), mfachallengedb.NewDB(), jwt.New("HS256", "registration-test-secret", 900), cr, nil, lo, lo),
+		mailer: m,
+	}
+}
+
+func registerData(username, email string) registration.RegisterData {
+	return registration.RegisterData{
+		Username:  username,
+		Password:  password,
+		FirstName: "John",
+		LastName:  "Doe",
+		Email:     email,
+	}
+}
+
+func (f *fixture) login(username string) error {
+	_, err := f.auth.Authenticate(context.Background(), auth.Credentials{Username: username, Password: password})
+	return err
+}
+
+// count returns the number of users matching the conditions
+func (f *fixture) count(t *testing.T, query string, args ...interface{}) int64 {
+	t.Helper()
+	var count int64
+	assert.Nil(t, f.db.Model(&model.User{}).Where(query, args...).Count(&count).Error)
+	return count
+}
+
+func TestRegister(t *testing.T) {
+	cases := []struct {
+		name string
+		// misses is the number of times the registered emails are not found, see staleUserDB
+		misses int
+		data   registration.RegisterData
+		// wantCreated tells whether the pending user is created & the verification email is sent
+		wantCreated bool
+		// wantExists & wantTaken are the usernames of the account exists & username taken emails
+		wantExists string
+		wantTaken  string
+	}{
+		{
+			name:        "Success",
+			data:        registerData("janedoe", "jane@mail.com"),
+			wantCreated: true,
+		},
+		{
+			name:       "Email registered",
+			data:       registerData("janedoe", "john@mail.com"),
+			wantExists: "johndoe",
+		},
+		{
+			name:       "Email & username registered",
+			data:       registerData("johndoe", "john@mail.com"),
+			wantExists: "johndoe",
+		},
+		{
+			name:      "Username registered",
+			data:      registerData("johndoe", "jane@mail.com"),
+			wantTaken: "johndoe",
+		},
+		{
+			name:       "Email registered concurrently",
+			misses:     1,
+			data:       registerData("janedoe", "john@mail.com"),
+			wantExists: "johndoe",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, &staleUserDB{DB: userdb.NewDB(), misses: tt.misses})
+			ctx := context.Background()
+			hashedPwd, err := mock.Crypter().HashPassword(password)
+			assert.Nil(t, err)
+			assert.Nil(t, f.db.Create(&model.User{Username: "johndoe", Email: "john@mail.com", Password: hashedPwd, Role: model.RoleUser}).Error)
+
+			// the response does not tell whether the email or the username is registered
+			assert.Nil(t, f.svc.Register(ctx, tt.data))
+			assert.Equal(t, int64(1), f.count(t, "email = ?", "john@mail.com"))
+
+			if tt.wantExists != "" {
+				assert.Len(t, f.mailer.exists, 1)
+				assert.Equal(t, tt.wantExists, f.mailer.exists[0].Username)
+			} else {
+				assert.Empty(t, f.mailer.exists)
+			}
+			if tt.wantTaken != "" {
+				assert.Len(t, f.mailer.taken, 1)
+				assert.Equal(t, tt.wantTaken, f.mailer.taken[0].Username)
+				assert.Equal(t, tt.data.Email, f.mailer.taken[0].Email, "the registrant is told, not the owner of the username")
+			} else {
+				assert.Empty(t, f.mailer.taken)
+			}
+			if !tt.wantCreated {
+				assert.Empty(t, f.mailer.tokens)
+				assert.Equal(t, int64(1), f.count(t, "1 = 1"))
+				return
+			}
+
+			usr := &model.User{}
+			assert.Nil(t, f.db.Where("username = ?", tt.data.Username).First(usr).Error)
+			assert.True(t, usr.Pending)
+			assert.Equal(t, model.RoleUser, usr.Role)
+			assert.ErrorIs(t, f.login(tt.data.Username), auth.ErrEmailNotVerified, "pending users cannot login")
+
+			assert.Nil(t, f.svc.VerifyEmail(ctx, registration.VerifyEmailData{Token: f.mailer.token(t)}))
+			assert.Nil(t, f.db.First(usr, usr.ID).Error)
+			assert.False(t, usr.Pending)
+			assert.NotNil(t, usr.EmailVerifiedAt)
+			assert.Nil(t, f.login(tt.data.Username))
+		})
+	}
+}
+
+func TestEmailUnique(t *testing.T) {
+	f := newFixture(t, nil)
+	assert.Nil(t, f.db.Create(&model.User{Username: "johndoe", Email: "john@mail.com", Password: "hashed"}).Error)
+	assert.NotNil(t, f.db.Create(&model.User{Username: "janedoe", Email: "john@mail.com", Password: "hashed"}).Error)
+
+	// the email of a deleted user can be registered again
+	assert.Nil(t, f.db.Where("username = ?", "johndoe").Delete(&model.User{}).Error)
+	assert.Nil(t, f.db.Create(&model.User{Username: "janedoe", Email: "john@mail.com", Password: "hashed"}).Error)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	cases := []struct {
+		name string
+		// prepare returns the token to verify, given the token of the verification email
+		prepare func(t *testing.T, f *fixture, token string) string
+		wantErr error
+		// wantLoginErr is the error of the login after the verification
+		wantLoginErr error
+	}{
+		{
+			name:    "Success",
+			prepare: func(_ *testing.T, _ *fixture, token string) string { return token },
+		},
+		{
+			name:         "Unknown token",
+			prepare:      func(_ *testing.T, _ *fixture, _ string) string { return "unknown" },
+			wantErr:      registration.ErrInvalidVerificationToken,
+			wantLoginErr: auth.ErrEmailNotVerified,
+		},
+		{
+			name: "Used token",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				assert.Nil(t, f.svc.VerifyEmail(context.Background(), registration.VerifyEmailData{Token: token}))
+				return token
+			},
+			wantErr: registration.ErrInvalidVerificationToken,
+		},
+		{
+			name: "Expired token",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				assert.Nil(t, f.db.Model(&model.EmailVerification{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute)).Error)
+				return token
+			},
+			wantErr:      registration.ErrInvalidVerificationToken,
+			wantLoginErr: auth.ErrEmailNotVerified,
+		},
+		{
+			name: "Superseded by a new link",
+			prepare: func(t *testing.T, f *fixture, token string) string {
+				assert.Nil(t, f.svc.ResendVerification(context.Background(), registration.ResendVerificationData{Email: "john@mail.com"}))
+				assert.NotEqual(t, token, f.mailer.token(t))
+				return token
+			},
+			wantErr:      registration.ErrInvalidVerificationToken,
+			wantLoginErr: auth.ErrEmailNotVerified,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, nil)
+			ctx := context.Background()
+			assert.Nil(t, f.svc.Register(ctx, registerData("johndoe", "john@mail.com")))
+
+			err := f.svc.VerifyEmail(ctx, registration.VerifyEmailData{Token: tt.prepare(t, f, f.mailer.token(t))})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.ErrorIs(t, f.login("johndoe"), tt.wantLoginErr)
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	cases := []struct {
+		name     string
+		email    string
+		verified bool
+		wantSent bool
+	}{
+		{name: "Pending user", email: "john@mail.com", wantSent: true},
+		{name: "Verified user", email: "john@mail.com", verified: true},
+		{name: "Unknown email", email: "unknown@mail.com"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, nil)
+			ctx := context.Background()
+			assert.Nil(t, f.svc.Register(ctx, registerData("johndoe", "john@mail.com")))
+			first := f.mailer.token(t)
+			if tt.verified {
+				assert.Nil(t, f.svc.VerifyEmail(ctx, registration.VerifyEmailData{Token: first}))
+			}
+
+			// the same response for pending, verified & unknown emails
+			assert.Nil(t, f.svc.ResendVerification(ctx, registration.ResendVerificationData{Email: tt.email}))
+			if !tt.wantSent {
+				assert.Len(t, f.mailer.tokens, 1)
+				return
+			}
+			assert.Len(t, f.mailer.tokens, 2)
+			assert.Nil(t, f.svc.VerifyEmail(ctx, registration.VerifyEmailData{Token: f.mailer.token(t)}))
+		})
+	}
+}
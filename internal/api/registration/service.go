@@ -0,0 +1,67 @@
+package registration
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/util/background"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// New creates new registration application service, the emails are sent by the background worker bg
+func New(db *gorm.DB, udb UserDB, evdb EmailVerificationDB, mailer Mailer, bg Background, cr Crypter) *Registration {
+	return &Registration{
+		db:     db,
+		udb:    udb,
+		evdb:   evdb,
+		mailer: mailer,
+		bg:     bg,
+		cr:     cr,
+	}
+}
+
+// Registration represents registration application service
+type Registration struct {
+	db     *gorm.DB
+	udb    UserDB
+	evdb   EmailVerificationDB
+	mailer Mailer
+	bg     Background
+	cr     Crypter
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	dbutil.Intf
+	FindByEmail(context.Context, *gorm.DB, string) (*model.User, error)
+}
+
+// EmailVerificationDB represents email verification repository interface
+type EmailVerificationDB interface {
+	dbutil.Intf
+	FindByToken(context.Context, *gorm.DB, string) (*model.EmailVerification, error)
+	MarkUsed(context.Context, *gorm.DB, int) (bool, error)
+	InvalidateByUserID(context.Context, *gorm.DB, int) error
+}
+
+// Mailer represents email sending interface
+type Mailer interface {
+	SendEmailVerification(context.Context, *model.User, string, time.Duration) error
+	SendAccountExists(context.Context, *model.User) error
+	SendUsernameTaken(context.Context, *model.User) error
+}
+
+// Background represents background worker interface
+type Background interface {
+	Go(context.Context, background.Task) bool
+}
+
+// Crypter represents security interface
+type Crypter interface {
+	HashPassword(string) (string, error)
+	UID() string
+	HashToken(string) string
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/server"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents session http service
+type HTTP struct {
+	svc  Service
+	auth model.Auth
+}
+
+// Service represents session application interface
+type Service interface {
+	List(context.Context, *model.AuthUser, int) ([]*model.Session, error)
+	Delete(context.Context, *model.AuthUser, int, int) error
+}
+
+// NewHTTP creates new session http service, the routes are registered on the users group
+func NewHTTP(svc Service, auth model.Auth, eg *echo.Group) {
+	h := HTTP{svc, auth}
+
+	eg.GET("/me/sessions", h.listMine)
+	eg.DELETE("/me/sessions/:sid", h.deleteMine)
+	eg.GET("/:id/sessions", h.list, rbac.Owned(model.ObjectUser, model.ActionManageSessions))
+	eg.DELETE("/:id/sessions/:sid", h.delete, rbac.Owned(model.ObjectUser, model.ActionManageSessions))
+}
+
+// ListResp contains list of sessions
+type ListResp struct {
+	Data []*model.Session `json:"data"`
+}
+
+// @Security		BearerToken
+// @Summary		Returns current user's sessions
+// @Description	Returns the devices the current user is logged in from
+// @Accept			json
+// @Produce		json
+// @Tags			sessions
+// @ID				sessionsListMine
+// @Success		200						{object}	session.ListResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/sessions	[get]
+func (h *HTTP) listMine(c echo.Context) error {
+	authUsr := h.auth.User(c)
+	resp, err := h.svc.List(c.Request().Context(), authUsr, authUsr.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ListResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Ends a session of current user
+// @Description	Logs the current user out of the device of the session
+// @Accept			json
+// @Produce		json
+// @Tags			sessions
+// @ID				sessionsDeleteMine
+// @Param			sid								path		int	true	"Session ID"
+// @Success		200								{object}	SwaggOKResp
+// @Failure		400								{object}	SwaggErrDetailsResp
+// @Failure		401								{object}	SwaggErrDetailsResp
+// @Failure		403								{object}	SwaggErrDetailsResp
+// @Failure		500								{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/sessions/{sid}	[delete]
+func (h *HTTP) deleteMine(c echo.Context) error {
+	sid, err := reqSessionID(c)
+	if err != nil {
+		return err
+	}
+	authUsr := h.auth.User(c)
+	if err := h.svc.Delete(c.Request().Context(), authUsr, authUsr.ID, sid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Security		BearerToken
+// @Summary		Returns user's sessions
+// @Description	Returns the devices the user is logged in from
+// @Accept			json
+// @Produce		json
+// @Tags			sessions
+// @ID				sessionsList
+// @Param			id							path		int	true	"User ID"
+// @Success		200							{object}	session.ListResp
+// @Failure		400							{object}	SwaggErrDetailsResp
+// @Failure		401							{object}	SwaggErrDetailsResp
+// @Failure		403							{object}	SwaggErrDetailsResp
+// @Failure		500							{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "manage_sessions", "owned": true}
+// @Router			/v1/users/{id}/sessions	[get]
+func (h *HTTP) list(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	resp, err := h.svc.List(c.Request().Context(), h.auth.User(c), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ListResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Ends a session of user
+// @Description	Logs the user out of the device of the session
+// @Accept			json
+// @Produce		json
+// @Tags			sessions
+// @ID				sessionsDelete
+// @Param			id									path		int	true	"User ID"
+// @Param			sid									path		int	true	"Session ID"
+// @Success		200									{object}	SwaggOKResp
+// @Failure		400									{object}	SwaggErrDetailsResp
+// @Failure		401									{object}	SwaggErrDetailsResp
+// @Failure		403									{object}	SwaggErrDetailsResp
+// @Failure		500									{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "manage_sessions", "owned": true}
+// @Router			/v1/users/{id}/sessions/{sid}	[delete]
+func (h *HTTP) delete(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	sid, err := reqSessionID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.svc.Delete(c.Request().Context(), h.auth.User(c), id, sid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// reqSessionID returns the session ID url parameter
+func reqSessionID(c echo.Context) (int, error) {
+	sid, err := strconv.Atoi(c.Param("sid"))
+	if err != nil {
+		return 0, server.NewHTTPValidationError("Invalid session ID")
+	}
+	return sid, nil
+}
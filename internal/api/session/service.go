@@ -0,0 +1,48 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// New creates new session application service
+func New(db *gorm.DB, sdb SessionDB, rtdb RefreshTokenDB, jwt JWT, rbacSvc rbac.Intf) *Session {
+	return &Session{
+		db:   db,
+		sdb:  sdb,
+		rtdb: rtdb,
+		jwt:  jwt,
+		rbac: rbacSvc,
+	}
+}
+
+// Session represents session application service
+type Session struct {
+	db   *gorm.DB
+	sdb  SessionDB
+	rtdb RefreshTokenDB
+	jwt  JWT
+	rbac rbac.Intf
+}
+
+// SessionDB represents session repository interface
+type SessionDB interface {
+	dbutil.Intf
+	ListActiveByUserID(context.Context, *gorm.DB, int) ([]*model.Session, error)
+}
+
+// RefreshTokenDB represents refresh token repository interface
+type RefreshTokenDB interface {
+	RevokeFamily(context.Context, *gorm.DB, string) error
+}
+
+// JWT represents token revocation interface
+type JWT interface {
+	RevokeToken(context.Context, string, time.Time) error
+}
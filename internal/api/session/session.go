@@ -0,0 +1,84 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+)
+
+// Custom errors
+var (
+	ErrSessionNotFound  = server.NewHTTPError(http.StatusBadRequest, "SESSION_NOTFOUND", "Session not found")
+	ErrAPIKeyNotAllowed = server.NewHTTPError(http.StatusForbidden, "API_KEY_NOT_ALLOWED", "This action is not allowed using an API key")
+	ErrImpersonated     = server.NewHTTPError(http.StatusForbidden, "IMPERSONATION_NOT_ALLOWED", "This action is not allowed while impersonating another user")
+)
+
+// List returns the active sessions of the given user, the session of the request is marked as current
+func (s *Session) List(ctx context.Context, authUsr *model.AuthUser, uid int) ([]*model.Session, error) {
+	if err := s.enforce(authUsr, uid); err != nil {
+		return nil, err
+	}
+
+	data, err := s.sdb.ListActiveByUserID(ctx, s.db, uid)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error listing sessions").SetInternal(err)
+	}
+	for _, sess := range data {
+		sess.Current = sess.ID == authUsr.SessionID
+	}
+
+	return data, nil
+}
+
+// Delete ends a session of the given user, its refresh tokens and latest access token are revoked.
+// Not allowed from an impersonated session, so that support staff cannot log the user out.
+func (s *Session) Delete(ctx context.Context, authUsr *model.AuthUser, uid, id int) error {
+	if authUsr.IsImpersonated() {
+		return ErrImpersonated
+	}
+	if err := s.enforce(authUsr, uid); err != nil {
+		return err
+	}
+
+	rec := new(model.Session)
+	if err := s.sdb.View(ctx, s.db, rec, "id = ? AND user_id = ?", id, uid); err != nil {
+		return ErrSessionNotFound.SetInternal(err)
+	}
+
+	if err := s.rtdb.RevokeFamily(ctx, s.db, rec.Family); err != nil {
+		return server.NewHTTPInternalError("Error revoking refresh token").SetInternal(err)
+	}
+	if rec.TokenID != "" && rec.TokenExpiresAt.After(time.Now()) {
+		if err := s.jwt.RevokeToken(ctx, rec.TokenID, rec.TokenExpiresAt); err != nil {
+			return server.NewHTTPInternalError("Error revoking token").SetInternal(err)
+		}
+	}
+
+	logger.LogSecurityEvent(ctx, "session_revoked", map[string]interface{}{
+		"user_id":    uid,
+		"session_id": rec.ID,
+		"actor_id":   authUsr.ID,
+	})
+
+	return nil
+}
+
+// enforce checks user permission to manage the sessions of the given user, users may always manage their own sessions
+// but not by API key, which is not scoped for it
+func (s *Session) enforce(authUsr *model.AuthUser, uid int) error {
+	if uid == authUsr.ID {
+		if authUsr.APIKeyID != 0 {
+			return ErrAPIKeyNotAllowed
+		}
+		return nil
+	}
+	if !authUsr.Enforce(s.rbac, model.ObjectUser, model.ActionManageSessions) {
+		return rbac.ErrForbiddenAction
+	}
+	return nil
+}
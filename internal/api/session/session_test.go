@@ -0,0 +1,260 @@
+package session_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/api/auth"
+	"github.com/vuduongtp/go-core/internal/api/session"
+	mfachallengedb "github.com/vuduongtp/go-core/internal/db/mfachallenge"
+	refreshtokendb "github.com/vuduongtp/go-core/internal/db/refreshtoken"
+	sessiondb "github.com/vuduongtp/go-core/internal/db/session"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// enforcer allows the admin role to manage the sessions of any user
+type enforcer struct{}
+
+func (enforcer) Enforce(rvals ...interface{}) (bool, error) {
+	return rvals[0] == model.RoleAdmin && rvals[2] == model.ObjectUser && rvals[3] == model.ActionManageSessions, nil
+}
+
+// revoker records the revoked access tokens
+type revoker struct {
+	revoked []string
+}
+
+func (r *revoker) RevokeToken(_ context.Context, id string, _ time.Time) error {
+	r.revoked = append(r.revoked, id)
+	return nil
+}
+
+type fixture struct {
+	db      *gorm.DB
+	svc     *session.Session
+	auth    *auth.Auth
+	revoker *revoker
+	user    *model.User
+	other   *model.User
+}
+
+func newFixture(t *testing.T) *fixture {
+	db := mock.DB(t, &model.User{}, &model.Session{}, &model.RefreshToken{}, &model.MFAChallenge{})
+	f := &fixture{
+		db:      db,
+		revoker: &revoker{},
+		user:    &model.User{Username: "johndoe", Email: "john@mail.com", Password: "-", Role: model.RoleUser},
+		other:   &model.User{Username: "janedoe", Email: "jane@mail.com", Password: "-", Role: model.RoleUser},
+	}
+	assert.Nil(t, db.Create(f.user).Error)
+	assert.Nil(t, db.Create(f.other).Error)
+
+	sdb, rtdb := sessiondb.NewDB(), refreshtokendb.NewDB()
+	lo := lockout.New(lockout.NewMemoryStore(), lockout.DefaultPolicy)
+	f.auth = auth.New(db, userdb.NewDB(), rtdb, sdb, mfachallengedb.NewDB(), jwt.New("HS256", "session-test-secret", 900), mock.Crypter(), nil, lo, lo)
+	f.svc = session.New(db, sdb, rtdb, f.revoker, enforcer{})
+	return f
+}
+
+// login logs the user in from the given device, returns the refresh token & the recorded session
+func (f *fixture) login(t *testing.T, usr *model.User, userAgent string) (string, *model.Session) {
+	t.Helper()
+	ctx := httputil.WithClientInfo(context.Background(), httputil.ClientInfo{IP: "10.0.0.1", UserAgent: userAgent})
+	token, err := f.auth.LoginUser(ctx, usr)
+	assert.Nil(t, err)
+
+	rt := &model.RefreshToken{}
+	assert.Nil(t, f.db.Order("id DESC").First(rt).Error)
+	sess := &model.Session{}
+	assert.Nil(t, f.db.Where("family = ?", rt.Family).First(sess).Error)
+	return token.RefreshToken, sess
+}
+
+func (f *fixture) authUser(usr *model.User, sessionID int) *model.AuthUser {
+	return &model.AuthUser{ID: usr.ID, Username: usr.Username, Role: usr.Role, SessionID: sessionID}
+}
+
+func TestLoginRecordsSession(t *testing.T) {
+	f := newFixture(t)
+
+	_, sess := f.login(t, f.user, "Firefox")
+	assert.Equal(t, f.user.ID, sess.UserID)
+	assert.Equal(t, "Firefox", sess.UserAgent)
+	assert.Equal(t, "10.0.0.1", sess.IP)
+	assert.NotEmpty(t, sess.TokenID, "the access token is tracked to be revoked with the session")
+	assert.True(t, sess.TokenExpiresAt.After(time.Now()))
+}
+
+// admin is the auth user allowed to manage the sessions of any user, see enforcer
+var admin = &model.AuthUser{ID: 100, Username: "admin", Role: model.RoleAdmin}
+
+func TestList(t *testing.T) {
+	cases := []struct {
+		name string
+		// prepare logs the users in, returns the auth user, the user whose sessions are listed & the IDs of the listed sessions
+		prepare func(t *testing.T, f *fixture) (*model.AuthUser, int, []int)
+		wantErr error
+	}{
+		{
+			name: "Current session",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, []int) {
+				_, current := f.login(t, f.user, "Firefox")
+				_, other := f.login(t, f.user, "Chrome")
+				return f.authUser(f.user, current.ID), f.user.ID, []int{other.ID, current.ID}
+			},
+		},
+		{
+			name: "Active sessions only",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, []int) {
+				ctx := context.Background()
+				rotated, active := f.login(t, f.user, "rotated")
+				_, revoked := f.login(t, f.user, "revoked")
+				_, used := f.login(t, f.user, "used")
+				_, expired := f.login(t, f.user, "expired")
+				f.login(t, f.other, "other")
+
+				// the family stays active after the rotation of its refresh token
+				_, err := f.auth.RefreshToken(ctx, auth.RefreshTokenData{RefreshToken: rotated})
+				assert.Nil(t, err)
+				assert.Nil(t, refreshtokendb.NewDB().RevokeFamily(ctx, f.db, revoked.Family))
+				// the latest token of the family has been used without being rotated, i.e. the family has ended
+				assert.Nil(t, f.db.Model(&model.RefreshToken{}).Where("family = ?", used.Family).Update("used_at", time.Now()).Error)
+				assert.Nil(t, f.db.Model(&model.RefreshToken{}).Where("family = ?", expired.Family).Update("expires_at", time.Now()).Error)
+				return f.authUser(f.user, 0), f.user.ID, []int{active.ID}
+			},
+		},
+		{
+			name: "Sessions of another user",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, []int) {
+				f.login(t, f.other, "other")
+				return f.authUser(f.user, 0), f.other.ID, nil
+			},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name: "Sessions of another user by admin",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, []int) {
+				_, sess := f.login(t, f.other, "other")
+				return admin, f.other.ID, []int{sess.ID}
+			},
+		},
+		{
+			name: "API key",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, []int) {
+				f.login(t, f.user, "Firefox")
+				// API keys are not scoped for the own sessions
+				keyUsr := f.authUser(f.user, 0)
+				keyUsr.APIKeyID = 1
+				return keyUsr, f.user.ID, nil
+			},
+			wantErr: session.ErrAPIKeyNotAllowed,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			authUsr, uid, wantIDs := tt.prepare(t, f)
+
+			data, err := f.svc.List(context.Background(), authUsr, uid)
+			assert.ErrorIs(t, err, tt.wantErr)
+			ids := []int{}
+			for _, sess := range data {
+				ids = append(ids, sess.ID)
+				assert.Equal(t, authUsr.SessionID != 0 && sess.ID == authUsr.SessionID, sess.Current, sess.UserAgent)
+			}
+			if wantIDs == nil {
+				wantIDs = []int{}
+			}
+			assert.ElementsMatch(t, wantIDs, ids)
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := []struct {
+		name string
+		// prepare logs the users in, returns the auth user, the user whose session is deleted & the session
+		prepare func(t *testing.T, f *fixture) (*model.AuthUser, int, *model.Session)
+		wantErr error
+	}{
+		{
+			name: "Own session",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, *model.Session) {
+				_, sess := f.login(t, f.user, "Firefox")
+				_, current := f.login(t, f.user, "Chrome")
+				return f.authUser(f.user, current.ID), f.user.ID, sess
+			},
+		},
+		{
+			name: "Impersonated",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, *model.Session) {
+				_, sess := f.login(t, f.user, "Firefox")
+				// support staff impersonating the user cannot log the user out
+				impersonated := f.authUser(f.user, 0)
+				impersonated.ActorID = 100
+				return impersonated, f.user.ID, sess
+			},
+			wantErr: session.ErrImpersonated,
+		},
+		{
+			name: "Session of another user through the own sessions",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, *model.Session) {
+				_, sess := f.login(t, f.other, "other")
+				return f.authUser(f.user, 0), f.user.ID, sess
+			},
+			wantErr: session.ErrSessionNotFound,
+		},
+		{
+			name: "Session of another user",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, *model.Session) {
+				_, sess := f.login(t, f.other, "other")
+				return f.authUser(f.user, 0), f.other.ID, sess
+			},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name: "Session of another user by admin",
+			prepare: func(t *testing.T, f *fixture) (*model.AuthUser, int, *model.Session) {
+				_, sess := f.login(t, f.other, "other")
+				return admin, f.other.ID, sess
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			ctx := context.Background()
+			authUsr, uid, sess := tt.prepare(t, f)
+
+			err := f.svc.Delete(ctx, authUsr, uid, sess.ID)
+			assert.ErrorIs(t, err, tt.wantErr)
+
+			var active int64
+			assert.Nil(t, f.db.Model(&model.RefreshToken{}).Where("family = ? AND revoked_at IS NULL", sess.Family).Count(&active).Error)
+			if tt.wantErr != nil {
+				assert.Empty(t, f.revoker.revoked)
+				assert.Equal(t, int64(1), active, "the session is kept")
+				return
+			}
+			// the access token & the refresh tokens of the session are revoked
+			assert.Equal(t, []string{sess.TokenID}, f.revoker.revoked)
+			assert.Zero(t, active)
+
+			data, err := f.svc.List(ctx, admin, sess.UserID)
+			assert.Nil(t, err)
+			for _, rec := range data {
+				assert.NotEqual(t, sess.ID, rec.ID)
+			}
+		})
+	}
+}
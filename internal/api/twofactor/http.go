@@ -0,0 +1,158 @@
+package twofactor
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/util/totp"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTP represents two-factor authentication http service
+type HTTP struct {
+	svc  Service
+	auth model.Auth
+}
+
+// Service represents two-factor authentication application interface
+type Service interface {
+	Enroll(context.Context, *model.AuthUser, PasswordData) (*totp.Key, error)
+	Enable(context.Context, *model.AuthUser, CodeData) ([]string, error)
+	Disable(context.Context, *model.AuthUser, PasswordData) error
+	RegenerateRecoveryCodes(context.Context, *model.AuthUser, CodeData) ([]string, error)
+}
+
+// NewHTTP creates new two-factor authentication http service
+func NewHTTP(svc Service, auth model.Auth, eg *echo.Group) {
+	h := HTTP{svc, auth}
+
+	eg.POST("", h.enroll)
+	eg.POST("/verify", h.enable)
+	eg.DELETE("", h.disable)
+	eg.POST("/recovery-codes", h.regenerateRecoveryCodes)
+}
+
+// CodeData contains the two-factor authentication code from json request
+type CodeData struct {
+	// TOTP code from the authenticator app, or a recovery code where allowed
+	Code string `json:"code" validate:"required" example:"123456"`
+}
+
+// PasswordData contains the password confirmation from json request
+type PasswordData struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// RecoveryCodesResp contains the recovery codes response
+type RecoveryCodesResp struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// @Security		BearerToken
+// @Summary		Starts two-factor authentication enrollment
+// @Description	Generates a new TOTP secret, returns the otpauth URI and its QR code to be scanned by authenticator apps. The password is required
+// @Accept			json
+// @Produce		json
+// @Tags			2fa
+// @ID				twoFactorEnroll
+// @Param			request				body		twofactor.PasswordData	true	"PasswordData"
+// @Success		200					{object}	totp.Key
+// @Failure		400					{object}	SwaggErrDetailsResp
+// @Failure		401					{object}	SwaggErrDetailsResp
+// @Failure		403					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/2fa	[post]
+func (h *HTTP) enroll(c echo.Context) error {
+	r := PasswordData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Enroll(c.Request().Context(), h.auth.User(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// @Security		BearerToken
+// @Summary		Enables two-factor authentication
+// @Description	Verifies the first code of the enrolled secret, returns the recovery codes which are shown only once
+// @Accept			json
+// @Produce		json
+// @Tags			2fa
+// @ID				twoFactorEnable
+// @Param			request						body		twofactor.CodeData	true	"CodeData"
+// @Success		200							{object}	twofactor.RecoveryCodesResp
+// @Failure		400							{object}	SwaggErrDetailsResp
+// @Failure		401							{object}	SwaggErrDetailsResp
+// @Failure		403							{object}	SwaggErrDetailsResp
+// @Failure		500							{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/2fa/verify	[post]
+func (h *HTTP) enable(c echo.Context) error {
+	r := CodeData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.Enable(c.Request().Context(), h.auth.User(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, RecoveryCodesResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Disables two-factor authentication
+// @Description	Disables two-factor authentication and deletes the recovery codes, the password is required
+// @Accept			json
+// @Produce		json
+// @Tags			2fa
+// @ID				twoFactorDisable
+// @Param			request				body		twofactor.PasswordData	true	"PasswordData"
+// @Success		200					{object}	SwaggOKResp
+// @Failure		400					{object}	SwaggErrDetailsResp
+// @Failure		401					{object}	SwaggErrDetailsResp
+// @Failure		403					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/2fa	[delete]
+func (h *HTTP) disable(c echo.Context) error {
+	r := PasswordData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	if err := h.svc.Disable(c.Request().Context(), h.auth.User(c), r); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Security		BearerToken
+// @Summary		Regenerates recovery codes
+// @Description	Replaces all recovery codes, the current two-factor authentication code is required
+// @Accept			json
+// @Produce		json
+// @Tags			2fa
+// @ID				twoFactorRecoveryCodes
+// @Param			request								body		twofactor.CodeData	true	"CodeData"
+// @Success		200									{object}	twofactor.RecoveryCodesResp
+// @Failure		400									{object}	SwaggErrDetailsResp
+// @Failure		401									{object}	SwaggErrDetailsResp
+// @Failure		403									{object}	SwaggErrDetailsResp
+// @Failure		500									{object}	SwaggErrDetailsResp
+// @Router			/v1/users/me/2fa/recovery-codes	[post]
+func (h *HTTP) regenerateRecoveryCodes(c echo.Context) error {
+	r := CodeData{}
+	if err := c.Bind(&r); err != nil {
+		return err
+	}
+	resp, err := h.svc.RegenerateRecoveryCodes(c.Request().Context(), h.auth.User(c), r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, RecoveryCodesResp{resp})
+}
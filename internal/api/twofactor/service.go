@@ -0,0 +1,64 @@
+package twofactor
+
+import (
+	"context"
+	"time"
+
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/totp"
+
+	"gorm.io/gorm"
+)
+
+// New creates new two-factor authentication application service, the TOTP secrets are encrypted at rest by secrets
+func New(db *gorm.DB, udb UserDB, rcdb RecoveryCodeDB, totpSvc TOTP, cr Crypter, secrets Cipher) *TwoFactor {
+	return &TwoFactor{
+		db:      db,
+		udb:     udb,
+		rcdb:    rcdb,
+		totp:    totpSvc,
+		cr:      cr,
+		secrets: secrets,
+	}
+}
+
+// TwoFactor represents two-factor authentication application service
+type TwoFactor struct {
+	db   *gorm.DB
+	udb  UserDB
+	rcdb RecoveryCodeDB
+	totp TOTP
+	cr   Crypter
+
+	secrets Cipher
+}
+
+// UserDB represents user repository interface
+type UserDB interface {
+	dbutil.Intf
+	UpdateTOTPCounter(context.Context, *gorm.DB, int, int64) (bool, error)
+}
+
+// RecoveryCodeDB represents recovery code repository interface
+type RecoveryCodeDB interface {
+	dbutil.Intf
+	MarkUsed(context.Context, *gorm.DB, int, string) (bool, error)
+}
+
+// TOTP represents time-based one-time password interface
+type TOTP interface {
+	Generate(string) (*totp.Key, error)
+	Validate(string, string, time.Time) (int64, bool)
+}
+
+// Cipher represents encryption interface
+type Cipher interface {
+	Encrypt(string) (string, error)
+	Decrypt(string) (string, error)
+}
+
+// Crypter represents security interface
+type Crypter interface {
+	CompareHashAndPassword(string, string) bool
+	HashToken(string) string
+}
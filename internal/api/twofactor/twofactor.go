@@ -0,0 +1,256 @@
+package twofactor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+	"github.com/vuduongtp/go-core/pkg/util/totp"
+
+	"gorm.io/gorm"
+)
+
+// Custom errors
+var (
+	ErrAlreadyEnabled    = server.NewHTTPError(http.StatusBadRequest, "2FA_ALREADY_ENABLED", "Two-factor authentication is already enabled")
+	ErrNotEnabled        = server.NewHTTPError(http.StatusBadRequest, "2FA_NOT_ENABLED", "Two-factor authentication is not enabled")
+	ErrNotEnrolled       = server.NewHTTPError(http.StatusBadRequest, "2FA_NOT_ENROLLED", "Two-factor authentication enrollment has not been started")
+	ErrInvalidCode       = server.NewHTTPError(http.StatusBadRequest, "INVALID_2FA_CODE", "Invalid two-factor authentication code")
+	ErrIncorrectPassword = server.NewHTTPError(http.StatusBadRequest, "INCORRECT_PASSWORD", "Incorrect password")
+	ErrUserNotFound      = server.NewHTTPError(http.StatusBadRequest, "USER_NOTFOUND", "User not found")
+	ErrImpersonated      = server.NewHTTPError(http.StatusForbidden, "IMPERSONATION_NOT_ALLOWED", "This action is not allowed while impersonating another user")
+	ErrAPIKeyNotAllowed  = server.NewHTTPError(http.StatusForbidden, "API_KEY_NOT_ALLOWED", "This action is not allowed using an API key")
+)
+
+// RecoveryCodeCount is the number of recovery codes issued to an user
+const RecoveryCodeCount = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Enroll starts the enrollment of the authenticated user, returns the secret to be added to authenticator apps.
+// The password is required, the two-factor authentication is not enabled until the first code is verified, see Enable.
+func (s *TwoFactor) Enroll(ctx context.Context, authUsr *model.AuthUser, data PasswordData) (*totp.Key, error) {
+	if authUsr.APIKeyID != 0 {
+		return nil, ErrAPIKeyNotAllowed
+	}
+	if authUsr.IsImpersonated() {
+		return nil, ErrImpersonated
+	}
+	usr, err := s.user(ctx, authUsr.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.cr.CompareHashAndPassword(usr.Password, data.Password) {
+		return nil, ErrIncorrectPassword
+	}
+	if usr.TOTPEnabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	key, err := s.totp.Generate(usr.Username)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error generating secret").SetInternal(err)
+	}
+
+	secret, err := s.secrets.Encrypt(key.Secret)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error encrypting secret").SetInternal(err)
+	}
+	updates := map[string]interface{}{"totp_secret": secret, "totp_last_counter": 0}
+	if err := s.udb.Update(ctx, s.db, updates, usr.ID); err != nil {
+		return nil, server.NewHTTPInternalError("Error updating user").SetInternal(err)
+	}
+
+	return key, nil
+}
+
+// Enable verifies the first code of the pending secret and enables the two-factor authentication.
+// Returns the recovery codes, they are shown only once.
+func (s *TwoFactor) Enable(ctx context.Context, authUsr *model.AuthUser, data CodeData) ([]string, error) {
+	if authUsr.APIKeyID != 0 {
+		return nil, ErrAPIKeyNotAllowed
+	}
+	if authUsr.IsImpersonated() {
+		return nil, ErrImpersonated
+	}
+	usr, err := s.user(ctx, authUsr.ID)
+	if err != nil {
+		return nil, err
+	}
+	if usr.TOTPEnabled {
+		return nil, ErrAlreadyEnabled
+	}
+	if usr.TOTPSecret == "" {
+		return nil, ErrNotEnrolled
+	}
+
+	secret, err := s.secrets.Decrypt(usr.TOTPSecret)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error decrypting secret").SetInternal(err)
+	}
+	counter, ok := s.totp.Validate(secret, strings.TrimSpace(data.Code), time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	var codes []string
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"totp_enabled": true, "totp_last_counter": counter}
+		if err := s.udb.Update(ctx, tx, updates, usr.ID); err != nil {
+			return err
+		}
+		codes, err = s.replaceRecoveryCodes(ctx, tx, usr.ID)
+		return err
+	})
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error enabling two-factor authentication").SetInternal(err)
+	}
+
+	logger.LogSecurityEvent(ctx, "2fa_enabled", map[string]interface{}{"user_id": usr.ID})
+	return codes, nil
+}
+
+// Disable disables the two-factor authentication of the authenticated user
+func (s *TwoFactor) Disable(ctx context.Context, authUsr *model.AuthUser, data PasswordData) error {
+	if authUsr.APIKeyID != 0 {
+		return ErrAPIKeyNotAllowed
+	}
+	if authUsr.IsImpersonated() {
+		return ErrImpersonated
+	}
+	usr, err := s.user(ctx, authUsr.ID)
+	if err != nil {
+		return err
+	}
+	if !s.cr.CompareHashAndPassword(usr.Password, data.Password) {
+		return ErrIncorrectPassword
+	}
+	if !usr.TOTPEnabled && usr.TOTPSecret == "" {
+		return ErrNotEnabled
+	}
+
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_counter": 0}
+		if err := s.udb.Update(ctx, tx, updates, usr.ID); err != nil {
+			return err
+		}
+		return s.rcdb.DeletePermanently(ctx, tx, "user_id = ?", usr.ID)
+	})
+	if err != nil {
+		return server.NewHTTPInternalError("Error disabling two-factor authentication").SetInternal(err)
+	}
+
+	logger.LogSecurityEvent(ctx, "2fa_disabled", map[string]interface{}{"user_id": usr.ID})
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the authenticated user, the current code is required
+func (s *TwoFactor) RegenerateRecoveryCodes(ctx context.Context, authUsr *model.AuthUser, data CodeData) ([]string, error) {
+	if authUsr.APIKeyID != 0 {
+		return nil, ErrAPIKeyNotAllowed
+	}
+	if authUsr.IsImpersonated() {
+		return nil, ErrImpersonated
+	}
+	usr, err := s.user(ctx, authUsr.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !usr.TOTPEnabled {
+		return nil, ErrNotEnabled
+	}
+
+	var codes []string
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		ok, err := s.Verify(ctx, tx, usr, data.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+		codes, err = s.replaceRecoveryCodes(ctx, tx, usr.ID)
+		return err
+	})
+	if errors.Is(err, ErrInvalidCode) {
+		return nil, ErrInvalidCode
+	}
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error generating recovery codes").SetInternal(err)
+	}
+
+	return codes, nil
+}
+
+// Verify checks the second factor of the user, either a TOTP code or an unused recovery code.
+// Each TOTP code and recovery code is accepted only once, it is consumed on the given db, which may be a transaction.
+func (s *TwoFactor) Verify(ctx context.Context, db *gorm.DB, usr *model.User, code string) (bool, error) {
+	if !usr.TOTPEnabled {
+		return false, nil
+	}
+
+	secret, err := s.secrets.Decrypt(usr.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+	code = strings.TrimSpace(code)
+	if counter, ok := s.totp.Validate(secret, code, time.Now()); ok {
+		return s.udb.UpdateTOTPCounter(ctx, db, usr.ID, counter)
+	}
+
+	ok, err := s.rcdb.MarkUsed(ctx, db, usr.ID, s.cr.HashToken(normalizeRecoveryCode(code)))
+	if err != nil || !ok {
+		return false, err
+	}
+
+	logger.LogSecurityEvent(ctx, "recovery_code_used", map[string]interface{}{"user_id": usr.ID})
+	return true, nil
+}
+
+// user returns the user of the given ID
+func (s *TwoFactor) user(ctx context.Context, id int) (*model.User, error) {
+	rec := new(model.User)
+	if err := s.udb.View(ctx, s.db, rec, id); err != nil {
+		return nil, ErrUserNotFound.SetInternal(err)
+	}
+	return rec, nil
+}
+
+// replaceRecoveryCodes deletes the existing recovery codes of the user and issues new ones
+func (s *TwoFactor) replaceRecoveryCodes(ctx context.Context, tx *gorm.DB, uid int) ([]string, error) {
+	if err := s.rcdb.DeletePermanently(ctx, tx, "user_id = ?", uid); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	recs := make([]*model.RecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		// 16 characters, formatted as xxxx-xxxx-xxxx-xxxx for readability
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		recs[i] = &model.RecoveryCode{UserID: uid, Code: s.cr.HashToken(raw)}
+	}
+
+	if err := s.rcdb.CreateInBatches(ctx, tx, recs, RecoveryCodeCount); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode strips the separators & spaces of the recovery code typed by users
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
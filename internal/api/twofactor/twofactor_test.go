@@ -0,0 +1,246 @@
+package twofactor_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/api/auth"
+	"github.com/vuduongtp/go-core/internal/api/twofactor"
+	mfachallengedb "github.com/vuduongtp/go-core/internal/db/mfachallenge"
+	recoverycodedb "github.com/vuduongtp/go-core/internal/db/recoverycode"
+	refreshtokendb "github.com/vuduongtp/go-core/internal/db/refreshtoken"
+	sessiondb "github.com/vuduongtp/go-core/internal/db/session"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+	"github.com/vuduongtp/go-core/pkg/util/crypter"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
+	"github.com/vuduongtp/go-core/pkg/util/totp"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+const password = "Sup3r-Secret!"
+
+// staleChallengeDB returns the challenges as not used yet, as if another request completed them in the meantime
+type staleChallengeDB struct {
+	*mfachallengedb.DB
+}
+
+func (d staleChallengeDB) FindByToken(ctx context.Context, db *gorm.DB, hashedToken string) (*model.MFAChallenge, error) {
+	rec, err := d.DB.FindByToken(ctx, db, hashedToken)
+	if rec != nil {
+		rec.UsedAt = nil
+	}
+	return rec, err
+}
+
+type fixture struct {
+	db      *gorm.DB
+	svc     *twofactor.TwoFactor
+	auth    *auth.Auth
+	totp    *totp.Service
+	user    *model.User
+	authUsr *model.AuthUser
+}
+
+func newFixture(t *testing.T, mcdb auth.MFAChallengeDB) *fixture {
+	db := mock.DB(t, &model.User{}, &model.Session{}, &model.RefreshToken{}, &model.MFAChallenge{}, &model.RecoveryCode{})
+	cr := mock.Crypter()
+	hashedPwd, err := cr.HashPassword(password)
+	assert.Nil(t, err)
+	usr := &model.User{Username: "johndoe", Password: hashedPwd, Role: model.RoleUser}
+	assert.Nil(t, db.Create(usr).Error)
+
+	secrets, err := crypter.NewCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	assert.Nil(t, err)
+	totpSvc := totp.New("GoCore")
+	udb := userdb.NewDB()
+	svc := twofactor.New(db, udb, recoverycodedb.NewDB(), totpSvc, cr, secrets)
+	lo := lockout.New(lockout.NewMemoryStore(), lockout.DefaultPolicy)
+	return &fixture{
+		db:      db,
+		svc:     svc,
+		auth:    auth.New(db, udb, refreshtokendb.NewDB(), sessiondb.NewDB(), mcdb, jwt.New("HS256", "2fa-test-secret", 900), cr, svc, lo, lo),
+		totp:    totpSvc,
+		user:    usr,
+		authUsr: &model.AuthUser{ID: usr.ID, Username: usr.Username, Role: usr.Role},
+	}
+}
+
+// code returns the TOTP code of the secret at the given period from now
+func (f *fixture) code(t *testing.T, secret string, periods int) string {
+	t.Helper()
+	code, err := f.totp.Code(secret, time.Now().Add(time.Duration(periods)*totp.DefaultConfig.Period))
+	assert.Nil(t, err)
+	return code
+}
+
+// enable enables the two-factor authentication of the user with the code of the previous period,
+// returns the secret & the recovery codes
+func (f *fixture) enable(t *testing.T) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	key, err := f.svc.Enroll(ctx, f.authUsr, twofactor.PasswordData{Password: password})
+	assert.Nil(t, err)
+	codes, err := f.svc.Enable(ctx, f.authUsr, twofactor.CodeData{Code: f.code(t, key.Secret, -1)})
+	assert.Nil(t, err)
+	return key.Secret, codes
+}
+
+// unusedRecoveryCodes returns the number of the recovery codes not used yet
+func (f *fixture) unusedRecoveryCodes(t *testing.T) int64 {
+	t.Helper()
+	var count int64
+	assert.Nil(t, f.db.Model(&model.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", f.user.ID).Count(&count).Error)
+	return count
+}
+
+func TestEnable(t *testing.T) {
+	f := newFixture(t, mfachallengedb.NewDB())
+	ctx := context.Background()
+
+	_, err := f.svc.Enroll(ctx, f.authUsr, twofactor.PasswordData{Password: "wrong"})
+	assert.ErrorIs(t, err, twofactor.ErrIncorrectPassword)
+	_, err = f.svc.Enable(ctx, f.authUsr, twofactor.CodeData{Code: "123456"})
+	assert.ErrorIs(t, err, twofactor.ErrNotEnrolled)
+
+	key, err := f.svc.Enroll(ctx, f.authUsr, twofactor.PasswordData{Password: password})
+	assert.Nil(t, err)
+	usr := &model.User{}
+	assert.Nil(t, f.db.First(usr, f.user.ID).Error)
+	assert.NotEmpty(t, usr.TOTPSecret)
+	assert.NotContains(t, usr.TOTPSecret, key.Secret, "the secret is encrypted at rest")
+	assert.False(t, usr.TOTPEnabled, "pending until the first code is verified")
+
+	_, err = f.svc.Enable(ctx, f.authUsr, twofactor.CodeData{Code: "000000"})
+	assert.ErrorIs(t, err, twofactor.ErrInvalidCode)
+	codes, err := f.svc.Enable(ctx, f.authUsr, twofactor.CodeData{Code: f.code(t, key.Secret, 0)})
+	assert.Nil(t, err)
+	assert.Len(t, codes, twofactor.RecoveryCodeCount)
+	assert.Equal(t, int64(twofactor.RecoveryCodeCount), f.unusedRecoveryCodes(t))
+
+	assert.Nil(t, f.db.First(usr, f.user.ID).Error)
+	assert.True(t, usr.TOTPEnabled)
+	_, err = f.svc.Enroll(ctx, f.authUsr, twofactor.PasswordData{Password: password})
+	assert.ErrorIs(t, err, twofactor.ErrAlreadyEnabled)
+
+	// the recovery codes are replaced with the current code
+	_, err = f.svc.RegenerateRecoveryCodes(ctx, f.authUsr, twofactor.CodeData{Code: "000000"})
+	assert.ErrorIs(t, err, twofactor.ErrInvalidCode)
+	newCodes, err := f.svc.RegenerateRecoveryCodes(ctx, f.authUsr, twofactor.CodeData{Code: codes[0]})
+	assert.Nil(t, err)
+	assert.Len(t, newCodes, twofactor.RecoveryCodeCount)
+	assert.NotContains(t, newCodes, codes[0])
+	assert.Equal(t, int64(twofactor.RecoveryCodeCount), f.unusedRecoveryCodes(t))
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	cases := []struct {
+		name string
+		// mcdb of the auth service, the default one if nil
+		mcdb auth.MFAChallengeDB
+		// prepare returns the code to verify, given the secret & the recovery codes, and may change the challenge
+		prepare func(t *testing.T, f *fixture, secret string, codes []string) string
+		wantErr error
+		// wantRecoveryCodes is the number of unused recovery codes after the login
+		wantRecoveryCodes int64
+		// wantRetry tells whether the challenge can still be completed after the failure
+		wantRetry bool
+	}{
+		{
+			name: "TOTP code",
+			prepare: func(t *testing.T, f *fixture, secret string, _ []string) string {
+				return f.code(t, secret, 0)
+			},
+			wantRecoveryCodes: twofactor.RecoveryCodeCount,
+		},
+		{
+			name: "Recovery code",
+			prepare: func(_ *testing.T, _ *fixture, _ string, codes []string) string {
+				return strings.ToUpper(codes[0])
+			},
+			wantRecoveryCodes: twofactor.RecoveryCodeCount - 1,
+		},
+		{
+			name: "TOTP code replayed",
+			prepare: func(t *testing.T, f *fixture, secret string, _ []string) string {
+				return f.code(t, secret, -1)
+			},
+			wantErr:           auth.ErrInvalid2FACode,
+			wantRecoveryCodes: twofactor.RecoveryCodeCount,
+			wantRetry:         true,
+		},
+		{
+			name: "Recovery code used",
+			prepare: func(t *testing.T, f *fixture, _ string, codes []string) string {
+				assert.Nil(t, f.db.Model(&model.RecoveryCode{}).Where("1 = 1").Update("used_at", time.Now()).Error)
+				return codes[0]
+			},
+			wantErr:   auth.ErrInvalid2FACode,
+			wantRetry: true,
+		},
+		{
+			name: "Expired challenge",
+			prepare: func(t *testing.T, f *fixture, _ string, codes []string) string {
+				assert.Nil(t, f.db.Model(&model.MFAChallenge{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Second)).Error)
+				return codes[0]
+			},
+			wantErr:           auth.ErrInvalidMFAToken,
+			wantRecoveryCodes: twofactor.RecoveryCodeCount,
+		},
+		{
+			name: "Challenge completed concurrently",
+			mcdb: staleChallengeDB{mfachallengedb.NewDB()},
+			prepare: func(t *testing.T, f *fixture, _ string, codes []string) string {
+				assert.Nil(t, f.db.Model(&model.MFAChallenge{}).Where("1 = 1").Update("used_at", time.Now()).Error)
+				return codes[0]
+			},
+			wantErr:           auth.ErrInvalidMFAToken,
+			wantRecoveryCodes: twofactor.RecoveryCodeCount,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mcdb := tt.mcdb
+			if mcdb == nil {
+				mcdb = mfachallengedb.NewDB()
+			}
+			f := newFixture(t, mcdb)
+			ctx := context.Background()
+			secret, codes := f.enable(t)
+
+			challenge, err := f.auth.Authenticate(ctx, auth.Credentials{Username: f.user.Username, Password: password})
+			assert.Nil(t, err)
+			assert.True(t, challenge.MFARequired)
+			assert.Empty(t, challenge.AccessToken)
+
+			code := tt.prepare(t, f, secret, codes)
+			resp, err := f.auth.LoginTwoFactor(ctx, auth.TwoFactorData{MFAToken: challenge.MFAToken, Code: code})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantRecoveryCodes, f.unusedRecoveryCodes(t))
+			if tt.wantErr == nil {
+				assert.NotEmpty(t, resp.AccessToken)
+				assert.NotEmpty(t, resp.RefreshToken)
+
+				// the challenge is single use
+				_, err = f.auth.LoginTwoFactor(ctx, auth.TwoFactorData{MFAToken: challenge.MFAToken, Code: codes[1]})
+				assert.ErrorIs(t, err, auth.ErrInvalidMFAToken)
+				assert.Equal(t, tt.wantRecoveryCodes, f.unusedRecoveryCodes(t), "the code is not consumed")
+				return
+			}
+
+			_, err = f.auth.LoginTwoFactor(ctx, auth.TwoFactorData{MFAToken: challenge.MFAToken, Code: f.code(t, secret, 0)})
+			if tt.wantRetry {
+				assert.Nil(t, err, "the failed code does not complete the challenge")
+			} else {
+				assert.ErrorIs(t, err, auth.ErrInvalidMFAToken)
+			}
+		})
+	}
+}
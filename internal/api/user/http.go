@@ -3,12 +3,15 @@ package user
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/rbac"
 	"github.com/vuduongtp/go-core/pkg/server"
 	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
 	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
 
 	"github.com/labstack/echo/v4"
 )
@@ -28,25 +31,36 @@ type Service interface {
 	Delete(context.Context, *model.AuthUser, int) error
 	Me(context.Context, *model.AuthUser) (*model.User, error)
 	ChangePassword(context.Context, *model.AuthUser, PasswordChangeData) error
+	ListLocks(context.Context, *model.AuthUser) ([]*lockout.Attempt, error)
+	ClearLock(context.Context, *model.AuthUser, string) error
+	Unlock(context.Context, *model.AuthUser, int) error
+	Impersonate(context.Context, *model.AuthUser, int) (*model.AuthToken, error)
 }
 
+// sortFields holds the fields the users may be sorted by
+var sortFields = httputil.MustSortFieldsOf(model.User{})
+
 // NewHTTP creates new user http service
 func NewHTTP(svc Service, auth model.Auth, eg *echo.Group) {
 	h := HTTP{svc, auth}
 
-	eg.POST("", h.create)
-	eg.GET("/:id", h.view)
-	eg.GET("", h.list)
-	eg.PATCH("/:id", h.update)
-	eg.DELETE("/:id", h.delete)
+	eg.POST("", h.create, rbac.Require(model.ObjectUser, model.ActionCreateAll))
+	eg.GET("/:id", h.view, rbac.Owned(model.ObjectUser, model.ActionViewAll))
+	eg.GET("", h.list, rbac.Require(model.ObjectUser, model.ActionViewAll))
+	eg.PATCH("/:id", h.update, rbac.Owned(model.ObjectUser, model.ActionUpdateAll))
+	eg.DELETE("/:id", h.delete, rbac.Owned(model.ObjectUser, model.ActionDeleteAll))
 	eg.GET("/me", h.me)
 	eg.PATCH("/me/password", h.changePassword)
+	eg.GET("/locks", h.listLocks, rbac.Require(model.ObjectUser, model.ActionManageLocks))
+	eg.DELETE("/locks/:key", h.clearLock, rbac.Require(model.ObjectUser, model.ActionManageLocks))
+	eg.DELETE("/:id/lock", h.unlock, rbac.Require(model.ObjectUser, model.ActionManageLocks))
+	eg.POST("/:id/impersonate", h.impersonate, rbac.Require(model.ObjectUser, model.ActionImpersonate))
 }
 
 // CreationData contains user data from json request
 type CreationData struct {
 	Username  string `json:"username" validate:"required,min=3"`
-	Password  string `json:"password" validate:"required,min=8"`
+	Password  string `json:"password" validate:"required,password"`
 	FirstName string `json:"first_name" validate:"required"`
 	LastName  string `json:"last_name" validate:"required"`
 	Email     string `json:"email" validate:"required,email"`
@@ -62,20 +76,29 @@ type UpdateData struct {
 	Email     *string `json:"email,omitempty" validate:"omitempty,email"`
 	Mobile    *string `json:"mobile,omitempty" validate:"omitempty,mobile"`
 	Role      *string `json:"role,omitempty"`
-	Blocked   *bool   `json:"blocked,omitempty"`
 }
 
 // PasswordChangeData contains password change request
 type PasswordChangeData struct {
 	OldPassword        string `json:"old_password" validate:"required"`
-	NewPassword        string `json:"new_password" validate:"required,min=8"`
+	NewPassword        string `json:"new_password" validate:"required,password"`
 	NewPasswordConfirm string `json:"new_password_confirm" validate:"required,eqfield=NewPassword"`
 }
 
 // ListResp contains list of users and current page number response
 type ListResp struct {
-	Data       []*model.User `json:"data"`
-	TotalCount int64         `json:"total_count"`
+	Data []*model.User `json:"data"`
+	// Total number of users, not returned in the cursor mode
+	TotalCount *int64 `json:"total_count,omitempty"`
+	// Cursor of the next page in the cursor mode, empty if there is none
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Cursor of the previous page in the cursor mode, empty if there is none
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// LocksResp contains list of failed login attempts response
+type LocksResp struct {
+	Data []*lockout.Attempt `json:"data"`
 }
 
 // @Security		BearerToken
@@ -91,6 +114,7 @@ type ListResp struct {
 // @Failure		401			{object}	SwaggErrDetailsResp
 // @Failure		403			{object}	SwaggErrDetailsResp
 // @Failure		500			{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "create_all"}
 // @Router			/v1/users	[post]
 func (h *HTTP) create(c echo.Context) error {
 	r := CreationData{}
@@ -103,10 +127,6 @@ func (h *HTTP) create(c echo.Context) error {
 	r.Mobile = strings.TrimSpace(strings.Replace(r.Mobile, " ", "", -1))
 	r.Role = strings.TrimSpace(r.Role)
 
-	if err := validateRole(&r.Role); err != nil {
-		return err
-	}
-
 	resp, err := h.svc.Create(c.Request().Context(), h.auth.User(c), r)
 	if err != nil {
 		return err
@@ -117,7 +137,7 @@ func (h *HTTP) create(c echo.Context) error {
 
 // @Security		BearerToken
 // @Summary		Returns a single user
-// @Description	Returns a single user
+// @Description	Returns a single user, users without permission to view all users may view themselves
 // @Accept			json
 // @Produce		json
 // @Tags			users
@@ -129,6 +149,7 @@ func (h *HTTP) create(c echo.Context) error {
 // @Failure		403				{object}	SwaggErrDetailsResp
 // @Failure		404				{object}	SwaggErrDetailsResp
 // @Failure		500				{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "view_all", "owned": true}
 // @Router			/v1/users/{id}	[get]
 func (h *HTTP) view(c echo.Context) error {
 	id, err := httputil.ReqID(c)
@@ -156,24 +177,32 @@ func (h *HTTP) view(c echo.Context) error {
 // @Failure		401			{object}	SwaggErrDetailsResp
 // @Failure		403			{object}	SwaggErrDetailsResp
 // @Failure		500			{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "view_all"}
 // @Router			/v1/users	[get]
 func (h *HTTP) list(c echo.Context) error {
-	lq, err := httputil.ReqListQuery(c)
+	lq, err := httputil.ReqListQuery(c, sortFields)
 	if err != nil {
 		return err
 	}
-	var count int64 = 0
-	resp, err := h.svc.List(c.Request().Context(), h.auth.User(c), lq, &count)
+	// the total count is not queried in the cursor mode
+	var count *int64
+	if lq.Cursor == nil {
+		count = new(int64)
+	}
+	resp, err := h.svc.List(c.Request().Context(), h.auth.User(c), lq, count)
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, ListResp{resp, count})
+	if lq.Cursor != nil {
+		return c.JSON(http.StatusOK, ListResp{Data: resp, NextCursor: lq.Cursor.Next.Encode(), PrevCursor: lq.Cursor.Prev.Encode()})
+	}
+	return c.JSON(http.StatusOK, ListResp{Data: resp, TotalCount: count})
 }
 
 // @Security		BearerToken
 // @Summary		Updates user information
-// @Description	Updates user information
+// @Description	Updates user information, users without permission to update all users may update themselves except the role & the email
 // @Accept			json
 // @Produce		json
 // @Tags			users
@@ -186,6 +215,7 @@ func (h *HTTP) list(c echo.Context) error {
 // @Failure		403				{object}	SwaggErrDetailsResp
 // @Failure		404				{object}	SwaggErrDetailsResp
 // @Failure		500				{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "update_all", "owned": true}
 // @Router			/v1/users/{id}	[patch]
 func (h *HTTP) update(c echo.Context) error {
 	id, err := httputil.ReqID(c)
@@ -202,10 +232,6 @@ func (h *HTTP) update(c echo.Context) error {
 	r.Mobile = httputil.RemoveSpacePointer(r.Mobile)
 	r.Role = httputil.RemoveSpacePointer(r.Role)
 
-	if err := validateRole(r.Role); err != nil {
-		return err
-	}
-
 	resp, err := h.svc.Update(c.Request().Context(), h.auth.User(c), id, r)
 	if err != nil {
 		return err
@@ -228,6 +254,7 @@ func (h *HTTP) update(c echo.Context) error {
 // @Failure		403				{object}	SwaggErrDetailsResp
 // @Failure		404				{object}	SwaggErrDetailsResp
 // @Failure		500				{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "delete_all", "owned": true}
 // @Router			/v1/users/{id}	[delete]
 func (h *HTTP) delete(c echo.Context) error {
 	id, err := httputil.ReqID(c)
@@ -291,20 +318,105 @@ func (h *HTTP) changePassword(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
-func validateRole(input *string) error {
-	if input == nil {
-		return nil
+// @Security		BearerToken
+// @Summary		Lists failed login attempts
+// @Description	Lists failed login attempts of all accounts & client IPs, including the locked ones
+// @Accept			json
+// @Produce		json
+// @Tags			users
+// @ID				usersListLocks
+// @Success		200					{object}	user.LocksResp
+// @Failure		401					{object}	SwaggErrDetailsResp
+// @Failure		403					{object}	SwaggErrDetailsResp
+// @Failure		500					{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "manage_locks"}
+// @Router			/v1/users/locks	[get]
+func (h *HTTP) listLocks(c echo.Context) error {
+	resp, err := h.svc.ListLocks(c.Request().Context(), h.auth.User(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, LocksResp{resp})
+}
+
+// @Security		BearerToken
+// @Summary		Clears a login lock
+// @Description	Clears the failed login attempts and the lock of the given key. e.g: account:johndoe, ip:10.0.0.1
+// @Accept			json
+// @Produce		json
+// @Tags			users
+// @ID				usersClearLock
+// @Param			key						path		string	true	"Lock key"
+// @Success		200						{object}	SwaggOKResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "manage_locks"}
+// @Router			/v1/users/locks/{key}	[delete]
+func (h *HTTP) clearLock(c echo.Context) error {
+	key, err := url.PathUnescape(c.Param("key"))
+	if err != nil || key == "" {
+		return server.NewHTTPValidationError("Invalid key")
+	}
+	if err := h.svc.ClearLock(c.Request().Context(), h.auth.User(c), key); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Security		BearerToken
+// @Summary		Unlocks an user account
+// @Description	Clears the failed login attempts and the lock of an user account
+// @Accept			json
+// @Produce		json
+// @Tags			users
+// @ID				usersUnlock
+// @Param			id						path		int	true	"User ID"
+// @Success		200						{object}	SwaggOKResp
+// @Failure		400						{object}	SwaggErrDetailsResp
+// @Failure		401						{object}	SwaggErrDetailsResp
+// @Failure		403						{object}	SwaggErrDetailsResp
+// @Failure		500						{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "manage_locks"}
+// @Router			/v1/users/{id}/lock	[delete]
+func (h *HTTP) unlock(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.svc.Unlock(c.Request().Context(), h.auth.User(c), id); err != nil {
+		return err
 	}
 
-	validRole := false
-	for _, role := range model.AvailableRoles {
-		if role == *input {
-			validRole = true
-			break
-		}
+	return c.NoContent(http.StatusOK)
+}
+
+// @Security		BearerToken
+// @Summary		Impersonates an user
+// @Description	Issues a short-lived access token to act as the user, with the authenticated user in the `act` claim. The token cannot be refreshed, nor used to change passwords or manage API keys
+// @Accept			json
+// @Produce		json
+// @Tags			users
+// @ID				usersImpersonate
+// @Param			id								path		int	true	"User ID"
+// @Success		200								{object}	model.AuthToken
+// @Failure		400								{object}	SwaggErrDetailsResp
+// @Failure		401								{object}	SwaggErrDetailsResp
+// @Failure		403								{object}	SwaggErrDetailsResp
+// @Failure		500								{object}	SwaggErrDetailsResp
+// @x-permission	{"object": "user", "action": "impersonate"}
+// @Router			/v1/users/{id}/impersonate	[post]
+func (h *HTTP) impersonate(c echo.Context) error {
+	id, err := httputil.ReqID(c)
+	if err != nil {
+		return err
 	}
-	if !validRole {
-		return server.NewHTTPValidationError("Invalid role")
+	resp, err := h.svc.Impersonate(c.Request().Context(), h.auth.User(c), id)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	return c.JSON(http.StatusOK, resp)
 }
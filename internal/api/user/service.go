@@ -6,21 +6,27 @@ import (
 	"github.com/vuduongtp/go-core/internal/model"
 	"github.com/vuduongtp/go-core/pkg/rbac"
 	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
 
 	"gorm.io/gorm"
 )
 
-// New creates new user application service
-func New(db *gorm.DB, udb MyDB, rbacSvc rbac.Intf, cr Crypter) *User {
-	return &User{db: db, udb: udb, rbac: rbacSvc, cr: cr}
+// New creates new user application service, the new passwords are checked by pwd
+func New(db *gorm.DB, udb MyDB, mdb MembershipDB, rbacSvc RBAC, cr Crypter, pwd PasswordChecker, lockoutSvc Lockout, auth Auth) *User {
+	return &User{db: db, udb: udb, mdb: mdb, rbac: rbacSvc, cr: cr, pwd: pwd, lockout: lockoutSvc, auth: auth}
 }
 
 // User represents user application service
 type User struct {
 	db   *gorm.DB
 	udb  MyDB
-	rbac rbac.Intf
+	mdb  MembershipDB
+	rbac RBAC
 	cr   Crypter
+	pwd  PasswordChecker
+
+	lockout Lockout
+	auth    Auth
 }
 
 // MyDB represents user repository interface
@@ -29,8 +35,36 @@ type MyDB interface {
 	FindByUsername(context.Context, *gorm.DB, string) (*model.User, error)
 }
 
+// MembershipDB represents organization membership repository interface, see dbutil.NewTenantDB
+type MembershipDB interface {
+	dbutil.Intf
+}
+
+// RBAC represents the access control interface, roles are looked up since they may be managed at runtime
+type RBAC interface {
+	rbac.Intf
+	RoleExists(string) bool
+}
+
+// PasswordChecker represents the new password checking interface, see passwordutil.Checker
+type PasswordChecker interface {
+	Check(context.Context, *gorm.DB, *model.User, string) error
+	Record(context.Context, *gorm.DB, *model.User) error
+}
+
 // Crypter represents security interface
 type Crypter interface {
 	CompareHashAndPassword(hasedPwd string, rawPwd string) bool
-	HashPassword(string) string
+	HashPassword(string) (string, error)
+}
+
+// Lockout represents login lockout management interface
+type Lockout interface {
+	List(context.Context) ([]*lockout.Attempt, error)
+	Unlock(context.Context, string) error
+}
+
+// Auth represents the token issuing interface for impersonation
+type Auth interface {
+	Impersonate(context.Context, *model.AuthUser, *model.User, *model.Membership) (*model.AuthToken, error)
 }
@@ -2,13 +2,17 @@ package user
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/vuduongtp/go-core/internal/model"
 	"github.com/vuduongtp/go-core/pkg/rbac"
 	"github.com/vuduongtp/go-core/pkg/server"
 	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
 	structutil "github.com/vuduongtp/go-core/pkg/util/struct"
+
+	"gorm.io/gorm"
 )
 
 // Custom errors
@@ -16,17 +20,32 @@ var (
 	ErrIncorrectPassword = server.NewHTTPError(http.StatusBadRequest, "INCORRECT_PASSWORD", "Incorrect old password")
 	ErrUserNotFound      = server.NewHTTPError(http.StatusBadRequest, "USER_NOTFOUND", "User not found")
 	ErrUsernameExisted   = server.NewHTTPValidationError("Username already existed")
+	ErrEmailExisted      = server.NewHTTPValidationError("Email already existed")
+	ErrInvalidRole       = server.NewHTTPValidationError("Invalid role")
+	ErrCannotImpersonate = server.NewHTTPError(http.StatusBadRequest, "CANNOT_IMPERSONATE", "This user cannot be impersonated")
+	ErrImpersonated      = server.NewHTTPError(http.StatusForbidden, "IMPERSONATION_NOT_ALLOWED", "This action is not allowed while impersonating another user")
+	ErrAPIKeyNotAllowed  = server.NewHTTPError(http.StatusForbidden, "API_KEY_NOT_ALLOWED", "This action is not allowed using an API key")
+	ErrInvalidCursor     = server.NewHTTPValidationError("Invalid cursor")
 )
 
 // Create creates a new user account
 func (s *User) Create(ctx context.Context, authUsr *model.AuthUser, data CreationData) (*model.User, error) {
-	if err := s.enforce(authUsr, model.ActionCreateAll); err != nil {
+	if err := s.validateRole(authUsr, data.Role); err != nil {
 		return nil, err
 	}
 
-	if existed, err := s.udb.Exist(ctx, s.db, map[string]interface{}{"username": data.Username}); err != nil || existed {
+	// usernames are unique across organizations
+	if existed, err := s.udb.Exist(dbutil.WithoutTenant(ctx), s.db, map[string]interface{}{"username": data.Username}); err != nil || existed {
 		return nil, ErrUsernameExisted.SetInternal(err)
 	}
+	if err := s.checkEmail(ctx, data.Email, 0); err != nil {
+		return nil, err
+	}
+
+	hashedPwd, err := s.cr.HashPassword(data.Password)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error hashing password").SetInternal(err)
+	}
 
 	rec := &model.User{
 		FirstName: data.FirstName,
@@ -34,12 +53,30 @@ func (s *User) Create(ctx context.Context, authUsr *model.AuthUser, data Creatio
 		Email:     data.Email,
 		Mobile:    data.Mobile,
 		Username:  data.Username,
-		Password:  s.cr.HashPassword(data.Password),
+		Password:  hashedPwd,
 		Blocked:   data.Blocked,
 		Role:      data.Role,
 	}
 
-	if err := s.udb.Create(ctx, s.db, rec); err != nil {
+	// users created within an organization become its members, the role applies within the organization only
+	_, inOrg := dbutil.TenantFromContext(ctx)
+	if inOrg {
+		rec.Role = model.RoleUser
+	}
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := s.udb.Create(ctx, tx, rec); err != nil {
+			return err
+		}
+		if !inOrg {
+			return nil
+		}
+		return s.mdb.Create(ctx, tx, &model.Membership{UserID: rec.ID, Role: data.Role})
+	})
+	if err != nil {
+		// the email has been registered concurrently, violating the unique index
+		if eerr := s.checkEmail(ctx, data.Email, rec.ID); eerr == ErrEmailExisted {
+			return nil, ErrEmailExisted.SetInternal(err)
+		}
 		return nil, server.NewHTTPInternalError("Error creating user").SetInternal(err)
 	}
 
@@ -48,7 +85,7 @@ func (s *User) Create(ctx context.Context, authUsr *model.AuthUser, data Creatio
 
 // View returns single user
 func (s *User) View(ctx context.Context, authUsr *model.AuthUser, id int) (*model.User, error) {
-	if err := s.enforce(authUsr, model.ActionViewAll); err != nil {
+	if err := s.enforceOwner(authUsr, model.ActionViewAll, id); err != nil {
 		return nil, err
 	}
 
@@ -62,27 +99,79 @@ func (s *User) View(ctx context.Context, authUsr *model.AuthUser, id int) (*mode
 
 // List returns list of users
 func (s *User) List(ctx context.Context, authUsr *model.AuthUser, lq *dbutil.ListQueryCondition, count *int64) ([]*model.User, error) {
-	if err := s.enforce(authUsr, model.ActionViewAll); err != nil {
-		return nil, err
-	}
-
 	var data []*model.User
 	if err := s.udb.List(ctx, s.db, &data, lq, count); err != nil {
+		if errors.Is(err, dbutil.ErrInvalidCursor) {
+			return nil, ErrInvalidCursor.SetInternal(err)
+		}
 		return nil, server.NewHTTPInternalError("Error listing user").SetInternal(err)
 	}
 
 	return data, nil
 }
 
-// Update updates user information
+// Update updates user information, users may update their own information except the role & the email.
+// The email is not changed by users themselves as it is not verified again, e.g. to take over the accounts linked to it.
+// Within an organization, only the role of the membership is updated for the other users, see enforceAccount.
+// The current role of the user is evaluated by the conditions of the policies, see model.AttrRole
 func (s *User) Update(ctx context.Context, authUsr *model.AuthUser, id int, data UpdateData) (*model.User, error) {
-	if err := s.enforce(authUsr, model.ActionUpdateAll); err != nil {
+	attrs, err := s.attrs(ctx, id)
+	if err != nil {
+		// the existence of the user is not disclosed without permission
+		if ferr := s.enforceOwner(authUsr, model.ActionUpdateAll, id); ferr != nil {
+			return nil, ferr
+		}
 		return nil, err
 	}
+	if !authUsr.EnforceOwnerAttrs(s.rbac, model.ObjectUser, model.ActionUpdateAll, id, attrs) {
+		return nil, rbac.ErrForbiddenAction
+	}
+	if data.Role != nil || data.Email != nil {
+		if !authUsr.EnforceAttrs(s.rbac, model.ObjectUser, model.ActionUpdateAll, attrs) {
+			return nil, rbac.ErrForbiddenAction
+		}
+	}
+	// the users change their own name & mobile within the organizations, not impersonated
+	own := id == authUsr.ID && !authUsr.IsImpersonated()
+	if data.Email != nil || (!own && (data.FirstName != nil || data.LastName != nil || data.Mobile != nil)) {
+		if err := s.enforceAccount(ctx, authUsr, id); err != nil {
+			return nil, err
+		}
+	}
+	if data.Role != nil {
+		if err := s.validateRole(authUsr, *data.Role); err != nil {
+			return nil, err
+		}
+	}
+
+	if data.Email != nil {
+		if err := s.checkEmail(ctx, *data.Email, id); err != nil {
+			return nil, err
+		}
+	}
+
+	// the role of the membership is updated within an organization
+	role := data.Role
+	_, inOrg := dbutil.TenantFromContext(ctx)
+	if inOrg {
+		data.Role = nil
+	}
 
 	// optimistic update
 	updates := structutil.ToMap(data)
-	if err := s.udb.Update(ctx, s.db, updates, id); err != nil {
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := s.udb.Update(ctx, tx, updates, id); err != nil {
+			return err
+		}
+		if !inOrg || role == nil {
+			return nil
+		}
+		return s.mdb.Update(ctx, tx, map[string]interface{}{"role": *role}, map[string]interface{}{"user_id": id})
+	})
+	if err != nil {
+		if data.Email != nil && s.checkEmail(ctx, *data.Email, id) == ErrEmailExisted {
+			return nil, ErrEmailExisted.SetInternal(err)
+		}
 		return nil, server.NewHTTPInternalError("Error updating user").SetInternal(err)
 	}
 
@@ -94,9 +183,21 @@ func (s *User) Update(ctx context.Context, authUsr *model.AuthUser, id int, data
 	return rec, nil
 }
 
-// Delete deletes a user
+// checkEmail checks that the email is not registered by another user than the given one, emails are unique across organizations
+func (s *User) checkEmail(ctx context.Context, email string, id int) error {
+	existed, err := s.udb.Exist(dbutil.WithoutTenant(ctx), s.db, map[string]interface{}{"email": email, "id__notexact": id})
+	if err != nil {
+		return server.NewHTTPInternalError("Error checking email").SetInternal(err)
+	}
+	if existed {
+		return ErrEmailExisted
+	}
+	return nil
+}
+
+// Delete deletes a user, or removes the user from the active organization if any
 func (s *User) Delete(ctx context.Context, authUsr *model.AuthUser, id int) error {
-	if err := s.enforce(authUsr, model.ActionDeleteAll); err != nil {
+	if err := s.enforceOwner(authUsr, model.ActionDeleteAll, id); err != nil {
 		return err
 	}
 
@@ -104,6 +205,12 @@ func (s *User) Delete(ctx context.Context, authUsr *model.AuthUser, id int) erro
 		return ErrUserNotFound.SetInternal(err)
 	}
 
+	if _, inOrg := dbutil.TenantFromContext(ctx); inOrg {
+		if err := s.mdb.DeletePermanently(ctx, s.db, map[string]interface{}{"user_id": id}); err != nil {
+			return server.NewHTTPInternalError("Error removing member").SetInternal(err)
+		}
+		return nil
+	}
 	if err := s.udb.Delete(ctx, s.db, id); err != nil {
 		return server.NewHTTPInternalError("Error deleting user").SetInternal(err)
 	}
@@ -120,8 +227,15 @@ func (s *User) Me(ctx context.Context, authUsr *model.AuthUser) (*model.User, er
 	return rec, nil
 }
 
-// ChangePassword changes authenticated user password
+// ChangePassword changes authenticated user password, not allowed by API key or from an impersonated session
 func (s *User) ChangePassword(ctx context.Context, authUsr *model.AuthUser, data PasswordChangeData) error {
+	if authUsr.APIKeyID != 0 {
+		return ErrAPIKeyNotAllowed
+	}
+	if authUsr.IsImpersonated() {
+		return ErrImpersonated
+	}
+
 	rec, err := s.Me(ctx, authUsr)
 	if err != nil {
 		return err
@@ -131,17 +245,141 @@ func (s *User) ChangePassword(ctx context.Context, authUsr *model.AuthUser, data
 		return ErrIncorrectPassword
 	}
 
-	hashedPwd := s.cr.HashPassword(data.NewPassword)
-	if err = s.udb.Update(ctx, s.db, map[string]interface{}{"password": hashedPwd}, rec.ID); err != nil {
+	if err := s.pwd.Check(ctx, s.db, rec, data.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPwd, err := s.cr.HashPassword(data.NewPassword)
+	if err != nil {
+		return server.NewHTTPInternalError("Error hashing password").SetInternal(err)
+	}
+	err = dbutil.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := s.udb.Update(ctx, tx, map[string]interface{}{"password": hashedPwd}, rec.ID); err != nil {
+			return err
+		}
+		return s.pwd.Record(ctx, tx, rec)
+	})
+	if err != nil {
 		return server.NewHTTPInternalError("Error changing password").SetInternal(err)
 	}
 
 	return nil
 }
 
-// enforce checks user permission to perform the action
-func (s *User) enforce(authUsr *model.AuthUser, action string) error {
-	if !s.rbac.Enforce(authUsr.Role, model.ObjectUser, action) {
+// Impersonate issues a short-lived access token to act as the given user, the authenticated user is kept as the actor.
+// Impersonating is not allowed by API key or from an impersonated session, and only superadmins may impersonate superadmins.
+// Within an organization, the token is limited to the membership of the user in it.
+func (s *User) Impersonate(ctx context.Context, authUsr *model.AuthUser, id int) (*model.AuthToken, error) {
+	if authUsr.IsImpersonated() || authUsr.APIKeyID != 0 {
+		return nil, ErrImpersonated
+	}
+	if authUsr.ID == id {
+		return nil, ErrCannotImpersonate
+	}
+
+	rec := new(model.User)
+	if err := s.udb.View(ctx, s.db, rec, id); err != nil {
+		return nil, ErrUserNotFound.SetInternal(err)
+	}
+	if rec.Blocked || rec.Pending {
+		return nil, ErrCannotImpersonate
+	}
+	if rec.Role == model.RoleSuperAdmin && authUsr.Role != model.RoleSuperAdmin {
+		return nil, rbac.ErrForbiddenAction
+	}
+
+	var m *model.Membership
+	if _, inOrg := dbutil.TenantFromContext(ctx); inOrg {
+		m = new(model.Membership)
+		if err := s.mdb.View(ctx, s.db, m, "user_id = ?", id); err != nil {
+			return nil, ErrUserNotFound.SetInternal(err)
+		}
+	}
+
+	return s.auth.Impersonate(ctx, authUsr, rec, m)
+}
+
+// ListLocks returns failed login attempts of all accounts & client IPs, including the locked ones
+func (s *User) ListLocks(ctx context.Context, authUsr *model.AuthUser) ([]*lockout.Attempt, error) {
+	data, err := s.lockout.List(ctx)
+	if err != nil {
+		return nil, server.NewHTTPInternalError("Error listing login attempts").SetInternal(err)
+	}
+
+	return data, nil
+}
+
+// ClearLock clears the failed login attempts and the lock of the given key
+func (s *User) ClearLock(ctx context.Context, authUsr *model.AuthUser, key string) error {
+	if err := s.lockout.Unlock(ctx, key); err != nil {
+		return server.NewHTTPInternalError("Error clearing login attempts").SetInternal(err)
+	}
+
+	return nil
+}
+
+// Unlock clears the failed login attempts and the lock of a user account
+func (s *User) Unlock(ctx context.Context, authUsr *model.AuthUser, id int) error {
+	rec := new(model.User)
+	if err := s.udb.View(ctx, s.db, rec, id); err != nil {
+		return ErrUserNotFound.SetInternal(err)
+	}
+
+	if err := s.lockout.Unlock(ctx, model.LockKeyAccount(rec.Username)); err != nil {
+		return server.NewHTTPInternalError("Error clearing login attempts").SetInternal(err)
+	}
+
+	return nil
+}
+
+// validateRole checks that the role exists in the RBAC policies, only superadmins may grant the superadmin role
+func (s *User) validateRole(authUsr *model.AuthUser, role string) error {
+	if !s.rbac.RoleExists(role) {
+		return ErrInvalidRole
+	}
+	if role == model.RoleSuperAdmin && authUsr.Role != model.RoleSuperAdmin {
+		return rbac.ErrForbiddenAction
+	}
+	return nil
+}
+
+// attrs returns the attributes of the user of the given ID evaluated by the conditions of the policies,
+// the role is the one of the membership within an organization
+func (s *User) attrs(ctx context.Context, id int) (rbac.Attrs, error) {
+	rec := new(model.User)
+	if err := s.udb.View(ctx, s.db, rec, id); err != nil {
+		return nil, ErrUserNotFound.SetInternal(err)
+	}
+	role := rec.Role
+	if _, inOrg := dbutil.TenantFromContext(ctx); inOrg {
+		m := new(model.Membership)
+		if err := s.mdb.View(ctx, s.db, m, "user_id = ?", id); err != nil {
+			return nil, ErrUserNotFound.SetInternal(err)
+		}
+		role = m.Role
+	}
+	return rbac.Attrs{model.AttrRole: role}, nil
+}
+
+// enforceAccount checks user permission to update the account of the user of the given ID, which is shared by all organizations.
+// Within an organization, the permission must be granted outside of it, see model.AuthUser.Global
+func (s *User) enforceAccount(ctx context.Context, authUsr *model.AuthUser, id int) error {
+	if _, inOrg := dbutil.TenantFromContext(ctx); !inOrg {
+		return nil
+	}
+	rec := new(model.User)
+	if err := s.udb.View(dbutil.WithoutTenant(ctx), s.db, rec, id); err != nil {
+		return ErrUserNotFound.SetInternal(err)
+	}
+	if !authUsr.Global().EnforceAttrs(s.rbac, model.ObjectUser, model.ActionUpdateAll, rbac.Attrs{model.AttrRole: rec.Role}) {
+		return rbac.ErrForbiddenAction
+	}
+	return nil
+}
+
+// enforceOwner checks user permission to perform the action on the user of the given ID, falling back to the owner-scoped action for oneself
+func (s *User) enforceOwner(authUsr *model.AuthUser, action string, id int) error {
+	if !authUsr.EnforceOwner(s.rbac, model.ObjectUser, action, id) {
 		return rbac.ErrForbiddenAction
 	}
 	return nil
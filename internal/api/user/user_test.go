@@ -0,0 +1,324 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vuduongtp/go-core/internal/api/user"
+	membershipdb "github.com/vuduongtp/go-core/internal/db/membership"
+	passwordhistorydb "github.com/vuduongtp/go-core/internal/db/passwordhistory"
+	userdb "github.com/vuduongtp/go-core/internal/db/user"
+	"github.com/vuduongtp/go-core/internal/model"
+	passwordutil "github.com/vuduongtp/go-core/internal/util/password"
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// permissions of the builtin roles used by the tests
+var permissions = mock.Enforcer{
+	model.RoleAdmin + " " + model.ObjectUser + " " + model.ActionViewAll:   true,
+	model.RoleAdmin + " " + model.ObjectUser + " " + model.ActionUpdateAll: true,
+	model.RoleUser + " " + model.ObjectUser + " " + model.ActionView:       true,
+	model.RoleUser + " " + model.ObjectUser + " " + model.ActionUpdate:     true,
+}
+
+// impersonator records the membership of the impersonation tokens
+type impersonator struct {
+	memberships []*model.Membership
+}
+
+func (i *impersonator) Impersonate(_ context.Context, _ *model.AuthUser, _ *model.User, m *model.Membership) (*model.AuthToken, error) {
+	i.memberships = append(i.memberships, m)
+	return &model.AuthToken{AccessToken: "impersonated"}, nil
+}
+
+// orgID is the organization of the fixture users, in which the other user is admin
+const orgID = 1
+
+type fixture struct {
+	db    *gorm.DB
+	svc   *user.User
+	auth  *impersonator
+	user  *model.User
+	other *model.User
+}
+
+func newFixture(t *testing.T) *fixture {
+	db := mock.DB(t, &model.User{}, &model.Membership{}, &model.PasswordHistory{})
+	cr := mock.Crypter()
+	f := &fixture{
+		db:    db,
+		user:  &model.User{Username: "johndoe", FirstName: "John", Email: "john@mail.com", Password: "-", Role: model.RoleUser},
+		other: &model.User{Username: "janedoe", FirstName: "Jane", Email: "jane@mail.com", Password: "-", Role: model.RoleUser},
+	}
+	assert.Nil(t, db.Create(f.user).Error)
+	assert.Nil(t, db.Create(f.other).Error)
+	assert.Nil(t, db.Create(&model.Membership{OrgID: orgID, UserID: f.user.ID, Role: model.RoleUser}).Error)
+	assert.Nil(t, db.Create(&model.Membership{OrgID: orgID, UserID: f.other.ID, Role: model.RoleAdmin}).Error)
+
+	pwd := passwordutil.New(passwordhistorydb.NewDB(), cr, passwordpolicy.New())
+	f.auth = &impersonator{}
+	f.svc = user.New(db, userdb.NewDB(), membershipdb.NewDB(), permissions, cr, pwd, nil, f.auth)
+	return f
+}
+
+func (f *fixture) authUser(usr *model.User) *model.AuthUser {
+	return &model.AuthUser{ID: usr.ID, Username: usr.Username, Role: usr.Role, GlobalRole: usr.Role}
+}
+
+// orgUser returns the user within the organization of the fixture, as resolved by the tenant middleware
+func (f *fixture) orgUser(t *testing.T, usr *model.User) *model.AuthUser {
+	t.Helper()
+	m := &model.Membership{}
+	assert.Nil(t, f.db.Where("org_id = ? AND user_id = ?", orgID, usr.ID).First(m).Error)
+	authUsr := f.authUser(usr)
+	authUsr.OrgID, authUsr.Role = orgID, m.Role
+	return authUsr
+}
+
+// memberRole returns the role of the user within the organization of the fixture
+func (f *fixture) memberRole(t *testing.T, id int) string {
+	t.Helper()
+	m := &model.Membership{}
+	assert.Nil(t, f.db.Where("org_id = ? AND user_id = ?", orgID, id).First(m).Error)
+	return m.Role
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestUpdate(t *testing.T) {
+	admin := &model.AuthUser{ID: 100, Username: "admin", Role: model.RoleAdmin, GlobalRole: model.RoleAdmin}
+	cases := []struct {
+		name    string
+		authUsr func(t *testing.T, f *fixture) *model.AuthUser
+		// id returns the ID of the updated user
+		id func(f *fixture) int
+		// org tells whether the organization of the fixture is the active one
+		org     bool
+		data    user.UpdateData
+		wantErr error
+		// want is the updated user, with the fixture user as the base
+		want func(usr *model.User)
+		// wantMemberRole is the role of the membership after the update, unchanged if empty
+		wantMemberRole string
+	}{
+		{
+			name:    "Own name",
+			authUsr: func(_ *testing.T, f *fixture) *model.AuthUser { return f.authUser(f.user) },
+			id:      func(f *fixture) int { return f.user.ID },
+			data:    user.UpdateData{FirstName: strPtr("Johnny")},
+			want:    func(usr *model.User) { usr.FirstName = "Johnny" },
+		},
+		{
+			name:    "Own email",
+			authUsr: func(_ *testing.T, f *fixture) *model.AuthUser { return f.authUser(f.user) },
+			id:      func(f *fixture) int { return f.user.ID },
+			data:    user.UpdateData{FirstName: strPtr("Johnny"), Email: strPtr("attacker@mail.com")},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name:    "Own role",
+			authUsr: func(_ *testing.T, f *fixture) *model.AuthUser { return f.authUser(f.user) },
+			id:      func(f *fixture) int { return f.user.ID },
+			data:    user.UpdateData{Role: strPtr(model.RoleAdmin)},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name:    "Another user",
+			authUsr: func(_ *testing.T, f *fixture) *model.AuthUser { return f.authUser(f.other) },
+			id:      func(f *fixture) int { return f.user.ID },
+			data:    user.UpdateData{FirstName: strPtr("Johnny")},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name:    "Email by admin",
+			authUsr: func(_ *testing.T, _ *fixture) *model.AuthUser { return admin },
+			id:      func(f *fixture) int { return f.user.ID },
+			data:    user.UpdateData{Email: strPtr("johnny@mail.com")},
+			want:    func(usr *model.User) { usr.Email = "johnny@mail.com" },
+		},
+		{
+			name:    "Email of another user by admin",
+			authUsr: func(_ *testing.T, _ *fixture) *model.AuthUser { return admin },
+			id:      func(f *fixture) int { return f.user.ID },
+			data:    user.UpdateData{Email: strPtr("jane@mail.com")},
+			wantErr: user.ErrEmailExisted,
+		},
+		{
+			name:    "Own name within the organization",
+			authUsr: func(t *testing.T, f *fixture) *model.AuthUser { return f.orgUser(t, f.user) },
+			id:      func(f *fixture) int { return f.user.ID },
+			org:     true,
+			data:    user.UpdateData{FirstName: strPtr("Johnny")},
+			want:    func(usr *model.User) { usr.FirstName = "Johnny" },
+		},
+		{
+			name:           "Member role by organization admin",
+			authUsr:        func(t *testing.T, f *fixture) *model.AuthUser { return f.orgUser(t, f.other) },
+			id:             func(f *fixture) int { return f.user.ID },
+			org:            true,
+			data:           user.UpdateData{Role: strPtr(model.RoleAdmin)},
+			wantMemberRole: model.RoleAdmin,
+		},
+		{
+			name:    "Member name by organization admin",
+			authUsr: func(t *testing.T, f *fixture) *model.AuthUser { return f.orgUser(t, f.other) },
+			id:      func(f *fixture) int { return f.user.ID },
+			org:     true,
+			data:    user.UpdateData{FirstName: strPtr("Johnny")},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name:    "Member email by organization admin",
+			authUsr: func(t *testing.T, f *fixture) *model.AuthUser { return f.orgUser(t, f.other) },
+			id:      func(f *fixture) int { return f.user.ID },
+			org:     true,
+			data:    user.UpdateData{Email: strPtr("attacker@mail.com")},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name: "Member name by organization admin impersonating",
+			authUsr: func(t *testing.T, f *fixture) *model.AuthUser {
+				authUsr := f.orgUser(t, f.user)
+				authUsr.Role, authUsr.GlobalRole, authUsr.ActorID = model.RoleUser, "", f.other.ID
+				return authUsr
+			},
+			id:      func(f *fixture) int { return f.user.ID },
+			org:     true,
+			data:    user.UpdateData{FirstName: strPtr("Johnny")},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name:    "Own email by organization admin",
+			authUsr: func(t *testing.T, f *fixture) *model.AuthUser { return f.orgUser(t, f.other) },
+			id:      func(f *fixture) int { return f.other.ID },
+			org:     true,
+			data:    user.UpdateData{Email: strPtr("janet@mail.com")},
+			wantErr: rbac.ErrForbiddenAction,
+		},
+		{
+			name: "Member email by admin within the organization",
+			authUsr: func(_ *testing.T, _ *fixture) *model.AuthUser {
+				authUsr := *admin
+				authUsr.OrgID = orgID
+				return &authUsr
+			},
+			id:   func(f *fixture) int { return f.user.ID },
+			org:  true,
+			data: user.UpdateData{Email: strPtr("johnny@mail.com")},
+			want: func(usr *model.User) { usr.Email = "johnny@mail.com" },
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			ctx := context.Background()
+			if tt.org {
+				ctx = dbutil.WithTenant(ctx, orgID)
+			}
+			id := tt.id(f)
+			before := &model.User{}
+			assert.Nil(t, f.db.First(before, id).Error)
+			memberRole := f.memberRole(t, id)
+
+			_, err := f.svc.Update(ctx, tt.authUsr(t, f), id, tt.data)
+			assert.ErrorIs(t, err, tt.wantErr)
+			want := *before
+			if tt.want != nil {
+				tt.want(&want)
+			}
+			usr := &model.User{}
+			assert.Nil(t, f.db.First(usr, id).Error)
+			assert.Equal(t, want.FirstName, usr.FirstName)
+			assert.Equal(t, want.Email, usr.Email)
+			assert.Equal(t, want.Role, usr.Role)
+			if tt.wantMemberRole != "" {
+				memberRole = tt.wantMemberRole
+			}
+			assert.Equal(t, memberRole, f.memberRole(t, id))
+		})
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	cases := []struct {
+		name    string
+		authUsr func(t *testing.T, f *fixture) *model.AuthUser
+		// id returns the ID of the impersonated user
+		id func(f *fixture) int
+		// org tells whether the organization of the fixture is the active one
+		org     bool
+		wantErr error
+		// wantMembership is the membership the token is limited to
+		wantMembership *model.Membership
+	}{
+		{
+			name: "Global",
+			authUsr: func(_ *testing.T, _ *fixture) *model.AuthUser {
+				return &model.AuthUser{ID: 100, Username: "admin", Role: model.RoleAdmin, GlobalRole: model.RoleAdmin}
+			},
+			id: func(f *fixture) int { return f.user.ID },
+		},
+		{
+			name:           "Member by organization admin",
+			authUsr:        func(t *testing.T, f *fixture) *model.AuthUser { return f.orgUser(t, f.other) },
+			id:             func(f *fixture) int { return f.user.ID },
+			org:            true,
+			wantMembership: &model.Membership{OrgID: orgID, Role: model.RoleUser},
+		},
+		{
+			name:    "Non-member by organization admin",
+			authUsr: func(t *testing.T, f *fixture) *model.AuthUser { return f.orgUser(t, f.other) },
+			id:      func(_ *fixture) int { return 100 },
+			org:     true,
+			wantErr: user.ErrUserNotFound,
+		},
+		{
+			name: "Impersonated",
+			authUsr: func(t *testing.T, f *fixture) *model.AuthUser {
+				authUsr := f.orgUser(t, f.other)
+				authUsr.ActorID = 100
+				return authUsr
+			},
+			id:      func(f *fixture) int { return f.user.ID },
+			org:     true,
+			wantErr: user.ErrImpersonated,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			ctx := context.Background()
+			if tt.org {
+				ctx = dbutil.WithTenant(ctx, orgID)
+			}
+			assert.Nil(t, f.db.Create(&model.User{Base: model.Base{ID: 100}, Username: "outsider", Email: "outsider@mail.com", Password: "-", Role: model.RoleUser}).Error)
+
+			_, err := f.svc.Impersonate(ctx, tt.authUsr(t, f), tt.id(f))
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				assert.Empty(t, f.auth.memberships)
+				return
+			}
+			if !assert.Len(t, f.auth.memberships, 1) {
+				return
+			}
+			m := f.auth.memberships[0]
+			if tt.wantMembership == nil {
+				assert.Nil(t, m)
+				return
+			}
+			if assert.NotNil(t, m) {
+				assert.Equal(t, tt.wantMembership.OrgID, m.OrgID)
+				assert.Equal(t, tt.wantMembership.Role, m.Role)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new API key database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.APIKey{})}
+}
+
+// DB represents the client for api_keys table
+type DB struct {
+	*dbutil.DB
+}
+
+// FindByKey queries for single API key by its hashed value
+func (d *DB) FindByKey(ctx context.Context, db *gorm.DB, hashedKey string) (*model.APIKey, error) {
+	rec := new(model.APIKey)
+	if err := d.View(ctx, db, rec, "key_hash = ?", hashedKey); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// ListByUserID returns all API keys of the given user, newest first
+func (d *DB) ListByUserID(ctx context.Context, db *gorm.DB, uid int) ([]*model.APIKey, error) {
+	var recs []*model.APIKey
+	err := db.WithContext(ctx).Where("user_id = ?", uid).Order("id DESC").Find(&recs).Error
+	return recs, err
+}
+
+// Touch updates the last used time of the key, at most once per the given interval to limit writes
+func (d *DB) Touch(ctx context.Context, db *gorm.DB, id int, interval time.Duration) error {
+	now := time.Now()
+	return db.WithContext(ctx).Model(d.Model).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-interval)).
+		UpdateColumn("last_used_at", now).Error
+}
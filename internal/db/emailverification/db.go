@@ -0,0 +1,46 @@
+package emailverification
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new email verification database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.EmailVerification{})}
+}
+
+// DB represents the client for email_verifications table
+type DB struct {
+	*dbutil.DB
+}
+
+// FindByToken queries for single email verification by its hashed token
+func (d *DB) FindByToken(ctx context.Context, db *gorm.DB, hashedToken string) (*model.EmailVerification, error) {
+	rec := new(model.EmailVerification)
+	if err := d.View(ctx, db, rec, "token = ?", hashedToken); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// MarkUsed marks the token as used.
+// Returns false if the token has already been used in the meantime.
+func (d *DB) MarkUsed(ctx context.Context, db *gorm.DB, id int) (bool, error) {
+	res := db.WithContext(ctx).Model(d.Model).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+// InvalidateByUserID marks all unused tokens of the given user as used
+func (d *DB) InvalidateByUserID(ctx context.Context, db *gorm.DB, uid int) error {
+	return db.WithContext(ctx).Model(d.Model).
+		Where("user_id = ? AND used_at IS NULL", uid).
+		Update("used_at", time.Now()).Error
+}
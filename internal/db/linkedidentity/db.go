@@ -0,0 +1,29 @@
+package linkedidentity
+
+import (
+	"context"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new linked identity database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.LinkedIdentity{})}
+}
+
+// DB represents the client for linked_identities table
+type DB struct {
+	*dbutil.DB
+}
+
+// FindBySubject queries for single linked identity by provider and subject
+func (d *DB) FindBySubject(ctx context.Context, db *gorm.DB, provider, subject string) (*model.LinkedIdentity, error) {
+	rec := new(model.LinkedIdentity)
+	if err := d.View(ctx, db, rec, "provider = ? AND subject = ?", provider, subject); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
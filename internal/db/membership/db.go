@@ -0,0 +1,29 @@
+package membership
+
+import (
+	"context"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new membership database instance, the queries are restricted to the active organization if any
+func NewDB() *DB {
+	return &DB{dbutil.NewTenantDB(model.Membership{}, "org_id")}
+}
+
+// DB represents the client for memberships table
+type DB struct {
+	*dbutil.DB
+}
+
+// FindByUser queries for the membership of the given user in the given organization, regardless of the active organization
+func (d *DB) FindByUser(ctx context.Context, db *gorm.DB, orgID, uid int) (*model.Membership, error) {
+	rec := new(model.Membership)
+	if err := d.View(dbutil.WithoutTenant(ctx), db, rec, "org_id = ? AND user_id = ?", orgID, uid); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
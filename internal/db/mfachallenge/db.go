@@ -0,0 +1,39 @@
+package mfachallenge
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new MFA challenge database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.MFAChallenge{})}
+}
+
+// DB represents the client for mfa_challenges table
+type DB struct {
+	*dbutil.DB
+}
+
+// FindByToken queries for single challenge by its hashed token
+func (d *DB) FindByToken(ctx context.Context, db *gorm.DB, hashedToken string) (*model.MFAChallenge, error) {
+	rec := new(model.MFAChallenge)
+	if err := d.View(ctx, db, rec, "token = ?", hashedToken); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// MarkUsed marks the challenge as completed.
+// Returns false if the challenge has already been completed in the meantime.
+func (d *DB) MarkUsed(ctx context.Context, db *gorm.DB, id int) (bool, error) {
+	res := db.WithContext(ctx).Model(d.Model).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,30 @@
+package organization
+
+import (
+	"context"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new organization database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.Organization{})}
+}
+
+// DB represents the client for organizations table
+type DB struct {
+	*dbutil.DB
+}
+
+// ListByUserID returns the organizations the given user is a member of
+func (d *DB) ListByUserID(ctx context.Context, db *gorm.DB, uid int) ([]*model.Organization, error) {
+	var recs []*model.Organization
+	err := db.WithContext(ctx).
+		Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&model.Membership{}).Select("org_id").Where("user_id = ?", uid)).
+		Order("name").
+		Find(&recs).Error
+	return recs, err
+}
@@ -0,0 +1,55 @@
+package passwordhistory
+
+import (
+	"context"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new password history database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.PasswordHistory{})}
+}
+
+// DB represents the client for password_histories table
+type DB struct {
+	*dbutil.DB
+}
+
+// ListRecent returns the hashes of the latest previous passwords of the user, most recent first
+func (d *DB) ListRecent(ctx context.Context, db *gorm.DB, uid int, limit int) ([]string, error) {
+	var hashes []string
+	if limit <= 0 {
+		return hashes, nil
+	}
+	err := db.WithContext(ctx).Model(d.Model).
+		Where("user_id = ?", uid).
+		Order("id DESC").
+		Limit(limit).
+		Pluck("password", &hashes).Error
+	return hashes, err
+}
+
+// Prune permanently deletes the previous passwords of the user except the latest ones
+func (d *DB) Prune(ctx context.Context, db *gorm.DB, uid int, keep int) error {
+	query := db.WithContext(ctx).Unscoped().Where("user_id = ?", uid)
+	if keep > 0 {
+		var ids []int
+		if err := db.WithContext(ctx).Model(d.Model).
+			Where("user_id = ?", uid).
+			Order("id DESC").
+			Offset(keep-1).
+			Limit(1).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		query = query.Where("id < ?", ids[0])
+	}
+	return query.Delete(&model.PasswordHistory{}).Error
+}
@@ -0,0 +1,46 @@
+package passwordreset
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new password reset database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.PasswordReset{})}
+}
+
+// DB represents the client for password_resets table
+type DB struct {
+	*dbutil.DB
+}
+
+// FindByToken queries for single password reset by its hashed token
+func (d *DB) FindByToken(ctx context.Context, db *gorm.DB, hashedToken string) (*model.PasswordReset, error) {
+	rec := new(model.PasswordReset)
+	if err := d.View(ctx, db, rec, "token = ?", hashedToken); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// MarkUsed marks the token as used.
+// Returns false if the token has already been used in the meantime.
+func (d *DB) MarkUsed(ctx context.Context, db *gorm.DB, id int) (bool, error) {
+	res := db.WithContext(ctx).Model(d.Model).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+// InvalidateByUserID marks all unused tokens of the given user as used
+func (d *DB) InvalidateByUserID(ctx context.Context, db *gorm.DB, uid int) error {
+	return db.WithContext(ctx).Model(d.Model).
+		Where("user_id = ? AND used_at IS NULL", uid).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,30 @@
+package recoverycode
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new recovery code database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.RecoveryCode{})}
+}
+
+// DB represents the client for recovery_codes table
+type DB struct {
+	*dbutil.DB
+}
+
+// MarkUsed marks the unused recovery code of the user as used.
+// Returns false if there is no such code or it has already been used.
+func (d *DB) MarkUsed(ctx context.Context, db *gorm.DB, uid int, hashedCode string) (bool, error) {
+	res := db.WithContext(ctx).Model(d.Model).
+		Where("user_id = ? AND code = ? AND used_at IS NULL", uid, hashedCode).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
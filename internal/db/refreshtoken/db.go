@@ -0,0 +1,53 @@
+package refreshtoken
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new refresh token database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.RefreshToken{})}
+}
+
+// DB represents the client for refresh_tokens table
+type DB struct {
+	*dbutil.DB
+}
+
+// FindByToken queries for single refresh token by its hashed value
+func (d *DB) FindByToken(ctx context.Context, db *gorm.DB, hashedToken string) (*model.RefreshToken, error) {
+	rec := new(model.RefreshToken)
+	if err := d.View(ctx, db, rec, "token = ?", hashedToken); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// MarkUsed marks the token as used (rotated).
+// Returns false if the token has already been used or revoked in the meantime.
+func (d *DB) MarkUsed(ctx context.Context, db *gorm.DB, id int) (bool, error) {
+	res := db.WithContext(ctx).Model(d.Model).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+// RevokeFamily revokes all active tokens of the given family
+func (d *DB) RevokeFamily(ctx context.Context, db *gorm.DB, family string) error {
+	return db.WithContext(ctx).Model(d.Model).
+		Where("family = ? AND revoked_at IS NULL", family).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeByUserID revokes all active tokens of the given user
+func (d *DB) RevokeByUserID(ctx context.Context, db *gorm.DB, uid int) error {
+	return db.WithContext(ctx).Model(d.Model).
+		Where("user_id = ? AND revoked_at IS NULL", uid).
+		Update("revoked_at", time.Now()).Error
+}
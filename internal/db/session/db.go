@@ -0,0 +1,45 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"gorm.io/gorm"
+)
+
+// NewDB returns a new session database instance
+func NewDB() *DB {
+	return &DB{dbutil.NewDB(model.Session{})}
+}
+
+// DB represents the client for sessions table
+type DB struct {
+	*dbutil.DB
+}
+
+// FindByFamily queries for single session by its refresh token family
+func (d *DB) FindByFamily(ctx context.Context, db *gorm.DB, family string) (*model.Session, error) {
+	rec := new(model.Session)
+	if err := d.View(ctx, db, rec, "family = ?", family); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// ListActiveByUserID returns the sessions of the given user that still hold an active refresh token, most recently used first.
+// Sessions are ended by revoking their refresh token family, whether by logout, password reset or token reuse detection,
+// or by the expiration of their latest refresh token.
+func (d *DB) ListActiveByUserID(ctx context.Context, db *gorm.DB, uid int) ([]*model.Session, error) {
+	var recs []*model.Session
+	err := db.WithContext(ctx).
+		Where("user_id = ?", uid).
+		Where("EXISTS (?)", db.Session(&gorm.Session{NewDB: true}).Model(&model.RefreshToken{}).Select("1").
+			Where("refresh_tokens.family = sessions.family AND refresh_tokens.used_at IS NULL AND refresh_tokens.revoked_at IS NULL").
+			Where("refresh_tokens.expires_at > ?", time.Now())).
+		Order("last_used DESC").
+		Find(&recs).Error
+	return recs, err
+}
@@ -9,30 +9,40 @@ import (
 	"gorm.io/gorm"
 )
 
-// NewDB returns a new user database instance
+// NewDB returns a new user database instance, the queries are restricted to the members of the active organization if any
 func NewDB() *DB {
-	return &DB{dbutil.NewDB(model.User{})}
+	repo := &dbutil.Repo[model.User]{TenantScope: memberOf}
+	return &DB{DB: repo.DB(), Repo: repo}
 }
 
-// DB represents the client for user table
+// memberOf restricts the query to the members of the organization, users may belong to several organizations
+func memberOf(db *gorm.DB, orgID int) *gorm.DB {
+	members := db.Session(&gorm.Session{NewDB: true}).Model(&model.Membership{}).Select("user_id").Where("org_id = ?", orgID)
+	return db.Where("users.id IN (?)", members)
+}
+
+// DB represents the client for user table, it implements dbutil.Intf for compatibility
 type DB struct {
 	*dbutil.DB
+	// Repo is the typed repository of the users, sharing the tenant scope
+	Repo *dbutil.Repo[model.User]
 }
 
 // FindByUsername queries for single user by username
 func (d *DB) FindByUsername(ctx context.Context, db *gorm.DB, uname string) (*model.User, error) {
-	rec := new(model.User)
-	if err := d.View(ctx, db, rec, "username = ?", uname); err != nil {
-		return nil, err
-	}
-	return rec, nil
+	return d.Repo.View(ctx, db, "username = ?", uname)
+}
+
+// UpdateTOTPCounter records the time step of the latest accepted TOTP code.
+// Returns false if the same or a later code has been accepted already, i.e. the code is replayed.
+func (d *DB) UpdateTOTPCounter(ctx context.Context, db *gorm.DB, id int, counter int64) (bool, error) {
+	res := db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND totp_last_counter < ?", id, counter).
+		Update("totp_last_counter", counter)
+	return res.RowsAffected > 0, res.Error
 }
 
-// FindByRefreshToken queries for single user by refresh token
-func (d *DB) FindByRefreshToken(ctx context.Context, db *gorm.DB, token string) (*model.User, error) {
-	rec := new(model.User)
-	if err := d.View(ctx, db, rec, "refresh_token = ?", token); err != nil {
-		return nil, err
-	}
-	return rec, nil
+// FindByEmail queries for single user by email
+func (d *DB) FindByEmail(ctx context.Context, db *gorm.DB, email string) (*model.User, error) {
+	return d.Repo.View(ctx, db, "email = ?", email)
 }
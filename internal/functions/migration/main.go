@@ -3,6 +3,7 @@ package migration
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/vuduongtp/go-core/config"
@@ -26,6 +27,17 @@ type Base struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
+// CasbinRule is the casbin_rules table copy used by the migrations
+type CasbinRule struct {
+	PType string `gorm:"size:100"`
+	V0    string `gorm:"size:100"`
+	V1    string `gorm:"size:100"`
+	V2    string `gorm:"size:100"`
+	V3    string `gorm:"size:100"`
+	V4    string `gorm:"size:100"`
+	V5    string `gorm:"size:100"`
+}
+
 // Run executes the migration
 func Run() (respErr error) {
 	cfg, err := config.Load()
@@ -123,7 +135,11 @@ func Run() (respErr error) {
 					if usr.Password == "" {
 						usr.Password = usr.Username + "123!@#"
 					}
-					usr.Password = crypter.HashPassword(usr.Password)
+					hashedPwd, err := crypter.HashPassword(usr.Password)
+					if err != nil {
+						return err
+					}
+					usr.Password = hashedPwd
 					if err := tx.Create(usr).Error; err != nil {
 						return err
 					}
@@ -149,7 +165,503 @@ func Run() (respErr error) {
 				return tx.Migrator().DropTable("users", "countries")
 			},
 		},
+		// create refresh_tokens table to support multiple sessions and token rotation
+		{
+			ID: "202610181000",
+			Migrate: func(tx *gorm.DB) error {
+				type RefreshToken struct {
+					Base
+					UserID    int    `gorm:"index;not null"`
+					Family    string `gorm:"type:varchar(255);index;not null"`
+					Token     string `gorm:"type:varchar(255);uniqueIndex;not null"`
+					UsedAt    *time.Time
+					RevokedAt *time.Time
+				}
+
+				if err := tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&RefreshToken{}); err != nil {
+					return err
+				}
+
+				return tx.Migrator().DropColumn("users", "refresh_token")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				type User struct {
+					RefreshToken string `gorm:"type:varchar(255)"`
+				}
+				if err := tx.Migrator().AddColumn(&User{}, "RefreshToken"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("refresh_tokens")
+			},
+		},
+		// create tables for revoked access tokens
+		{
+			ID: "202610181100",
+			Migrate: func(tx *gorm.DB) error {
+				type RevokedToken struct {
+					JTI       string    `gorm:"type:varchar(255);primaryKey"`
+					ExpiresAt time.Time `gorm:"index"`
+					CreatedAt time.Time
+				}
+
+				type RevokedSubject struct {
+					Subject       string `gorm:"type:varchar(255);primaryKey"`
+					RevokedBefore time.Time
+					UpdatedAt     time.Time
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&RevokedToken{}, &RevokedSubject{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("revoked_tokens", "revoked_subjects")
+			},
+		},
+		// create login attempts table for login lockout
+		{
+			ID: "202610181200",
+			Migrate: func(tx *gorm.DB) error {
+				type LoginAttempt struct {
+					Key           string `gorm:"column:lock_key;type:varchar(255);primaryKey"`
+					Failures      int    `gorm:"not null;default:0"`
+					Lockouts      int    `gorm:"not null;default:0"`
+					LockedUntil   *time.Time
+					LastFailureAt time.Time
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&LoginAttempt{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("login_attempts")
+			},
+		},
+		// add TOTP two-factor authentication
+		{
+			ID: "202610181300",
+			Migrate: func(tx *gorm.DB) error {
+				type User struct {
+					TOTPSecret      string `gorm:"type:varchar(255)"`
+					TOTPEnabled     bool   `gorm:"not null;default:false"`
+					TOTPLastCounter int64  `gorm:"not null;default:0"`
+				}
+
+				type RecoveryCode struct {
+					Base
+					UserID int    `gorm:"index;not null"`
+					Code   string `gorm:"type:varchar(255);not null"`
+					UsedAt *time.Time
+				}
+
+				type MFAChallenge struct {
+					Base
+					UserID    int    `gorm:"index;not null"`
+					Token     string `gorm:"type:varchar(255);uniqueIndex;not null"`
+					ExpiresAt time.Time
+					UsedAt    *time.Time
+				}
+
+				for _, field := range []string{"TOTPSecret", "TOTPEnabled", "TOTPLastCounter"} {
+					if err := tx.Migrator().AddColumn(&User{}, field); err != nil {
+						return err
+					}
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&RecoveryCode{}, &MFAChallenge{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"totp_secret", "totp_enabled", "totp_last_counter"} {
+					if err := tx.Migrator().DropColumn("users", column); err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().DropTable("recovery_codes", "mfa_challenges")
+			},
+		},
+		// create password_resets table for self-service password reset
+		{
+			ID: "202610181400",
+			Migrate: func(tx *gorm.DB) error {
+				type PasswordReset struct {
+					Base
+					UserID    int    `gorm:"index;not null"`
+					Token     string `gorm:"type:varchar(255);uniqueIndex;not null"`
+					ExpiresAt time.Time
+					UsedAt    *time.Time
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&PasswordReset{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("password_resets")
+			},
+		},
+		// add email verification for self-registration
+		{
+			ID: "202610181500",
+			Migrate: func(tx *gorm.DB) error {
+				type User struct {
+					Pending         bool `gorm:"not null;default:false"`
+					EmailVerifiedAt *time.Time
+				}
+
+				type EmailVerification struct {
+					Base
+					UserID    int    `gorm:"index;not null"`
+					Token     string `gorm:"type:varchar(255);uniqueIndex;not null"`
+					ExpiresAt time.Time
+					UsedAt    *time.Time
+				}
+
+				for _, field := range []string{"Pending", "EmailVerifiedAt"} {
+					if err := tx.Migrator().AddColumn(&User{}, field); err != nil {
+						return err
+					}
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&EmailVerification{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"pending", "email_verified_at"} {
+					if err := tx.Migrator().DropColumn("users", column); err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().DropTable("email_verifications")
+			},
+		},
+		// create linked_identities table for social login
+		{
+			ID: "202610181600",
+			Migrate: func(tx *gorm.DB) error {
+				type LinkedIdentity struct {
+					Base
+					UserID   int    `gorm:"index;not null"`
+					Provider string `gorm:"type:varchar(100);uniqueIndex:idx_linked_identity;not null"`
+					Subject  string `gorm:"type:varchar(255);uniqueIndex:idx_linked_identity;not null"`
+					Email    string `gorm:"type:varchar(255)"`
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&LinkedIdentity{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("linked_identities")
+			},
+		},
+		// create api_keys table
+		{
+			ID: "202610181700",
+			Migrate: func(tx *gorm.DB) error {
+				type APIKey struct {
+					Base
+					UserID     int      `gorm:"index;not null"`
+					Name       string   `gorm:"type:varchar(100);not null"`
+					Prefix     string   `gorm:"type:varchar(20);not null"`
+					KeyHash    string   `gorm:"type:varchar(255);uniqueIndex;not null"`
+					Scopes     []string `gorm:"type:text;serializer:json"`
+					ExpiresAt  *time.Time
+					LastUsedAt *time.Time
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&APIKey{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("api_keys")
+			},
+		},
+		// create sessions table
+		{
+			ID: "202610181800",
+			Migrate: func(tx *gorm.DB) error {
+				type Session struct {
+					Base
+					UserID         int    `gorm:"index;not null"`
+					Family         string `gorm:"type:varchar(255);uniqueIndex;not null"`
+					UserAgent      string `gorm:"type:varchar(500)"`
+					IP             string `gorm:"type:varchar(100)"`
+					LastUsed       time.Time
+					TokenID        string `gorm:"type:varchar(255)"`
+					TokenExpiresAt time.Time
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&Session{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("sessions")
+			},
+		},
+		// create password_histories table for the password policy
+		{
+			ID: "202610181900",
+			Migrate: func(tx *gorm.DB) error {
+				type PasswordHistory struct {
+					Base
+					UserID   int    `gorm:"index;not null"`
+					Password string `gorm:"type:varchar(255);not null"`
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&PasswordHistory{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("password_histories")
+			},
+		},
+		// record who blocked an user account and why
+		{
+			ID: "202610182000",
+			Migrate: func(tx *gorm.DB) error {
+				type User struct {
+					BlockedReason string `gorm:"type:varchar(255)"`
+					BlockedAt     *time.Time
+					BlockedBy     *int
+				}
+
+				for _, field := range []string{"BlockedReason", "BlockedAt", "BlockedBy"} {
+					if err := tx.Migrator().AddColumn(&User{}, field); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"blocked_reason", "blocked_at", "blocked_by"} {
+					if err := tx.Migrator().DropColumn("users", column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		// create casbin_rules table & insert the default roles, which were hardcoded so far
+		{
+			ID: "202610182100",
+			Migrate: func(tx *gorm.DB) error {
+				type CasbinRule struct {
+					PType string `gorm:"size:100"`
+					V0    string `gorm:"size:100"`
+					V1    string `gorm:"size:100"`
+					V2    string `gorm:"size:100"`
+					V3    string `gorm:"size:100"`
+					V4    string `gorm:"size:100"`
+					V5    string `gorm:"size:100"`
+				}
+
+				if err := tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&CasbinRule{}); err != nil {
+					return err
+				}
+
+				defaultRules := []*CasbinRule{
+					// users may view & update their own account only
+					{PType: "p", V0: model.RoleUser, V1: model.ObjectUser, V2: model.ActionView},
+					{PType: "p", V0: model.RoleUser, V1: model.ObjectUser, V2: model.ActionUpdate},
+					{PType: "p", V0: model.RoleUser, V1: model.ObjectCountry, V2: model.ActionViewAll},
+					{PType: "p", V0: model.RoleAdmin, V1: model.ObjectUser, V2: model.ActionAny},
+					{PType: "p", V0: model.RoleAdmin, V1: model.ObjectCountry, V2: model.ActionAny},
+					{PType: "p", V0: model.RoleSuperAdmin, V1: model.ObjectAny, V2: model.ActionAny},
+					// roles inheritance
+					{PType: "g", V0: model.RoleAdmin, V1: model.RoleUser},
+					{PType: "g", V0: model.RoleSuperAdmin, V1: model.RoleAdmin},
+				}
+				for _, rec := range defaultRules {
+					if err := tx.Create(rec).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("casbin_rules")
+			},
+		},
+		// create organizations & memberships tables, add the domain to the casbin rules
+		{
+			ID: "202610182200",
+			Migrate: func(tx *gorm.DB) error {
+				type Organization struct {
+					Base
+					Name string `gorm:"type:varchar(255);not null"`
+					Slug string `gorm:"type:varchar(100);uniqueIndex;not null"`
+				}
+				type Membership struct {
+					Base
+					OrgID  int    `gorm:"uniqueIndex:idx_memberships_org_user;not null"`
+					UserID int    `gorm:"uniqueIndex:idx_memberships_org_user;not null"`
+					Role   string `gorm:"type:varchar(255);not null"`
+				}
+
+				if err := tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&Organization{}, &Membership{}); err != nil {
+					return err
+				}
+
+				// the existing rules apply to all domains
+				return migrateCasbinRules(tx, func(rule *CasbinRule) {
+					switch rule.PType {
+					case "p":
+						rule.V1, rule.V2, rule.V3 = "*", rule.V1, rule.V2
+					case "g":
+						rule.V2 = "*"
+					}
+				}, &CasbinRule{PType: "p", V0: model.RoleAdmin, V1: "*", V2: model.ObjectMembership, V3: model.ActionAny})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("memberships", "organizations"); err != nil {
+					return err
+				}
+
+				// the rules of specific domains cannot be kept
+				if err := tx.Where("p_type = ? AND v1 <> ?", "p", "*").Or("p_type = ? AND v2 <> ?", "g", "*").
+					Delete(&CasbinRule{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Where("p_type = ? AND v2 = ?", "p", model.ObjectMembership).Delete(&CasbinRule{}).Error; err != nil {
+					return err
+				}
+				return migrateCasbinRules(tx, func(rule *CasbinRule) {
+					switch rule.PType {
+					case "p":
+						rule.V1, rule.V2, rule.V3 = rule.V2, rule.V3, ""
+					case "g":
+						rule.V2 = ""
+					}
+				})
+			},
+		},
+		// create casbin_revisions table, the RBAC policy is reloaded by the instances when its revision changes
+		{
+			ID: "202610182300",
+			Migrate: func(tx *gorm.DB) error {
+				type CasbinRevision struct {
+					ID        int   `gorm:"primaryKey;autoIncrement:false"`
+					Revision  int64 `gorm:"not null"`
+					UpdatedAt time.Time
+				}
+
+				return tx.Set("gorm:table_options", defaultTableOpts).AutoMigrate(&CasbinRevision{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("casbin_revisions")
+			},
+		},
+		// add expiration to revoked subjects, the records are deleted once all the revoked tokens have expired
+		{
+			ID: "202610190100",
+			Migrate: func(tx *gorm.DB) error {
+				type RevokedSubject struct {
+					ExpiresAt *time.Time `gorm:"index"`
+				}
+
+				return tx.Migrator().AddColumn(&RevokedSubject{}, "ExpiresAt")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn("revoked_subjects", "expires_at")
+			},
+		},
+		// add expiration to refresh tokens, the active ones expire as of now like the newly issued ones
+		{
+			ID: "202610190200",
+			Migrate: func(tx *gorm.DB) error {
+				type RefreshToken struct {
+					ExpiresAt time.Time `gorm:"index"`
+				}
+
+				if err := tx.Migrator().AddColumn(&RefreshToken{}, "ExpiresAt"); err != nil {
+					return err
+				}
+				// see auth.RefreshTokenDuration
+				return tx.Model(&RefreshToken{}).Where("1 = 1").Update("expires_at", time.Now().Add(30*24*time.Hour)).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn("refresh_tokens", "expires_at")
+			},
+		},
+		// encrypt the TOTP secrets at rest with TOTP_ENCRYPTION_KEY
+		{
+			ID: "202610190300",
+			Migrate: func(tx *gorm.DB) error {
+				return migrateTOTPSecrets(tx, cfg.TOTPEncryptionKey, (*crypter.Cipher).Encrypt)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return migrateTOTPSecrets(tx, cfg.TOTPEncryptionKey, (*crypter.Cipher).Decrypt)
+			},
+		},
+		// unique emails of the users, the deleted users are excluded except on MySQL which has no partial indexes
+		{
+			ID: "202610190400",
+			Migrate: func(tx *gorm.DB) error {
+				mysql := tx.Dialector.Name() == "mysql"
+				q := tx.Table("users").Select("email").Group("email").Having("COUNT(*) > 1")
+				if !mysql {
+					q = q.Where("deleted_at IS NULL")
+				}
+				var emails []string
+				if err := q.Pluck("email", &emails).Error; err != nil {
+					return err
+				}
+				if len(emails) > 0 {
+					return fmt.Errorf("duplicate user emails must be resolved first: %s", strings.Join(emails, ", "))
+				}
+
+				sql := "CREATE UNIQUE INDEX idx_users_email ON users (email)"
+				if !mysql {
+					sql += " WHERE deleted_at IS NULL"
+				}
+				return tx.Exec(sql).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropIndex("users", "idx_users_email")
+			},
+		},
 	})
 
 	return nil
 }
+
+// migrateCasbinRules rewrites all casbin rules with the given function and appends the new rules,
+// the rules are re-inserted since the table has no primary key
+func migrateCasbinRules(tx *gorm.DB, fn func(*CasbinRule), newRules ...*CasbinRule) error {
+	var rules []*CasbinRule
+	if err := tx.Find(&rules).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("1 = 1").Delete(&CasbinRule{}).Error; err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		fn(rule)
+	}
+	rules = append(rules, newRules...)
+	if len(rules) == 0 {
+		return nil
+	}
+	return tx.Create(&rules).Error
+}
+
+// migrateTOTPSecrets rewrites the TOTP secrets of the users with the given function of the cipher,
+// the key is only required if there are secrets
+func migrateTOTPSecrets(tx *gorm.DB, key string, fn func(*crypter.Cipher, string) (string, error)) error {
+	type User struct {
+		ID         int
+		TOTPSecret string
+	}
+
+	var users []*User
+	if err := tx.Where("totp_secret <> ?", "").Find(&users).Error; err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	c, err := crypter.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("TOTP_ENCRYPTION_KEY: %w", err)
+	}
+	for _, usr := range users {
+		secret, err := fn(c, usr.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("id = ?", usr.ID).Update("totp_secret", secret).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
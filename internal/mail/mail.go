@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"context"
+	"embed"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/util/email"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// New creates new mailer, webURL is the base URL of the web app that the links in emails point to
+func New(sender email.Sender, webURL string) *Mailer {
+	return &Mailer{sender: sender, webURL: strings.TrimRight(webURL, "/")}
+}
+
+// Mailer renders the emails from templates and sends them
+type Mailer struct {
+	sender email.Sender
+	webURL string
+}
+
+// SendPasswordReset sends the link to reset the password of the user
+func (m *Mailer) SendPasswordReset(ctx context.Context, u *model.User, token string, expiresIn time.Duration) error {
+	return m.send(ctx, u.Email, "Reset your password", "password_reset", map[string]interface{}{
+		"Name":      displayName(u),
+		"URL":       m.link("/reset-password", token),
+		"ExpiresIn": int(expiresIn.Minutes()),
+	})
+}
+
+// SendEmailVerification sends the link to verify the email of a self-registered user
+func (m *Mailer) SendEmailVerification(ctx context.Context, u *model.User, token string, expiresIn time.Duration) error {
+	return m.send(ctx, u.Email, "Verify your email", "email_verification", map[string]interface{}{
+		"Name":      displayName(u),
+		"URL":       m.link("/verify-email", token),
+		"ExpiresIn": int(expiresIn.Hours()),
+	})
+}
+
+// SendAccountExists notifies the user that the email has been used again to register, instead of telling the registrant
+func (m *Mailer) SendAccountExists(ctx context.Context, u *model.User) error {
+	return m.send(ctx, u.Email, "You already have an account", "account_exists", map[string]interface{}{
+		"Name":     displayName(u),
+		"Username": u.Username,
+		"URL":      m.webURL + "/forgot-password",
+	})
+}
+
+// SendUsernameTaken notifies the registrant that the username is taken, so that only the owner of the email is told.
+// The registrant is not a user yet, only the registration data is set
+func (m *Mailer) SendUsernameTaken(ctx context.Context, registrant *model.User) error {
+	return m.send(ctx, registrant.Email, "Your registration could not be completed", "username_taken", map[string]interface{}{
+		"Name":     displayName(registrant),
+		"Username": registrant.Username,
+		"URL":      m.webURL + "/register",
+	})
+}
+
+// SendAccountBlocked notifies the user that the account has been blocked for the given reason
+func (m *Mailer) SendAccountBlocked(ctx context.Context, u *model.User, reason string) error {
+	return m.send(ctx, u.Email, "Your account has been blocked", "account_blocked", map[string]interface{}{
+		"Name":   displayName(u),
+		"Reason": reason,
+	})
+}
+
+// SendAccountUnblocked notifies the user that the account has been unblocked
+func (m *Mailer) SendAccountUnblocked(ctx context.Context, u *model.User) error {
+	return m.send(ctx, u.Email, "Your account has been unblocked", "account_unblocked", map[string]interface{}{
+		"Name": displayName(u),
+	})
+}
+
+// send renders both HTML & text bodies of the template and sends the email, it is cancelled along with ctx
+func (m *Mailer) send(ctx context.Context, to, subject, tpl string, data interface{}) error {
+	html, err := email.ParseFromFSTemplate(templates, "templates/"+tpl+".html", data)
+	if err != nil {
+		return err
+	}
+	text, err := email.ParseFromFSTemplate(templates, "templates/"+tpl+".txt", data)
+	if err != nil {
+		return err
+	}
+
+	return m.sender.SendEmailWithContext(ctx, email.Input{
+		To:       []string{to},
+		Subject:  subject,
+		HTMLBody: html,
+		TextBody: text,
+	})
+}
+
+// link returns the web app URL of the given path carrying the token
+func (m *Mailer) link(path, token string) string {
+	return m.webURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+func displayName(u *model.User) string {
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		return name
+	}
+	return u.Username
+}
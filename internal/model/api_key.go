@@ -0,0 +1,40 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey represents the personal API key model, used by scripts & integrations to act on behalf of its user
+type APIKey struct {
+	Base
+	UserID int    `json:"user_id" gorm:"index;not null"`
+	Name   string `json:"name" gorm:"type:varchar(100);not null"`
+	// Prefix holds the first characters of the key, to identify it in lists
+	Prefix string `json:"prefix" gorm:"type:varchar(20);not null"`
+	// KeyHash holds the hashed value of the key, the raw value is only returned on creation
+	KeyHash string `json:"-" gorm:"type:varchar(255);uniqueIndex;not null"`
+	// Scopes restrict the permissions of the user's role, in object:action format. e.g: country:view_all, user:*
+	Scopes     []string   `json:"scopes" gorm:"type:text;serializer:json"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+} // @name APIKey
+
+// IsValid reports whether the key has not expired at the given time
+func (k *APIKey) IsValid(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// Scope returns the scope of the given RBAC object & action
+func Scope(object, action string) string {
+	return object + ":" + action
+}
+
+// ParseScope splits the scope into RBAC object & action, returns false if the format is invalid
+func ParseScope(scope string) (string, string, bool) {
+	parts := strings.SplitN(scope, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -1,6 +1,11 @@
 package model
 
 import (
+	"strconv"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/rbac"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -10,6 +15,10 @@ type AuthToken struct {
 	TokenType    string `json:"token_type,omitempty"`
 	ExpiresIn    int    `json:"expires_in,omitempty"`
 	RefreshToken string `json:"refresh_token,omitempty"`
+	// MFARequired is set when the user has to pass the second factor, the MFAToken is then
+	// returned instead of the tokens above and must be exchanged on /login/2fa
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
 } // @name AuthToken
 
 // AuthUser represents data stored in JWT token for user
@@ -18,9 +27,118 @@ type AuthUser struct {
 	Username string
 	Email    string
 	Role     string
+	// TokenID is the unique ID (jti) of the access token in use
+	TokenID string
+	// TokenExpiresAt is the expiration time of the access token in use
+	TokenExpiresAt time.Time
+	// SessionID is the ID of the login session of the access token in use
+	SessionID int
+	// APIKeyID is the ID of the API key in use, if the user is authenticated by API key
+	APIKeyID int
+	// Scopes restrict the permissions of the role when authenticated by API key, see APIKey.Scopes
+	Scopes []string
+	// ActorID & ActorUsername identify the real user when the token is issued by impersonation
+	ActorID       int
+	ActorUsername string
+	// OrgID is the active organization, the role is then the one of the user's membership in it
+	OrgID int
+	// GlobalRole is the role of the user outside of the organizations, see Global.
+	// Empty for the impersonation tokens pinned to an organization, which are limited to the membership
+	GlobalRole string
+	// IP is the client IP of the request, evaluated by the conditions of the RBAC policies
+	IP string
+}
+
+// Domain returns the RBAC domain of the user, i.e. the active organization
+func (u *AuthUser) Domain() string {
+	if u.OrgID == 0 {
+		return rbac.DomainAny
+	}
+	return strconv.Itoa(u.OrgID)
+}
+
+// Global returns the user outside of the active organization, to check the permissions on the resources
+// shared by all organizations, e.g. the user accounts
+func (u *AuthUser) Global() *AuthUser {
+	if u.OrgID == 0 {
+		return u
+	}
+	global := *u
+	global.OrgID = 0
+	global.Role = u.GlobalRole
+	return &global
+}
+
+// IsImpersonated reports whether the user is impersonated by another user (the actor)
+func (u *AuthUser) IsImpersonated() bool {
+	return u.ActorID != 0
+}
+
+// HasScope reports whether the user may perform the action on the object within its scopes.
+// Users authenticated by access token are not restricted.
+func (u *AuthUser) HasScope(object, action string) bool {
+	if u.APIKeyID == 0 {
+		return true
+	}
+	for _, scope := range u.Scopes {
+		obj, act, ok := ParseScope(scope)
+		if !ok {
+			continue
+		}
+		if (obj == ObjectAny || obj == object) && (act == ActionAny || act == action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Attrs returns the attributes of the request, i.e. the client IP & the current time, with the given resource attributes
+func (u *AuthUser) Attrs(resource ...rbac.Attrs) rbac.Attrs {
+	return rbac.Attrs{rbac.AttrIP: u.IP, rbac.AttrTime: time.Now()}.Merge(resource...)
+}
+
+// Enforce reports whether the user may perform the action on the object, by its role in the active organization and within its scopes.
+// Enforcement errors deny the action.
+func (u *AuthUser) Enforce(e rbac.Intf, object, action string) bool {
+	return u.EnforceAttrs(e, object, action, nil)
+}
+
+// EnforceAttrs reports whether the user may perform the action on a resource of the object having the given attributes,
+// which are evaluated by the conditions of the policies along with the request attributes, see Attrs
+func (u *AuthUser) EnforceAttrs(e rbac.Intf, object, action string, attrs rbac.Attrs) bool {
+	if !u.HasScope(object, action) {
+		return false
+	}
+	ok, err := e.Enforce(u.Role, u.Domain(), object, action, u.Attrs(attrs))
+	return ok && err == nil
+}
+
+// EnforceOwner reports whether the user may perform the action on a resource of the object owned by the given user.
+// The `_all` action is checked first, then its owner-scoped variant if the user owns the resource. e.g: update_all -> update
+func (u *AuthUser) EnforceOwner(e rbac.Intf, object, action string, ownerID int) bool {
+	return u.EnforceOwnerAttrs(e, object, action, ownerID, nil)
+}
+
+// EnforceOwnerAttrs is EnforceOwner for a resource having the given attributes, see EnforceAttrs
+func (u *AuthUser) EnforceOwnerAttrs(e rbac.Intf, object, action string, ownerID int, attrs rbac.Attrs) bool {
+	if u.EnforceAttrs(e, object, action, attrs) {
+		return true
+	}
+	owned := rbac.OwnerAction(action)
+	return u.ID != 0 && u.ID == ownerID && owned != action && u.EnforceAttrs(e, object, owned, attrs)
 }
 
 // Auth represents auth interface
 type Auth interface {
 	User(echo.Context) *AuthUser
 }
+
+// LockKeyAccount returns the lockout key of the account with the given username
+func LockKeyAccount(username string) string {
+	return "account:" + username
+}
+
+// LockKeyIP returns the lockout key of the given client IP
+func LockKeyIP(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// EmailVerification represents a single-use token to verify the email of a self-registered user
+type EmailVerification struct {
+	Base
+	UserID int `json:"user_id" gorm:"index;not null"`
+	// Token holds the hashed value of the verification token, the raw value is only sent to the user by email
+	Token     string     `json:"-" gorm:"type:varchar(255);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+} // @name EmailVerification
+
+// IsValid reports whether the token can still be used at the given time
+func (v *EmailVerification) IsValid(now time.Time) bool {
+	return v.UsedAt == nil && v.ExpiresAt.After(now)
+}
@@ -0,0 +1,12 @@
+package model
+
+// LinkedIdentity represents an identity at an external provider (Google, GitHub, OIDC...) linked to an user
+type LinkedIdentity struct {
+	Base
+	UserID int `json:"user_id" gorm:"index;not null"`
+	// Name of the provider. e.g: google
+	Provider string `json:"provider" gorm:"type:varchar(100);uniqueIndex:idx_linked_identity;not null"`
+	// Unique ID of the user at the provider
+	Subject string `json:"subject" gorm:"type:varchar(255);uniqueIndex:idx_linked_identity;not null"`
+	Email   string `json:"email" gorm:"type:varchar(255)"`
+} // @name LinkedIdentity
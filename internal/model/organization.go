@@ -0,0 +1,16 @@
+package model
+
+// Organization represents the organization model, each organization is a tenant isolated from the others
+type Organization struct {
+	Base
+	Name string `json:"name" gorm:"type:varchar(255);not null"`
+	Slug string `json:"slug" gorm:"type:varchar(100);uniqueIndex;not null"`
+} // @name Organization
+
+// Membership represents the membership of an user in an organization, with the role of the user within it
+type Membership struct {
+	Base
+	OrgID  int    `json:"org_id" gorm:"uniqueIndex:idx_memberships_org_user;not null"`
+	UserID int    `json:"user_id" gorm:"uniqueIndex:idx_memberships_org_user;not null"`
+	Role   string `json:"role" gorm:"type:varchar(255);not null"`
+} // @name Membership
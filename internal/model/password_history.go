@@ -0,0 +1,9 @@
+package model
+
+// PasswordHistory represents a previous password of a user, kept to prevent reusing it
+type PasswordHistory struct {
+	Base
+	UserID int `json:"user_id" gorm:"index;not null"`
+	// Password holds the hashed value of the previous password
+	Password string `json:"-" gorm:"type:varchar(255);not null"`
+} // @name PasswordHistory
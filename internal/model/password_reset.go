@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// PasswordReset represents a single-use token to reset the password of an user
+type PasswordReset struct {
+	Base
+	UserID int `json:"user_id" gorm:"index;not null"`
+	// Token holds the hashed value of the reset token, the raw value is only sent to the user by email
+	Token     string     `json:"-" gorm:"type:varchar(255);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+} // @name PasswordReset
+
+// IsValid reports whether the token can still be used at the given time
+func (r *PasswordReset) IsValid(now time.Time) bool {
+	return r.UsedAt == nil && r.ExpiresAt.After(now)
+}
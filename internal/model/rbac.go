@@ -7,14 +7,19 @@ const (
 	RoleUser       = "user"
 )
 
-// AvailableRoles for validation
-var AvailableRoles = []string{RoleAdmin, RoleUser}
+// BuiltinRoles are used by the application itself & cannot be deleted
+var BuiltinRoles = []string{RoleSuperAdmin, RoleAdmin, RoleUser}
 
 // RBAC objects
 const (
 	ObjectAny     = "*"
 	ObjectUser    = "user"
 	ObjectCountry = "country"
+	// Roles, policies & role inheritance
+	ObjectRBAC = "rbac"
+	// Organizations (tenants) & their members
+	ObjectOrganization = "organization"
+	ObjectMembership   = "membership"
 )
 
 // RBAC actions
@@ -28,4 +33,61 @@ const (
 	ActionUpdate    = "update"
 	ActionDeleteAll = "delete_all"
 	ActionDelete    = "delete"
+	// Listing & clearing login lockouts
+	ActionManageLocks = "manage_locks"
+	// Listing & revoking login sessions of other users
+	ActionManageSessions = "manage_sessions"
+	// Acting as another user with a short-lived token
+	ActionImpersonate = "impersonate"
+	// Blocking & unblocking user accounts
+	ActionBlock = "block"
 )
+
+// RBAC resource attributes, evaluated by the conditions of the policies. e.g: attr(r.attrs, "role") == "user"
+const (
+	// The role of the user being managed, within the active organization if any
+	AttrRole = "role"
+)
+
+// Role represents a role with its own permissions & the roles it inherits from
+type Role struct {
+	Name        string        `json:"name"`
+	Inherits    []string      `json:"inherits"`
+	Permissions []*Permission `json:"permissions"`
+} // @name Role
+
+// Permission represents an action allowed on an object within a domain
+type Permission struct {
+	Domain    string `json:"domain"`
+	Object    string `json:"object"`
+	Action    string `json:"action"`
+	Condition string `json:"condition,omitempty"`
+} // @name Permission
+
+// Policy represents a permission granted to a role within a domain, i.e. an organization ID or "*" for all.
+// The permission is granted only if the condition on the request & resource attributes is met, if any
+type Policy struct {
+	Role      string `json:"role"`
+	Domain    string `json:"domain"`
+	Object    string `json:"object"`
+	Action    string `json:"action"`
+	Condition string `json:"condition,omitempty"`
+} // @name Policy
+
+// RoleInheritance represents a role inheriting the permissions of its parent role within a domain
+type RoleInheritance struct {
+	Role   string `json:"role"`
+	Parent string `json:"parent"`
+	Domain string `json:"domain"`
+} // @name RoleInheritance
+
+// RoutePermission represents the permission required by an API route, with the roles granted it in all organizations
+type RoutePermission struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Object string `json:"object"`
+	Action string `json:"action"`
+	// Owned tells whether the owners of the resources may be allowed without the permission, e.g. by the owner-scoped action
+	Owned bool     `json:"owned"`
+	Roles []string `json:"roles"`
+} // @name RoutePermission
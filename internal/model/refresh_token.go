@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// RefreshToken represents the refresh token model.
+// All tokens rotated from the same login share the same family.
+type RefreshToken struct {
+	Base
+	UserID int    `json:"user_id" gorm:"index;not null"`
+	Family string `json:"family" gorm:"type:varchar(255);index;not null"`
+	// Token holds the hashed value of the refresh token, the raw value is only returned to the client
+	Token     string     `json:"-" gorm:"type:varchar(255);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+} // @name RefreshToken
+
+// IsExpired reports whether the token has expired at the given time
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// IsUsed reports whether the token has already been rotated
+func (t *RefreshToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
+
+// IsRevoked reports whether the token has been revoked
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
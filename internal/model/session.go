@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Session represents a login of an user on a device, it lasts as long as its refresh token family
+type Session struct {
+	Base
+	UserID int `json:"user_id" gorm:"index;not null"`
+	// Family of the refresh tokens issued to the session
+	Family    string    `json:"-" gorm:"type:varchar(255);uniqueIndex;not null"`
+	UserAgent string    `json:"user_agent" gorm:"type:varchar(500)"`
+	IP        string    `json:"ip" gorm:"type:varchar(100)"`
+	LastUsed  time.Time `json:"last_used"`
+	// ID & expiration time of the latest access token, it is revoked along with the session
+	TokenID        string    `json:"-" gorm:"type:varchar(255)"`
+	TokenExpiresAt time.Time `json:"-"`
+	// Current is set when the session is the one making the request
+	Current bool `json:"current" gorm:"-"`
+} // @name Session
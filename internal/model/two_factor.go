@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// RecoveryCode represents a single-use code to pass the two-factor authentication without the authenticator app
+type RecoveryCode struct {
+	Base
+	UserID int `json:"user_id" gorm:"index;not null"`
+	// Code holds the hashed value of the recovery code, the raw value is only returned to the user
+	Code   string     `json:"-" gorm:"type:varchar(255);not null"`
+	UsedAt *time.Time `json:"used_at,omitempty"`
+} // @name RecoveryCode
+
+// MFAChallenge represents a login waiting for the second factor
+type MFAChallenge struct {
+	Base
+	UserID int `json:"user_id" gorm:"index;not null"`
+	// Token holds the hashed value of the challenge token, the raw value is only returned to the client
+	Token     string     `json:"-" gorm:"type:varchar(255);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+} // @name MFAChallenge
+
+// IsValid reports whether the challenge can still be completed at the given time
+func (c *MFAChallenge) IsValid(now time.Time) bool {
+	return c.UsedAt == nil && c.ExpiresAt.After(now)
+}
@@ -7,14 +7,26 @@ type User struct {
 	Base
 	FirstName string `json:"first_name" gorm:"type:varchar(255)"`
 	LastName  string `json:"last_name" gorm:"type:varchar(255)"`
-	Email     string `json:"email" gorm:"type:varchar(255)"`
-	Mobile    string `json:"mobile,omitempty" gorm:"type:varchar(255)"`
+	// Email is unique among the users not deleted
+	Email  string `json:"email" gorm:"type:varchar(255);uniqueIndex:idx_users_email,where:deleted_at IS NULL"`
+	Mobile string `json:"mobile,omitempty" gorm:"type:varchar(255)"`
 
-	Username     string     `json:"username" gorm:"type:varchar(255);unique_index;not null"`
-	Password     string     `json:"-" gorm:"type:varchar(255);not null"`
-	LastLogin    *time.Time `json:"last_login,omitempty"`
-	Blocked      bool       `json:"blocked" gorm:"not null;default:false"`
-	RefreshToken string     `json:"-" gorm:"type:varchar(255);unique_index"`
+	Username  string     `json:"username" gorm:"type:varchar(255);unique_index;not null"`
+	Password  string     `json:"-" gorm:"type:varchar(255);not null"`
+	LastLogin *time.Time `json:"last_login,omitempty"`
+	Blocked   bool       `json:"blocked" gorm:"not null;default:false"`
+	// Reason, time & the user who blocked the account, see ActionBlock
+	BlockedReason string     `json:"blocked_reason,omitempty" gorm:"type:varchar(255)"`
+	BlockedAt     *time.Time `json:"blocked_at,omitempty"`
+	BlockedBy     *int       `json:"blocked_by,omitempty"`
+	// Pending is set for self-registered users until their email is verified
+	Pending         bool       `json:"pending" gorm:"not null;default:false"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
 
 	Role string `json:"role" gorm:"varchar(255)"`
+
+	// TOTP two-factor authentication, the secret is encrypted at rest and pending until the first code is verified
+	TOTPSecret      string `json:"-" gorm:"type:varchar(255)"`
+	TOTPEnabled     bool   `json:"totp_enabled" gorm:"not null;default:false"`
+	TOTPLastCounter int64  `json:"-" gorm:"not null;default:0"`
 } // @name User
@@ -1,30 +1,45 @@
 package rbac
 
 import (
+	"time"
+
 	"github.com/vuduongtp/go-core/internal/model"
 	"github.com/vuduongtp/go-core/pkg/rbac"
-)
-
-// New returns new RBAC service
-func New(enableLog bool) *rbac.RBAC {
-	r := rbac.NewWithConfig(rbac.Config{EnableLog: enableLog})
-
-	// Add permission for user role
-	r.AddPolicy(model.RoleUser, model.ObjectUser, model.ActionViewAll)
-	r.AddPolicy(model.RoleUser, model.ObjectCountry, model.ActionViewAll)
+	"github.com/vuduongtp/go-core/pkg/rbac/casbinadapter"
 
-	// Add permission for admin role
-	r.AddPolicy(model.RoleAdmin, model.ObjectUser, model.ActionAny)
-	r.AddPolicy(model.RoleAdmin, model.ObjectCountry, model.ActionAny)
-
-	// Add permission for superadmin role
-	r.AddPolicy(model.RoleSuperAdmin, model.ObjectAny, model.ActionAny)
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
 
-	// Roles inheritance
-	r.AddGroupingPolicy(model.RoleAdmin, model.RoleUser)
-	r.AddGroupingPolicy(model.RoleSuperAdmin, model.RoleAdmin)
+// New returns new RBAC service, the roles & policies are loaded from the casbin_rules table
+// and the changes made via the /v1/rbac endpoints are saved back to it.
+// The policy is reloaded when changed by another instance, checked at the given interval unless zero.
+// Permissions are evaluated per domain, i.e. per organization, see model.AuthUser.Domain,
+// and the policies may have conditions on the request & resource attributes, see model.AuthUser.EnforceAttrs
+func New(db *gorm.DB, enableLog bool, watchInterval time.Duration) (*rbac.RBAC, error) {
+	cfg := rbac.Config{Model: rbac.NewABACWithDomainModel(), GormDB: db, EnableLog: enableLog}
+	if watchInterval > 0 {
+		w, err := casbinadapter.NewWatcher(db, watchInterval)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Watcher = w
+	}
+
+	r, err := rbac.NewWithConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	r.GetModel().PrintPolicy()
 
-	return r
+	return r, nil
+}
+
+// Middleware returns the middleware checking the permissions required by the routes with the authenticated user,
+// see rbac.Require & model.AuthUser.Enforce
+func Middleware(e rbac.Intf, auth model.Auth) echo.MiddlewareFunc {
+	return rbac.Middleware(func(c echo.Context, object, action string) bool {
+		return auth.User(c).Enforce(e, object, action)
+	})
 }
@@ -0,0 +1,77 @@
+package passwordutil
+
+import (
+	"context"
+	"strings"
+
+	"github.com/vuduongtp/go-core/internal/model"
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
+
+	"gorm.io/gorm"
+)
+
+// New creates new password checker, the new passwords of the users are checked against the policy
+// and the recent passwords kept by phdb
+func New(phdb HistoryDB, cr Crypter, policy Policy) *Checker {
+	return &Checker{phdb: phdb, cr: cr, policy: policy}
+}
+
+// Checker checks the new passwords of the users, shared by the password change & reset
+type Checker struct {
+	phdb   HistoryDB
+	cr     Crypter
+	policy Policy
+}
+
+// HistoryDB represents password history repository interface
+type HistoryDB interface {
+	dbutil.Intf
+	ListRecent(context.Context, *gorm.DB, int, int) ([]string, error)
+	Prune(context.Context, *gorm.DB, int, int) error
+}
+
+// Crypter represents security interface
+type Crypter interface {
+	CompareHashAndPassword(hasedPwd string, rawPwd string) bool
+}
+
+// Policy represents password policy interface
+type Policy interface {
+	Validate(string, ...string) error
+	HistorySize() int
+}
+
+// Check checks the new password against the policy, including the user information and the password history.
+// Errors of the breached password source are only logged, so that they do not block password changes.
+func (c *Checker) Check(ctx context.Context, db *gorm.DB, usr *model.User, password string) error {
+	err := c.policy.Validate(password, usr.Username, usr.Email)
+	if err == nil && c.policy.HistorySize() > 0 {
+		hashes, herr := c.phdb.ListRecent(ctx, db, usr.ID, c.policy.HistorySize()-1)
+		if herr != nil {
+			return server.NewHTTPInternalError("Error checking password history").SetInternal(herr)
+		}
+		err = passwordpolicy.CheckHistory(password, append([]string{usr.Password}, hashes...), c.cr.CompareHashAndPassword)
+	}
+	if perr, ok := passwordpolicy.AsError(err); ok {
+		return server.NewHTTPValidationError("NewPassword " + strings.Join(perr.Messages(), ", "))
+	}
+	if err != nil {
+		logger.LogErrorf(ctx, "error checking breached password: %+v", err)
+	}
+	return nil
+}
+
+// Record keeps the current password of the user in the history, as it is being replaced within the transaction
+func (c *Checker) Record(ctx context.Context, tx *gorm.DB, usr *model.User) error {
+	keep := c.policy.HistorySize() - 1
+	if keep <= 0 {
+		return nil
+	}
+	if err := c.phdb.Create(ctx, tx, &model.PasswordHistory{UserID: usr.ID, Password: usr.Password}); err != nil {
+		return err
+	}
+	return c.phdb.Prune(ctx, tx, usr.ID, keep)
+}
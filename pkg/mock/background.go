@@ -0,0 +1,16 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/vuduongtp/go-core/pkg/util/background"
+)
+
+// Background runs the background tasks right away, so that their results can be checked once the call returns
+type Background struct{}
+
+// Go runs the task, its error is ignored as it would only be logged
+func (Background) Go(ctx context.Context, fn background.Task) bool {
+	_ = fn(ctx)
+	return true
+}
@@ -0,0 +1,31 @@
+package mock
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/util/crypter"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// DB returns the database of the test with the tables of the given models migrated.
+// It is a sqlite file removed with the test, so that it can be shared by concurrent connections.
+func DB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Crypter returns the crypter service hashing the passwords at the minimum cost, to keep the tests fast
+func Crypter() *crypter.Service {
+	return crypter.NewWithConfig(crypter.Config{Bcrypt: crypter.BcryptHasher{Cost: bcrypt.MinCost}})
+}
@@ -0,0 +1,21 @@
+package mock
+
+import "strings"
+
+// Enforcer grants the permissions listed as "role object action" in all domains, the conditions are not evaluated
+type Enforcer map[string]bool
+
+// Enforce reports whether the permission of the role, object & action requested is listed
+func (e Enforcer) Enforce(rvals ...interface{}) (bool, error) {
+	return e[rvals[0].(string)+" "+rvals[2].(string)+" "+rvals[3].(string)], nil
+}
+
+// RoleExists reports whether any permission of the role is listed
+func (e Enforcer) RoleExists(role string) bool {
+	for p := range e {
+		if strings.HasPrefix(p, role+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Knetic/govaluate"
+	"github.com/casbin/casbin/v2/util"
+)
+
+// Attrs holds the attributes of the request & the resource, evaluated by the conditions of the ABAC models.
+// e.g: Attrs{AttrIP: "10.0.0.1", "role": "user"}
+type Attrs map[string]interface{}
+
+// Request attributes
+const (
+	// AttrIP is the client IP of the request, as string
+	AttrIP = "ip"
+	// AttrTime is the time of the request, as time.Time
+	AttrTime = "time"
+)
+
+// Merge returns a copy of the attributes overridden by the given ones
+func (a Attrs) Merge(others ...Attrs) Attrs {
+	res := make(Attrs, len(a))
+	for k, v := range a {
+		res[k] = v
+	}
+	for _, o := range others {
+		for k, v := range o {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// Conditions returns the functions available in the conditions of the policies, they are registered on the enforcer by NewWithConfig.
+//   - attr(r.attrs, name): the value of the attribute, nil if not set. e.g: attr(r.attrs, "role") == "user"
+//   - ipInRange(ip, cidr...): whether the IP belongs to one of the CIDR ranges. e.g: ipInRange(attr(r.attrs, "ip"), "10.0.0.0/8")
+//   - timeBetween(t, start, end): whether the time of day is within [start, end), in 15:04 format & UTC.
+//     The range wraps around midnight if start is after end. e.g: timeBetween(attr(r.attrs, "time"), "08:00", "18:00")
+//
+// As the attributes may be missing, the conditions should compare them with the allowed values rather than the forbidden ones.
+func Conditions() map[string]govaluate.ExpressionFunction {
+	return map[string]govaluate.ExpressionFunction{
+		"attr":        attrFunc,
+		"ipInRange":   ipInRangeFunc,
+		"timeBetween": timeBetweenFunc,
+	}
+}
+
+// MaxConditionLength is the maximum length of the conditions, i.e. the size of the columns of the rules, see casbinadapter.CasbinRule
+const MaxConditionLength = 100
+
+// ValidateCondition checks the syntax & the length of a policy condition, using the functions of Conditions
+func ValidateCondition(cond string) error {
+	if cond == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(cond) > MaxConditionLength {
+		return fmt.Errorf("the condition exceeds %d characters", MaxConditionLength)
+	}
+	_, err := govaluate.NewEvaluableExpressionWithFunctions(util.EscapeAssertion(cond), Conditions())
+	return err
+}
+
+func attrFunc(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("attr: expected 2 arguments, got %d", len(args))
+	}
+	name, ok := args[1].(string)
+	if !ok {
+		return nil, errors.New("attr: the name must be a string")
+	}
+	attrs, _ := args[0].(Attrs)
+	return attrs[name], nil
+}
+
+func ipInRangeFunc(args ...interface{}) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("ipInRange: expected at least 2 arguments, got %d", len(args))
+	}
+	s, _ := args[0].(string)
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false, nil
+	}
+	for _, arg := range args[1:] {
+		cidr, ok := arg.(string)
+		if !ok {
+			return nil, errors.New("ipInRange: the ranges must be strings")
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("ipInRange: %w", err)
+		}
+		if ipNet.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func timeBetweenFunc(args ...interface{}) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("timeBetween: expected 3 arguments, got %d", len(args))
+	}
+	start, err := parseTimeOfDay(args[1])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTimeOfDay(args[2])
+	if err != nil {
+		return nil, err
+	}
+	t, ok := args[0].(time.Time)
+	if !ok {
+		return false, nil
+	}
+	t = t.UTC()
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if start <= end {
+		return start <= now && now < end, nil
+	}
+	return now >= start || now < end, nil
+}
+
+// parseTimeOfDay returns the duration since midnight of the time of day in 15:04 format
+func parseTimeOfDay(arg interface{}) (time.Duration, error) {
+	s, _ := arg.(string)
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("timeBetween: invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,131 @@
+package rbac_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/rbac/casbinadapter"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestABACWithDomainModel(t *testing.T) {
+	r, err := rbac.NewWithConfig(rbac.Config{Model: rbac.NewABACWithDomainModel(), EnableLog: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddPolicy("user", rbac.DomainAny, "user", "view", "")
+	r.AddPolicy("admin", rbac.DomainAny, "user", "update_all", `attr(r.attrs, "role") == "user"`)
+	r.AddPolicy("admin", rbac.DomainAny, "report", "view_all", `ipInRange(attr(r.attrs, "ip"), "10.0.0.0/8", "192.168.1.0/24")`)
+	r.AddPolicy("operator", "1", "report", "view_all", `timeBetween(attr(r.attrs, "time"), "22:00", "06:00")`)
+	r.AddGroupingPolicy("admin", "user", rbac.DomainAny)
+
+	night := time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)
+	day := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		sub   string
+		dom   string
+		obj   string
+		act   string
+		attrs rbac.Attrs
+		want  bool
+	}{
+		{name: "Policy without condition", sub: "user", dom: "1", obj: "user", act: "view", want: true},
+		{name: "Inherited policy without condition", sub: "admin", dom: "1", obj: "user", act: "view", attrs: rbac.Attrs{"role": "admin"}, want: true},
+		{name: "Matching resource attribute", sub: "admin", dom: "1", obj: "user", act: "update_all", attrs: rbac.Attrs{"role": "user"}, want: true},
+		{name: "Other resource attribute", sub: "admin", dom: "1", obj: "user", act: "update_all", attrs: rbac.Attrs{"role": "admin"}, want: false},
+		{name: "Missing resource attribute", sub: "admin", dom: "1", obj: "user", act: "update_all", want: false},
+		{name: "IP in range", sub: "admin", dom: "1", obj: "report", act: "view_all", attrs: rbac.Attrs{rbac.AttrIP: "192.168.1.20"}, want: true},
+		{name: "IP out of range", sub: "admin", dom: "1", obj: "report", act: "view_all", attrs: rbac.Attrs{rbac.AttrIP: "172.16.0.1"}, want: false},
+		{name: "Invalid IP", sub: "admin", dom: "1", obj: "report", act: "view_all", attrs: rbac.Attrs{rbac.AttrIP: "localhost"}, want: false},
+		{name: "Time of day around midnight", sub: "operator", dom: "1", obj: "report", act: "view_all", attrs: rbac.Attrs{rbac.AttrTime: night}, want: true},
+		{name: "Time of day out of range", sub: "operator", dom: "1", obj: "report", act: "view_all", attrs: rbac.Attrs{rbac.AttrTime: day}, want: false},
+		{name: "Condition in another domain", sub: "operator", dom: "2", obj: "report", act: "view_all", attrs: rbac.Attrs{rbac.AttrTime: night}, want: false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Enforce(tt.sub, tt.dom, tt.obj, tt.act, tt.attrs)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestABACModel(t *testing.T) {
+	r, err := rbac.NewWithConfig(rbac.Config{Model: rbac.NewABACModel(), EnableLog: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddPolicy("admin", "user", "delete", `attr(r.attrs, "role") in ("user", "guest")`)
+
+	ok, err := r.Enforce("admin", "user", "delete", rbac.Attrs{"role": "guest"})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = r.Enforce("admin", "user", "delete", rbac.Attrs{"role": "admin"})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestABACPersistence(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "abac.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&casbinadapter.CasbinRule{}); err != nil {
+		t.Fatal(err)
+	}
+	// created without condition, e.g. before using the ABAC model
+	assert.Nil(t, db.Create(&casbinadapter.CasbinRule{PType: "p", V0: "user", V1: rbac.DomainAny, V2: "user", V3: "view"}).Error)
+
+	cfg := rbac.Config{Model: rbac.NewABACWithDomainModel(), GormDB: db, EnableLog: false}
+	r, err := rbac.NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond := `ipInRange(attr(r.attrs, "ip"), "10.0.0.0/8")`
+	r.AddPolicy("admin", rbac.DomainAny, "user", "view", cond)
+	r.AddPolicy("admin", rbac.DomainAny, "user", "view", "")
+
+	r2, err := rbac.NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{
+		{"user", rbac.DomainAny, "user", "view", ""},
+		{"admin", rbac.DomainAny, "user", "view", cond},
+		{"admin", rbac.DomainAny, "user", "view", ""},
+	}, r2.GetPolicy())
+
+	// the rule with the condition is kept
+	r2.RemovePolicy("admin", rbac.DomainAny, "user", "view", "")
+	r3, err := rbac.NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, r3.HasPolicy("admin", rbac.DomainAny, "user", "view", cond))
+	assert.False(t, r3.HasPolicy("admin", rbac.DomainAny, "user", "view", ""))
+}
+
+func TestValidateCondition(t *testing.T) {
+	assert.Nil(t, rbac.ValidateCondition(""))
+	assert.Nil(t, rbac.ValidateCondition(`attr(r.attrs, "role") == "user" && timeBetween(attr(r.attrs, "time"), "08:00", "18:00")`))
+	assert.NotNil(t, rbac.ValidateCondition(`attr(r.attrs, "role") ==`))
+	assert.NotNil(t, rbac.ValidateCondition(`unknown(r.attrs)`))
+	// the rules cannot store longer conditions
+	long := `attr(r.attrs, "role") == "user" && ipInRange(attr(r.attrs, "ip"), "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16")`
+	assert.Greater(t, len(long), rbac.MaxConditionLength)
+	assert.NotNil(t, rbac.ValidateCondition(long))
+}
+
+func TestAttrsMerge(t *testing.T) {
+	a := rbac.Attrs{rbac.AttrIP: "10.0.0.1", "role": "user"}
+	got := a.Merge(rbac.Attrs{"role": "admin"})
+	assert.Equal(t, rbac.Attrs{rbac.AttrIP: "10.0.0.1", "role": "admin"}, got)
+	assert.Equal(t, "user", a["role"])
+}
@@ -1,13 +1,19 @@
 package casbinadapter
 
 import (
-	"github.com/casbin/casbin/model"
-	"github.com/casbin/casbin/persist"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// CasbinRule represents enforcer policies
+// CasbinRule represents enforcer policies, the rules are unique, see Adapter.Migrate
 type CasbinRule struct {
+	ID    int    `gorm:"primaryKey"`
 	PType string `gorm:"size:100"`
 	V0    string `gorm:"size:100"`
 	V1    string `gorm:"size:100"`
@@ -17,73 +23,320 @@ type CasbinRule struct {
 	V5    string `gorm:"size:100"`
 }
 
-// Adapter represents the Gorm adapter for casbin policy storage.
+// Filter selects the policy rules to load, a rule matches when each of its fields is one of the given values.
+// Fields without values match any value. The rules matching any filter of a []Filter are loaded.
+type Filter struct {
+	PType []string
+	V0    []string
+	V1    []string
+	V2    []string
+	V3    []string
+	V4    []string
+	V5    []string
+}
+
+// ErrInvalidFilter is returned when loading the policy with a filter which is neither a Filter nor a []Filter
+var ErrInvalidFilter = errors.New("invalid filter type")
+
+// Config represents the config of the adapter
+type Config struct {
+	// TableName is the table of the policy rules
+	TableName string
+	// BatchSize is the number of rules inserted per statement when saving the whole policy
+	BatchSize int
+	// SkipMigration disables the creation & migration of the table, see Adapter.Migrate
+	SkipMigration bool
+}
+
+// DefaultConfig is the default adapter config
+var DefaultConfig = Config{
+	TableName: "casbin_rules",
+	BatchSize: 100,
+}
+
+// Adapter represents the Gorm adapter for casbin policy storage, working with sqlite, mysql & postgres.
+// It supports the batch operations & the filtered loading of the policy, see persist.BatchAdapter & persist.FilteredAdapter.
 type Adapter struct {
-	db *gorm.DB
+	db        *gorm.DB
+	tableName string
+	batchSize int
+	filtered  bool
+}
+
+// NewAdapter is the constructor for Adapter with default config, the table is created or migrated.
+func NewAdapter(db *gorm.DB) (*Adapter, error) {
+	return NewAdapterWithConfig(db, DefaultConfig)
+}
+
+// NewAdapterWithConfig is the constructor for Adapter with custom config, the table is created or migrated unless skipped.
+func NewAdapterWithConfig(db *gorm.DB, cfg Config) (*Adapter, error) {
+	if cfg.TableName == "" {
+		cfg.TableName = DefaultConfig.TableName
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultConfig.BatchSize
+	}
+
+	a := &Adapter{db: db, tableName: cfg.TableName, batchSize: cfg.BatchSize}
+	if !cfg.SkipMigration {
+		if err := a.Migrate(); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Migrate creates the table of the rules with a primary key and a unique index over all rule fields.
+// A table created without the primary key is rebuilt under a temporary name then swapped in, dropping duplicated rules,
+// so that the existing rules are kept until the new table is ready. See rebuild.
+// The migration holds an advisory lock in MySQL & Postgres, so that the instances starting at once do not run it concurrently.
+func (a *Adapter) Migrate() error {
+	return a.withLock(func(db *gorm.DB) error {
+		if err := a.recover(db); err != nil {
+			return err
+		}
+
+		m := db.Migrator()
+		if m.HasTable(a.tableName) && !m.HasColumn(a.tableName, "id") {
+			return a.rebuild(db)
+		}
+
+		if err := db.Table(a.tableName).AutoMigrate(&CasbinRule{}); err != nil {
+			return err
+		}
+		return a.createIndex(db, a.tableName)
+	})
+}
+
+// migrationLockTimeout is the time in seconds to wait for the migration lock in MySQL
+const migrationLockTimeout = 60
+
+// withLock runs fn on a single connection holding the advisory lock of the rules table.
+// SQLite has no advisory lock, its writes are serialized by the database lock instead.
+func (a *Adapter) withLock(fn func(db *gorm.DB) error) error {
+	key := a.tableName + "_migration"
+	switch a.db.Dialector.Name() {
+	case "postgres":
+		return a.db.Connection(func(conn *gorm.DB) error {
+			if err := conn.Exec("SELECT pg_advisory_lock(hashtext(?))", key).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(hashtext(?))", key)
+			return fn(conn)
+		})
+	case "mysql":
+		return a.db.Connection(func(conn *gorm.DB) error {
+			var locked sql.NullInt64
+			if err := conn.Raw("SELECT GET_LOCK(?, ?)", key, migrationLockTimeout).Row().Scan(&locked); err != nil {
+				return err
+			}
+			if locked.Int64 != 1 {
+				return fmt.Errorf("timeout acquiring the migration lock of %s", a.tableName)
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", key)
+			return fn(conn)
+		})
+	}
+	return fn(a.db)
+}
+
+// recover cleans up a rebuild that has been interrupted, see rebuild.
+// The old table is restored if the new one has not been swapped in yet, the rules are then rebuilt again.
+func (a *Adapter) recover(db *gorm.DB) error {
+	tmpName, oldName := a.tableName+"_tmp", a.tableName+"_old"
+	m := db.Migrator()
+	if m.HasTable(oldName) {
+		if m.HasTable(a.tableName) {
+			// the new table has been swapped in already
+			if err := m.DropTable(oldName); err != nil {
+				return err
+			}
+		} else if err := m.RenameTable(oldName, a.tableName); err != nil {
+			return err
+		}
+	}
+	return m.DropTable(tmpName)
+}
+
+// rebuild copies the distinct rules into a new table which replaces the existing one.
+// It runs in a single transaction where the DDL statements are transactional, i.e. Postgres & SQLite.
+// MySQL commits each of them, the tables are then swapped by a single statement, see swap.
+func (a *Adapter) rebuild(db *gorm.DB) error {
+	if db.Dialector.Name() == "mysql" {
+		return a.copyAndSwap(db)
+	}
+	return db.Transaction(a.copyAndSwap)
+}
+
+func (a *Adapter) copyAndSwap(db *gorm.DB) error {
+	tmpName, oldName := a.tableName+"_tmp", a.tableName+"_old"
+	m := db.Migrator()
+	if err := db.Table(tmpName).AutoMigrate(&CasbinRule{}); err != nil {
+		return err
+	}
+
+	err := db.Exec("INSERT INTO ? (p_type, v0, v1, v2, v3, v4, v5) SELECT DISTINCT p_type, v0, v1, v2, v3, v4, v5 FROM ?",
+		clause.Table{Name: tmpName}, clause.Table{Name: a.tableName}).Error
+	if err != nil {
+		return err
+	}
+	if err := a.createIndex(db, tmpName); err != nil {
+		return err
+	}
+
+	if err := a.swap(db, tmpName, oldName); err != nil {
+		return err
+	}
+	return m.DropTable(oldName)
 }
 
-// NewAdapter is the constructor for Adapter.
-func NewAdapter(db *gorm.DB) *Adapter {
-	return &Adapter{db}
+// swap replaces the rules table by the temporary one, the rules table is renamed to oldName
+func (a *Adapter) swap(db *gorm.DB, tmpName, oldName string) error {
+	if db.Dialector.Name() == "mysql" {
+		// renamed atomically, the rules table is never missing
+		return db.Exec("RENAME TABLE ? TO ?, ? TO ?",
+			clause.Table{Name: a.tableName}, clause.Table{Name: oldName},
+			clause.Table{Name: tmpName}, clause.Table{Name: a.tableName}).Error
+	}
+
+	m := db.Migrator()
+	if err := m.RenameTable(a.tableName, oldName); err != nil {
+		return err
+	}
+	return m.RenameTable(tmpName, a.tableName)
+}
+
+// createIndex creates the unique index over the rule fields on the given table, named after the rules table
+// given that the index names are unique per schema in postgres
+func (a *Adapter) createIndex(db *gorm.DB, tableName string) error {
+	name := "idx_" + a.tableName + "_rule"
+	if db.Migrator().HasIndex(tableName, name) {
+		return nil
+	}
+	return db.Exec("CREATE UNIQUE INDEX ? ON ? (p_type, v0, v1, v2, v3, v4, v5)",
+		clause.Column{Name: name}, clause.Table{Name: tableName}).Error
+}
+
+// table returns the session of the rules table
+func (a *Adapter) table(db *gorm.DB) *gorm.DB {
+	return db.Table(a.tableName)
 }
 
 // LoadPolicy loads policy from database.
 func (a *Adapter) LoadPolicy(cm model.Model) error {
-	var lines []CasbinRule
-	err := a.db.Find(&lines).Error
-	if err != nil {
+	if err := a.loadPolicy(a.table(a.db), cm); err != nil {
 		return err
 	}
+	a.filtered = false
+	return nil
+}
 
-	for _, line := range lines {
-		loadPolicyLine(line, cm)
+// LoadFilteredPolicy loads only the policy rules that match the filter, either a Filter or a []Filter.
+func (a *Adapter) LoadFilteredPolicy(cm model.Model, filter interface{}) error {
+	var filters []Filter
+	switch f := filter.(type) {
+	case Filter:
+		filters = []Filter{f}
+	case *Filter:
+		filters = []Filter{*f}
+	case []Filter:
+		filters = f
+	default:
+		return ErrInvalidFilter
 	}
 
+	db := a.table(a.db)
+	for i, f := range filters {
+		if i == 0 {
+			db = db.Where(f.where(a.db))
+		} else {
+			db = db.Or(f.where(a.db))
+		}
+	}
+	if err := a.loadPolicy(db, cm); err != nil {
+		return err
+	}
+	a.filtered = true
 	return nil
 }
 
-// SavePolicy saves policy to database.
-func (a *Adapter) SavePolicy(cm model.Model) error {
-	err := a.db.Exec("DELETE FROM casbin_rules;").Error
-	if err != nil {
+// IsFiltered returns true if the loaded policy has been filtered.
+func (a *Adapter) IsFiltered() bool {
+	return a.filtered
+}
+
+func (a *Adapter) loadPolicy(db *gorm.DB, cm model.Model) error {
+	// the rules are loaded in insertion order rather than the order of the unique index
+	var lines []CasbinRule
+	if err := db.Order("id").Find(&lines).Error; err != nil {
 		return err
 	}
 
-	for ptype, ast := range cm["p"] {
-		for _, rule := range ast.Policy {
-			line := savePolicyLine(ptype, rule)
-			err := a.db.Create(&line).Error
-			if err != nil {
-				return err
-			}
+	for _, line := range lines {
+		if err := loadPolicyLine(line, cm); err != nil {
+			return err
 		}
 	}
 
-	for ptype, ast := range cm["g"] {
-		for _, rule := range ast.Policy {
-			line := savePolicyLine(ptype, rule)
-			err := a.db.Create(&line).Error
-			if err != nil {
-				return err
+	return nil
+}
+
+// SavePolicy replaces the policy in database in a single transaction, the existing rules are kept on failure.
+func (a *Adapter) SavePolicy(cm model.Model) error {
+	var lines []CasbinRule
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range cm[sec] {
+			for _, rule := range ast.Policy {
+				lines = append(lines, savePolicyLine(ptype, rule))
 			}
 		}
 	}
 
-	return nil
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := a.table(tx).Where("1 = 1").Delete(&CasbinRule{}).Error; err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		return a.table(tx).CreateInBatches(&lines, a.batchSize).Error
+	})
 }
 
 // AddPolicy adds a policy rule to the storage.
 func (a *Adapter) AddPolicy(sec string, ptype string, rule []string) error {
 	line := savePolicyLine(ptype, rule)
-	err := a.db.Create(&line).Error
+	err := a.table(a.db).Create(&line).Error
 	return err
 }
 
+// AddPolicies adds policy rules to the storage, all or none of them.
+func (a *Adapter) AddPolicies(sec string, ptype string, rules [][]string) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	lines := make([]CasbinRule, 0, len(rules))
+	for _, rule := range rules {
+		lines = append(lines, savePolicyLine(ptype, rule))
+	}
+	return a.table(a.db).Create(&lines).Error
+}
+
+// RemovePolicies removes policy rules from the storage, all or none of them.
+func (a *Adapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		for _, rule := range rules {
+			if err := deleteRule(a.table(tx), ptype, rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // RemovePolicy removes a policy rule from the storage.
 func (a *Adapter) RemovePolicy(sec string, ptype string, rule []string) error {
-	line := savePolicyLine(ptype, rule)
-	err := rawDelete(a.db, line)
-	return err
+	return deleteRule(a.table(a.db), ptype, rule)
 }
 
 // RemoveFilteredPolicy removes policy rules that match the filter from the storage.
@@ -109,32 +362,26 @@ func (a *Adapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int,
 	if fieldIndex <= 5 && 5 < fieldIndex+len(fieldValues) {
 		line.V5 = fieldValues[5-fieldIndex]
 	}
-	err := rawDelete(a.db, line)
+	err := rawDelete(a.table(a.db), line)
 	return err
 }
 
-func loadPolicyLine(line CasbinRule, cm model.Model) {
-	lineText := line.PType
-	if line.V0 != "" {
-		lineText += ", " + line.V0
+// loadPolicyLine loads the rule as is, the values may contain commas or quotes such as the conditions of the ABAC models.
+// The trailing empty values are trimmed, except those defined by the policy, e.g. a policy without condition.
+func loadPolicyLine(line CasbinRule, cm model.Model) error {
+	if line.PType == "" {
+		return nil
 	}
-	if line.V1 != "" {
-		lineText += ", " + line.V1
+	rule := []string{line.PType, line.V0, line.V1, line.V2, line.V3, line.V4, line.V5}
+	n := len(rule)
+	for n > 1 && rule[n-1] == "" {
+		n--
 	}
-	if line.V2 != "" {
-		lineText += ", " + line.V2
-	}
-	if line.V3 != "" {
-		lineText += ", " + line.V3
-	}
-	if line.V4 != "" {
-		lineText += ", " + line.V4
-	}
-	if line.V5 != "" {
-		lineText += ", " + line.V5
+	if ast, ok := cm["p"][line.PType]; ok && n <= len(ast.Tokens) && len(ast.Tokens) < len(rule) {
+		n = len(ast.Tokens) + 1
 	}
 
-	persist.LoadPolicyLine(lineText, cm)
+	return persist.LoadPolicyArray(rule[:n], cm)
 }
 
 func savePolicyLine(ptype string, rule []string) CasbinRule {
@@ -192,6 +439,32 @@ func rawDelete(db *gorm.DB, line CasbinRule) error {
 		queryArgs = append(queryArgs, line.V5)
 	}
 	args := append([]interface{}{queryStr}, queryArgs...)
-	err := db.Delete(CasbinRule{}, args...).Error
+	err := db.Delete(&CasbinRule{}, args...).Error
 	return err
 }
+
+// deleteRule deletes the rule, matching all of its values including the empty ones,
+// so that the rules differing by an empty value only are kept. e.g. with & without condition
+func deleteRule(db *gorm.DB, ptype string, rule []string) error {
+	db = db.Where("p_type = ?", ptype)
+	for i, v := range rule {
+		if i+1 >= len(filterColumns) {
+			break
+		}
+		db = db.Where(filterColumns[i+1]+" = ?", v)
+	}
+	return db.Delete(&CasbinRule{}).Error
+}
+
+// where returns the conditions of the filter as a group, see https://gorm.io/docs/advanced_query.html#Group-Conditions
+func (f Filter) where(db *gorm.DB) *gorm.DB {
+	cond := db.Session(&gorm.Session{NewDB: true})
+	for i, values := range [][]string{f.PType, f.V0, f.V1, f.V2, f.V3, f.V4, f.V5} {
+		if len(values) > 0 {
+			cond = cond.Where(filterColumns[i]+" IN ?", values)
+		}
+	}
+	return cond
+}
+
+var filterColumns = []string{"p_type", "v0", "v1", "v2", "v3", "v4", "v5"}
@@ -2,17 +2,62 @@ package casbinadapter_test
 
 import (
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/vuduongtp/go-core/pkg/rbac/casbinadapter"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
 
-	"github.com/casbin/casbin"
-	"github.com/casbin/casbin/util"
-	"gorm.io/driver/sqlite"
-	_ "gorm.io/driver/sqlite"
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/util"
 	"gorm.io/gorm"
 )
 
+// openDB connects to the database of the dialect, mysql & postgres are tested only when
+// their DSN is given by the TEST_MYSQL_DSN & TEST_POSTGRES_DSN environment variables
+func openDB(t *testing.T, dialect string) *gorm.DB {
+	dsn := filepath.Join(t.TempDir(), "gorm.db")
+	switch dialect {
+	case "mysql":
+		dsn = os.Getenv("TEST_MYSQL_DSN")
+	case "postgres":
+		dsn = os.Getenv("TEST_POSTGRES_DSN")
+	}
+	if dsn == "" {
+		t.Skipf("No DSN for %s", dialect)
+	}
+
+	db, err := dbutil.New(dialect, dsn, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	dropTables := func() {
+		if err := db.Migrator().DropTable("casbin_rules", "custom_rules", "legacy_rules", "legacy_rules_old", "legacy_rules_tmp"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dropTables()
+	t.Cleanup(dropTables)
+	return db
+}
+
+func newAdapter(t *testing.T, db *gorm.DB) *casbinadapter.Adapter {
+	a, err := casbinadapter.NewAdapter(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func newEnforcer(t *testing.T, a *casbinadapter.Adapter) *casbin.Enforcer {
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
 func testGetPolicy(t *testing.T, e *casbin.Enforcer, res [][]string) {
 	myRes := e.GetPolicy()
 	log.Print("Policy: ", myRes)
@@ -22,16 +67,18 @@ func testGetPolicy(t *testing.T, e *casbin.Enforcer, res [][]string) {
 	}
 }
 
-func initPolicy(t *testing.T, db *gorm.DB) {
+func initPolicy(t *testing.T, a *casbinadapter.Adapter) {
 	// Because the DB is empty at first,
 	// so we need to load the policy from the file adapter (.CSV) first.
-	e := casbin.NewEnforcer("testdata/rbac_model.conf", "testdata/rbac_policy.csv")
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", "testdata/rbac_policy.csv")
+	if err != nil {
+		panic(err)
+	}
 
-	a := casbinadapter.NewAdapter(db)
 	// This is a trick to save the current policy to the DB.
 	// We can't call e.SavePolicy() because the adapter in the enforcer is still the file adapter.
 	// The current policy means the policy in the Casbin enforcer (aka in memory).
-	err := a.SavePolicy(e.GetModel())
+	err = a.SavePolicy(e.GetModel())
 	if err != nil {
 		panic(err)
 	}
@@ -50,29 +97,57 @@ func initPolicy(t *testing.T, db *gorm.DB) {
 
 func testSaveLoad(t *testing.T, db *gorm.DB) {
 	// Initialize some policy in DB.
-	initPolicy(t, db)
+	initPolicy(t, newAdapter(t, db))
 	// Note: you don't need to look at the above code
 	// if you already have a working DB with policy inside.
 
 	// Now the DB has policy, so we can provide a normal use case.
 	// Create an adapter and an enforcer.
 	// NewEnforcer() will load the policy automatically.
-	a := casbinadapter.NewAdapter(db)
-	e := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	e := newEnforcer(t, newAdapter(t, db))
 	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}})
+
+	// The whole policy is replaced.
+	e.RemovePolicy("bob", "data2", "write")
+	e.EnableAutoSave(false)
+	e.AddPolicy("carol", "data1", "read")
+	if err := e.SavePolicy(); err != nil {
+		t.Fatal(err)
+	}
+	e.LoadPolicy()
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}, {"carol", "data1", "read"}})
+}
+
+func testSaveRollback(t *testing.T, db *gorm.DB) {
+	a := newAdapter(t, db)
+	initPolicy(t, a)
+
+	// A duplicated rule violates the unique index midway, the existing policy must be kept.
+	e := newEnforcer(t, a)
+	ast := e.GetModel()["p"]["p"]
+	ast.Policy = append(ast.Policy, []string{"carol", "data1", "read"}, []string{"carol", "data1", "read"})
+	if err := a.SavePolicy(e.GetModel()); err == nil {
+		t.Error("Saving duplicated rules is supposed to fail")
+	}
+
+	e.LoadPolicy()
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}})
+
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err == nil {
+		t.Error("Adding a duplicated rule is supposed to fail")
+	}
 }
 
 func testAutoSave(t *testing.T, db *gorm.DB) {
 	// Initialize some policy in DB.
-	initPolicy(t, db)
+	initPolicy(t, newAdapter(t, db))
 	// Note: you don't need to look at the above code
 	// if you already have a working DB with policy inside.
 
 	// Now the DB has policy, so we can provide a normal use case.
 	// Create an adapter and an enforcer.
 	// NewEnforcer() will load the policy automatically.
-	a := casbinadapter.NewAdapter(db)
-	e := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	e := newEnforcer(t, newAdapter(t, db))
 
 	// AutoSave is enabled by default.
 	// Now we disable it.
@@ -109,15 +184,175 @@ func testAutoSave(t *testing.T, db *gorm.DB) {
 	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
 }
 
-func TestAdapters(t *testing.T) {
-	db, err := gorm.Open(sqlite.Open("testdata/gorm.db"), &gorm.Config{})
+func testBatch(t *testing.T, db *gorm.DB) {
+	initPolicy(t, newAdapter(t, db))
+
+	e := newEnforcer(t, newAdapter(t, db))
+
+	if _, err := e.AddPolicies([][]string{{"carol", "data1", "read"}, {"carol", "data2", "read"}}); err != nil {
+		t.Fatal(err)
+	}
+	e.LoadPolicy()
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}, {"carol", "data1", "read"}, {"carol", "data2", "read"}})
+
+	if _, err := e.RemovePolicies([][]string{{"carol", "data1", "read"}, {"carol", "data2", "read"}}); err != nil {
+		t.Fatal(err)
+	}
+	e.LoadPolicy()
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}})
+}
+
+func testFilteredLoad(t *testing.T, db *gorm.DB) {
+	initPolicy(t, newAdapter(t, db))
+
+	e := newEnforcer(t, newAdapter(t, db))
+
+	if err := e.LoadFilteredPolicy(casbinadapter.Filter{PType: []string{"p"}, V1: []string{"data2"}}); err != nil {
+		t.Fatal(err)
+	}
+	testGetPolicy(t, e, [][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}})
+	if !e.IsFiltered() {
+		t.Error("Policy is supposed to be filtered")
+	}
+	if err := e.SavePolicy(); err == nil {
+		t.Error("Filtered policy is not supposed to be saved")
+	}
+
+	if err := e.LoadFilteredPolicy([]casbinadapter.Filter{{V0: []string{"alice"}}, {V0: []string{"bob"}}}); err != nil {
+		t.Fatal(err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+
+	if err := e.LoadFilteredPolicy("data2"); err != casbinadapter.ErrInvalidFilter {
+		t.Error("Error: ", err, ", supposed to be ", casbinadapter.ErrInvalidFilter)
+	}
+
+	e.LoadPolicy()
+	if e.IsFiltered() {
+		t.Error("Policy is not supposed to be filtered")
+	}
+}
+
+func testTableName(t *testing.T, db *gorm.DB) {
+	initPolicy(t, newAdapter(t, db))
+
+	a, err := casbinadapter.NewAdapterWithConfig(db, casbinadapter.Config{TableName: "custom_rules", BatchSize: 2})
 	if err != nil {
-		t.Fatalf("Error establishing connection %v", err)
+		t.Fatal(err)
+	}
+	e := newEnforcer(t, a)
+	testGetPolicy(t, e, [][]string{})
+
+	// saved in several batches
+	e.AddPolicy("carol", "data1", "read")
+	e.AddPolicy("carol", "data2", "read")
+	e.AddPolicy("carol", "data3", "read")
+	if err := e.SavePolicy(); err != nil {
+		t.Fatal(err)
+	}
+	e.LoadPolicy()
+	testGetPolicy(t, e, [][]string{{"carol", "data1", "read"}, {"carol", "data2", "read"}, {"carol", "data3", "read"}})
+
+	// the default table is left untouched
+	testGetPolicy(t, newEnforcer(t, newAdapter(t, db)), [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}})
+}
+
+// legacyRule is the rule of the table created without primary key nor unique index
+type legacyRule struct {
+	PType string `gorm:"size:100"`
+	V0    string `gorm:"size:100"`
+	V1    string `gorm:"size:100"`
+	V2    string `gorm:"size:100"`
+	V3    string `gorm:"size:100"`
+	V4    string `gorm:"size:100"`
+	V5    string `gorm:"size:100"`
+}
+
+func createLegacyTable(t *testing.T, db *gorm.DB, name string) {
+	if err := db.Table(name).AutoMigrate(&legacyRule{}); err != nil {
+		t.Fatal(err)
+	}
+	rules := []legacyRule{
+		{PType: "p", V0: "alice", V1: "data1", V2: "read"},
+		{PType: "p", V0: "alice", V1: "data1", V2: "read"},
+		{PType: "p", V0: "bob", V1: "data2", V2: "write"},
+	}
+	if err := db.Table(name).Create(&rules).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testMigrateLegacy(t *testing.T, db *gorm.DB) {
+	createLegacyTable(t, db, "legacy_rules")
+
+	cfg := casbinadapter.Config{TableName: "legacy_rules"}
+	a, err := casbinadapter.NewAdapterWithConfig(db, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !db.Migrator().HasColumn("legacy_rules", "id") {
+		t.Error("The primary key is supposed to be added")
+	}
+	if !db.Migrator().HasIndex("legacy_rules", "idx_legacy_rules_rule") {
+		t.Error("The unique index is supposed to be created")
 	}
-	// connection.Close() is not available for GORM 1.20.0
-	// db.Close()
-	db.AutoMigrate(&casbinadapter.CasbinRule{})
+	e := newEnforcer(t, a)
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
 
-	testSaveLoad(t, db)
-	testAutoSave(t, db)
+	// the migration is idempotent
+	if _, err := casbinadapter.NewAdapterWithConfig(db, cfg); err != nil {
+		t.Fatal(err)
+	}
+	e.LoadPolicy()
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+}
+
+func testMigrateInterrupted(t *testing.T, db *gorm.DB) {
+	cfg := casbinadapter.Config{TableName: "legacy_rules"}
+	m := db.Migrator()
+	if err := m.DropTable("legacy_rules"); err != nil {
+		t.Fatal(err)
+	}
+
+	// interrupted between the renames: the old table is restored & rebuilt again
+	createLegacyTable(t, db, "legacy_rules_old")
+	if err := db.Table("legacy_rules_tmp").AutoMigrate(&casbinadapter.CasbinRule{}); err != nil {
+		t.Fatal(err)
+	}
+	a, err := casbinadapter.NewAdapterWithConfig(db, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HasTable("legacy_rules_old") || m.HasTable("legacy_rules_tmp") {
+		t.Error("The leftover tables are supposed to be dropped")
+	}
+	testGetPolicy(t, newEnforcer(t, a), [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+
+	// interrupted before dropping the old table: the new one is kept
+	createLegacyTable(t, db, "legacy_rules_old")
+	a, err = casbinadapter.NewAdapterWithConfig(db, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HasTable("legacy_rules_old") {
+		t.Error("The old table is supposed to be dropped")
+	}
+	testGetPolicy(t, newEnforcer(t, a), [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+}
+
+func TestAdapters(t *testing.T) {
+	for _, dialect := range []string{"sqlite3", "mysql", "postgres"} {
+		t.Run(dialect, func(t *testing.T) {
+			db := openDB(t, dialect)
+
+			testSaveLoad(t, db)
+			testSaveRollback(t, db)
+			testAutoSave(t, db)
+			testBatch(t, db)
+			testFilteredLoad(t, db)
+			testTableName(t, db)
+			testMigrateLegacy(t, db)
+			testMigrateInterrupted(t, db)
+		})
+	}
 }
@@ -0,0 +1,133 @@
+package casbinadapter
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Revision represents the revision of the policy shared by all instances, it is increased on every change
+type Revision struct {
+	ID        int   `gorm:"primaryKey;autoIncrement:false"`
+	Revision  int64 `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+// TableName returns the table name of Revision model
+func (Revision) TableName() string {
+	return "casbin_revisions"
+}
+
+// revisionID is the ID of the single Revision record
+const revisionID = 1
+
+// NewWatcher creates new watcher, polling the revision of the policy at the given interval.
+// The casbin_revisions table must be migrated beforehand.
+func NewWatcher(db *gorm.DB, interval time.Duration) (*Watcher, error) {
+	w := &Watcher{db: db, done: make(chan struct{})}
+	rev, err := w.current()
+	if err != nil {
+		return nil, err
+	}
+	w.revision = rev
+
+	go w.run(interval)
+	return w, nil
+}
+
+// Watcher is the Gorm implementation of persist.Watcher, so that several instances share the same policy.
+// The instance changing the policy increases the revision in database, the others reload the policy when they see a new revision.
+type Watcher struct {
+	db *gorm.DB
+
+	mu       sync.Mutex
+	revision int64
+	callback func(string)
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// SetUpdateCallback sets the function called with the new revision when the policy has been changed by another instance
+func (w *Watcher) SetUpdateCallback(fn func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = fn
+	return nil
+}
+
+// Update increases the revision after the policy has been changed by this instance
+func (w *Watcher) Update() error {
+	err := w.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"revision":   gorm.Expr("casbin_revisions.revision + 1"),
+			"updated_at": time.Now().UTC(),
+		}),
+	}).Create(&Revision{ID: revisionID, Revision: 1}).Error
+	if err != nil {
+		return err
+	}
+	rev, err := w.current()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// the changes of other instances in the meantime are left to the polling
+	if rev == w.revision+1 {
+		w.revision = rev
+	}
+	return nil
+}
+
+// Close stops the polling, the callback will not be called any more
+func (w *Watcher) Close() {
+	w.closeOnce.Do(func() { close(w.done) })
+}
+
+func (w *Watcher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check calls the callback if the revision has been changed, errors are retried on the next tick
+func (w *Watcher) check() {
+	rev, err := w.current()
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	if rev == w.revision {
+		w.mu.Unlock()
+		return
+	}
+	w.revision = rev
+	callback := w.callback
+	w.mu.Unlock()
+
+	if callback != nil {
+		callback(strconv.FormatInt(rev, 10))
+	}
+}
+
+// current returns the revision in database, 0 if the policy has never been changed
+func (w *Watcher) current() (int64, error) {
+	var rec Revision
+	res := w.db.Where("id = ?", revisionID).Limit(1).Find(&rec)
+	return rec.Revision, res.Error
+}
@@ -0,0 +1,61 @@
+package casbinadapter_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/rbac/casbinadapter"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newWatchedEnforcer(t *testing.T, db *gorm.DB) *casbin.SyncedEnforcer {
+	e, err := casbin.NewSyncedEnforcer("testdata/rbac_model.conf", newAdapter(t, db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := casbinadapter.NewWatcher(db, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Close)
+	if err := e.SetWatcher(w); err != nil {
+		t.Fatal(err)
+	}
+	// reload with the lock of the synced enforcer
+	w.SetUpdateCallback(func(string) { e.LoadPolicy() })
+	return e
+}
+
+func TestWatcher(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "watcher.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&casbinadapter.Revision{}); err != nil {
+		t.Fatal(err)
+	}
+
+	e1 := newWatchedEnforcer(t, db)
+	e2 := newWatchedEnforcer(t, db)
+
+	_, err = e1.AddPolicy("alice", "data1", "read")
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return e2.HasPolicy("alice", "data1", "read")
+	}, time.Second, 10*time.Millisecond, "the other instance reloads the policy")
+
+	_, err = e2.AddPolicies([][]string{{"bob", "data2", "write"}, {"bob", "data2", "read"}})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return len(e1.GetPolicy()) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	var rev casbinadapter.Revision
+	assert.Nil(t, db.First(&rev).Error)
+	assert.Equal(t, int64(2), rev.Revision)
+}
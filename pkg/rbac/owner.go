@@ -0,0 +1,25 @@
+package rbac
+
+import "strings"
+
+// AllSuffix marks the actions on all resources of an object, their owner-scoped variants have no suffix. e.g: view_all & view
+const AllSuffix = "_all"
+
+// OwnerAction returns the owner-scoped variant of the action, e.g: update_all -> update.
+// Other actions are returned as is.
+func OwnerAction(action string) string {
+	return strings.TrimSuffix(action, AllSuffix)
+}
+
+// EnforceOwner determines whether the subject may perform the action on a resource of the object.
+// The action is checked first, then its owner-scoped variant if the subject owns the resource.
+func EnforceOwner(e Intf, sub, obj, act string, isOwner bool) (bool, error) {
+	if ok, err := e.Enforce(sub, obj, act); ok || err != nil {
+		return ok, err
+	}
+	owned := OwnerAction(act)
+	if !isOwner || owned == act {
+		return false, nil
+	}
+	return e.Enforce(sub, obj, owned)
+}
@@ -0,0 +1,44 @@
+package rbac_test
+
+import (
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/rbac"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforceOwner(t *testing.T) {
+	r, err := rbac.NewWithConfig(rbac.Config{EnableLog: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddPolicy("user", "post", "view_all")
+	r.AddPolicy("user", "post", "update")
+	r.AddPolicy("admin", "post", "*")
+
+	cases := []struct {
+		name    string
+		sub     string
+		act     string
+		isOwner bool
+		want    bool
+	}{
+		{name: "All action allowed", sub: "user", act: "view_all", want: true},
+		{name: "Owner falls back to owner-scoped action", sub: "user", act: "update_all", isOwner: true, want: true},
+		{name: "Non-owner does not fall back", sub: "user", act: "update_all", want: false},
+		{name: "Owner-scoped action not granted", sub: "user", act: "delete_all", isOwner: true, want: false},
+		{name: "Actions without suffix are checked as is", sub: "user", act: "publish", isOwner: true, want: false},
+		{name: "Wildcard action", sub: "admin", act: "delete_all", want: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rbac.EnforceOwner(r, tt.sub, "post", tt.act, tt.isOwner)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, "view", rbac.OwnerAction("view_all"))
+	assert.Equal(t, "manage_locks", rbac.OwnerAction("manage_locks"))
+}
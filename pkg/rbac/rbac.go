@@ -1,57 +1,99 @@
 package rbac
 
+import (
+	"sort"
+
+	"github.com/vuduongtp/go-core/pkg/rbac/casbinadapter"
+)
+
 // AddRoleForUserID adds a role for a user by ID. Returns false if the user already has the role (aka not affected).
-func (s *RBAC) AddRoleForUserID(uid int, role string) bool {
-	return s.Enforcer.AddRoleForUser(NormalizeUser(uid), role)
+func (s *RBAC) AddRoleForUserID(uid int, role string) (bool, error) {
+	return s.SyncedEnforcer.AddRoleForUser(NormalizeUser(uid), role)
 }
 
 // GetRolesForUserID gets the roles that a user has.
-func (s *RBAC) GetRolesForUserID(uid int) []string {
-	roles, _ := s.Enforcer.GetRolesForUser(NormalizeUser(uid))
-	return roles
+func (s *RBAC) GetRolesForUserID(uid int) ([]string, error) {
+	return s.SyncedEnforcer.GetRolesForUser(NormalizeUser(uid))
 }
 
 // ReplaceRoleForUserID removes all current roles then adds the new role for a user ID
-func (s *RBAC) ReplaceRoleForUserID(uid int, role string) bool {
-	s.DeleteRolesForUserID(uid)
+func (s *RBAC) ReplaceRoleForUserID(uid int, role string) (bool, error) {
+	if _, err := s.DeleteRolesForUserID(uid); err != nil {
+		return false, err
+	}
 	return s.AddRoleForUserID(uid, role)
 }
 
 // DeleteRoleForUserID deletes a role for a user ID. Returns false if the user does not have the role (aka not affected).
-func (s *RBAC) DeleteRoleForUserID(uid int, role string) bool {
-	return s.Enforcer.DeleteRoleForUser(NormalizeUser(uid), role)
+func (s *RBAC) DeleteRoleForUserID(uid int, role string) (bool, error) {
+	return s.SyncedEnforcer.DeleteRoleForUser(NormalizeUser(uid), role)
 }
 
 // DeleteRolesForUserID delete all roles for a user ID. Returns false if the user does not have any roles (aka not affected).
-func (s *RBAC) DeleteRolesForUserID(uid int) bool {
-	return s.Enforcer.DeleteRolesForUser(NormalizeUser(uid))
+func (s *RBAC) DeleteRolesForUserID(uid int) (bool, error) {
+	return s.SyncedEnforcer.DeleteRolesForUser(NormalizeUser(uid))
 }
 
 // DeleteUserID deletes a user ID. Returns false if the user does not exist (aka not affected).
-func (s *RBAC) DeleteUserID(uid int) bool {
-	return s.Enforcer.DeleteUser(NormalizeUser(uid))
+func (s *RBAC) DeleteUserID(uid int) (bool, error) {
+	return s.SyncedEnforcer.DeleteUser(NormalizeUser(uid))
 }
 
 // HasRoleForUserID determines whether a user has a role.
-func (s *RBAC) HasRoleForUserID(uid int, role string) bool {
-	has, _ := s.Enforcer.HasRoleForUser(NormalizeUser(uid), role)
-	return has
+func (s *RBAC) HasRoleForUserID(uid int, role string) (bool, error) {
+	return s.SyncedEnforcer.HasRoleForUser(NormalizeUser(uid), role)
 }
 
 // EnforceUserID determines whether a user ID has permission to do stuff
-func (s *RBAC) EnforceUserID(uid int, rvals ...interface{}) bool {
+func (s *RBAC) EnforceUserID(uid int, rvals ...interface{}) (bool, error) {
 	rvals = append([]interface{}{NormalizeUser(uid)}, rvals...)
-	return s.Enforcer.Enforce(rvals...)
+	return s.SyncedEnforcer.Enforce(rvals...)
 }
 
 // AddGroupingPolicy2 adds a role inheritance rule to the current policy.
 // If the rule already exists, the function returns false and the rule will not be added.
 // Otherwise the function returns true by adding the new rule.
-func (s *RBAC) AddGroupingPolicy2(params ...interface{}) bool {
-	return s.Enforcer.AddNamedGroupingPolicy("g2", params...)
+func (s *RBAC) AddGroupingPolicy2(params ...interface{}) (bool, error) {
+	return s.SyncedEnforcer.AddNamedGroupingPolicy("g2", params...)
 }
 
 // RemoveGroupingPolicy2 removes a role inheritance rule from the current policy.
-func (s *RBAC) RemoveGroupingPolicy2(params ...interface{}) bool {
-	return s.Enforcer.RemoveNamedGroupingPolicy("g2", params...)
+func (s *RBAC) RemoveGroupingPolicy2(params ...interface{}) (bool, error) {
+	return s.SyncedEnforcer.RemoveNamedGroupingPolicy("g2", params...)
+}
+
+// LoadDomainPolicy loads only the policies & role inheritance rules of the given domains and of DomainAny,
+// for the RBAC with domain model backed by casbinadapter.Adapter. The filtered policy cannot be saved as a whole.
+func (s *RBAC) LoadDomainPolicy(domains ...string) error {
+	domains = append(domains, DomainAny)
+	return s.SyncedEnforcer.LoadFilteredPolicy([]casbinadapter.Filter{
+		{PType: []string{"p"}, V1: domains},
+		{PType: []string{"g"}, V2: domains},
+	})
+}
+
+// Roles returns the roles having policies or taking part in a role inheritance rule in any domain, sorted by name
+func (s *RBAC) Roles() []string {
+	set := make(map[string]bool)
+	for _, role := range s.SyncedEnforcer.GetAllSubjects() {
+		set[role] = true
+	}
+	for _, rule := range s.SyncedEnforcer.GetGroupingPolicy() {
+		// the domain of the rule follows the roles in the RBAC with domain model
+		set[rule[0]], set[rule[1]] = true, true
+	}
+
+	roles := make([]string, 0, len(set))
+	for role := range set {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// RoleExists determines whether a role has policies or takes part in a role inheritance rule
+func (s *RBAC) RoleExists(role string) bool {
+	return len(s.SyncedEnforcer.GetFilteredPolicy(0, role)) > 0 ||
+		len(s.SyncedEnforcer.GetFilteredGroupingPolicy(0, role)) > 0 ||
+		len(s.SyncedEnforcer.GetFilteredGroupingPolicy(1, role)) > 0
 }
@@ -0,0 +1,104 @@
+package rbac_test
+
+import (
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/rbac/casbinadapter"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRoles(t *testing.T) {
+	r, err := rbac.NewWithConfig(rbac.Config{EnableLog: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddPolicy("user", "post", "view")
+	r.AddPolicy("admin", "post", "*")
+	r.AddGroupingPolicy("admin", "user")
+	r.AddGroupingPolicy("editor", "user")
+
+	assert.Equal(t, []string{"admin", "editor", "user"}, r.Roles())
+	assert.True(t, r.RoleExists("admin"))
+	assert.True(t, r.RoleExists("editor"))
+	assert.False(t, r.RoleExists("guest"))
+
+	r.RemoveGroupingPolicy("editor", "user")
+	assert.False(t, r.RoleExists("editor"))
+}
+
+func TestDomainModel(t *testing.T) {
+	r, err := rbac.NewWithConfig(rbac.Config{Model: rbac.NewRBACWithDomainModel(), EnableLog: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddPolicy("user", rbac.DomainAny, "post", "view")
+	r.AddPolicy("admin", rbac.DomainAny, "post", "*")
+	r.AddPolicy("editor", "1", "post", "update")
+	r.AddGroupingPolicy("admin", "user", rbac.DomainAny)
+	r.AddGroupingPolicy("editor", "user", "1")
+
+	cases := []struct {
+		name string
+		sub  string
+		dom  string
+		act  string
+		want bool
+	}{
+		{name: "Global policy in any domain", sub: "user", dom: "2", act: "view", want: true},
+		{name: "Global policy without domain", sub: "user", dom: rbac.DomainAny, act: "view", want: true},
+		{name: "Wildcard action", sub: "admin", dom: "1", act: "delete", want: true},
+		{name: "Global inheritance", sub: "admin", dom: "2", act: "view", want: true},
+		{name: "Domain policy", sub: "editor", dom: "1", act: "update", want: true},
+		{name: "Domain policy in another domain", sub: "editor", dom: "2", act: "update", want: false},
+		{name: "Domain inheritance", sub: "editor", dom: "1", act: "view", want: true},
+		{name: "Domain inheritance in another domain", sub: "editor", dom: "2", act: "view", want: false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Enforce(tt.sub, tt.dom, "post", tt.act)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, []string{"admin", "editor", "user"}, r.Roles())
+}
+
+func TestLoadDomainPolicy(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&casbinadapter.CasbinRule{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := rbac.NewWithConfig(rbac.Config{Model: rbac.NewRBACWithDomainModel(), GormDB: db, EnableLog: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.AddPolicies([][]string{
+		{"user", rbac.DomainAny, "post", "view"},
+		{"editor", "1", "post", "update"},
+		{"editor", "2", "post", "delete"},
+	})
+	assert.Nil(t, err)
+	_, err = r.AddGroupingPolicies([][]string{{"editor", "user", "1"}, {"editor", "user", "2"}})
+	assert.Nil(t, err)
+
+	assert.Nil(t, r.LoadDomainPolicy("1"))
+	assert.True(t, r.IsFiltered())
+	assert.Equal(t, [][]string{{"user", rbac.DomainAny, "post", "view"}, {"editor", "1", "post", "update"}}, r.GetPolicy())
+	assert.Equal(t, [][]string{{"editor", "user", "1"}}, r.GetGroupingPolicy())
+	ok, err := r.Enforce("editor", "1", "post", "view")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	assert.Nil(t, r.LoadPolicy())
+	assert.False(t, r.IsFiltered())
+	assert.Len(t, r.GetPolicy(), 3)
+}
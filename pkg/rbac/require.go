@@ -0,0 +1,149 @@
+package rbac
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EnforceFunc reports whether the user of the request may perform the action on the object
+type EnforceFunc func(c echo.Context, object, action string) bool
+
+// EnforceFuncKey is the context key of the enforcement function used by Require, see Middleware
+const EnforceFuncKey = "rbac_enforce"
+
+// probeKey is the context key of the permission filled in by Require when probed, see permissionOf
+const probeKey = "rbac_probe"
+
+// Middleware returns the middleware setting the enforcement function used by Require.
+// It must be registered on the group before the routes, after the authentication middleware.
+func Middleware(fn EnforceFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(EnforceFuncKey, fn)
+			return next(c)
+		}
+	}
+}
+
+// Require returns the middleware allowing the request only if the user may perform the action on the object,
+// ErrForbiddenAccess is returned otherwise, or if no enforcement function is set. e.g:
+//
+//	eg.GET("", h.list, rbac.Require(model.ObjectUser, model.ActionViewAll))
+//
+// The permissions of the routes are recorded by Routes.
+func Require(object, action string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if p, ok := c.Get(probeKey).(*RoutePermission); ok {
+				p.Object, p.Action = object, action
+				return nil
+			}
+			fn, ok := c.Get(EnforceFuncKey).(EnforceFunc)
+			if !ok || !fn(c, object, action) {
+				return ErrForbiddenAccess
+			}
+			return next(c)
+		}
+	}
+}
+
+// Owned returns the middleware recording the permission required by the route, which is checked by the handler
+// as the owners of the resources may be allowed without it, e.g. by the owner-scoped action, see OwnerAction. e.g:
+//
+//	eg.GET("/:id", h.view, rbac.Owned(model.ObjectUser, model.ActionViewAll))
+//
+// Unlike Require, the request is not checked. The permissions of the routes are recorded by Routes.
+func Owned(object, action string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if p, ok := c.Get(probeKey).(*RoutePermission); ok {
+				p.Object, p.Action, p.Owned = object, action, true
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// permissionCodes are the codes shared by the middlewares returned by Require & Owned, which tell them apart from the others
+var permissionCodes = map[uintptr]bool{
+	reflect.ValueOf(Require("", "")).Pointer(): true,
+	reflect.ValueOf(Owned("", "")).Pointer():   true,
+}
+
+// permissionOf returns the permission required by the middleware if returned by Require or Owned
+func permissionOf(mw echo.MiddlewareFunc) (RoutePermission, bool) {
+	if mw == nil || !permissionCodes[reflect.ValueOf(mw).Pointer()] {
+		return RoutePermission{}, false
+	}
+	p := RoutePermission{}
+	c := echo.New().NewContext(nil, nil)
+	c.Set(probeKey, &p)
+	_ = mw(nil)(c)
+	return p, true
+}
+
+// RoutePermission represents the permission required by a route, see Require & Owned
+type RoutePermission struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Object string `json:"object"`
+	Action string `json:"action"`
+	// Owned tells whether the owners of the resources may be allowed without the permission, which is checked by the handler
+	Owned bool `json:"owned"`
+}
+
+// Routes records the permissions required by the routes, it is safe for concurrent use
+type Routes struct {
+	mu   sync.RWMutex
+	data []RoutePermission
+}
+
+// NewRoutes returns the record of the permissions required by the routes added to e from now on,
+// including those required by the middlewares of their groups
+func NewRoutes(e *echo.Echo) *Routes {
+	r := &Routes{}
+	prev := e.OnAddRouteHandler
+	e.OnAddRouteHandler = func(host string, route echo.Route, handler echo.HandlerFunc, middleware []echo.MiddlewareFunc) {
+		if prev != nil {
+			prev(host, route, handler, middleware)
+		}
+		r.add(route, middleware)
+	}
+	return r
+}
+
+// List returns the permissions required by the routes, sorted by path & method
+func (r *Routes) List() []RoutePermission {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	data := make([]RoutePermission, len(r.data))
+	copy(data, r.data)
+	sort.SliceStable(data, func(i, j int) bool {
+		if data[i].Path != data[j].Path {
+			return data[i].Path < data[j].Path
+		}
+		return data[i].Method < data[j].Method
+	})
+	return data
+}
+
+func (r *Routes) add(route echo.Route, middleware []echo.MiddlewareFunc) {
+	// the catch-all routes added by the groups having middlewares
+	if route.Method == echo.RouteNotFound {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, mw := range middleware {
+		if p, ok := permissionOf(mw); ok {
+			p.Method, p.Path = route.Method, route.Path
+			r.data = append(r.data, p)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package rbac_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/rbac"
+	"github.com/vuduongtp/go-core/pkg/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequire(t *testing.T) {
+	r, err := rbac.NewWithConfig(rbac.Config{EnableLog: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddPolicy("user", "post", "view")
+	r.AddPolicy("admin", "post", "*")
+
+	e := echo.New()
+	e.HTTPErrorHandler = server.NewErrorHandler(e).Handle
+	routes := rbac.NewRoutes(e)
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	eg := e.Group("/posts", rbac.Middleware(func(c echo.Context, obj, act string) bool {
+		allowed, err := r.Enforce(c.Request().Header.Get("X-Role"), obj, act)
+		return allowed && err == nil
+	}))
+	eg.GET("", ok, rbac.Require("post", "view"))
+	eg.DELETE("/:id", ok, middleware.RemoveTrailingSlash(), rbac.Require("post", "delete"))
+	eg.GET("/public", ok)
+	// without enforcement function
+	e.GET("/unset", ok, rbac.Require("post", "view"))
+	// checked by the handler
+	e.GET("/owned/:id", ok, rbac.Owned("post", "update_all"))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		role   string
+		want   int
+	}{
+		{name: "Allowed", method: http.MethodGet, path: "/posts", role: "user", want: http.StatusOK},
+		{name: "Forbidden", method: http.MethodDelete, path: "/posts/1", role: "user", want: http.StatusForbidden},
+		{name: "Wildcard action", method: http.MethodDelete, path: "/posts/1", role: "admin", want: http.StatusOK},
+		{name: "Unknown role", method: http.MethodGet, path: "/posts", role: "guest", want: http.StatusForbidden},
+		{name: "Without permission", method: http.MethodGet, path: "/posts/public", role: "guest", want: http.StatusOK},
+		{name: "Without enforcement function", method: http.MethodGet, path: "/unset", role: "admin", want: http.StatusForbidden},
+		{name: "Checked by the handler", method: http.MethodGet, path: "/owned/1", role: "guest", want: http.StatusOK},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Role", tt.role)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	assert.Equal(t, []rbac.RoutePermission{
+		{Method: http.MethodGet, Path: "/owned/:id", Object: "post", Action: "update_all", Owned: true},
+		{Method: http.MethodGet, Path: "/posts", Object: "post", Action: "view"},
+		{Method: http.MethodDelete, Path: "/posts/:id", Object: "post", Action: "delete"},
+		{Method: http.MethodGet, Path: "/unset", Object: "post", Action: "view"},
+	}, routes.List())
+}
+
+func TestRoutesGroupPermission(t *testing.T) {
+	e := echo.New()
+	hooked := 0
+	e.OnAddRouteHandler = func(host string, route echo.Route, handler echo.HandlerFunc, middleware []echo.MiddlewareFunc) {
+		hooked++
+	}
+	routes := rbac.NewRoutes(e)
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	eg := e.Group("/reports", rbac.Require("report", "view_all"))
+	eg.GET("", ok)
+	eg.POST("", ok, rbac.Require("report", "create_all"))
+
+	assert.NotZero(t, hooked, "the previous hook is kept")
+	assert.Equal(t, []rbac.RoutePermission{
+		{Method: http.MethodGet, Path: "/reports", Object: "report", Action: "view_all"},
+		{Method: http.MethodPost, Path: "/reports", Object: "report", Action: "view_all"},
+		{Method: http.MethodPost, Path: "/reports", Object: "report", Action: "create_all"},
+	}, routes.List())
+}
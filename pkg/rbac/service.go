@@ -6,9 +6,9 @@ import (
 	"github.com/vuduongtp/go-core/pkg/rbac/casbinadapter"
 	"github.com/vuduongtp/go-core/pkg/server"
 
-	"github.com/casbin/casbin"
-	"github.com/casbin/casbin/model"
-	"github.com/casbin/casbin/persist"
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
 	"gorm.io/gorm"
 )
 
@@ -20,20 +20,23 @@ var (
 
 // Config represents the config for RBAC service
 type Config struct {
-	Model     model.Model
-	Adapter   persist.Adapter
-	GormDB    *gorm.DB
+	Model   model.Model
+	Adapter persist.Adapter
+	// GormDB overrides the adapter with the Gorm adapter of default config, see casbinadapter.NewAdapter
+	GormDB *gorm.DB
+	// Watcher notifies the other instances of the policy changes & reloads the policy on their changes, see casbinadapter.Watcher
+	Watcher   persist.Watcher
 	EnableLog bool
 }
 
-// RBAC is RBAC application service
+// RBAC is RBAC application service, it is safe for concurrent use since the policy may be reloaded by the watcher
 type RBAC struct {
-	*casbin.Enforcer
+	*casbin.SyncedEnforcer
 }
 
 // Intf represents common interface for the RBAC service
 type Intf interface {
-	Enforce(rvals ...interface{}) bool
+	Enforce(rvals ...interface{}) (bool, error)
 }
 
 // DefaultConfig represents the default configuration
@@ -41,16 +44,17 @@ var DefaultConfig = Config{
 	Model:     NewRBACModel(),
 	Adapter:   nil,
 	GormDB:    nil,
+	Watcher:   nil,
 	EnableLog: true,
 }
 
 // New creates new RBAC service with default configuration
-func New() *RBAC {
+func New() (*RBAC, error) {
 	return NewWithConfig(DefaultConfig)
 }
 
-// NewWithConfig creates new RBAC service with custom configuration
-func NewWithConfig(cfg Config) *RBAC {
+// NewWithConfig creates new RBAC service with custom configuration, the policy is loaded from the adapter if any
+func NewWithConfig(cfg Config) (*RBAC, error) {
 	if cfg.Model == nil {
 		cfg.Model = DefaultConfig.Model
 	}
@@ -58,24 +62,42 @@ func NewWithConfig(cfg Config) *RBAC {
 		cfg.GormDB = DefaultConfig.GormDB
 	}
 	if cfg.GormDB != nil {
-		cfg.Adapter = casbinadapter.NewAdapter(cfg.GormDB)
+		a, err := casbinadapter.NewAdapter(cfg.GormDB)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Adapter = a
 	} else if cfg.Adapter == nil {
 		cfg.Adapter = DefaultConfig.Adapter
 	}
 
-	var ce *casbin.Enforcer
+	params := []interface{}{cfg.Model}
 	if cfg.Adapter != nil {
-		ce = casbin.NewEnforcer(cfg.Model, cfg.Adapter, cfg.EnableLog)
-	} else {
-		ce = casbin.NewEnforcer(cfg.Model, cfg.EnableLog)
+		params = append(params, cfg.Adapter)
+	}
+	ce, err := casbin.NewSyncedEnforcer(append(params, cfg.EnableLog)...)
+	if err != nil {
+		return nil, err
+	}
+	for name, fn := range Conditions() {
+		ce.AddFunction(name, fn)
+	}
+	if cfg.Watcher != nil {
+		if err := ce.SetWatcher(cfg.Watcher); err != nil {
+			return nil, err
+		}
+		// the default callback reloads the policy without locking the synced enforcer
+		if err := cfg.Watcher.SetUpdateCallback(func(string) { _ = ce.LoadPolicy() }); err != nil {
+			return nil, err
+		}
 	}
 
-	return &RBAC{ce}
+	return &RBAC{ce}, nil
 }
 
 // NewRBACModel initializes the RBAC casbin model
 func NewRBACModel() model.Model {
-	m := casbin.NewModel()
+	m := model.NewModel()
 	m.AddDef("r", "r", "sub, obj, act")
 	m.AddDef("p", "p", "sub, obj, act")
 	m.AddDef("g", "g", "_, _")
@@ -86,7 +108,7 @@ func NewRBACModel() model.Model {
 
 // NewRBACWithLevelInheritanceModel initializes the RBAC with level inheritance model
 func NewRBACWithLevelInheritanceModel() model.Model {
-	m := casbin.NewModel()
+	m := model.NewModel()
 	m.AddDef("r", "r", "sub, lvl, obj, act")
 	m.AddDef("p", "p", "sub, lvl, obj, act")
 	m.AddDef("g", "g", "_, _")
@@ -96,13 +118,42 @@ func NewRBACWithLevelInheritanceModel() model.Model {
 	return m
 }
 
-// NewRBACWithDomainModel initializes the RBAC with domain model
+// DomainAny is the domain of the policies & role inheritance rules applied to all domains
+const DomainAny = "*"
+
+// NewRBACWithDomainModel initializes the RBAC with domain model.
+// The policies & role inheritance rules of DomainAny apply to all domains, objects & actions accept the "*" wildcard.
 func NewRBACWithDomainModel() model.Model {
-	m := casbin.NewModel()
+	m := model.NewModel()
 	m.AddDef("r", "r", "sub, dom, obj, act")
 	m.AddDef("p", "p", "sub, dom, obj, act")
 	m.AddDef("g", "g", "_, _, _")
 	m.AddDef("e", "e", "some(where (p.eft == allow))")
-	m.AddDef("m", "m", `g(r.sub, p.sub, r.dom) && r.dom == p.dom && r.obj == p.obj && r.act == p.act`)
+	m.AddDef("m", "m", `(g(r.sub, p.sub, r.dom) || g(r.sub, p.sub, "*")) && (r.dom == p.dom || p.dom == "*") && (r.obj == p.obj || p.obj == "*") && (r.act == p.act || p.act == "*")`)
+	return m
+}
+
+// NewABACModel initializes the RBAC model with conditions on the attributes, see Conditions.
+// The request carries the attributes, e.g: Enforce(sub, obj, act, Attrs{...}), the policies may have a condition evaluated against them.
+// The policies without condition match regardless of the attributes.
+func NewABACModel() model.Model {
+	m := model.NewModel()
+	m.AddDef("r", "r", "sub, obj, act, attrs")
+	m.AddDef("p", "p", "sub, obj, act, cond")
+	m.AddDef("g", "g", "_, _")
+	m.AddDef("e", "e", "some(where (p.eft == allow))")
+	m.AddDef("m", "m", `g(r.sub, p.sub) && (r.obj == p.obj || p.obj == "*") && (r.act == p.act || p.act == "*") && (p.cond == "" || eval(p.cond))`)
+	return m
+}
+
+// NewABACWithDomainModel initializes the RBAC with domain model with conditions on the attributes, see NewABACModel.
+// e.g: the policy `admin, *, user, update_all, attr(r.attrs, "role") == "user"` lets admins update the users having the user role only
+func NewABACWithDomainModel() model.Model {
+	m := model.NewModel()
+	m.AddDef("r", "r", "sub, dom, obj, act, attrs")
+	m.AddDef("p", "p", "sub, dom, obj, act, cond")
+	m.AddDef("g", "g", "_, _, _")
+	m.AddDef("e", "e", "some(where (p.eft == allow))")
+	m.AddDef("m", "m", `(g(r.sub, p.sub, r.dom) || g(r.sub, p.sub, "*")) && (r.dom == p.dom || p.dom == "*") && (r.obj == p.obj || p.obj == "*") && (r.act == p.act || p.act == "*") && (p.cond == "" || eval(p.cond))`)
 	return m
 }
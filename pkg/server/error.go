@@ -27,10 +27,11 @@ type ErrorResponse struct {
 
 // HTTPError represents an error that occurred while handling a request
 type HTTPError struct {
-	Code     int    `json:"code"`
-	Type     string `json:"type"`
-	Message  string `json:"message"`
-	Internal error  `json:"-"`
+	Code     int         `json:"code"`
+	Type     string      `json:"type"`
+	Message  string      `json:"message"`
+	Internal error       `json:"-"`
+	Header   http.Header `json:"-"`
 } // @name ErrorResponse
 
 // NewHTTPError creates a new HTTPError instance
@@ -70,6 +71,15 @@ func (he *HTTPError) SetInternal(err error) *HTTPError {
 	return he
 }
 
+// SetHeader sets the response header sent along with the error. e.g: Retry-After
+func (he *HTTPError) SetHeader(key, value string) *HTTPError {
+	if he.Header == nil {
+		he.Header = make(http.Header)
+	}
+	he.Header.Set(key, value)
+	return he
+}
+
 // ErrorHandler represents the custom http error handler
 type ErrorHandler struct {
 	e *echo.Echo
@@ -97,6 +107,11 @@ func (ce *ErrorHandler) Handle(err error, c echo.Context) {
 		if e.Message != "" {
 			httpErr.Message = e.Message
 		}
+		if !c.Response().Committed {
+			for k, v := range e.Header {
+				c.Response().Header()[k] = v
+			}
+		}
 		if e.Internal != nil && !c.Response().Committed {
 			logger.LogErrorf(c.Request().Context(), "internal err: %+v", e.Internal)
 		}
@@ -123,11 +138,11 @@ func (ce *ErrorHandler) Handle(err error, c echo.Context) {
 	case validator.ValidationErrors:
 		httpErr.Code = http.StatusBadRequest
 		httpErr.Type = ValidationErrorType
-		var errMsg []string
-		for _, v := range e {
-			errMsg = append(errMsg, getVldErrorMsg(v))
-		}
-		httpErr.Message = strings.Join(errMsg, "\n")
+		httpErr.Message = getVldErrorsMsg(e, nil)
+	case *ValidationErrors:
+		httpErr.Code = http.StatusBadRequest
+		httpErr.Type = ValidationErrorType
+		httpErr.Message = getVldErrorsMsg(e.ValidationErrors, e)
 	default:
 		if ce.e.Debug {
 			httpErr.Message = err.Error()
@@ -157,6 +172,22 @@ var validationErrors = map[string]string{
 	"url":      "'s value should be a valid URL",
 }
 
+// getVldErrorsMsg returns the messages of the validation errors, one per line.
+// The messages of the failed custom validations are taken from ve if given
+func getVldErrorsMsg(errs validator.ValidationErrors, ve *ValidationErrors) string {
+	var errMsg []string
+	for _, v := range errs {
+		if ve != nil {
+			if msg, ok := ve.Message(v); ok {
+				errMsg = append(errMsg, v.Field()+" "+msg)
+				continue
+			}
+		}
+		errMsg = append(errMsg, getVldErrorMsg(v))
+	}
+	return strings.Join(errMsg, "\n")
+}
+
 func getVldErrorMsg(v validator.FieldError) string {
 	field := v.Field()
 	vtag := v.ActualTag()
@@ -175,6 +206,8 @@ func getVldErrorMsg(v validator.FieldError) string {
 		return field + " should be greater than " + vtagVal
 	case "eqfield":
 		return field + " does not match " + vtagVal
+	case "password":
+		return field + " does not meet the password policy"
 	}
 
 	return field + " failed on " + vtag + " validation"
@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Custom errors
+var (
+	ErrInvalidAPIKey = server.NewHTTPError(http.StatusUnauthorized, "INVALID_API_KEY", "Invalid or expired API key")
+)
+
+// AuthenticateFunc returns the authenticated user of the given raw API key
+type AuthenticateFunc func(ctx context.Context, key string) (interface{}, error)
+
+// Config represents the config for API key middleware
+type Config struct {
+	// Header carrying the API key
+	Header string
+	// Authenticate converts the API key into the authenticated user stored in the context under jwt.AuthUserKey,
+	// so that handlers get the same user whichever way the request is authenticated
+	Authenticate AuthenticateFunc
+	// Fallback is the middleware used for requests without API key. e.g: jwt.Service.MWFunc()
+	// Requests without API key are rejected if nil.
+	Fallback echo.MiddlewareFunc
+}
+
+// DefaultConfig represents the default configuration
+var DefaultConfig = Config{
+	Header: "X-API-Key",
+}
+
+func (c *Config) fillDefaults() {
+	if c.Header == "" {
+		c.Header = DefaultConfig.Header
+	}
+}
+
+// New creates new API key service with default configuration
+func New(authenticate AuthenticateFunc, fallback echo.MiddlewareFunc) *Service {
+	cfg := DefaultConfig
+	cfg.Authenticate = authenticate
+	cfg.Fallback = fallback
+	return NewWithConfig(cfg)
+}
+
+// NewWithConfig creates new API key service with custom configuration
+func NewWithConfig(cfg Config) *Service {
+	cfg.fillDefaults()
+	if cfg.Authenticate == nil {
+		panic("api key authenticate function is required")
+	}
+	return &Service{cfg: cfg}
+}
+
+// Service provides an API key authentication implementation
+type Service struct {
+	cfg Config
+}
+
+// MWFunc authenticates requests by the API key header if present, or by the fallback middleware otherwise
+func (s *Service) MWFunc() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		var fallback echo.HandlerFunc
+		if s.cfg.Fallback != nil {
+			fallback = s.cfg.Fallback(next)
+		}
+
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(s.cfg.Header)
+			if key == "" {
+				if fallback == nil {
+					return ErrInvalidAPIKey
+				}
+				return fallback(c)
+			}
+
+			ctx := c.Request().Context()
+			usr, err := s.cfg.Authenticate(ctx, key)
+			if err != nil || usr == nil {
+				if err != nil {
+					logger.LogErrorf(ctx, "error authenticating api key: %+v", err.Error())
+				}
+				return ErrInvalidAPIKey.SetInternal(err)
+			}
+			c.Set(jwt.AuthUserKey, usr)
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package apikey_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/mock"
+	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/apikey"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func authenticate(_ context.Context, key string) (interface{}, error) {
+	if key != "valid-key" {
+		return nil, errors.New("key not found")
+	}
+	return "api-user", nil
+}
+
+func TestMWFunc(t *testing.T) {
+	cases := []struct {
+		name       string
+		apiKey     string
+		header     string
+		wantStatus int
+		wantUser   string
+	}{
+		{
+			name:       "No credentials",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Invalid API key",
+			apiKey:     "invalid-key",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Invalid API key does not fall back to valid token",
+			apiKey:     "invalid-key",
+			header:     mock.HeaderValid(),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Valid API key",
+			apiKey:     "valid-key",
+			wantStatus: http.StatusOK,
+			wantUser:   "api-user",
+		},
+		{
+			name:       "Valid token",
+			header:     mock.HeaderValid(),
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	jwtMW := jwt.New("HS256", "jwtsecret", 60)
+	e := echo.New()
+	e.HTTPErrorHandler = server.NewErrorHandler(e).Handle
+	e.Use(apikey.New(authenticate, jwtMW.MWFunc()).MWFunc())
+	e.GET("/hello", func(c echo.Context) error {
+		if usr, ok := c.Get(jwt.AuthUserKey).(string); ok {
+			return c.String(http.StatusOK, usr)
+		}
+		return c.String(http.StatusOK, "")
+	})
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantUser, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMWFuncWithoutFallback(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = server.NewErrorHandler(e).Handle
+	e.Use(apikey.NewWithConfig(apikey.Config{Header: "X-Token", Authenticate: authenticate}).MWFunc())
+	e.GET("/hello", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("Authorization", mock.HeaderValid())
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("X-Token", "valid-key")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,51 @@
+package jwt
+
+import (
+	"fmt"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// Claims represents the claims of the access token
+type Claims struct {
+	jwt.RegisteredClaims
+	// ID of the user, kept as `id` claim for compatibility with the tokens issued before
+	UserID   int    `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Role     string `json:"role,omitempty"`
+	// ID of the login session the token is issued to
+	SessionID int `json:"sid,omitempty"`
+	// Actor is the real user acting on behalf of the subject, set on impersonation tokens
+	Actor *Actor `json:"act,omitempty"`
+	// OrgID pins the token to an organization (tenant), see the tenant middleware
+	OrgID int `json:"org_id,omitempty"`
+}
+
+// Actor represents the `act` (actor) claim of RFC 8693, identifying the party acting on behalf of the subject
+type Actor struct {
+	Subject  string `json:"sub"`
+	UserID   int    `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// validate validates the registered claims, tolerating the given clock skew (leeway) for time based claims
+func (c *Claims) validate(now time.Time, leeway time.Duration, issuer, audience string) error {
+	if !c.VerifyExpiresAt(now.Add(-leeway), false) {
+		return jwt.ErrTokenExpired
+	}
+	if !c.VerifyNotBefore(now.Add(leeway), false) {
+		return jwt.ErrTokenNotValidYet
+	}
+	if !c.VerifyIssuedAt(now.Add(leeway), false) {
+		return jwt.ErrTokenUsedBeforeIssued
+	}
+	if issuer != "" && !c.VerifyIssuer(issuer, true) {
+		return fmt.Errorf("%w: %s", jwt.ErrTokenInvalidIssuer, c.Issuer)
+	}
+	if audience != "" && !c.VerifyAudience(audience, true) {
+		return fmt.Errorf("%w: %v", jwt.ErrTokenInvalidAudience, c.Audience)
+	}
+	return nil
+}
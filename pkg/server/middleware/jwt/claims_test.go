@@ -0,0 +1,135 @@
+package jwt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTokenClaims(t *testing.T) {
+	now := time.Now()
+	signer := jwt.NewWithConfig(jwt.Config{Algorithm: "HS256", Secret: "jwtsecret", Duration: 60, Issuer: "go-core", Audience: "api"})
+	cases := []struct {
+		name    string
+		cfg     jwt.Config
+		claims  *jwt.Claims
+		expire  time.Time
+		wantErr error
+	}{
+		{
+			name:   "Success",
+			cfg:    jwt.Config{Issuer: "go-core", Audience: "api"},
+			claims: &jwt.Claims{UserID: 1},
+			expire: now.Add(time.Minute),
+		},
+		{
+			name:    "Expired",
+			cfg:     jwt.Config{},
+			claims:  &jwt.Claims{UserID: 1},
+			expire:  now.Add(-10 * time.Second),
+			wantErr: gojwt.ErrTokenExpired,
+		},
+		{
+			name:   "Expired within leeway",
+			cfg:    jwt.Config{Leeway: 30},
+			claims: &jwt.Claims{UserID: 1},
+			expire: now.Add(-10 * time.Second),
+		},
+		{
+			name:    "Invalid issuer",
+			cfg:     jwt.Config{Issuer: "another-issuer"},
+			claims:  &jwt.Claims{UserID: 1},
+			expire:  now.Add(time.Minute),
+			wantErr: gojwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name:    "Invalid audience",
+			cfg:     jwt.Config{Audience: "another-api"},
+			claims:  &jwt.Claims{UserID: 1},
+			expire:  now.Add(time.Minute),
+			wantErr: gojwt.ErrTokenInvalidAudience,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenStr, _, err := signer.GenerateToken(tt.claims, &tt.expire)
+			assert.Nil(t, err)
+
+			tt.cfg.Algorithm = "HS256"
+			tt.cfg.Secret = "jwtsecret"
+			token, err := jwt.NewWithConfig(tt.cfg).ParseToken(tokenStr)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, token.Valid)
+			claims := token.Claims.(*jwt.Claims)
+			assert.Equal(t, 1, claims.UserID)
+			assert.Equal(t, "go-core", claims.Issuer)
+			assert.Equal(t, gojwt.ClaimStrings{"api"}, claims.Audience)
+		})
+	}
+}
+
+func TestMWFuncAuthUser(t *testing.T) {
+	type authUser struct {
+		ID       int
+		Username string
+	}
+	j := jwt.NewWithConfig(jwt.Config{
+		Algorithm: "HS256",
+		Secret:    "jwtsecret",
+		Duration:  60,
+		AuthUserFunc: func(claims *jwt.Claims) interface{} {
+			return &authUser{ID: claims.UserID, Username: claims.Username}
+		},
+	})
+
+	var got interface{}
+	e := echo.New()
+	e.Use(j.MWFunc())
+	e.GET("/hello", func(c echo.Context) error {
+		got = c.Get(jwt.AuthUserKey)
+		return c.NoContent(http.StatusOK)
+	})
+
+	token, _, err := j.GenerateToken(&jwt.Claims{UserID: 1, Username: "johndoe"}, nil)
+	assert.Nil(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, &authUser{ID: 1, Username: "johndoe"}, got)
+}
+
+func TestActorClaim(t *testing.T) {
+	j := jwt.NewWithConfig(jwt.Config{Algorithm: "HS256", Secret: "jwtsecret", Duration: 60})
+	claims := &jwt.Claims{UserID: 2, Username: "johndoe", Actor: &jwt.Actor{Subject: "1", UserID: 1, Username: "admin"}}
+	claims.Subject = "2"
+
+	tokenStr, _, err := j.GenerateToken(claims, nil)
+	assert.Nil(t, err)
+
+	token, err := j.ParseToken(tokenStr)
+	assert.Nil(t, err)
+	got := token.Claims.(*jwt.Claims)
+	assert.Equal(t, "2", got.Subject)
+	assert.Equal(t, &jwt.Actor{Subject: "1", UserID: 1, Username: "admin"}, got.Actor)
+
+	// the actor claim is omitted from regular tokens
+	tokenStr, _, err = j.GenerateToken(&jwt.Claims{UserID: 2}, nil)
+	assert.Nil(t, err)
+	token, err = j.ParseToken(tokenStr)
+	assert.Nil(t, err)
+	assert.Nil(t, token.Claims.(*jwt.Claims).Actor)
+}
@@ -1,56 +1,185 @@
 package jwt
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/util/crypter"
 	"github.com/vuduongtp/go-core/pkg/util/logger"
 
 	jwt "github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 )
 
+// Config represents the config for JWT service
+type Config struct {
+	// Signing algorithm. e.g: HS256
+	Algorithm string
+	// Secret key used for signing with HMAC algorithms (HS*).
+	Secret string
+	// Keys used for signing & verifying with asymmetric algorithms (RS*, PS*, ES*, EdDSA).
+	// Several keys can be active at the same time, tokens are verified by the key matching their `kid` header.
+	Keys []Key
+	// SigningKeyID is the ID of the key used to sign new tokens, the first key having a private key is used if empty
+	SigningKeyID string
+	// Duration (in seconds) for which the jwt token is valid.
+	Duration int
+	// Issuer is set as `iss` claim of new tokens, and required to match when parsing tokens if not empty.
+	Issuer string
+	// Audience is set as `aud` claim of new tokens, and required to be present when parsing tokens if not empty.
+	Audience string
+	// Leeway (in seconds) tolerates the clock skew between servers when validating exp, nbf & iat claims.
+	Leeway int
+	// AuthUserFunc converts the verified claims into the authenticated user stored in the context under AuthUserKey.
+	// The claims are stored as is if nil.
+	AuthUserFunc func(*Claims) interface{}
+	// RevocationStore holds revoked tokens, tokens are not checked for revocation if nil
+	RevocationStore RevocationStore
+	// SubjectCheck rejects the tokens of the subjects no longer allowed, e.g: blocked users. Not checked if nil
+	SubjectCheck SubjectCheck
+	// SubjectCheckTTL (in seconds) for which the results of the subject check are cached, defaults to 30 seconds
+	SubjectCheckTTL int
+}
+
 // New generates new JWT service necessery for auth middleware
 func New(algo, secret string, duration int) *Service {
-	signingMethod := jwt.GetSigningMethod(algo)
+	return NewWithConfig(Config{
+		Algorithm:       algo,
+		Secret:          secret,
+		Duration:        duration,
+		RevocationStore: NewMemoryRevocationStore(),
+	})
+}
+
+// NewWithConfig generates new JWT service with custom configuration
+func NewWithConfig(cfg Config) *Service {
+	signingMethod := jwt.GetSigningMethod(cfg.Algorithm)
 	if signingMethod == nil {
 		panic("invalid jwt signing method")
 	}
-	return &Service{
-		algo:     signingMethod,
-		key:      []byte(secret),
-		duration: time.Duration(duration) * time.Second,
+	j := &Service{
+		algo:         signingMethod,
+		keys:         make(map[string]*keyPair),
+		duration:     time.Duration(cfg.Duration) * time.Second,
+		issuer:       cfg.Issuer,
+		audience:     cfg.Audience,
+		leeway:       time.Duration(cfg.Leeway) * time.Second,
+		authUserFunc: cfg.AuthUserFunc,
+		revocation:   cfg.RevocationStore,
+		subjectCheck: cfg.SubjectCheck,
+	}
+	if cfg.SubjectCheck != nil {
+		ttl := time.Duration(cfg.SubjectCheckTTL) * time.Second
+		if ttl <= 0 {
+			ttl = DefaultSubjectCheckTTL
+		}
+		j.subjectCache = newSubjectCache(ttl)
+	}
+
+	if !isAsymmetric(signingMethod) {
+		j.signingKey = &keyPair{signKey: []byte(cfg.Secret), verifyKey: []byte(cfg.Secret)}
+		return j
+	}
+
+	for _, k := range cfg.Keys {
+		kp, err := parseKey(signingMethod, k)
+		if err != nil {
+			panic(err)
+		}
+		if _, ok := j.keys[kp.id]; ok {
+			panic("duplicated jwt key ID: " + kp.id)
+		}
+		j.keys[kp.id] = kp
+		j.keyIDs = append(j.keyIDs, kp.id)
+		if kp.signKey != nil && (kp.id == cfg.SigningKeyID || (cfg.SigningKeyID == "" && j.signingKey == nil)) {
+			j.signingKey = kp
+		}
+	}
+	if j.signingKey == nil {
+		panic("jwt signing key not found")
 	}
+
+	return j
 }
 
 // Service provides a Json-Web-Token authentication implementation
 type Service struct {
-	// Secret key used for signing.
-	key []byte
+	// Key used for signing new tokens.
+	signingKey *keyPair
+	// Active keys by ID (asymmetric algorithms only), in configured order.
+	keys   map[string]*keyPair
+	keyIDs []string
 	// Duration (in seconds) for which the jwt token is valid.
 	duration time.Duration
 	// Service signing algorithm
 	algo jwt.SigningMethod
+	// Expected issuer & audience of the tokens
+	issuer   string
+	audience string
+	// Tolerated clock skew
+	leeway time.Duration
+	// Converts claims into the authenticated user
+	authUserFunc func(*Claims) interface{}
+	// Storage of revoked tokens
+	revocation RevocationStore
+	// Check of the subjects allowed to use their tokens, with its cached results
+	subjectCheck SubjectCheck
+	subjectCache *subjectCache
+	// Longest lifetime (in nanoseconds) of the tokens generated with a custom expiration, see maxLifetime
+	customLifetime int64
+}
+
+// AuthUserKey is the context key of the authenticated user set by the middleware
+const AuthUserKey = "auth_user"
+
+// Custom errors
+var (
+	ErrUnauthorized           = newErrUnauthorized()
+	ErrRevocationNotSupported = server.NewHTTPInternalError("Token revocation is not supported")
+)
+
+// newErrUnauthorized returns a new unauthorized error, the middleware returns a new one per request to set its internal error
+func newErrUnauthorized() *server.HTTPError {
+	return server.NewHTTPError(http.StatusUnauthorized, "UNAUTHORIZED", "Your session is unauthorized or has expired.")
 }
 
 // MWFunc makes JWT implement the Middleware interface.
 func (j *Service) MWFunc() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			ctx := c.Request().Context()
 			token, err := j.ParseTokenFromHeader(c)
 			if err != nil || !token.Valid {
 				if err != nil {
-					logger.LogErrorf(c.Request().Context(), "error parsing token: %+v", err.Error())
+					logger.LogErrorf(ctx, "error parsing token: %+v", err.Error())
 				}
-				return server.NewHTTPError(http.StatusUnauthorized, "UNAUTHORIZED", "Your session is unauthorized or has expired.").SetInternal(err)
+				return newErrUnauthorized().SetInternal(err)
+			}
+
+			claims := token.Claims.(*Claims)
+			if err := j.checkRevoked(ctx, claims); err != nil {
+				return newErrUnauthorized().SetInternal(err)
 			}
+			if err := j.checkSubject(ctx, claims); err != nil {
+				return newErrUnauthorized().SetInternal(err)
+			}
+
+			// both identities are logged for the requests made on behalf of another user
+			ctx = logger.AddLogField(ctx, "sub", claims.Subject)
+			if claims.Actor != nil {
+				ctx = logger.AddLogField(ctx, "act_sub", claims.Actor.Subject)
+			}
+			c.SetRequest(c.Request().WithContext(ctx))
 
-			claims := token.Claims.(jwt.MapClaims)
-			for key, val := range claims {
-				c.Set(key, val)
+			if j.authUserFunc != nil {
+				c.Set(AuthUserKey, j.authUserFunc(claims))
+			} else {
+				c.Set(AuthUserKey, claims)
 			}
 
 			return next(c)
@@ -72,26 +201,124 @@ func (j *Service) ParseTokenFromHeader(c echo.Context) (*jwt.Token, error) {
 	return j.ParseToken(parts[1])
 }
 
-// ParseToken parses token from string
+// ParseToken parses token from string, the claims of the returned token are of *Claims type
 func (j *Service) ParseToken(input string) (*jwt.Token, error) {
-	return jwt.Parse(input, func(token *jwt.Token) (interface{}, error) {
-		if j.algo != token.Method {
-			return nil, fmt.Errorf("token method mismatched")
-		}
-		return j.key, nil
-	})
+	token, err := jwt.ParseWithClaims(input, &Claims{}, j.keyFunc, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := token.Claims.(*Claims).validate(time.Now(), j.leeway, j.issuer, j.audience); err != nil {
+		token.Valid = false
+		return token, err
+	}
+
+	return token, nil
+}
+
+// keyFunc returns the key to verify the given token
+func (j *Service) keyFunc(token *jwt.Token) (interface{}, error) {
+	if j.algo != token.Method {
+		return nil, fmt.Errorf("token method mismatched")
+	}
+	if !isAsymmetric(j.algo) {
+		return j.signingKey.verifyKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	kp, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID: %s", kid)
+	}
+	return kp.verifyKey, nil
 }
 
-// GenerateToken generates new Service token and populates it with user data
-func (j *Service) GenerateToken(claims map[string]interface{}, expire *time.Time) (string, int, error) {
+// GenerateToken generates new Service token and populates it with user data.
+// The registered claims (iss, aud, exp, nbf, iat & jti) are filled in if not set.
+// Each token is given an unique ID (jti) so that it can be revoked individually.
+func (j *Service) GenerateToken(claims *Claims, expire *time.Time) (string, int, error) {
+	now := time.Now()
 	if expire == nil {
-		expTime := time.Now().Add(j.duration)
+		expTime := now.Add(j.duration)
 		expire = &expTime
+	} else {
+		j.trackLifetime(expire.Sub(now))
+	}
+	claims.ExpiresAt = jwt.NewNumericDate(*expire)
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	if claims.ID == "" {
+		claims.ID = crypter.UID()
+	}
+	if claims.Issuer == "" {
+		claims.Issuer = j.issuer
+	}
+	if claims.Audience == nil && j.audience != "" {
+		claims.Audience = jwt.ClaimStrings{j.audience}
+	}
+
+	token := jwt.NewWithClaims(j.algo, claims)
+	if j.signingKey.id != "" {
+		token.Header["kid"] = j.signingKey.id
+	}
+	tokenString, err := token.SignedString(j.signingKey.signKey)
+
+	return tokenString, int(expire.Sub(now).Seconds()), err
+}
+
+// RevokeToken revokes a single token by its ID (jti) until it expires
+func (j *Service) RevokeToken(ctx context.Context, jti string, exp time.Time) error {
+	if j.revocation == nil {
+		return ErrRevocationNotSupported
 	}
-	claims["exp"] = expire.Unix()
+	return j.revocation.Revoke(ctx, jti, exp)
+}
+
+// RevokeSubject revokes all tokens issued to the subject (sub) until the current second.
+// As `iat` is in whole seconds, the tokens issued later in the same second are revoked too, they must be issued again.
+func (j *Service) RevokeSubject(ctx context.Context, sub string) error {
+	if j.revocation == nil {
+		return ErrRevocationNotSupported
+	}
+	before := time.Now().Truncate(time.Second)
+	return j.revocation.RevokeSubject(ctx, sub, before, before.Add(j.maxLifetime()+j.leeway))
+}
+
+// trackLifetime records the lifetime of a token generated with a custom expiration if it is the longest so far
+func (j *Service) trackLifetime(d time.Duration) {
+	for {
+		cur := atomic.LoadInt64(&j.customLifetime)
+		if int64(d) <= cur || atomic.CompareAndSwapInt64(&j.customLifetime, cur, int64(d)) {
+			return
+		}
+	}
+}
 
-	token := jwt.NewWithClaims(j.algo, jwt.MapClaims(claims))
-	tokenString, err := token.SignedString(j.key)
+// maxLifetime returns the longest lifetime of the tokens generated, for which the revocation of the subjects lasts
+func (j *Service) maxLifetime() time.Duration {
+	if d := time.Duration(atomic.LoadInt64(&j.customLifetime)); d > j.duration {
+		return d
+	}
+	return j.duration
+}
+
+// checkRevoked returns error if the token has been revoked
+func (j *Service) checkRevoked(ctx context.Context, claims *Claims) error {
+	if j.revocation == nil {
+		return nil
+	}
 
-	return tokenString, int(expire.Sub(time.Now()).Seconds()), err
+	var iat time.Time
+	if claims.IssuedAt != nil {
+		iat = claims.IssuedAt.Time
+	}
+
+	revoked, err := j.revocation.IsRevoked(ctx, claims.ID, claims.Subject, iat)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return fmt.Errorf("token revoked")
+	}
+	return nil
 }
@@ -0,0 +1,181 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// Key represents a PEM encoded key used by asymmetric algorithms (RS*, PS*, ES*, EdDSA)
+type Key struct {
+	// ID of the key, sent in the `kid` header of the tokens
+	ID string
+	// PEM encoded private key. Keys without private key can only verify tokens, e.g. keys being rotated out
+	PrivateKey []byte
+	// PEM encoded public key. Optional if the private key is given
+	PublicKey []byte
+}
+
+// keyPair holds the parsed signing & verifying keys
+type keyPair struct {
+	id        string
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// LoadKeys reads PEM keys from the given directory.
+// Private keys are read from `<kid>.pem` files, public keys from `<kid>.pub.pem` files.
+func LoadKeys(dir string) ([]Key, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*Key)
+	var ids []string
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		name := filepath.Base(f)
+		isPublic := strings.HasSuffix(name, ".pub.pem")
+		kid := strings.TrimSuffix(strings.TrimSuffix(name, ".pem"), ".pub")
+		if _, ok := keys[kid]; !ok {
+			keys[kid] = &Key{ID: kid}
+			ids = append(ids, kid)
+		}
+		if isPublic {
+			keys[kid].PublicKey = b
+		} else {
+			keys[kid].PrivateKey = b
+		}
+	}
+
+	out := make([]Key, 0, len(ids))
+	for _, kid := range ids {
+		out = append(out, *keys[kid])
+	}
+	return out, nil
+}
+
+// parseKey parses the PEM encoded key for the given signing method
+func parseKey(method jwt.SigningMethod, k Key) (*keyPair, error) {
+	kp := &keyPair{id: k.ID}
+
+	var err error
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if k.PrivateKey != nil {
+			kp.signKey, err = jwt.ParseRSAPrivateKeyFromPEM(k.PrivateKey)
+		}
+		if err == nil && k.PublicKey != nil {
+			kp.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(k.PublicKey)
+		}
+	case *jwt.SigningMethodECDSA:
+		if k.PrivateKey != nil {
+			kp.signKey, err = jwt.ParseECPrivateKeyFromPEM(k.PrivateKey)
+		}
+		if err == nil && k.PublicKey != nil {
+			kp.verifyKey, err = jwt.ParseECPublicKeyFromPEM(k.PublicKey)
+		}
+	case *jwt.SigningMethodEd25519:
+		if k.PrivateKey != nil {
+			kp.signKey, err = jwt.ParseEdPrivateKeyFromPEM(k.PrivateKey)
+		}
+		if err == nil && k.PublicKey != nil {
+			kp.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(k.PublicKey)
+		}
+	default:
+		return nil, fmt.Errorf("signing method %s does not use PEM keys", method.Alg())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %s: %w", k.ID, err)
+	}
+
+	if kp.verifyKey == nil {
+		signer, ok := kp.signKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("invalid key %s: either private or public key is required", k.ID)
+		}
+		kp.verifyKey = signer.Public()
+	}
+
+	return kp, nil
+}
+
+// isAsymmetric checks whether the signing method uses private/public key pairs
+func isAsymmetric(method jwt.SigningMethod) bool {
+	_, isHMAC := method.(*jwt.SigningMethodHMAC)
+	return !isHMAC
+}
+
+// JWK represents a JSON Web Key (RFC 7517), only the public parts are included
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA public key
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC & OKP public key
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet represents a JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns public keys of all active keys. The shared secret of HMAC algorithms is never exposed
+func (j *Service) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if !isAsymmetric(j.algo) {
+		return set
+	}
+
+	for _, kid := range j.keyIDs {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: j.algo.Alg()}
+		switch pub := j.keys[kid].verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// JWKSHandler serves the JSON Web Key Set, e.g. on /.well-known/jwks.json
+func (j *Service) JWKSHandler(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(http.StatusOK, j.JWKS())
+}
@@ -0,0 +1,148 @@
+package jwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func genKey(t *testing.T, algo string, kid string) jwt.Key {
+	var priv crypto.Signer
+	var err error
+	switch algo {
+	case "RS256", "PS256":
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		priv, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "EdDSA":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwt.Key{
+		ID:         kid,
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		PublicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}),
+	}
+}
+
+func TestAsymmetricAlgorithms(t *testing.T) {
+	cases := []struct {
+		algo    string
+		wantKty string
+		wantCrv string
+	}{
+		{algo: "RS256", wantKty: "RSA"},
+		{algo: "PS256", wantKty: "RSA"},
+		{algo: "ES256", wantKty: "EC", wantCrv: "P-256"},
+		{algo: "ES384", wantKty: "EC", wantCrv: "P-384"},
+		{algo: "EdDSA", wantKty: "OKP", wantCrv: "Ed25519"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.algo, func(t *testing.T) {
+			key := genKey(t, tt.algo, "key1")
+			// the public key is derived from the private key
+			key.PublicKey = nil
+			j := jwt.NewWithConfig(jwt.Config{Algorithm: tt.algo, Keys: []jwt.Key{key}, Duration: 60})
+
+			tokenStr, _, err := j.GenerateToken(&jwt.Claims{UserID: 1}, nil)
+			assert.Nil(t, err)
+
+			token, err := j.ParseToken(tokenStr)
+			assert.Nil(t, err)
+			assert.True(t, token.Valid)
+			assert.Equal(t, "key1", token.Header["kid"])
+
+			jwks := j.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, "key1", jwks.Keys[0].Kid)
+			assert.Equal(t, tt.algo, jwks.Keys[0].Alg)
+			assert.Equal(t, tt.wantKty, jwks.Keys[0].Kty)
+			assert.Equal(t, tt.wantCrv, jwks.Keys[0].Crv)
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := genKey(t, "ES256", "old")
+	newKey := genKey(t, "ES256", "new")
+
+	oldSvc := jwt.NewWithConfig(jwt.Config{Algorithm: "ES256", Keys: []jwt.Key{oldKey}, Duration: 60})
+	oldToken, _, err := oldSvc.GenerateToken(&jwt.Claims{UserID: 1}, nil)
+	assert.Nil(t, err)
+
+	// the old key is kept for verification only while new tokens are signed by the new key
+	retiredKey := jwt.Key{ID: oldKey.ID, PublicKey: oldKey.PublicKey}
+	svc := jwt.NewWithConfig(jwt.Config{Algorithm: "ES256", Keys: []jwt.Key{retiredKey, newKey}, SigningKeyID: "new", Duration: 60})
+
+	token, err := svc.ParseToken(oldToken)
+	assert.Nil(t, err)
+	assert.True(t, token.Valid)
+
+	newToken, _, err := svc.GenerateToken(&jwt.Claims{UserID: 1}, nil)
+	assert.Nil(t, err)
+	token, err = svc.ParseToken(newToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", token.Header["kid"])
+	assert.Len(t, svc.JWKS().Keys, 2)
+
+	// once the old key is removed, its tokens are rejected
+	svc = jwt.NewWithConfig(jwt.Config{Algorithm: "ES256", Keys: []jwt.Key{newKey}, Duration: 60})
+	_, err = svc.ParseToken(oldToken)
+	assert.NotNil(t, err)
+
+	assert.Panics(t, func() {
+		jwt.NewWithConfig(jwt.Config{Algorithm: "ES256", Keys: []jwt.Key{retiredKey}, Duration: 60})
+	}, "a signing key is required")
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	key1 := genKey(t, "RS256", "key1")
+	key2 := genKey(t, "RS256", "key2")
+	files := map[string][]byte{
+		"key1.pem":     key1.PrivateKey,
+		"key2.pub.pem": key2.PublicKey,
+	}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := jwt.LoadKeys(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []jwt.Key{
+		{ID: "key1", PrivateKey: key1.PrivateKey},
+		{ID: "key2", PublicKey: key2.PublicKey},
+	}, keys)
+}
+
+func TestJWKSWithHMAC(t *testing.T) {
+	j := jwt.New("HS256", "jwtsecret", 60)
+	assert.Empty(t, j.JWKS().Keys)
+}
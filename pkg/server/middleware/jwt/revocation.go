@@ -0,0 +1,171 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationStore represents the storage of revoked tokens
+type RevocationStore interface {
+	// Revoke revokes a single token by its ID (jti), the record can be dropped after `exp`
+	Revoke(ctx context.Context, jti string, exp time.Time) error
+	// RevokeSubject revokes all tokens of the subject (sub) issued until the given time, i.e. whose `iat` is not later.
+	// The record can be dropped after `exp`, when all these tokens have expired by themselves
+	RevokeSubject(ctx context.Context, sub string, before, exp time.Time) error
+	// IsRevoked checks whether the token identified by jti, sub & iat claims has been revoked
+	IsRevoked(ctx context.Context, jti, sub string, iat time.Time) (bool, error)
+}
+
+// NewMemoryRevocationStore creates new in-memory revocation store.
+// Revoked tokens are not shared between instances, use the GORM store for multiple instances.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens:   make(map[string]time.Time),
+		subjects: make(map[string]revokedSubject),
+	}
+}
+
+// MemoryRevocationStore is the in-memory implementation of RevocationStore
+type MemoryRevocationStore struct {
+	mu sync.RWMutex
+	// tokens maps revoked jti to its expiration time
+	tokens map[string]time.Time
+	// subjects maps revoked sub to the revocation time
+	subjects map[string]revokedSubject
+}
+
+type revokedSubject struct {
+	before time.Time
+	exp    time.Time
+}
+
+// deleteExpired drops the records which are no longer needed, the lock must be held
+func (s *MemoryRevocationStore) deleteExpired(now time.Time) {
+	for k, v := range s.tokens {
+		if v.Before(now) {
+			delete(s.tokens, k)
+		}
+	}
+	for k, v := range s.subjects {
+		if v.exp.Before(now) {
+			delete(s.subjects, k)
+		}
+	}
+}
+
+// Revoke revokes a single token by its ID (jti)
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// drop expired records on the way
+	s.deleteExpired(time.Now())
+	s.tokens[jti] = exp
+	return nil
+}
+
+// RevokeSubject revokes all tokens of the subject issued until the given time
+func (s *MemoryRevocationStore) RevokeSubject(ctx context.Context, sub string, before, exp time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteExpired(time.Now())
+	s.subjects[sub] = revokedSubject{before: before, exp: exp}
+	return nil
+}
+
+// IsRevoked checks whether the token has been revoked
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti, sub string, iat time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[jti]; ok && jti != "" {
+		return true, nil
+	}
+	if rec, ok := s.subjects[sub]; ok && sub != "" && !iat.After(rec.before) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// RevokedToken represents a revoked token stored in database
+type RevokedToken struct {
+	JTI       string    `gorm:"type:varchar(255);primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// RevokedSubject represents a subject whose tokens issued until RevokedBefore are revoked
+type RevokedSubject struct {
+	Subject       string `gorm:"type:varchar(255);primaryKey"`
+	RevokedBefore time.Time
+	ExpiresAt     time.Time `gorm:"index"`
+	UpdatedAt     time.Time
+}
+
+// NewGormRevocationStore creates new GORM revocation store, the expired records are deleted on insert.
+// The revoked_tokens & revoked_subjects tables must be migrated beforehand.
+func NewGormRevocationStore(db *gorm.DB) *GormRevocationStore {
+	return &GormRevocationStore{db}
+}
+
+// GormRevocationStore is the GORM implementation of RevocationStore
+type GormRevocationStore struct {
+	db *gorm.DB
+}
+
+// Revoke revokes a single token by its ID (jti)
+func (s *GormRevocationStore) Revoke(ctx context.Context, jti string, exp time.Time) error {
+	if err := s.DeleteExpired(ctx); err != nil {
+		return err
+	}
+	rec := &RevokedToken{JTI: jti, ExpiresAt: exp.UTC()}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(rec).Error
+}
+
+// RevokeSubject revokes all tokens of the subject issued until the given time
+func (s *GormRevocationStore) RevokeSubject(ctx context.Context, sub string, before, exp time.Time) error {
+	if err := s.DeleteExpired(ctx); err != nil {
+		return err
+	}
+	rec := &RevokedSubject{Subject: sub, RevokedBefore: before.UTC(), ExpiresAt: exp.UTC()}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "expires_at", "updated_at"}),
+	}).Create(rec).Error
+}
+
+// IsRevoked checks whether the token has been revoked
+func (s *GormRevocationStore) IsRevoked(ctx context.Context, jti, sub string, iat time.Time) (bool, error) {
+	db := s.db.WithContext(ctx)
+	var count int64
+	if jti != "" {
+		if err := db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	if sub != "" {
+		if err := db.Model(&RevokedSubject{}).Where("subject = ? AND revoked_before >= ?", sub, iat.UTC()).Count(&count).Error; err != nil {
+			return false, err
+		}
+	}
+	return count > 0, nil
+}
+
+// DeleteExpired deletes the revoked tokens which have already expired by themselves,
+// and the revoked subjects whose tokens have all expired. It is called on insert
+func (s *GormRevocationStore) DeleteExpired(ctx context.Context) error {
+	db := s.db.WithContext(ctx)
+	now := time.Now().UTC()
+	if err := db.Where("expires_at < ?", now).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return db.Where("expires_at < ?", now).Delete(&RevokedSubject{}).Error
+}
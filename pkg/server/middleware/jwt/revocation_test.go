@@ -0,0 +1,163 @@
+package jwt_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRevocationStores(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&jwt.RevokedToken{}, &jwt.RevokedSubject{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]jwt.RevocationStore{
+		"Memory": jwt.NewMemoryRevocationStore(),
+		"Gorm":   jwt.NewGormRevocationStore(db),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+
+			revoked, err := store.IsRevoked(ctx, "jti1", "1", now)
+			assert.Nil(t, err)
+			assert.False(t, revoked)
+
+			assert.Nil(t, store.Revoke(ctx, "jti1", now.Add(time.Hour)))
+			// revoking twice must not fail
+			assert.Nil(t, store.Revoke(ctx, "jti1", now.Add(time.Hour)))
+
+			revoked, err = store.IsRevoked(ctx, "jti1", "1", now)
+			assert.Nil(t, err)
+			assert.True(t, revoked)
+
+			revoked, err = store.IsRevoked(ctx, "jti2", "1", now.Add(-time.Minute))
+			assert.Nil(t, err)
+			assert.False(t, revoked)
+
+			assert.Nil(t, store.RevokeSubject(ctx, "1", now, now.Add(time.Hour)))
+			assert.Nil(t, store.RevokeSubject(ctx, "1", now, now.Add(time.Hour)))
+
+			revoked, err = store.IsRevoked(ctx, "jti2", "1", now.Add(-time.Minute))
+			assert.Nil(t, err)
+			assert.True(t, revoked, "tokens issued before the revocation must be revoked")
+
+			revoked, err = store.IsRevoked(ctx, "jti3", "1", now.Add(time.Minute))
+			assert.Nil(t, err)
+			assert.False(t, revoked, "tokens issued after the revocation must stay valid")
+
+			revoked, err = store.IsRevoked(ctx, "jti2", "2", now.Add(-time.Minute))
+			assert.Nil(t, err)
+			assert.False(t, revoked, "other subjects must not be affected")
+
+			revoked, err = store.IsRevoked(ctx, "jti3", "1", now)
+			assert.Nil(t, err)
+			assert.True(t, revoked, "tokens issued at the time of the revocation must be revoked")
+
+			// the expired records are dropped on insert
+			assert.Nil(t, store.Revoke(ctx, "jti4", now.Add(-time.Second)))
+			assert.Nil(t, store.RevokeSubject(ctx, "2", now, now.Add(-time.Second)))
+			assert.Nil(t, store.Revoke(ctx, "jti5", now.Add(time.Hour)))
+			revoked, err = store.IsRevoked(ctx, "jti4", "2", now.Add(-time.Minute))
+			assert.Nil(t, err)
+			assert.False(t, revoked)
+		})
+	}
+}
+
+func TestGormRevocationStorePrune(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "revocation.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&jwt.RevokedToken{}, &jwt.RevokedSubject{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	store := jwt.NewGormRevocationStore(db)
+	assert.Nil(t, store.Revoke(ctx, "expired", now.Add(-time.Minute)))
+	assert.Nil(t, store.RevokeSubject(ctx, "1", now.Add(-time.Hour), now.Add(-time.Minute)))
+	assert.Nil(t, store.Revoke(ctx, "jti", now.Add(time.Hour)))
+
+	var tokens, subjects int64
+	assert.Nil(t, db.Model(&jwt.RevokedToken{}).Count(&tokens).Error)
+	assert.Nil(t, db.Model(&jwt.RevokedSubject{}).Count(&subjects).Error)
+	assert.Equal(t, int64(1), tokens)
+	assert.Equal(t, int64(0), subjects)
+}
+
+func TestMWFuncRevoked(t *testing.T) {
+	ctx := context.Background()
+	j := jwt.New("HS256", "jwtsecret", 60)
+	ts := httptest.NewServer(echoHandler(j.MWFunc()))
+	defer ts.Close()
+
+	doRequest := func(token string) int {
+		req, _ := http.NewRequest("GET", ts.URL+"/hello", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("Cannot create http request")
+		}
+		return res.StatusCode
+	}
+
+	token, _, err := j.GenerateToken(&jwt.Claims{RegisteredClaims: gojwt.RegisteredClaims{Subject: "1", ID: "jti1"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, doRequest(token))
+
+	assert.Nil(t, j.RevokeToken(ctx, "jti1", time.Now().Add(time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, doRequest(token))
+
+	earlier, _, err := j.GenerateToken(&jwt.Claims{RegisteredClaims: gojwt.RegisteredClaims{Subject: "2"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, doRequest(earlier))
+
+	// the token is issued & revoked at the start of the same second
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	token, _, err = j.GenerateToken(&jwt.Claims{RegisteredClaims: gojwt.RegisteredClaims{Subject: "2"}}, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, j.RevokeSubject(ctx, "2"))
+	assert.Equal(t, http.StatusUnauthorized, doRequest(earlier))
+	assert.Equal(t, http.StatusUnauthorized, doRequest(token), "tokens issued in the same second must be revoked")
+
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	token, _, err = j.GenerateToken(&jwt.Claims{RegisteredClaims: gojwt.RegisteredClaims{Subject: "2"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, doRequest(token), "tokens issued after the revocation must stay valid")
+}
+
+func TestMWFuncErrors(t *testing.T) {
+	j := jwt.New("HS256", "jwtsecret", 60)
+	mw := j.MWFunc()(func(c echo.Context) error { return nil })
+
+	errs := make(chan error, 2)
+	for _, header := range []string{"", "Bearer invalid"} {
+		go func(header string) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", header)
+			errs <- mw(echo.New().NewContext(req, httptest.NewRecorder()))
+		}(header)
+	}
+	err1, err2 := <-errs, <-errs
+	assert.NotSame(t, err1, err2, "each request gets its own error")
+	assert.Nil(t, jwt.ErrUnauthorized.Internal, "the shared error is not changed")
+}
@@ -0,0 +1,99 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SubjectCheck reports whether the subject (sub) may still use its tokens, e.g: the user has not been blocked
+type SubjectCheck func(ctx context.Context, sub string) (bool, error)
+
+// DefaultSubjectCheckTTL is the time the results of the subject check are cached for if not configured
+const DefaultSubjectCheckTTL = 30 * time.Second
+
+// maxSubjectCacheSize triggers the removal of expired entries when reached
+const maxSubjectCacheSize = 10000
+
+// InvalidateSubject drops the cached result of the subject check, so that the change of the subject is applied immediately.
+// Other instances apply it when their cached result expires.
+func (j *Service) InvalidateSubject(sub string) {
+	if j.subjectCache != nil {
+		j.subjectCache.delete(sub)
+	}
+}
+
+// checkSubject returns error if the subject of the token, or the actor of an impersonation token, is not allowed
+func (j *Service) checkSubject(ctx context.Context, claims *Claims) error {
+	if j.subjectCheck == nil {
+		return nil
+	}
+
+	subs := []string{claims.Subject}
+	if claims.Actor != nil {
+		subs = append(subs, claims.Actor.Subject)
+	}
+	for _, sub := range subs {
+		if sub == "" {
+			continue
+		}
+		allowed, ok := j.subjectCache.get(sub, time.Now())
+		if !ok {
+			var err error
+			if allowed, err = j.subjectCheck(ctx, sub); err != nil {
+				return err
+			}
+			j.subjectCache.set(sub, allowed, time.Now())
+		}
+		if !allowed {
+			return fmt.Errorf("subject %s is not allowed", sub)
+		}
+	}
+	return nil
+}
+
+func newSubjectCache(ttl time.Duration) *subjectCache {
+	return &subjectCache{ttl: ttl, entries: make(map[string]subjectEntry)}
+}
+
+// subjectCache holds the results of the subject check for a short time
+type subjectCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]subjectEntry
+}
+
+type subjectEntry struct {
+	allowed   bool
+	expiresAt time.Time
+}
+
+func (c *subjectCache) get(sub string, now time.Time) (allowed bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[sub]
+	if !ok || !e.expiresAt.After(now) {
+		return false, false
+	}
+	return e.allowed, true
+}
+
+func (c *subjectCache) set(sub string, allowed bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxSubjectCacheSize {
+		for k, e := range c.entries {
+			if !e.expiresAt.After(now) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[sub] = subjectEntry{allowed: allowed, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *subjectCache) delete(sub string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, sub)
+}
@@ -0,0 +1,70 @@
+package jwt_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/server/middleware/jwt"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMWFuncSubjectCheck(t *testing.T) {
+	var mu sync.Mutex
+	blocked := map[string]bool{}
+	calls := 0
+	j := jwt.NewWithConfig(jwt.Config{
+		Algorithm: "HS256",
+		Secret:    "jwtsecret",
+		Duration:  60,
+		SubjectCheck: func(ctx context.Context, sub string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			return !blocked[sub], nil
+		},
+		SubjectCheckTTL: 60,
+	})
+	e := echoHandler(j.MWFunc())
+
+	doRequest := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	setBlocked := func(sub string, v bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		blocked[sub] = v
+	}
+
+	token, _, err := j.GenerateToken(&jwt.Claims{RegisteredClaims: gojwt.RegisteredClaims{Subject: "1"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, doRequest(token))
+	assert.Equal(t, http.StatusOK, doRequest(token))
+	assert.Equal(t, 1, calls, "the result must be cached")
+
+	// the cached result is used until it expires or is invalidated
+	setBlocked("1", true)
+	assert.Equal(t, http.StatusOK, doRequest(token))
+	j.InvalidateSubject("1")
+	assert.Equal(t, http.StatusUnauthorized, doRequest(token))
+
+	setBlocked("1", false)
+	j.InvalidateSubject("1")
+	assert.Equal(t, http.StatusOK, doRequest(token))
+
+	// impersonation tokens are rejected if the actor is not allowed
+	token, _, err = j.GenerateToken(&jwt.Claims{RegisteredClaims: gojwt.RegisteredClaims{Subject: "1"}, Actor: &jwt.Actor{Subject: "2"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, doRequest(token))
+	setBlocked("2", true)
+	j.InvalidateSubject("2")
+	assert.Equal(t, http.StatusUnauthorized, doRequest(token))
+}
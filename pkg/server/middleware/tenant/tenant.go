@@ -0,0 +1,103 @@
+package tenant
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/vuduongtp/go-core/pkg/server"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Custom errors
+var (
+	ErrInvalidTenant  = server.NewHTTPValidationError("Invalid organization ID")
+	ErrTenantMismatch = server.NewHTTPError(http.StatusForbidden, "TENANT_MISMATCH", "The organization does not match the one of the access token")
+)
+
+// ClaimFunc returns the tenant pinned in the credentials of the request, e.g: the `org_id` claim of the access token. 0 if none
+type ClaimFunc func(c echo.Context) int
+
+// ResolveFunc checks the access of the authenticated user to the tenant, and applies the tenant to the user, e.g: its role within the tenant
+type ResolveFunc func(c echo.Context, tenantID int) error
+
+// Config represents the config for tenant middleware
+type Config struct {
+	// Header carrying the ID of the active tenant
+	Header string
+	// Claim returns the tenant pinned in the credentials, which the header may not override. Not checked if nil
+	Claim ClaimFunc
+	// Resolve is called with the active tenant before it is applied to the request context
+	Resolve ResolveFunc
+}
+
+// DefaultConfig represents the default configuration
+var DefaultConfig = Config{
+	Header: "X-Org-ID",
+}
+
+func (c *Config) fillDefaults() {
+	if c.Header == "" {
+		c.Header = DefaultConfig.Header
+	}
+}
+
+// New creates new tenant service with default configuration
+func New(claim ClaimFunc, resolve ResolveFunc) *Service {
+	cfg := DefaultConfig
+	cfg.Claim = claim
+	cfg.Resolve = resolve
+	return NewWithConfig(cfg)
+}
+
+// NewWithConfig creates new tenant service with custom configuration
+func NewWithConfig(cfg Config) *Service {
+	cfg.fillDefaults()
+	if cfg.Resolve == nil {
+		panic("tenant resolve function is required")
+	}
+	return &Service{cfg: cfg}
+}
+
+// Service selects the active tenant of the requests
+type Service struct {
+	cfg Config
+}
+
+// MWFunc selects the active tenant from the credentials or the header, if any.
+// The queries of the tenant-scoped DBs are then restricted to the tenant, see dbutil.WithTenant.
+// It must run after the authentication middleware.
+func (s *Service) MWFunc() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenantID := 0
+			if s.cfg.Claim != nil {
+				tenantID = s.cfg.Claim(c)
+			}
+			if header := c.Request().Header.Get(s.cfg.Header); header != "" {
+				id, err := strconv.Atoi(header)
+				if err != nil || id <= 0 {
+					return ErrInvalidTenant.SetInternal(err)
+				}
+				if tenantID != 0 && tenantID != id {
+					return ErrTenantMismatch
+				}
+				tenantID = id
+			}
+			if tenantID == 0 {
+				return next(c)
+			}
+
+			if err := s.cfg.Resolve(c, tenantID); err != nil {
+				return err
+			}
+			ctx := dbutil.WithTenant(c.Request().Context(), tenantID)
+			ctx = logger.AddLogField(ctx, "org_id", tenantID)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/server/middleware/tenant"
+	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var errNotMember = server.NewHTTPError(http.StatusForbidden, "NOT_MEMBER", "Not a member")
+
+func claim(c echo.Context) int {
+	id, _ := strconv.Atoi(c.Request().Header.Get("X-Claim"))
+	return id
+}
+
+func resolve(_ echo.Context, tenantID int) error {
+	if tenantID == 3 {
+		return errNotMember
+	}
+	return nil
+}
+
+func TestMWFunc(t *testing.T) {
+	cases := []struct {
+		name       string
+		claim      string
+		header     string
+		wantStatus int
+		wantTenant string
+	}{
+		{
+			name:       "No tenant",
+			wantStatus: http.StatusOK,
+			wantTenant: "0",
+		},
+		{
+			name:       "Tenant by header",
+			header:     "1",
+			wantStatus: http.StatusOK,
+			wantTenant: "1",
+		},
+		{
+			name:       "Tenant by claim",
+			claim:      "2",
+			wantStatus: http.StatusOK,
+			wantTenant: "2",
+		},
+		{
+			name:       "Header matching claim",
+			claim:      "2",
+			header:     "2",
+			wantStatus: http.StatusOK,
+			wantTenant: "2",
+		},
+		{
+			name:       "Header overriding claim",
+			claim:      "2",
+			header:     "1",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Invalid header",
+			header:     "abc",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Tenant not resolved",
+			header:     "3",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = server.NewErrorHandler(e).Handle
+	e.Use(tenant.New(claim, resolve).MWFunc())
+	e.GET("/hello", func(c echo.Context) error {
+		tenantID, _ := dbutil.TenantFromContext(c.Request().Context())
+		return c.String(http.StatusOK, strconv.Itoa(tenantID))
+	})
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+			if tt.claim != "" {
+				req.Header.Set("X-Claim", tt.claim)
+			}
+			if tt.header != "" {
+				req.Header.Set("X-Org-ID", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantTenant, rec.Body.String())
+			}
+		})
+	}
+}
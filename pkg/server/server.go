@@ -3,9 +3,11 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -13,6 +15,7 @@ import (
 	"github.com/labstack/gommon/log"
 	"github.com/vuduongtp/go-core/pkg/server/middleware/secure"
 	"github.com/vuduongtp/go-core/pkg/util/logger"
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
 	"github.com/vuduongtp/go-logadapter"
 )
 
@@ -26,6 +29,11 @@ type Config struct {
 	AllowOrigins    []string
 	IsEnableSwagger bool
 	SwaggerPath     string
+	// PasswordPolicy is used by the "password" validation tag, defaults to passwordpolicy.New()
+	PasswordPolicy *passwordpolicy.Policy
+	// TrustedProxies holds the CIDR ranges of the reverse proxies whose X-Forwarded-For header is trusted for the client IP.
+	// The IP of the direct peer is used if empty, the forwarded headers are ignored
+	TrustedProxies []string
 }
 
 var (
@@ -62,9 +70,14 @@ func (c *Config) fillDefaults() {
 func New(cfg *Config) *echo.Echo {
 	cfg.fillDefaults()
 	e := echo.New()
-	e.Validator = NewValidator()
+	vld := NewValidator()
+	if cfg.PasswordPolicy != nil {
+		vld.PasswordPolicy = cfg.PasswordPolicy
+	}
+	e.Validator = vld
 	e.HTTPErrorHandler = NewErrorHandler(e).Handle
 	e.Binder = NewBinder()
+	e.IPExtractor = newIPExtractor(cfg.TrustedProxies)
 	e.Debug = cfg.Debug
 	e.Logger = logadapter.NewEchoLogger()
 	e.Use(logadapter.NewEchoLoggerMiddleware())
@@ -83,8 +96,24 @@ func New(cfg *Config) *echo.Echo {
 	return e
 }
 
-// Start starts echo server
-func Start(e *echo.Echo, isDevelopment bool) {
+// newIPExtractor returns the extractor of the client IP (see echo.Context.RealIP) trusting only the given proxies
+func newIPExtractor(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range trustedProxies {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			panic(fmt.Sprintf("invalid trusted proxy range %q: %v", cidr, err))
+		}
+		opts = append(opts, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
+
+// Start starts echo server, the given functions are called on shutdown once the server has stopped, e.g. to finish background work
+func Start(e *echo.Echo, isDevelopment bool, onShutdown ...func(context.Context) error) {
 	// hide verbose logs
 	e.HideBanner = true
 
@@ -111,4 +140,9 @@ func Start(e *echo.Echo, isDevelopment bool) {
 		// Error from closing listeners, or context timeout:
 		logger.Error(fmt.Sprintf("⇨ http server shutting down error: %v\n", err))
 	}
+	for _, fn := range onShutdown {
+		if err := fn(ctx); err != nil {
+			logger.Error(fmt.Sprintf("⇨ shutting down error: %v\n", err))
+		}
+	}
 }
@@ -1,9 +1,14 @@
 package server_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/vuduongtp/go-core/pkg/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
 )
 
 // Improve tests
@@ -15,3 +20,27 @@ func TestNew(t *testing.T) {
 		t.Errorf("Server should not be nil")
 	}
 }
+
+func TestNewIPExtractor(t *testing.T) {
+	cases := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		want           string
+	}{
+		{name: "Direct peer by default", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "Trusted proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:1234", want: "203.0.113.9"},
+		{name: "Untrusted proxy", trustedProxies: []string{"10.0.0.0/8"}, remoteAddr: "192.168.1.1:1234", want: "192.168.1.1"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			e := server.New(&server.Config{TrustedProxies: tt.trustedProxies})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			// spoofed by the client, appended to by the proxy
+			req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.1, 203.0.113.9")
+			req.Header.Set(echo.HeaderXRealIP, "198.51.100.1")
+			assert.Equal(t, tt.want, e.NewContext(req, httptest.NewRecorder()).RealIP())
+		})
+	}
+}
@@ -1,28 +1,74 @@
 package server
 
 import (
-	"github.com/go-playground/validator/v10"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
+
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
+
+	"github.com/go-playground/validator/v10"
 )
 
 // CustomValidator holds custom validator
 type CustomValidator struct {
-	V *validator.Validate
+	V              *validator.Validate
+	PasswordPolicy *passwordpolicy.Policy
 }
 
 // NewValidator creates new custom validator
 func NewValidator() *CustomValidator {
-	V := validator.New()
-	V.RegisterValidation("date", validateDate)
-	V.RegisterValidation("mobile", validateMobile)
+	cv := &CustomValidator{V: validator.New(), PasswordPolicy: passwordpolicy.New()}
+	cv.V.RegisterValidation("date", validateDate)
+	cv.V.RegisterValidation("mobile", validateMobile)
+	cv.V.RegisterValidationCtx("password", cv.validatePassword)
 
-	return &CustomValidator{V}
+	return cv
 }
 
-// Validate validates the request
+// Validate validates the request.
+// The messages of the failed custom validations are carried along with the errors, see ValidationErrors
 func (cv *CustomValidator) Validate(i interface{}) error {
-	return cv.V.Struct(i)
+	msgs := fieldMessages{}
+	err := cv.V.StructCtx(context.WithValue(context.Background(), fieldMessagesKey{}, msgs), i)
+	var verrs validator.ValidationErrors
+	if len(msgs) > 0 && errors.As(err, &verrs) {
+		return &ValidationErrors{ValidationErrors: verrs, messages: msgs}
+	}
+	return err
+}
+
+// ValidationErrors holds the validation errors of a request with the messages of the failed custom validations,
+// e.g: the violations of the password policy, so that they do not need to be validated again
+type ValidationErrors struct {
+	validator.ValidationErrors
+	messages fieldMessages
+}
+
+// Message returns the message of the failed custom validation of the field error, if any
+func (ve *ValidationErrors) Message(fe validator.FieldError) (string, bool) {
+	msg, ok := ve.messages[fieldMessageKey(fe.StructField(), fe.Value())]
+	return msg, ok
+}
+
+// fieldMessages holds the messages of the failed custom validations during a validation, see fieldMessageKey
+type fieldMessages map[string]string
+
+type fieldMessagesKey struct{}
+
+// fieldMessageKey returns the key of the message of the field, having the given value
+func fieldMessageKey(field string, value interface{}) string {
+	return field + "=" + fmt.Sprint(value)
+}
+
+// setFieldMessage records the message of the failed validation of the field, if validating by Validate
+func setFieldMessage(ctx context.Context, fl validator.FieldLevel, msg string) {
+	if msgs, ok := ctx.Value(fieldMessagesKey{}).(fieldMessages); ok {
+		msgs[fieldMessageKey(fl.StructFieldName(), fl.Field().Interface())] = msg
+	}
 }
 
 func validateDate(fl validator.FieldLevel) bool {
@@ -36,3 +82,26 @@ func validateMobile(fl validator.FieldLevel) bool {
 	re := regexp.MustCompile(`^(\+\d{1,3})?\s?\d{5,15}$`)
 	return re.MatchString(strings.Replace(val, " ", "", -1))
 }
+
+// passwordUserInputFields are the sibling fields the password must not contain
+var passwordUserInputFields = []string{"Username", "Email"}
+
+// validatePassword validates the field against the password policy.
+// Errors of the breached password source are ignored, so that the request is not rejected because of them.
+func (cv *CustomValidator) validatePassword(ctx context.Context, fl validator.FieldLevel) bool {
+	var userInputs []string
+	parent := reflect.Indirect(fl.Parent())
+	if parent.Kind() == reflect.Struct {
+		for _, name := range passwordUserInputFields {
+			if f := parent.FieldByName(name); f.IsValid() && f.Kind() == reflect.String {
+				userInputs = append(userInputs, f.String())
+			}
+		}
+	}
+
+	perr, violated := passwordpolicy.AsError(cv.PasswordPolicy.Validate(fl.Field().String(), userInputs...))
+	if violated {
+		setFieldMessage(ctx, fl, strings.Join(perr.Messages(), ", "))
+	}
+	return !violated
+}
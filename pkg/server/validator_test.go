@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type PasswordReq struct {
+	Username string `json:"username"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+func TestValidatePassword(t *testing.T) {
+	cases := []struct {
+		name    string
+		req     PasswordReq
+		wantMsg string
+	}{
+		{
+			name:    "Too short & missing digit",
+			req:     PasswordReq{Username: "johndoe", Password: "short"},
+			wantMsg: "Password must be at least 8 characters long, must contain a digit",
+		},
+		{
+			name:    "Contains username",
+			req:     PasswordReq{Username: "johndoe", Password: "johndoe123"},
+			wantMsg: "Password must not contain the username or email",
+		},
+		{
+			name: "Success",
+			req:  PasswordReq{Username: "johndoe", Password: "correct horse 1"},
+		},
+	}
+	e := server.New(&server.Config{
+		PasswordPolicy: passwordpolicy.NewWithConfig(passwordpolicy.Config{RequireDigit: true}),
+	})
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Validator.Validate(tt.req)
+			if tt.wantMsg == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.NotNil(t, err)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			e.HTTPErrorHandler(err, e.NewContext(req, w))
+			var resp server.ErrorResponse
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, tt.wantMsg, resp.Error.Message)
+		})
+	}
+}
+
+// breachedSource reports the password as breached, counting the lookups
+type breachedSource struct {
+	password string
+	lookups  int
+}
+
+func (s *breachedSource) Range(prefix string) (map[string]int, error) {
+	s.lookups++
+	sum := sha1.Sum([]byte(s.password))
+	return map[string]int{strings.ToUpper(hex.EncodeToString(sum[:]))[passwordpolicy.PrefixLength:]: 1}, nil
+}
+
+func TestValidatePasswordBreached(t *testing.T) {
+	src := &breachedSource{password: "correct horse 1"}
+	e := server.New(&server.Config{
+		PasswordPolicy: passwordpolicy.NewWithConfig(passwordpolicy.Config{Breached: src}),
+	})
+	err := e.Validator.Validate(PasswordReq{Username: "johndoe", Password: "correct horse 1"})
+	assert.NotNil(t, err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	e.HTTPErrorHandler(err, e.NewContext(req, w))
+	var resp server.ErrorResponse
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Password has appeared in a data breach, please choose another one", resp.Error.Message)
+	assert.Equal(t, 1, src.lookups, "the message is carried from the validation")
+}
@@ -0,0 +1,155 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+)
+
+// Config represents the configuration of the background tasks
+type Config struct {
+	// Number of tasks run concurrently
+	Workers int
+	// Number of tasks waiting for a worker, further tasks are dropped
+	QueueSize int
+	// Time limit of each task
+	Timeout time.Duration
+}
+
+// DefaultConfig represents the default configuration
+var DefaultConfig = Config{
+	Workers:   4,
+	QueueSize: 1000,
+	Timeout:   30 * time.Second,
+}
+
+func (c *Config) fillDefaults() {
+	if c.Workers <= 0 {
+		c.Workers = DefaultConfig.Workers
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultConfig.QueueSize
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultConfig.Timeout
+	}
+}
+
+// Task is the work done in background, its error is logged
+type Task func(context.Context) error
+
+type queuedTask struct {
+	ctx context.Context
+	fn  Task
+}
+
+// New creates new worker with default configuration
+func New() *Worker {
+	return NewWithConfig(DefaultConfig)
+}
+
+// NewWithConfig creates new worker with the given configuration, its workers are started until Shutdown
+func NewWithConfig(cfg Config) *Worker {
+	cfg.fillDefaults()
+	stop, cancel := context.WithCancel(context.Background())
+	w := &Worker{
+		cfg:    cfg,
+		tasks:  make(chan queuedTask, cfg.QueueSize),
+		stop:   stop,
+		cancel: cancel,
+	}
+	w.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go w.run()
+	}
+	return w
+}
+
+// Worker runs the tasks that go on after the response is sent, e.g. sending emails,
+// with a bounded concurrency and time limit
+type Worker struct {
+	cfg   Config
+	tasks chan queuedTask
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+	// stop is cancelled when the shutdown times out, so that the running tasks give up
+	stop   context.Context
+	cancel context.CancelFunc
+}
+
+// Go queues the task, which is run with the values of ctx but neither its cancellation nor deadline.
+// Returns false if the task is dropped, as the queue is full or the worker is shut down.
+func (w *Worker) Go(ctx context.Context, fn Task) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		logger.LogErrorf(ctx, "background task dropped: worker is shut down")
+		return false
+	}
+	select {
+	case w.tasks <- queuedTask{httputil.DetachContext(ctx), fn}:
+		return true
+	default:
+		logger.LogErrorf(ctx, "background task dropped: queue is full")
+		return false
+	}
+}
+
+// Shutdown stops accepting tasks and waits for the queued ones to be done.
+// The running tasks are cancelled if ctx is done first.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.tasks)
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) run() {
+	defer w.wg.Done()
+	for t := range w.tasks {
+		w.do(t)
+	}
+}
+
+// do runs the task within the time limit, errors & panics are logged
+func (w *Worker) do(t queuedTask) {
+	ctx, cancel := context.WithTimeout(t.ctx, w.cfg.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stop.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogError(ctx, fmt.Errorf("background task panic: %v", r))
+		}
+	}()
+
+	if err := t.fn(ctx); err != nil {
+		logger.LogError(ctx, err)
+	}
+}
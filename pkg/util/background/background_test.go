@@ -0,0 +1,97 @@
+package background_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/util/background"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+func TestGo(t *testing.T) {
+	w := background.NewWithConfig(background.Config{Workers: 2, Timeout: time.Second})
+
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "value"))
+	results := make(chan error, 1)
+	assert.True(t, w.Go(parent, func(ctx context.Context) error {
+		// the task goes on after the request is done
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		if ctx.Value(ctxKey{}) != "value" {
+			results <- errors.New("values not kept")
+		} else if _, ok := ctx.Deadline(); !ok {
+			results <- errors.New("no time limit")
+		} else {
+			results <- ctx.Err()
+		}
+		return nil
+	}))
+	assert.Nil(t, <-results)
+
+	// errors & panics are logged only
+	assert.True(t, w.Go(parent, func(ctx context.Context) error { return errors.New("failed") }))
+	assert.True(t, w.Go(parent, func(ctx context.Context) error { panic("panicked") }))
+
+	var done int32
+	assert.True(t, w.Go(parent, func(ctx context.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&done, 1)
+		return nil
+	}))
+	assert.Nil(t, w.Shutdown(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&done), "queued tasks are done before the shutdown")
+	assert.False(t, w.Go(parent, func(ctx context.Context) error { return nil }), "no task after the shutdown")
+	assert.Nil(t, w.Shutdown(context.Background()))
+}
+
+func TestGoTimeout(t *testing.T) {
+	w := background.NewWithConfig(background.Config{Workers: 1, Timeout: 10 * time.Millisecond})
+	results := make(chan error, 1)
+	assert.True(t, w.Go(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		results <- ctx.Err()
+		return nil
+	}))
+	assert.ErrorIs(t, <-results, context.DeadlineExceeded)
+	assert.Nil(t, w.Shutdown(context.Background()))
+}
+
+func TestGoQueueFull(t *testing.T) {
+	w := background.NewWithConfig(background.Config{Workers: 1, QueueSize: 1, Timeout: time.Second})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	assert.True(t, w.Go(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+	assert.True(t, w.Go(context.Background(), func(ctx context.Context) error { return nil }), "queued")
+	assert.False(t, w.Go(context.Background(), func(ctx context.Context) error { return nil }), "dropped")
+	close(release)
+	assert.Nil(t, w.Shutdown(context.Background()))
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	w := background.NewWithConfig(background.Config{Workers: 1, Timeout: time.Minute})
+	started := make(chan struct{})
+	results := make(chan error, 1)
+	assert.True(t, w.Go(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		results <- ctx.Err()
+		return nil
+	}))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Shutdown(ctx), context.DeadlineExceeded)
+	assert.ErrorIs(t, <-results, context.Canceled, "the running task is cancelled")
+}
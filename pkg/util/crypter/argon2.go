@@ -0,0 +1,64 @@
+package crypter
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2idHasher hashes passwords using argon2id (RFC 9106)
+type Argon2idHasher struct {
+	// Memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	// Lengths of the salt & the hash in bytes
+	SaltLength int
+	KeyLength  uint32
+}
+
+// Algorithm returns the name of the algorithm
+func (h Argon2idHasher) Algorithm() string {
+	return AlgorithmArgon2id
+}
+
+// Hash hashes the password, e.g: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt, err := randomSalt(h.SaltLength)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	params := [][2]interface{}{{"m", h.Memory}, {"t", h.Iterations}, {"p", h.Parallelism}}
+	return formatPHC(AlgorithmArgon2id, argon2.Version, params, salt, key), nil
+}
+
+// Verify reports whether the password matches the hash, using the parameters stored in the hash
+func (h Argon2idHasher) Verify(hash, password string) (bool, error) {
+	p, err := parsePHC(AlgorithmArgon2id, hash)
+	if err != nil {
+		return false, err
+	}
+	m, t, par := p.params["m"], p.params["t"], p.params["p"]
+	if p.version != argon2.Version || m == 0 || t == 0 || par == 0 || par > 255 {
+		return false, ErrInvalidHash
+	}
+	key := argon2.IDKey([]byte(password), p.salt, uint32(t), uint32(m), uint8(par), uint32(len(p.hash)))
+	return equalHash(key, p.hash), nil
+}
+
+// Identifies reports whether the hash is an argon2id hash
+func (h Argon2idHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$")
+}
+
+// NeedsRehash reports whether the hash has been created with other parameters
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	p, err := parsePHC(AlgorithmArgon2id, hash)
+	if err != nil {
+		return true
+	}
+	return p.version != argon2.Version ||
+		p.params["m"] != int(h.Memory) || p.params["t"] != int(h.Iterations) || p.params["p"] != int(h.Parallelism) ||
+		len(p.salt) != h.SaltLength || len(p.hash) != int(h.KeyLength)
+}
@@ -0,0 +1,58 @@
+package crypter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCiphertext is returned when the ciphertext cannot be decrypted, e.g: encrypted with another key
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// NewCipher creates the AES-GCM cipher of the given key, which is 16, 24 or 32 bytes encoded in base64
+func NewCipher(key string) (*Cipher, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead}, nil
+}
+
+// Cipher encrypts the values stored at rest, e.g: the secrets that must be read back unlike the passwords
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// Encrypt returns the ciphertext of the value encoded in base64, a random nonce is prepended to it
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// Decrypt returns the value of the ciphertext returned by Encrypt
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(b) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+	n := c.aead.NonceSize()
+	plaintext, err := c.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
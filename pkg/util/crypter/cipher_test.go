@@ -0,0 +1,57 @@
+package crypter_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/util/crypter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCipher(t *testing.T) {
+	cases := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "AES-128", key: base64.StdEncoding.EncodeToString(make([]byte, 16))},
+		{name: "AES-256", key: base64.StdEncoding.EncodeToString(make([]byte, 32))},
+		{name: "Empty key", key: "", wantErr: true},
+		{name: "Invalid size", key: base64.StdEncoding.EncodeToString(make([]byte, 20)), wantErr: true},
+		{name: "Invalid encoding", key: "not base64!", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := crypter.NewCipher(tt.key)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
+
+func TestCipher(t *testing.T) {
+	c, err := crypter.NewCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	assert.Nil(t, err)
+
+	ciphertext, err := c.Encrypt("JBSWY3DPEHPK3PXP")
+	assert.Nil(t, err)
+	assert.NotContains(t, ciphertext, "JBSWY3DPEHPK3PXP")
+	plaintext, err := c.Decrypt(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plaintext)
+
+	// random nonce
+	other, err := c.Encrypt("JBSWY3DPEHPK3PXP")
+	assert.Nil(t, err)
+	assert.NotEqual(t, ciphertext, other)
+
+	otherKey, err := crypter.NewCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", 32))))
+	assert.Nil(t, err)
+	for _, s := range []string{"", "not base64!", "JBSWY3DPEHPK3PXP", ciphertext[:len(ciphertext)-4]} {
+		_, err = c.Decrypt(s)
+		assert.ErrorIs(t, err, crypter.ErrInvalidCiphertext, s)
+	}
+	_, err = otherKey.Decrypt(ciphertext)
+	assert.ErrorIs(t, err, crypter.ErrInvalidCiphertext)
+}
@@ -1,26 +1,113 @@
 package crypter
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
 	"github.com/segmentio/ksuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// New initalizes crypter service
+// Config represents the configuration of password hashing
+type Config struct {
+	// Algorithm of new hashes: bcrypt, argon2id or scrypt. Existing hashes of any of them can still be verified.
+	Algorithm string
+	Bcrypt    BcryptHasher
+	Argon2id  Argon2idHasher
+	Scrypt    ScryptHasher
+}
+
+// DefaultConfig represents the default configuration, the parameters follow OWASP recommendations
+var DefaultConfig = Config{
+	Algorithm: AlgorithmBcrypt,
+	Bcrypt:    BcryptHasher{Cost: bcrypt.DefaultCost},
+	Argon2id:  Argon2idHasher{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	Scrypt:    ScryptHasher{LogN: 17, R: 8, P: 1, SaltLength: 16, KeyLength: 32},
+}
+
+func (c *Config) fillDefaults() {
+	if c.Algorithm == "" {
+		c.Algorithm = DefaultConfig.Algorithm
+	}
+	if c.Bcrypt.Cost == 0 {
+		c.Bcrypt = DefaultConfig.Bcrypt
+	}
+	if c.Argon2id.Memory == 0 || c.Argon2id.Iterations == 0 || c.Argon2id.Parallelism == 0 {
+		c.Argon2id = DefaultConfig.Argon2id
+	}
+	if c.Argon2id.SaltLength == 0 {
+		c.Argon2id.SaltLength = DefaultConfig.Argon2id.SaltLength
+	}
+	if c.Argon2id.KeyLength == 0 {
+		c.Argon2id.KeyLength = DefaultConfig.Argon2id.KeyLength
+	}
+	if c.Scrypt.LogN == 0 || c.Scrypt.R == 0 || c.Scrypt.P == 0 {
+		c.Scrypt = DefaultConfig.Scrypt
+	}
+	if c.Scrypt.SaltLength == 0 {
+		c.Scrypt.SaltLength = DefaultConfig.Scrypt.SaltLength
+	}
+	if c.Scrypt.KeyLength == 0 {
+		c.Scrypt.KeyLength = DefaultConfig.Scrypt.KeyLength
+	}
+}
+
+// New initalizes crypter service with default configuration
 func New() *Service {
-	return &Service{}
+	return NewWithConfig(DefaultConfig)
+}
+
+// NewWithConfig initalizes crypter service with the given configuration, panics if the algorithm or its parameters are invalid
+func NewWithConfig(cfg Config) *Service {
+	cfg.fillDefaults()
+	if cfg.Bcrypt.Cost < bcrypt.MinCost || cfg.Bcrypt.Cost > bcrypt.MaxCost {
+		panic(fmt.Sprintf("invalid bcrypt cost: %d", cfg.Bcrypt.Cost))
+	}
+
+	s := &Service{hashers: []Hasher{cfg.Bcrypt, cfg.Argon2id, cfg.Scrypt}}
+	for _, h := range s.hashers {
+		if h.Algorithm() == cfg.Algorithm {
+			s.hasher = h
+		}
+	}
+	if s.hasher == nil {
+		panic("invalid password hashing algorithm: " + cfg.Algorithm)
+	}
+	return s
 }
 
 // Service holds crypter methods
-type Service struct{}
+type Service struct {
+	// Hasher of new hashes
+	hasher Hasher
+	// All supported hashers
+	hashers []Hasher
+}
+
+var defaultService = New()
 
-// HashPassword hashes the password using bcrypt
-func (*Service) HashPassword(password string) string {
-	return HashPassword(password)
+// HashPassword hashes the password using the configured algorithm
+func (s *Service) HashPassword(password string) (string, error) {
+	return s.hasher.Hash(password)
 }
 
 // CompareHashAndPassword matches hash with password. Returns true if hash and password match.
-func (*Service) CompareHashAndPassword(hash, password string) bool {
-	return CompareHashAndPassword(hash, password)
+// The hash may be of any supported algorithm.
+func (s *Service) CompareHashAndPassword(hash, password string) bool {
+	for _, h := range s.hashers {
+		if h.Identifies(hash) {
+			ok, err := h.Verify(hash, password)
+			return err == nil && ok
+		}
+	}
+	return false
+}
+
+// NeedsRehash reports whether the hash is of another algorithm or parameters than configured.
+// The password should be rehashed the next time it is verified.
+func (s *Service) NeedsRehash(hash string) bool {
+	return !s.hasher.Identifies(hash) || s.hasher.NeedsRehash(hash)
 }
 
 // UID returns unique string ID
@@ -28,20 +115,31 @@ func (*Service) UID() string {
 	return UID()
 }
 
+// HashToken returns the SHA-256 hex digest of the given token
+func (*Service) HashToken(token string) string {
+	return HashToken(token)
+}
+
 ///// Static functions /////
 
-// HashPassword hashes the password using bcrypt
-func HashPassword(password string) string {
-	hashedPW, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(hashedPW)
+// HashPassword hashes the password using bcrypt with default cost
+func HashPassword(password string) (string, error) {
+	return defaultService.HashPassword(password)
 }
 
 // CompareHashAndPassword matches hash with password. Returns true if hash and password match.
 func CompareHashAndPassword(hash, password string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	return defaultService.CompareHashAndPassword(hash, password)
 }
 
 // UID returns unique string ID
 func UID() string {
 	return ksuid.New().String()
 }
+
+// HashToken returns the SHA-256 hex digest of the given token.
+// Use it for high entropy random tokens only, passwords must go through HashPassword.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,112 @@
+package crypter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/util/crypter"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters to keep the tests fast
+var testConfig = crypter.Config{
+	Bcrypt:   crypter.BcryptHasher{Cost: bcrypt.MinCost},
+	Argon2id: crypter.Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1},
+	Scrypt:   crypter.ScryptHasher{LogN: 4, R: 8, P: 1},
+}
+
+func newService(algo string) *crypter.Service {
+	cfg := testConfig
+	cfg.Algorithm = algo
+	return crypter.NewWithConfig(cfg)
+}
+
+func TestHashPassword(t *testing.T) {
+	cases := []struct {
+		algo   string
+		prefix string
+	}{
+		{algo: crypter.AlgorithmBcrypt, prefix: "$2a$04$"},
+		{algo: crypter.AlgorithmArgon2id, prefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+		{algo: crypter.AlgorithmScrypt, prefix: "$scrypt$ln=4,r=8,p=1$"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.algo, func(t *testing.T) {
+			s := newService(tt.algo)
+			hash, err := s.HashPassword("secret")
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(hash, tt.prefix), hash)
+			assert.True(t, s.CompareHashAndPassword(hash, "secret"))
+			assert.False(t, s.CompareHashAndPassword(hash, "Secret"))
+			assert.False(t, s.NeedsRehash(hash))
+
+			// salted
+			other, err := s.HashPassword("secret")
+			assert.Nil(t, err)
+			assert.NotEqual(t, hash, other)
+		})
+	}
+}
+
+func TestHashPasswordTooLong(t *testing.T) {
+	_, err := newService(crypter.AlgorithmBcrypt).HashPassword(strings.Repeat("a", 73))
+	assert.NotNil(t, err)
+
+	_, err = crypter.HashPassword(strings.Repeat("a", 73))
+	assert.NotNil(t, err)
+}
+
+func TestCompareHashAndPasswordAcrossAlgorithms(t *testing.T) {
+	bcryptSvc := newService(crypter.AlgorithmBcrypt)
+	argonSvc := newService(crypter.AlgorithmArgon2id)
+	scryptSvc := newService(crypter.AlgorithmScrypt)
+
+	bcryptHash, _ := bcryptSvc.HashPassword("secret")
+	argonHash, _ := argonSvc.HashPassword("secret")
+	scryptHash, _ := scryptSvc.HashPassword("secret")
+
+	for _, hash := range []string{bcryptHash, argonHash, scryptHash} {
+		assert.True(t, argonSvc.CompareHashAndPassword(hash, "secret"), hash)
+	}
+	assert.True(t, argonSvc.NeedsRehash(bcryptHash))
+	assert.True(t, argonSvc.NeedsRehash(scryptHash))
+	assert.False(t, argonSvc.NeedsRehash(argonHash))
+}
+
+func TestNeedsRehash(t *testing.T) {
+	s := newService(crypter.AlgorithmArgon2id)
+
+	cfg := testConfig
+	cfg.Algorithm = crypter.AlgorithmArgon2id
+	cfg.Argon2id.Iterations = 2
+	hash, _ := crypter.NewWithConfig(cfg).HashPassword("secret")
+	assert.True(t, s.NeedsRehash(hash))
+
+	cfg = testConfig
+	cfg.Bcrypt.Cost = bcrypt.MinCost + 1
+	hash, _ = crypter.NewWithConfig(cfg).HashPassword("secret")
+	assert.True(t, newService(crypter.AlgorithmBcrypt).NeedsRehash(hash))
+}
+
+func TestCompareHashAndPasswordInvalidHash(t *testing.T) {
+	s := newService(crypter.AlgorithmArgon2id)
+	for _, hash := range []string{
+		"",
+		"plain",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=64;t=1$c2FsdA$aGFzaA",
+		"$scrypt$ln=99,r=8,p=1$c2FsdA$aGFzaA",
+		"$scrypt$ln=4,r=8,p=1$!!!$aGFzaA",
+	} {
+		assert.False(t, s.CompareHashAndPassword(hash, "secret"), hash)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	assert.Panics(t, func() { crypter.NewWithConfig(crypter.Config{Algorithm: "md5"}) })
+	assert.Panics(t, func() { crypter.NewWithConfig(crypter.Config{Bcrypt: crypter.BcryptHasher{Cost: 99}}) })
+}
@@ -0,0 +1,164 @@
+package crypter
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmScrypt   = "scrypt"
+)
+
+// ErrInvalidHash is returned when the hash is malformed or of another algorithm
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// Hasher represents a password hashing algorithm.
+// Hashes are in PHC string format, bcrypt hashes keep their own modular crypt format. e.g: $2a$10$...
+type Hasher interface {
+	// Algorithm returns the name of the algorithm
+	Algorithm() string
+	// Hash hashes the password with the hasher's parameters
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the hash
+	Verify(hash, password string) (bool, error)
+	// Identifies reports whether the hash is of the hasher's algorithm
+	Identifies(hash string) bool
+	// NeedsRehash reports whether the hash has been created with other parameters than the hasher's
+	NeedsRehash(hash string) bool
+}
+
+// BcryptHasher hashes passwords using bcrypt
+type BcryptHasher struct {
+	// Cost, from 4 to 31
+	Cost int
+}
+
+// Algorithm returns the name of the algorithm
+func (h BcryptHasher) Algorithm() string {
+	return AlgorithmBcrypt
+}
+
+// Hash hashes the password, bcrypt.ErrPasswordTooLong is returned for passwords longer than 72 bytes
+func (h BcryptHasher) Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Verify reports whether the password matches the hash
+func (h BcryptHasher) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Identifies reports whether the hash is a bcrypt hash
+func (h BcryptHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// NeedsRehash reports whether the hash has been created with another cost
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// phcHash represents the parsed PHC string: $<id>[$v=<version>][$<param>=<value>(,<param>=<value>)*][$<salt>[$<hash>]]
+type phcHash struct {
+	id      string
+	version int
+	params  map[string]int
+	salt    []byte
+	hash    []byte
+}
+
+var phcEncoding = base64.RawStdEncoding
+
+// parsePHC parses the PHC string of the given algorithm, salt & hash are required
+func parsePHC(id, s string) (*phcHash, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) < 5 || parts[0] != "" || parts[1] != id {
+		return nil, ErrInvalidHash
+	}
+	h := &phcHash{id: id, params: make(map[string]int)}
+	parts = parts[2:]
+
+	if strings.HasPrefix(parts[0], "v=") {
+		v, err := strconv.Atoi(strings.TrimPrefix(parts[0], "v="))
+		if err != nil {
+			return nil, ErrInvalidHash
+		}
+		h.version = v
+		parts = parts[1:]
+	}
+	if len(parts) != 3 {
+		return nil, ErrInvalidHash
+	}
+
+	for _, kv := range strings.Split(parts[0], ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, ErrInvalidHash
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidHash
+		}
+		h.params[k] = n
+	}
+
+	var err error
+	if h.salt, err = phcEncoding.DecodeString(parts[1]); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if h.hash, err = phcEncoding.DecodeString(parts[2]); err != nil || len(h.hash) == 0 {
+		return nil, ErrInvalidHash
+	}
+	return h, nil
+}
+
+// formatPHC returns the PHC string, params are formatted in the given order
+func formatPHC(id string, version int, params [][2]interface{}, salt, hash []byte) string {
+	var sb strings.Builder
+	sb.WriteString("$" + id)
+	if version > 0 {
+		sb.WriteString(fmt.Sprintf("$v=%d", version))
+	}
+	for i, p := range params {
+		if i == 0 {
+			sb.WriteString("$")
+		} else {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("%v=%v", p[0], p[1]))
+	}
+	sb.WriteString("$" + phcEncoding.EncodeToString(salt))
+	sb.WriteString("$" + phcEncoding.EncodeToString(hash))
+	return sb.String()
+}
+
+func randomSalt(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func equalHash(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
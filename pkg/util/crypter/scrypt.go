@@ -0,0 +1,70 @@
+package crypter
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ScryptHasher hashes passwords using scrypt (RFC 7914)
+type ScryptHasher struct {
+	// CPU/memory cost parameter as power of two, N = 2^LogN
+	LogN int
+	// Block size & parallelization parameters
+	R int
+	P int
+	// Lengths of the salt & the hash in bytes
+	SaltLength int
+	KeyLength  int
+}
+
+// Algorithm returns the name of the algorithm
+func (h ScryptHasher) Algorithm() string {
+	return AlgorithmScrypt
+}
+
+// Hash hashes the password, e.g: $scrypt$ln=17,r=8,p=1$<salt>$<hash>
+func (h ScryptHasher) Hash(password string) (string, error) {
+	salt, err := randomSalt(h.SaltLength)
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(password), salt, 1<<h.LogN, h.R, h.P, h.KeyLength)
+	if err != nil {
+		return "", err
+	}
+	params := [][2]interface{}{{"ln", h.LogN}, {"r", h.R}, {"p", h.P}}
+	return formatPHC(AlgorithmScrypt, 0, params, salt, key), nil
+}
+
+// Verify reports whether the password matches the hash, using the parameters stored in the hash
+func (h ScryptHasher) Verify(hash, password string) (bool, error) {
+	p, err := parsePHC(AlgorithmScrypt, hash)
+	if err != nil {
+		return false, err
+	}
+	ln := p.params["ln"]
+	if ln <= 0 || ln > 30 {
+		return false, ErrInvalidHash
+	}
+	key, err := scrypt.Key([]byte(password), p.salt, 1<<ln, p.params["r"], p.params["p"], len(p.hash))
+	if err != nil {
+		return false, err
+	}
+	return equalHash(key, p.hash), nil
+}
+
+// Identifies reports whether the hash is a scrypt hash
+func (h ScryptHasher) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$"+AlgorithmScrypt+"$")
+}
+
+// NeedsRehash reports whether the hash has been created with other parameters
+func (h ScryptHasher) NeedsRehash(hash string) bool {
+	p, err := parsePHC(AlgorithmScrypt, hash)
+	if err != nil {
+		return true
+	}
+	return p.params["ln"] != h.LogN || p.params["r"] != h.R || p.params["p"] != h.P ||
+		len(p.salt) != h.SaltLength || len(p.hash) != h.KeyLength
+}
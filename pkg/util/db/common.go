@@ -3,26 +3,38 @@ package dbutil
 import (
 	"context"
 	"reflect"
-	"strings"
 
 	"github.com/imdatngo/gowhere"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // NewDB creates new DB instance
 func NewDB(model interface{}) *DB {
-	return &DB{model, nil}
+	return &DB{Model: model}
+}
+
+// NewTenantDB creates new DB instance of a model whose records belong to a tenant, see DB.TenantColumn
+func NewTenantDB(model interface{}, column string) *DB {
+	return &DB{Model: model, TenantColumn: column}
 }
 
 // DB represents the client for common usages
 type DB struct {
 	// Model must be set to a specific model instance. e.g: model.User{}
 	Model interface{}
-	// GDB holds previous DB instance that just executed the query
+	// GDB is no longer set, the queries are not kept so that DB is safe for concurrent use.
+	//
+	// Deprecated: use the result of the methods instead, or Repo for typed results.
 	GDB *gorm.DB
+	// TenantColumn holds the tenant of the records. When the context has a tenant (see WithTenant),
+	// it is filled in on creation and the queries are restricted to the records of the tenant
+	TenantColumn string
+	// TenantScope overrides the filtering by TenantColumn, e.g: for records shared by tenants via a join table
+	TenantScope TenantScope
 }
 
-// Intf represents the common db interface
+// Intf represents the common db interface, see Repo for the typed version
 type Intf interface {
 	// Create creates a new record on database.
 	// `input` must be a non-nil pointer of the model. e.g: `input := &model.User{}`
@@ -34,7 +46,7 @@ type Intf interface {
 	// List returns list of records retrievable after filter & pagination if given.
 	// `output` must be a non-nil pointer of slice of the model. e.g: `data := []*model.User{}; db.List(dbconn, &data, nil, nil)`
 	// `lq` can be nil, then no filter & pagination are applied
-	// `count` can also be nil, then no extra query is executed to get the total count
+	// `count` can also be nil, then no extra query is executed to get the total count, nor in the cursor mode (see Cursor)
 	List(ctx context.Context, db *gorm.DB, output interface{}, lq *ListQueryCondition, count *int64) error
 	// Update updates data of the records matching the given conditions.
 	// `updates` could be a model struct or map[string]interface{}
@@ -57,27 +69,35 @@ type Intf interface {
 
 // ListQueryCondition holds data used for db queries
 type ListQueryCondition struct {
-	Filter  *gowhere.Plan
+	Filter *gowhere.Plan
+	// Sort holds the sort fields in `column [ASC|DESC]` format. e.g: []string{"name ASC", "created_at DESC"}
 	Sort    []string
 	Page    int
 	PerPage int
+	// Cursor enables the keyset pagination instead of Page, the cursors of the next & previous pages are set by List
+	Cursor *Cursor
 }
 
 // Create creates a new record on database.
 func (cdb *DB) Create(ctx context.Context, db *gorm.DB, input interface{}) error {
-	cdb.GDB = db.WithContext(ctx).Create(input)
-	return cdb.GDB.Error
+	if err := cdb.setTenant(ctx, db, input); err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Create(input).Error
 }
 
 // View returns single record matching the given conditions.
 func (cdb *DB) View(ctx context.Context, db *gorm.DB, output interface{}, cond ...interface{}) error {
 	where := parseCond(cond...)
-	cdb.GDB = db.WithContext(ctx).First(output, where...)
-	return cdb.GDB.Error
+	return cdb.scope(ctx, db).WithContext(ctx).First(output, where...).Error
 }
 
 // List returns list of records retrievable after filter & pagination if given.
 func (cdb *DB) List(ctx context.Context, db *gorm.DB, output interface{}, lq *ListQueryCondition, count *int64) error {
+	db = cdb.scope(ctx, db)
+	if lq != nil && lq.Cursor != nil {
+		return cdb.listCursor(ctx, db, output, lq)
+	}
 	if lq != nil {
 		if lq.Filter != nil {
 			db = db.Where(lq.Filter.SQL(), lq.Filter.Vars()...)
@@ -90,20 +110,24 @@ func (cdb *DB) List(ctx context.Context, db *gorm.DB, output interface{}, lq *Li
 			}
 		}
 
-		if lq.Sort != nil && len(lq.Sort) > 0 {
-			// Note: It's up to who using this package to validate the sort fields!
-			db = db.Order(strings.Join(lq.Sort, ", "))
+		// Note: the columns are quoted, it's still up to who using this package to whitelist the sort fields, see httputil.ReqListQuery
+		for _, sort := range lq.Sort {
+			column, desc, err := parseSort(sort)
+			if err != nil {
+				return err
+			}
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc})
 		}
 	}
 
-	cdb.GDB = db.WithContext(ctx).Find(output)
-	if err := cdb.GDB.Error; err != nil {
+	db = db.WithContext(ctx).Find(output)
+	if err := db.Error; err != nil {
 		return err
 	}
 
 	// Only count total records if requested
 	if count != nil {
-		if err := cdb.GDB.Limit(-1).Offset(-1).Count(count).Error; err != nil {
+		if err := db.Limit(-1).Offset(-1).Count(count).Error; err != nil {
 			return err
 		}
 	}
@@ -111,19 +135,41 @@ func (cdb *DB) List(ctx context.Context, db *gorm.DB, output interface{}, lq *Li
 	return nil
 }
 
+// listCursor returns the page of records at the cursor of the list query, one more record is read to tell whether there is a next page
+func (cdb *DB) listCursor(ctx context.Context, db *gorm.DB, output interface{}, lq *ListQueryCondition) error {
+	keys, err := cdb.keyset(db, lq.Sort)
+	if err != nil {
+		return err
+	}
+	if lq.Filter != nil {
+		db = db.Where(lq.Filter.SQL(), lq.Filter.Vars()...)
+	}
+	if db, err = lq.Cursor.paginate(db, keys); err != nil {
+		return err
+	}
+	if lq.PerPage > 0 {
+		db = db.Limit(lq.PerPage + 1)
+	}
+
+	if err := db.WithContext(ctx).Find(output).Error; err != nil {
+		return err
+	}
+	return lq.Cursor.setPages(ctx, output, keys, lq.PerPage)
+}
+
 // Update updates data of the records matching the given conditions.
 func (cdb *DB) Update(ctx context.Context, db *gorm.DB, updates interface{}, cond ...interface{}) error {
-	db = db.Model(cdb.Model)
+	db = cdb.scope(ctx, db.Model(cdb.Model))
 	if len(cond) > 0 {
 		where := parseCond(cond...)
 		db = db.Where(where[0], where[1:]...)
 	}
-	cdb.GDB = db.WithContext(ctx).Omit("id").Updates(updates)
-	return cdb.GDB.Error
+	return db.WithContext(ctx).Omit("id").Updates(updates).Error
 }
 
 // Delete deletes record matching given conditions.
 func (cdb *DB) Delete(ctx context.Context, db *gorm.DB, cond ...interface{}) error {
+	db = cdb.scope(ctx, db)
 	if len(cond) == 1 {
 		newCond := cond[0]
 		cType := reflect.TypeOf(newCond)
@@ -136,12 +182,12 @@ func (cdb *DB) Delete(ctx context.Context, db *gorm.DB, cond ...interface{}) err
 	}
 
 	where := parseCond(cond...)
-	cdb.GDB = db.WithContext(ctx).Delete(cdb.Model, where...)
-	return cdb.GDB.Error
+	return db.WithContext(ctx).Delete(cdb.newModel(), where...).Error
 }
 
 // DeletePermanently deletes record matching given conditions permanently.
 func (cdb *DB) DeletePermanently(ctx context.Context, db *gorm.DB, cond ...interface{}) error {
+	db = cdb.scope(ctx, db)
 	if len(cond) == 1 {
 		val := reflect.ValueOf(cond[0])
 		if val.Kind() == reflect.Ptr {
@@ -152,8 +198,7 @@ func (cdb *DB) DeletePermanently(ctx context.Context, db *gorm.DB, cond ...inter
 		}
 	}
 	where := parseCond(cond...)
-	cdb.GDB = db.WithContext(ctx).Unscoped().Delete(cdb.Model, where...)
-	return cdb.GDB.Error
+	return db.WithContext(ctx).Unscoped().Delete(cdb.Model, where...).Error
 }
 
 // Exist checks whether there is record matching the given conditions.
@@ -161,17 +206,28 @@ func (cdb *DB) Exist(ctx context.Context, db *gorm.DB, cond ...interface{}) (boo
 	var count int64
 	count = 0
 	where := parseCond(cond...)
-	cdb.GDB = db.WithContext(ctx).Model(cdb.Model).Where(where[0], where[1:]...).Count(&count)
-	return count > 0, cdb.GDB.Error
+	err := cdb.scope(ctx, db).WithContext(ctx).Model(cdb.Model).Where(where[0], where[1:]...).Count(&count).Error
+	return count > 0, err
 }
 
 // CreateInBatches creates batch of new record on database.
 func (cdb *DB) CreateInBatches(ctx context.Context, db *gorm.DB, input interface{}, batchSize int) error {
-	cdb.GDB = db.WithContext(ctx).CreateInBatches(input, batchSize)
-	return cdb.GDB.Error
+	if err := cdb.setTenant(ctx, db, input); err != nil {
+		return err
+	}
+	return db.WithContext(ctx).CreateInBatches(input, batchSize).Error
 }
 
 // ParseCond returns standard [sqlString, vars] format for query, powered by gowhere package (configurable version)
 func (cdb *DB) ParseCond(cond ...interface{}) []interface{} {
 	return parseCond(cond...)
 }
+
+// newModel returns a pointer to new instance of the model, soft delete requires an addressable value
+func (cdb *DB) newModel() interface{} {
+	t := reflect.TypeOf(cdb.Model)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return reflect.New(t).Interface()
+}
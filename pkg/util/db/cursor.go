@@ -0,0 +1,261 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor is returned when the cursor cannot be decoded, or does not match the sort of the list query, e.g: the sort changed
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor holds the keyset pagination of a list query: the records after (or before) the position are returned,
+// ordered by the sort fields then the primary key. Unlike the offset pagination, it does not slow down on large tables
+// and does not skip or repeat records when the data changes.
+// The NULL values of the nullable sort fields (pointers & driver.Valuer, e.g: sql.NullTime) are ordered as the greatest ones,
+// i.e. last in the ascending order, whatever the database.
+type Cursor struct {
+	// Values of the sort fields & the primary key of the record at the position, none for the first page
+	Values []json.RawMessage `json:"v,omitempty"`
+	// Before tells to return the records before the position, i.e. the previous page
+	Before bool `json:"b,omitempty"`
+	// Next & Prev are set by List to the cursors of the next & previous pages, nil if there is none
+	Next *Cursor `json:"-"`
+	Prev *Cursor `json:"-"`
+}
+
+// DecodeCursor returns the cursor of the encoded position, the cursor of the first page if empty. See Cursor.Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	c := &Cursor{}
+	if s == "" {
+		return c, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+// Encode returns the opaque string of the position, empty if nil
+func (c *Cursor) Encode() string {
+	if c == nil {
+		return ""
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// keysetField is a field of the keyset, i.e. a sort field or the primary key
+type keysetField struct {
+	field    *schema.Field
+	desc     bool
+	nullable bool
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// isNullable reports whether the values of the field may be NULL
+func isNullable(field *schema.Field) bool {
+	if field.PrimaryKey {
+		return false
+	}
+	return field.FieldType.Kind() == reflect.Ptr || field.FieldType.Implements(valuerType)
+}
+
+// isNull reports whether the value of the position is NULL
+func isNull(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return true
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		return err == nil && dv == nil
+	}
+	return false
+}
+
+// keyset returns the fields of the keyset: the sort fields of the query followed by the primary key.
+// The sort fields are in `column [ASC|DESC]` format.
+func (cdb *DB) keyset(db *gorm.DB, sort []string) ([]keysetField, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(cdb.Model); err != nil {
+		return nil, err
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, fmt.Errorf("primary key not found in %s", stmt.Schema.Name)
+	}
+
+	var keys []keysetField
+	hasPK := false
+	for _, s := range sort {
+		column, desc, err := parseSort(s)
+		if err != nil {
+			return nil, err
+		}
+		field := stmt.Schema.LookUpField(column)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("sort field %s not found in %s", column, stmt.Schema.Name)
+		}
+		keys = append(keys, keysetField{field: field, desc: desc, nullable: isNullable(field)})
+		hasPK = hasPK || field == pk
+	}
+	if !hasPK {
+		keys = append(keys, keysetField{field: pk})
+	}
+	return keys, nil
+}
+
+// paginate applies the keyset pagination of the cursor to the query, the order is reversed for the previous page
+func (c *Cursor) paginate(db *gorm.DB, keys []keysetField) (*gorm.DB, error) {
+	if len(c.Values) > 0 && len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	var conds []clause.Expression
+	for i, k := range keys {
+		col := k.column()
+		// the records before the position are read backwards
+		desc := k.desc != c.Before
+		if k.nullable {
+			// NULL values last, as the greatest ones
+			isNullCol := clause.Column{Name: db.Statement.Quote(clause.Column{Table: k.field.Schema.Table, Name: k.field.DBName}) + " IS NULL", Raw: true}
+			db = db.Order(clause.OrderByColumn{Column: isNullCol, Desc: desc})
+		}
+		db = db.Order(clause.OrderByColumn{Column: col, Desc: desc})
+		if len(c.Values) == 0 {
+			continue
+		}
+
+		// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+		and := make([]clause.Expression, 0, i+1)
+		for j, prev := range keys[:i] {
+			v, err := cursorValue(prev.field, c.Values[j])
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, prev.equal(v))
+		}
+		v, err := cursorValue(k.field, c.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		after, ok := k.after(v, desc)
+		if !ok {
+			// nothing is after NULL in the ascending order
+			continue
+		}
+		conds = append(conds, clause.And(append(and, after)...))
+	}
+	if len(conds) > 0 {
+		db = db.Where(clause.Or(conds...))
+	}
+	return db, nil
+}
+
+// column returns the column of the field
+func (k keysetField) column() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: k.field.DBName}
+}
+
+// equal returns the condition of the field having the value of the position
+func (k keysetField) equal(v interface{}) clause.Expression {
+	if k.nullable && isNull(v) {
+		return clause.Expr{SQL: "? IS NULL", Vars: []interface{}{k.column()}}
+	}
+	return clause.Eq{Column: k.column(), Value: v}
+}
+
+// after returns the condition of the field being after the value of the position in the given order,
+// false if no value can be after it, i.e. NULL in the ascending order
+func (k keysetField) after(v interface{}, desc bool) (clause.Expression, bool) {
+	col := k.column()
+	switch {
+	case !k.nullable && desc:
+		return clause.Lt{Column: col, Value: v}, true
+	case !k.nullable:
+		return clause.Gt{Column: col, Value: v}, true
+	case isNull(v) && desc:
+		return clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{col}}, true
+	case isNull(v):
+		return nil, false
+	case desc:
+		return clause.Lt{Column: col, Value: v}, true
+	}
+	return clause.Or(clause.Gt{Column: col, Value: v}, clause.Expr{SQL: "? IS NULL", Vars: []interface{}{col}}), true
+}
+
+// setPages sets the cursors of the next & previous pages, given the records of the page read by paginate,
+// including the extra one telling whether there are more records. The records are restored to the order of the sort.
+func (c *Cursor) setPages(ctx context.Context, output interface{}, keys []keysetField, perPage int) error {
+	val := reflect.Indirect(reflect.ValueOf(output))
+	more := perPage > 0 && val.Len() > perPage
+	if more {
+		val.Set(val.Slice(0, perPage))
+	}
+	if c.Before {
+		swap := reflect.Swapper(val.Interface())
+		for i, j := 0, val.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	c.Next, c.Prev = nil, nil
+	if val.Len() == 0 {
+		return nil
+	}
+	first, err := position(ctx, val.Index(0), keys)
+	if err != nil {
+		return err
+	}
+	last, err := position(ctx, val.Index(val.Len()-1), keys)
+	if err != nil {
+		return err
+	}
+	if (more && !c.Before) || (c.Before && len(c.Values) > 0) {
+		c.Next = &Cursor{Values: last}
+	}
+	if (more && c.Before) || (!c.Before && len(c.Values) > 0) {
+		c.Prev = &Cursor{Values: first, Before: true}
+	}
+	return nil
+}
+
+// position returns the values of the keyset of the record
+func position(ctx context.Context, rec reflect.Value, keys []keysetField) ([]json.RawMessage, error) {
+	rec = reflect.Indirect(rec)
+	values := make([]json.RawMessage, len(keys))
+	for i, k := range keys {
+		v, _ := k.field.ValueOf(ctx, rec)
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = b
+	}
+	return values, nil
+}
+
+// cursorValue returns the value of the position decoded as the type of the field
+func cursorValue(field *schema.Field, raw json.RawMessage) (interface{}, error) {
+	v := reflect.New(field.FieldType)
+	if err := json.Unmarshal(raw, v.Interface()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return v.Elem().Interface(), nil
+}
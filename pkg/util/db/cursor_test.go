@@ -0,0 +1,187 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type cursorRecord struct {
+	ID        int
+	Name      string
+	Rank      *int
+	CreatedAt time.Time
+}
+
+func names(data []*cursorRecord) []string {
+	res := make([]string, len(data))
+	for i, rec := range data {
+		res[i] = rec.Name
+	}
+	return res
+}
+
+func newCursorDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.Migrator().DropTable(&cursorRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&cursorRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// walk reads all the pages of the sort forwards then backwards, returns the pages read forwards
+func walk(t *testing.T, db *gorm.DB, sort []string, perPage int) [][]string {
+	t.Helper()
+	ctx := context.Background()
+	cdb := NewDB(cursorRecord{})
+	list := func(c *Cursor) ([]string, *Cursor) {
+		next, err := DecodeCursor(c.Encode())
+		assert.Nil(t, err)
+		var data []*cursorRecord
+		assert.Nil(t, cdb.List(ctx, db, &data, &ListQueryCondition{Sort: sort, PerPage: perPage, Cursor: next}, nil))
+		return names(data), next
+	}
+
+	var pages [][]string
+	page, c := list(&Cursor{})
+	pages = append(pages, page)
+	for c.Next != nil {
+		page, c = list(c.Next)
+		pages = append(pages, page)
+	}
+	for i := len(pages) - 2; i >= 0; i-- {
+		if !assert.NotNil(t, c.Prev) {
+			break
+		}
+		page, c = list(c.Prev)
+		assert.Equal(t, pages[i], page)
+	}
+	assert.Nil(t, c.Prev, "first page")
+	return pages
+}
+
+func TestListCursor(t *testing.T) {
+	db := newCursorDB(t)
+	ctx := context.Background()
+	cdb := NewDB(cursorRecord{})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// b & c have the same name, the primary key breaks the tie
+	for i, name := range []string{"e", "b", "c", "a", "d", "c"} {
+		assert.Nil(t, cdb.Create(ctx, db, &cursorRecord{Name: name, CreatedAt: now.Add(time.Duration(i) * time.Hour)}))
+	}
+
+	list := func(lq *ListQueryCondition) []string {
+		var data []*cursorRecord
+		var count int64 = -1
+		assert.Nil(t, cdb.List(ctx, db, &data, lq, &count))
+		assert.Equal(t, int64(-1), count, "no count in the cursor mode")
+		return names(data)
+	}
+
+	lq := &ListQueryCondition{Sort: []string{"name DESC"}, PerPage: 2, Cursor: &Cursor{}}
+	assert.Equal(t, []string{"e", "d"}, list(lq))
+	assert.Nil(t, lq.Cursor.Prev)
+	assert.NotNil(t, lq.Cursor.Next)
+
+	next, err := DecodeCursor(lq.Cursor.Next.Encode())
+	assert.Nil(t, err)
+	lq.Cursor = next
+	assert.Equal(t, []string{"c", "c"}, list(lq))
+	page2 := lq.Cursor
+
+	lq.Cursor = page2.Next
+	assert.Equal(t, []string{"b", "a"}, list(lq))
+	assert.Nil(t, lq.Cursor.Next, "last page")
+
+	lq.Cursor = lq.Cursor.Prev
+	assert.Equal(t, []string{"c", "c"}, list(lq))
+	assert.Equal(t, page2.Next.Values, lq.Cursor.Next.Values)
+
+	lq.Cursor = lq.Cursor.Prev
+	assert.Equal(t, []string{"e", "d"}, list(lq))
+	assert.Nil(t, lq.Cursor.Prev, "first page")
+
+	// time values are restored as of the field type
+	lq = &ListQueryCondition{Sort: []string{"created_at"}, PerPage: 4, Cursor: &Cursor{}}
+	assert.Equal(t, []string{"e", "b", "c", "a"}, list(lq))
+	lq.Cursor = lq.Cursor.Next
+	assert.Equal(t, []string{"d", "c"}, list(lq))
+
+	// cursor of another sort
+	lq = &ListQueryCondition{Sort: []string{"name", "created_at"}, Cursor: lq.Cursor.Prev}
+	var data []*cursorRecord
+	assert.ErrorIs(t, cdb.List(ctx, db, &data, lq, nil), ErrInvalidCursor)
+
+	_, err = DecodeCursor("invalid")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestListCursorMultiSort(t *testing.T) {
+	db := newCursorDB(t)
+	cdb := NewDB(cursorRecord{})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"b", "a", "b", "a", "c", "b"} {
+		assert.Nil(t, cdb.Create(context.Background(), db, &cursorRecord{Name: name, CreatedAt: now.Add(time.Duration(i) * time.Hour)}))
+	}
+
+	// s=name,-created_at
+	pages := walk(t, db, []string{"name", "created_at DESC"}, 2)
+	assert.Equal(t, [][]string{{"a", "a"}, {"b", "b"}, {"b", "c"}}, pages)
+
+	var data []*cursorRecord
+	assert.Nil(t, db.Order("name").Order("created_at DESC").Find(&data).Error)
+	var ids []int
+	for _, rec := range data {
+		ids = append(ids, rec.ID)
+	}
+	assert.Equal(t, []int{4, 2, 6, 3, 1, 5}, ids)
+}
+
+func TestListCursorNullable(t *testing.T) {
+	db := newCursorDB(t)
+	cdb := NewDB(cursorRecord{})
+	// the records without rank are ordered as the greatest ones
+	for i, name := range []string{"n1", "r2", "n2", "r1", "n3", "r3", "n4"} {
+		rec := &cursorRecord{Name: name}
+		if name[0] == 'r' {
+			rank := int(name[1] - '0')
+			rec.Rank = &rank
+		}
+		rec.CreatedAt = time.Date(2026, 1, 1, i, 0, 0, 0, time.UTC)
+		assert.Nil(t, cdb.Create(context.Background(), db, rec))
+	}
+
+	for _, perPage := range []int{1, 2, 3} {
+		var asc, desc []string
+		for _, page := range walk(t, db, []string{"rank"}, perPage) {
+			asc = append(asc, page...)
+		}
+		assert.Equal(t, []string{"r1", "r2", "r3", "n1", "n2", "n3", "n4"}, asc, "per page %d", perPage)
+
+		// the primary key is always ascending, it breaks the tie of the NULL values
+		for _, page := range walk(t, db, []string{"rank DESC"}, perPage) {
+			desc = append(desc, page...)
+		}
+		assert.Equal(t, []string{"n1", "n2", "n3", "n4", "r3", "r2", "r1"}, desc, "per page %d", perPage)
+	}
+
+	// a page ending on a NULL value, followed by the other NULL values
+	var data []*cursorRecord
+	lq := &ListQueryCondition{Sort: []string{"rank"}, PerPage: 4, Cursor: &Cursor{}}
+	assert.Nil(t, cdb.List(context.Background(), db, &data, lq, nil))
+	assert.Equal(t, []string{"r1", "r2", "r3", "n1"}, names(data))
+	lq.Cursor = lq.Cursor.Next
+	assert.Nil(t, cdb.List(context.Background(), db, &data, lq, nil))
+	assert.Equal(t, []string{"n2", "n3", "n4"}, names(data))
+	assert.Nil(t, lq.Cursor.Next, "last page")
+}
@@ -0,0 +1,85 @@
+package dbutil
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// NewRepo creates new typed repository of the model T. e.g: NewRepo[model.User]()
+func NewRepo[T any]() *Repo[T] {
+	return &Repo[T]{}
+}
+
+// NewTenantRepo creates new typed repository of a model whose records belong to a tenant, see Repo.TenantColumn
+func NewTenantRepo[T any](column string) *Repo[T] {
+	return &Repo[T]{TenantColumn: column}
+}
+
+// Repo represents the typed client of the model T for common usages.
+// It holds no state of the queries, so a single instance is safe for concurrent use.
+type Repo[T any] struct {
+	// TenantColumn holds the tenant of the records, see DB.TenantColumn
+	TenantColumn string
+	// TenantScope overrides the filtering by TenantColumn, see DB.TenantScope
+	TenantScope TenantScope
+}
+
+// DB returns the untyped client of the repository, implementing Intf for compatibility
+func (r *Repo[T]) DB() *DB {
+	var m T
+	return &DB{Model: m, TenantColumn: r.TenantColumn, TenantScope: r.TenantScope}
+}
+
+// Create creates a new record on database.
+func (r *Repo[T]) Create(ctx context.Context, db *gorm.DB, input *T) error {
+	return r.DB().Create(ctx, db, input)
+}
+
+// CreateInBatches creates batch of new records on database.
+func (r *Repo[T]) CreateInBatches(ctx context.Context, db *gorm.DB, input []*T, batchSize int) error {
+	return r.DB().CreateInBatches(ctx, db, input, batchSize)
+}
+
+// View returns single record matching the given conditions.
+// Note: RecordNotFound error is returned when there is no record that matches the conditions
+func (r *Repo[T]) View(ctx context.Context, db *gorm.DB, cond ...interface{}) (*T, error) {
+	rec := new(T)
+	if err := r.DB().View(ctx, db, rec, cond...); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// List returns list of records retrievable after filter & pagination if given.
+// `lq` can be nil, then no filter & pagination are applied
+// `count` can also be nil, then no extra query is executed to get the total count, nor in the cursor mode (see Cursor)
+func (r *Repo[T]) List(ctx context.Context, db *gorm.DB, lq *ListQueryCondition, count *int64) ([]*T, error) {
+	var data []*T
+	if err := r.DB().List(ctx, db, &data, lq, count); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Update updates data of the records matching the given conditions.
+// `updates` could be a model struct or map[string]interface{}
+func (r *Repo[T]) Update(ctx context.Context, db *gorm.DB, updates interface{}, cond ...interface{}) error {
+	return r.DB().Update(ctx, db, updates, cond...)
+}
+
+// Delete deletes record matching given conditions.
+// `cond` can be an instance of the model, then primary key will be used as the condition
+func (r *Repo[T]) Delete(ctx context.Context, db *gorm.DB, cond ...interface{}) error {
+	return r.DB().Delete(ctx, db, cond...)
+}
+
+// DeletePermanently deletes record matching given conditions permanently.
+func (r *Repo[T]) DeletePermanently(ctx context.Context, db *gorm.DB, cond ...interface{}) error {
+	return r.DB().DeletePermanently(ctx, db, cond...)
+}
+
+// Exist checks whether there is record matching the given conditions.
+func (r *Repo[T]) Exist(ctx context.Context, db *gorm.DB, cond ...interface{}) (bool, error) {
+	return r.DB().Exist(ctx, db, cond...)
+}
@@ -0,0 +1,83 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context in which the queries of tenant-scoped DBs are restricted to the given tenant
+func WithTenant(ctx context.Context, tenantID int) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// WithoutTenant returns a copy of the context in which the queries are not restricted to any tenant,
+// e.g: to check the uniqueness of usernames across tenants
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, 0)
+}
+
+// TenantFromContext returns the tenant of the context, if any
+func TenantFromContext(ctx context.Context) (int, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(int)
+	return tenantID, ok && tenantID != 0
+}
+
+// TenantScope restricts the query to the records of the given tenant
+type TenantScope func(db *gorm.DB, tenantID int) *gorm.DB
+
+// ColumnTenantScope returns the scope filtering the records by the given tenant column of the model table
+func ColumnTenantScope(column string) TenantScope {
+	return func(db *gorm.DB, tenantID int) *gorm.DB {
+		return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: tenantID})
+	}
+}
+
+// scope restricts the query to the tenant of the context, if any
+func (cdb *DB) scope(ctx context.Context, db *gorm.DB) *gorm.DB {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return db
+	}
+	if cdb.TenantScope != nil {
+		return cdb.TenantScope(db, tenantID)
+	}
+	if cdb.TenantColumn != "" {
+		return ColumnTenantScope(cdb.TenantColumn)(db, tenantID)
+	}
+	return db
+}
+
+// setTenant fills the tenant column of the new records with the tenant of the context, if any.
+// `input` is either a pointer of the model or a slice of them.
+func (cdb *DB) setTenant(ctx context.Context, db *gorm.DB, input interface{}) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok || cdb.TenantColumn == "" {
+		return nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(input); err != nil {
+		return err
+	}
+	field := stmt.Schema.LookUpField(cdb.TenantColumn)
+	if field == nil {
+		return fmt.Errorf("tenant column %s not found in %s", cdb.TenantColumn, stmt.Schema.Name)
+	}
+
+	val := reflect.Indirect(reflect.ValueOf(input))
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return field.Set(ctx, val, tenantID)
+	}
+	for i := 0; i < val.Len(); i++ {
+		if err := field.Set(ctx, reflect.Indirect(val.Index(i)), tenantID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type tenantRecord struct {
+	ID    int
+	OrgID int
+	Name  string
+}
+
+func TestTenantScope(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&tenantRecord{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cdb := NewTenantDB(tenantRecord{}, "org_id")
+	org1, org2 := WithTenant(context.Background(), 1), WithTenant(context.Background(), 2)
+
+	rec := &tenantRecord{Name: "a"}
+	assert.Nil(t, cdb.Create(org1, db, rec))
+	assert.Equal(t, 1, rec.OrgID)
+	batch := []*tenantRecord{{Name: "b"}, {Name: "c", OrgID: 1}}
+	assert.Nil(t, cdb.CreateInBatches(org2, db, &batch, 10))
+	assert.Equal(t, 2, batch[0].OrgID)
+	assert.Equal(t, 2, batch[1].OrgID, "the tenant of the context wins")
+
+	var data []*tenantRecord
+	assert.Nil(t, cdb.List(org2, db, &data, nil, nil))
+	assert.Len(t, data, 2)
+	assert.Nil(t, cdb.List(WithoutTenant(org2), db, &data, nil, nil))
+	assert.Len(t, data, 3)
+
+	assert.NotNil(t, cdb.View(org2, db, new(tenantRecord), rec.ID))
+	assert.Nil(t, cdb.View(org1, db, new(tenantRecord), rec.ID))
+
+	existed, err := cdb.Exist(org2, db, map[string]interface{}{"name": "a"})
+	assert.Nil(t, err)
+	assert.False(t, existed)
+
+	assert.Nil(t, cdb.Update(org2, db, map[string]interface{}{"name": "x"}, rec.ID))
+	assert.Nil(t, cdb.Delete(org2, db, rec.ID))
+	assert.Nil(t, cdb.View(context.Background(), db, rec, rec.ID))
+	assert.Equal(t, "a", rec.Name, "records of other tenants are not changed")
+
+	assert.Nil(t, cdb.Delete(org1, db, rec.ID))
+	assert.NotNil(t, cdb.View(context.Background(), db, new(tenantRecord), rec.ID))
+}
@@ -2,6 +2,8 @@ package dbutil
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/imdatngo/gowhere"
 	"gorm.io/gorm"
@@ -27,6 +29,27 @@ func parseCond(cond ...interface{}) []interface{} {
 	return parseCondWithConfig(gowhere.DefaultConfig, cond...)
 }
 
+// sortColumnRegexp matches the column of a sort, optionally qualified by the table
+var sortColumnRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// parseSort returns the column & the direction of the sort in `column [ASC|DESC]` format
+func parseSort(sort string) (column string, desc bool, err error) {
+	parts := strings.Fields(sort)
+	if len(parts) == 0 || len(parts) > 2 || !sortColumnRegexp.MatchString(parts[0]) {
+		return "", false, fmt.Errorf("invalid sort %q", sort)
+	}
+	if len(parts) == 2 {
+		switch strings.ToUpper(parts[1]) {
+		case "ASC":
+		case "DESC":
+			desc = true
+		default:
+			return "", false, fmt.Errorf("invalid sort %q", sort)
+		}
+	}
+	return parts[0], desc, nil
+}
+
 // InTransaction defines the transaction wrapper function
 type InTransaction func(tx *gorm.DB) error
 
@@ -48,7 +71,9 @@ func Transaction(db *gorm.DB, fn InTransaction) (err error) {
 			}
 		}
 		if err != nil {
-			err = tx.Rollback().Error
+			if rbErr := tx.Rollback().Error; rbErr != nil {
+				err = rbErr
+			}
 		} else {
 			err = tx.Commit().Error
 		}
@@ -0,0 +1,96 @@
+package dbutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testRecord struct {
+	ID   int
+	Name string
+}
+
+func TestTransaction(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&testRecord{}); err != nil {
+		t.Fatal(err)
+	}
+
+	errFailed := errors.New("failed")
+	cases := []struct {
+		name      string
+		fn        InTransaction
+		wantErr   error
+		wantCount int64
+	}{
+		{
+			name: "Commit",
+			fn: func(tx *gorm.DB) error {
+				return tx.Create(&testRecord{Name: "committed"}).Error
+			},
+			wantCount: 1,
+		},
+		{
+			name: "Rollback on error",
+			fn: func(tx *gorm.DB) error {
+				if err := tx.Create(&testRecord{Name: "rolled back"}).Error; err != nil {
+					return err
+				}
+				return errFailed
+			},
+			wantErr:   errFailed,
+			wantCount: 1,
+		},
+		{
+			name: "Rollback on panic",
+			fn: func(tx *gorm.DB) error {
+				tx.Create(&testRecord{Name: "panicked"})
+				panic("something went wrong")
+			},
+			wantErr:   errors.New("something went wrong"),
+			wantCount: 1,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Transaction(db, tt.fn)
+			assert.Equal(t, tt.wantErr, err)
+
+			var count int64
+			db.Model(&testRecord{}).Count(&count)
+			assert.Equal(t, tt.wantCount, count)
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	cases := []struct {
+		sort     string
+		wantCol  string
+		wantDesc bool
+		wantErr  bool
+	}{
+		{sort: "name", wantCol: "name"},
+		{sort: "name asc", wantCol: "name"},
+		{sort: "users.created_at DESC", wantCol: "users.created_at", wantDesc: true},
+		{sort: "", wantErr: true},
+		{sort: "name DOWN", wantErr: true},
+		{sort: "name; DROP TABLE users", wantErr: true},
+		{sort: "(SELECT 1)", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.sort, func(t *testing.T) {
+			col, desc, err := parseSort(tt.sort)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantCol, col)
+			assert.Equal(t, tt.wantDesc, desc)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package email
+
+import (
+	"context"
+	"sync"
+)
+
+// NewFakeSender creates new fake sender
+func NewFakeSender() *FakeSender {
+	return &FakeSender{}
+}
+
+// FakeSender records the emails instead of sending them, for tests & local development
+type FakeSender struct {
+	mu   sync.Mutex
+	sent []Input
+}
+
+// SendEmail records the email
+func (s *FakeSender) SendEmail(input Input) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, input)
+	return nil
+}
+
+// SendEmailWithContext records the email unless ctx is done
+func (s *FakeSender) SendEmailWithContext(ctx context.Context, input Input) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.SendEmail(input)
+}
+
+// Sent returns all recorded emails
+func (s *FakeSender) Sent() []Input {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Input(nil), s.sent...)
+}
+
+// Last returns the latest recorded email, nil if there is none
+func (s *FakeSender) Last() *Input {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sent) == 0 {
+		return nil
+	}
+	last := s.sent[len(s.sent)-1]
+	return &last
+}
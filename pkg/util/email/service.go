@@ -1,6 +1,8 @@
 package email
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ses"
@@ -26,3 +28,8 @@ type Email struct {
 	cfg Config
 	ses *ses.SES
 }
+
+// Sender represents the email sending interface, implemented by Email and FakeSender
+type Sender interface {
+	SendEmailWithContext(context.Context, Input) error
+}
@@ -2,6 +2,7 @@ package email
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"mime/multipart"
@@ -37,6 +38,11 @@ type Attachments struct {
 
 // SendEmail sends email using SES service
 func (s *Email) SendEmail(input Input) error {
+	return s.SendEmailWithContext(context.Background(), input)
+}
+
+// SendEmailWithContext sends email using SES service, the request is cancelled along with ctx
+func (s *Email) SendEmailWithContext(ctx context.Context, input Input) error {
 	if input.CharSet == "" {
 		input.CharSet = DefaultCharSet
 	}
@@ -73,7 +79,7 @@ func (s *Email) SendEmail(input Input) error {
 	}
 
 	// Attempt to send the email.
-	_, err := s.ses.SendEmail(awsInput)
+	_, err := s.ses.SendEmailWithContext(ctx, awsInput)
 	if err != nil {
 		return err
 	}
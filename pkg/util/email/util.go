@@ -3,6 +3,7 @@ package email
 import (
 	"bytes"
 	"html/template"
+	"io/fs"
 	"io/ioutil"
 )
 
@@ -26,8 +27,21 @@ func ParseFromPathTemplate(path string, data interface{}) (string, error) {
 		return "", err
 	}
 
-	tplStr := string(b)
+	return parseTemplate(string(b), data)
+}
+
+// ParseFromFSTemplate embeds data into the input template from path of template file in the given file system, e.g. embed.FS
+func ParseFromFSTemplate(fsys fs.FS, path string, data interface{}) (string, error) {
+	b, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return "", err
+	}
+
+	return parseTemplate(string(b), data)
+}
 
+// parseTemplate embeds data into the input template, providing helpers to output trusted content
+func parseTemplate(tplStr string, data interface{}) (string, error) {
 	buf := new(bytes.Buffer)
 
 	funcMap := template.FuncMap{
@@ -0,0 +1,43 @@
+package email_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/vuduongtp/go-core/pkg/util/email"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFromFSTemplate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"reset.html": {Data: []byte(`<a href="{{.URL | safeURL}}">Hi {{.Name}}</a>`)},
+	}
+
+	out, err := email.ParseFromFSTemplate(fsys, "reset.html", map[string]string{
+		"URL":  "https://example.com/reset?token=abc&x=1",
+		"Name": "<b>John</b>",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `<a href="https://example.com/reset?token=abc&amp;x=1">Hi &lt;b&gt;John&lt;/b&gt;</a>`, out)
+
+	_, err = email.ParseFromFSTemplate(fsys, "missing.html", nil)
+	assert.NotNil(t, err)
+}
+
+func TestFakeSender(t *testing.T) {
+	var sender email.Sender = email.NewFakeSender()
+	fake := sender.(*email.FakeSender)
+	assert.Nil(t, fake.Last())
+
+	assert.Nil(t, sender.SendEmailWithContext(context.Background(), email.Input{To: []string{"a@example.com"}, Subject: "first"}))
+	assert.Nil(t, sender.SendEmailWithContext(context.Background(), email.Input{To: []string{"b@example.com"}, Subject: "second"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, sender.SendEmailWithContext(ctx, email.Input{To: []string{"c@example.com"}, Subject: "cancelled"}), context.Canceled)
+
+	assert.Len(t, fake.Sent(), 2)
+	assert.Equal(t, "second", fake.Last().Subject)
+}
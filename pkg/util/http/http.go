@@ -1,15 +1,20 @@
 package httputil
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/vuduongtp/go-core/pkg/server"
 	dbutil "github.com/vuduongtp/go-core/pkg/util/db"
 
 	"github.com/imdatngo/gowhere"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/schema"
 )
 
 // ReqID returns id url parameter.
@@ -55,16 +60,50 @@ type ListRequest struct {
 	Limit int `json:"l,omitempty" query:"l" default:"25"`
 	// Current page number
 	Page int `json:"p,omitempty" query:"p" default:"1"`
-	// Field name for sorting
+	// Cursor of the page for the keyset pagination instead of the page number, empty for the first page.
+	// The cursors of the next & previous pages are returned instead of the total count
+	Cursor string `json:"cursor,omitempty" query:"cursor"`
+	// Comma separated field names for sorting, prefixed by - for the descending order. E.g: name,-created_at
 	Sort string `json:"s,omitempty" query:"s"`
-	// Sort direction order must be one of ASC, DESC
+	// Sort direction of the fields without prefix, must be one of ASC, DESC
 	Order string `json:"o,omitempty" query:"o" enums:"ASC,DESC" default:"ASC"`
 	// JSON string of filter. E.g: {"field_name":"value"}
 	Filter string `json:"f,omitempty" query:"f"`
 } // @name ListRequest
 
-// ReqListQuery parses url query string for listing request
-func ReqListQuery(c echo.Context) (*dbutil.ListQueryCondition, error) {
+// SortFields maps the field names accepted for sorting by a list endpoint to their columns, see ReqListQuery
+type SortFields map[string]string
+
+// NewSortFields returns the sort fields of the given columns, named after them. e.g: NewSortFields("name", "created_at")
+func NewSortFields(columns ...string) SortFields {
+	fields := make(SortFields, len(columns))
+	for _, col := range columns {
+		fields[col] = col
+	}
+	return fields
+}
+
+// MustSortFieldsOf returns the sort fields of the model: its columns, named after the json tags.
+// The fields without json name or column are omitted, e.g: `json:"-"`.
+// It panics if the model cannot be parsed, it is meant to be called on initialization.
+func MustSortFieldsOf(model interface{}) SortFields {
+	s, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		panic(err)
+	}
+	fields := make(SortFields)
+	for _, f := range s.Fields {
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.DBName == "" || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f.DBName
+	}
+	return fields
+}
+
+// ReqListQuery parses url query string for listing request, only the given fields may be sorted by
+func ReqListQuery(c echo.Context, sortable SortFields) (*dbutil.ListQueryCondition, error) {
 	lr := &ListRequest{}
 	if err := c.Bind(lr); err != nil {
 		return nil, err
@@ -76,6 +115,15 @@ func ReqListQuery(c echo.Context) (*dbutil.ListQueryCondition, error) {
 		Filter:  gowhere.WithConfig(gowhere.Config{Strict: true}),
 	}
 
+	// the cursor mode is requested by the cursor parameter, even empty
+	if _, ok := c.QueryParams()["cursor"]; ok {
+		cursor, err := dbutil.DecodeCursor(lr.Cursor)
+		if err != nil {
+			return nil, server.NewHTTPValidationError("Invalid cursor").SetInternal(err)
+		}
+		lq.Cursor = cursor
+	}
+
 	if lr.Filter != "" {
 		var filter interface{}
 		err := json.Unmarshal([]byte(lr.Filter), &filter)
@@ -89,13 +137,67 @@ func ReqListQuery(c echo.Context) (*dbutil.ListQueryCondition, error) {
 	}
 
 	if lr.Sort != "" {
-		sortField := lr.Sort
-		sortOrder := "ASC" // default
-		if lr.Order != "" && strings.ToLower(lr.Order) == "desc" {
-			sortOrder = "DESC"
+		order := "ASC" // default
+		if strings.ToLower(lr.Order) == "desc" {
+			order = "DESC"
+		}
+		for _, name := range strings.Split(lr.Sort, ",") {
+			name, dir := strings.TrimSpace(name), order
+			if strings.HasPrefix(name, "-") {
+				name, dir = name[1:], "DESC"
+			}
+			col, ok := sortable[name]
+			if !ok {
+				return nil, server.NewHTTPValidationError(fmt.Sprintf("Invalid sort field %q", name))
+			}
+			lq.Sort = append(lq.Sort, col+" "+dir)
 		}
-		lq.Sort = []string{sortField + " " + sortOrder}
 	}
 
 	return lq, nil
 }
+
+// ClientInfo holds information of the client sending the request
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type clientInfoKey struct{}
+
+// ReqClientInfo returns information of the client sending the request
+func ReqClientInfo(c echo.Context) ClientInfo {
+	return ClientInfo{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
+
+// ReqContext returns the request context carrying the client information, see ClientInfoFromContext
+func ReqContext(c echo.Context) context.Context {
+	return WithClientInfo(c.Request().Context(), ReqClientInfo(c))
+}
+
+// WithClientInfo returns a copy of ctx carrying the client information
+func WithClientInfo(ctx context.Context, ci ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, ci)
+}
+
+// ClientInfoFromContext returns the client information carried by ctx, empty if there is none
+func ClientInfoFromContext(ctx context.Context) ClientInfo {
+	ci, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return ci
+}
+
+// DetachContext returns a copy of ctx carrying its values but not its cancellation nor deadline,
+// for the work that goes on after the response is sent, e.g. sending emails in background
+func DetachContext(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+type detachedContext struct{ parent context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
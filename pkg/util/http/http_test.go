@@ -0,0 +1,86 @@
+package httputil_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/server"
+	httputil "github.com/vuduongtp/go-core/pkg/util/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type sortRecord struct {
+	ID        int       `json:"id"`
+	FullName  string    `json:"name" gorm:"column:full_name"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+func TestMustSortFieldsOf(t *testing.T) {
+	assert.Equal(t, httputil.SortFields{"id": "id", "name": "full_name", "created_at": "created_at"}, httputil.MustSortFieldsOf(sortRecord{}))
+	assert.Equal(t, httputil.SortFields{"id": "id", "name": "name"}, httputil.NewSortFields("id", "name"))
+}
+
+func TestReqListQuery(t *testing.T) {
+	sortable := httputil.MustSortFieldsOf(sortRecord{})
+	cases := []struct {
+		name     string
+		query    string
+		wantSort []string
+		wantErr  bool
+	}{
+		{name: "Without sort", query: "l=10&p=2"},
+		{name: "Single field", query: "s=name", wantSort: []string{"full_name ASC"}},
+		{name: "Single field with order", query: "s=name&o=desc", wantSort: []string{"full_name DESC"}},
+		{name: "Several fields", query: "s=name,-created_at", wantSort: []string{"full_name ASC", "created_at DESC"}},
+		{name: "Unknown field", query: "s=secret", wantErr: true},
+		{name: "Injection", query: "s=id%3BDROP%20TABLE%20users", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			lq, err := httputil.ReqListQuery(c, sortable)
+			if tt.wantErr {
+				var he *server.HTTPError
+				assert.ErrorAs(t, err, &he)
+				assert.Equal(t, http.StatusBadRequest, he.Code)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantSort, lq.Sort)
+			assert.Nil(t, lq.Cursor)
+		})
+	}
+}
+
+func TestReqListQueryCursor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?cursor=&l=10", nil)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	lq, err := httputil.ReqListQuery(c, nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, lq.Cursor, "first page")
+	assert.Equal(t, 10, lq.PerPage)
+
+	req = httptest.NewRequest(http.MethodGet, "/?cursor=invalid", nil)
+	c = echo.New().NewContext(req, httptest.NewRecorder())
+	_, err = httputil.ReqListQuery(c, nil)
+	assert.NotNil(t, err)
+}
+
+func TestDetachContext(t *testing.T) {
+	ci := httputil.ClientInfo{IP: "1.2.3.4"}
+	ctx, cancel := context.WithTimeout(httputil.WithClientInfo(context.Background(), ci), time.Minute)
+	cancel()
+
+	detached := httputil.DetachContext(ctx)
+	assert.Nil(t, detached.Err())
+	_, ok := detached.Deadline()
+	assert.False(t, ok)
+	assert.Equal(t, ci, httputil.ClientInfoFromContext(detached))
+}
@@ -0,0 +1,180 @@
+package lockout
+
+import (
+	"context"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/util/logger"
+)
+
+// Attempt represents the failed attempts of a key, e.g. an account or a client IP
+type Attempt struct {
+	// Key identifies what is being protected. e.g: account:johndoe
+	Key string `json:"key" gorm:"column:lock_key;type:varchar(255);primaryKey"`
+	// Number of consecutive failures since the last lockout or success
+	Failures int `json:"failures" gorm:"not null;default:0"`
+	// Number of lockouts so far, the lockout window grows with it
+	Lockouts int `json:"lockouts" gorm:"not null;default:0"`
+	// The key is locked until this time
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	// The time of the latest failure
+	LastFailureAt time.Time `json:"last_failure_at"`
+} // @name LoginAttempt
+
+// TableName returns the table name of Attempt model
+func (Attempt) TableName() string {
+	return "login_attempts"
+}
+
+// IsLocked checks whether the key is locked at the given time
+func (a *Attempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
+
+// Store represents the storage of failed attempts
+type Store interface {
+	// Get returns the attempt of the key, nil is returned if there is none
+	Get(ctx context.Context, key string) (*Attempt, error)
+	// Save creates or updates the attempt
+	Save(ctx context.Context, a *Attempt) error
+	// Update atomically updates the attempt of the key with the given function, it is created if there is none.
+	// Concurrent updates of the same key are serialized, so that no failure is lost
+	Update(ctx context.Context, key string, fn func(a *Attempt)) (*Attempt, error)
+	// Delete deletes the attempt of the key
+	Delete(ctx context.Context, key string) error
+	// List returns all attempts
+	List(ctx context.Context) ([]*Attempt, error)
+}
+
+// Policy represents the lockout policy
+type Policy struct {
+	// Number of consecutive failures before the key is locked
+	MaxFailures int
+	// Duration of the first lockout, it is doubled for every subsequent lockout
+	Duration time.Duration
+	// The upper limit of the lockout duration
+	MaxDuration time.Duration
+	// Failures & lockouts history is forgotten after this period without any failure
+	ResetAfter time.Duration
+}
+
+// DefaultPolicy represents the default lockout policy
+var DefaultPolicy = Policy{
+	MaxFailures: 5,
+	Duration:    time.Minute,
+	MaxDuration: time.Hour,
+	ResetAfter:  24 * time.Hour,
+}
+
+func (p *Policy) fillDefaults() {
+	if p.MaxFailures <= 0 {
+		p.MaxFailures = DefaultPolicy.MaxFailures
+	}
+	if p.Duration <= 0 {
+		p.Duration = DefaultPolicy.Duration
+	}
+	if p.MaxDuration <= 0 {
+		p.MaxDuration = DefaultPolicy.MaxDuration
+	}
+	if p.ResetAfter <= 0 {
+		p.ResetAfter = DefaultPolicy.ResetAfter
+	}
+}
+
+// lockDuration returns the duration of the n-th lockout
+func (p *Policy) lockDuration(n int) time.Duration {
+	d := p.Duration
+	for i := 1; i < n && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+	if d > p.MaxDuration {
+		d = p.MaxDuration
+	}
+	return d
+}
+
+// New creates new lockout service
+func New(store Store, policy Policy) *Service {
+	policy.fillDefaults()
+	return &Service{store: store, policy: policy}
+}
+
+// Service tracks failed attempts and locks keys temporarily
+type Service struct {
+	store  Store
+	policy Policy
+}
+
+// Check returns the remaining lockout duration of the key, zero if the key is not locked
+func (s *Service) Check(ctx context.Context, key string) (time.Duration, error) {
+	a, err := s.store.Get(ctx, key)
+	if err != nil || a == nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	if !a.IsLocked(now) {
+		return 0, nil
+	}
+	return a.LockedUntil.Sub(now), nil
+}
+
+// Fail records a failed attempt of the key.
+// The lockout duration is returned if the key gets locked by this failure.
+func (s *Service) Fail(ctx context.Context, key string) (time.Duration, error) {
+	var d time.Duration
+	a, err := s.store.Update(ctx, key, func(a *Attempt) {
+		now := time.Now()
+		if now.Sub(a.LastFailureAt) > s.policy.ResetAfter {
+			*a = Attempt{Key: key}
+		}
+		a.Failures++
+		a.LastFailureAt = now
+		d = 0
+		if a.Failures >= s.policy.MaxFailures {
+			a.Lockouts++
+			a.Failures = 0
+			d = s.policy.lockDuration(a.Lockouts)
+			lockedUntil := now.Add(d)
+			a.LockedUntil = &lockedUntil
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if d > 0 {
+		logger.LogSecurityEvent(ctx, "lockout", map[string]interface{}{
+			"key":          key,
+			"lockouts":     a.Lockouts,
+			"locked_until": a.LockedUntil,
+		})
+	}
+	return d, nil
+}
+
+// Reset clears the failures of the key, e.g. after a successful attempt.
+// The lockouts history is kept so that the next lockout lasts longer.
+func (s *Service) Reset(ctx context.Context, key string) error {
+	a, err := s.store.Get(ctx, key)
+	if err != nil || a == nil {
+		return err
+	}
+	if a.Failures == 0 && a.LockedUntil == nil {
+		return nil
+	}
+	_, err = s.store.Update(ctx, key, func(a *Attempt) {
+		a.Failures = 0
+		a.LockedUntil = nil
+	})
+	return err
+}
+
+// Unlock clears the failures, the lock and the lockouts history of the key
+func (s *Service) Unlock(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// List returns the attempts of all keys
+func (s *Service) List(ctx context.Context) ([]*Attempt, error) {
+	return s.store.List(ctx)
+}
@@ -0,0 +1,124 @@
+package lockout_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/util/lockout"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestService(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&lockout.Attempt{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]lockout.Store{
+		"Memory": lockout.NewMemoryStore(),
+		"Gorm":   lockout.NewGormStore(db),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := lockout.New(store, lockout.Policy{MaxFailures: 3, Duration: time.Minute, MaxDuration: 3 * time.Minute})
+			key := "account:johndoe"
+
+			// lockout window grows with every lockout, up to the max duration
+			for _, wantLock := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+				for i := 1; i < 3; i++ {
+					d, err := s.Fail(ctx, key)
+					assert.Nil(t, err)
+					assert.Zero(t, d)
+				}
+				d, err := s.Fail(ctx, key)
+				assert.Nil(t, err)
+				assert.Equal(t, wantLock, d)
+
+				remaining, err := s.Check(ctx, key)
+				assert.Nil(t, err)
+				assert.InDelta(t, wantLock, remaining, float64(time.Second))
+			}
+
+			// other keys are not affected
+			remaining, err := s.Check(ctx, "account:another")
+			assert.Nil(t, err)
+			assert.Zero(t, remaining)
+
+			attempts, err := s.List(ctx)
+			assert.Nil(t, err)
+			assert.Len(t, attempts, 1)
+			assert.Equal(t, key, attempts[0].Key)
+			assert.Equal(t, 3, attempts[0].Lockouts)
+
+			// success clears the lock but keeps the lockouts history
+			assert.Nil(t, s.Reset(ctx, key))
+			remaining, err = s.Check(ctx, key)
+			assert.Nil(t, err)
+			assert.Zero(t, remaining)
+			attempts, _ = s.List(ctx)
+			assert.Equal(t, 3, attempts[0].Lockouts)
+
+			// unlock clears everything
+			assert.Nil(t, s.Unlock(ctx, key))
+			attempts, err = s.List(ctx)
+			assert.Nil(t, err)
+			assert.Empty(t, attempts)
+		})
+	}
+}
+
+func TestServiceConcurrentFailures(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "lockout.db")+"?_busy_timeout=5000"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Error establishing connection %v", err)
+	}
+	if err := db.AutoMigrate(&lockout.Attempt{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]lockout.Store{
+		"Memory": lockout.NewMemoryStore(),
+		"Gorm":   lockout.NewGormStore(db),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := lockout.New(store, lockout.Policy{MaxFailures: 5, Duration: time.Minute, MaxDuration: time.Hour})
+			key := "account:johndoe"
+
+			// no failure is lost, every 5th failure locks the key
+			var wg sync.WaitGroup
+			var locks int32
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					d, err := s.Fail(ctx, key)
+					assert.Nil(t, err)
+					if d > 0 {
+						atomic.AddInt32(&locks, 1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, int32(4), locks)
+			attempts, err := s.List(ctx)
+			assert.Nil(t, err)
+			assert.Len(t, attempts, 1)
+			assert.Equal(t, 4, attempts[0].Lockouts)
+			assert.Equal(t, 0, attempts[0].Failures)
+		})
+	}
+}
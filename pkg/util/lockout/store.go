@@ -0,0 +1,144 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewMemoryStore creates new in-memory store.
+// Attempts are not shared between instances, use the GORM store for multiple instances.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]Attempt)}
+}
+
+// MemoryStore is the in-memory implementation of Store
+type MemoryStore struct {
+	mu       sync.RWMutex
+	attempts map[string]Attempt
+}
+
+// Get returns the attempt of the key
+func (s *MemoryStore) Get(ctx context.Context, key string) (*Attempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+// Save creates or updates the attempt
+func (s *MemoryStore) Save(ctx context.Context, a *Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[a.Key] = *a
+	return nil
+}
+
+// Update atomically updates the attempt of the key, it is created if there is none
+func (s *MemoryStore) Update(ctx context.Context, key string, fn func(a *Attempt)) (*Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok {
+		a = Attempt{Key: key}
+	}
+	fn(&a)
+	s.attempts[key] = a
+	return &a, nil
+}
+
+// Delete deletes the attempt of the key
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// List returns all attempts, ordered by key
+func (s *MemoryStore) List(ctx context.Context) ([]*Attempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Attempt, 0, len(s.attempts))
+	for _, a := range s.attempts {
+		a := a
+		out = append(out, &a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// NewGormStore creates new GORM store.
+// The login_attempts table must be migrated beforehand.
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db}
+}
+
+// GormStore is the GORM implementation of Store
+type GormStore struct {
+	db *gorm.DB
+}
+
+// Get returns the attempt of the key
+func (s *GormStore) Get(ctx context.Context, key string) (*Attempt, error) {
+	a := new(Attempt)
+	if err := s.db.WithContext(ctx).Where("lock_key = ?", key).First(a).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// Save creates or updates the attempt
+func (s *GormStore) Save(ctx context.Context, a *Attempt) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "lock_key"}},
+		UpdateAll: true,
+	}).Create(a).Error
+}
+
+// Update atomically updates the attempt of the key, it is created if there is none.
+// The row is created first then locked (SELECT ... FOR UPDATE) in a transaction, so that the concurrent updates wait for each other.
+func (s *GormStore) Update(ctx context.Context, key string, fn func(a *Attempt)) (*Attempt, error) {
+	a := new(Attempt)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Attempt{Key: key, LastFailureAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("lock_key = ?", key).First(a).Error; err != nil {
+			return err
+		}
+		fn(a)
+		a.Key = key
+		return tx.Save(a).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Delete deletes the attempt of the key
+func (s *GormStore) Delete(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("lock_key = ?", key).Delete(&Attempt{}).Error
+}
+
+// List returns all attempts, ordered by key
+func (s *GormStore) List(ctx context.Context) ([]*Attempt, error) {
+	var out []*Attempt
+	if err := s.db.WithContext(ctx).Order("lock_key").Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -58,6 +58,15 @@ func LogWarnf(ctx context.Context, format string, a ...any) {
 	logadapter.LogWithContext(ctx, fmt.Sprintf(format, a...), logadapter.LogTypeWarn)
 }
 
+// LogSecurityEvent for logging security related events (e.g. token reuse, account lockout) with context to log request_id and correlation_id
+func LogSecurityEvent(ctx context.Context, event string, fields map[string]interface{}) {
+	logFields := map[string]interface{}{"security_event": event}
+	for k, v := range fields {
+		logFields[k] = v
+	}
+	logadapter.LogWithContext(ctx, "security event: "+event, logadapter.LogTypeWarn, logFields)
+}
+
 // LogWithContext log content with context
 // content[0] : message -> interface{},
 // content[1] : log type -> string,
@@ -0,0 +1,122 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// GitHubConfig represents the configuration of the GitHub provider
+type GitHubConfig struct {
+	ClientID     string
+	ClientSecret string
+	// The callback URL registered at GitHub. e.g: https://api.example.com/oauth/github/callback
+	RedirectURL string
+	// Defaults to read:user and user:email
+	Scopes []string
+	// Base URLs, default to github.com. Override them for GitHub Enterprise
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+	// HTTP client used for the token & API requests, defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// NewGitHub creates new GitHub provider. GitHub does not support OpenID Connect, the identity is read from its API
+func NewGitHub(cfg GitHubConfig) *GitHub {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user", "user:email"}
+	}
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = "https://github.com/login/oauth/authorize"
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = "https://github.com/login/oauth/access_token"
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.github.com"
+	}
+	cfg.APIURL = strings.TrimSuffix(cfg.APIURL, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return &GitHub{
+		cfg: cfg,
+		oauth2: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  cfg.AuthURL,
+				TokenURL: cfg.TokenURL,
+			},
+		},
+	}
+}
+
+// GitHub represents the GitHub provider
+type GitHub struct {
+	cfg    GitHubConfig
+	oauth2 *oauth2.Config
+}
+
+// Name returns the name of the provider
+func (p *GitHub) Name() string {
+	return "github"
+}
+
+// AuthCodeURL returns the URL of GitHub's consent page, the nonce is not used by plain OAuth2
+func (p *GitHub) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth2.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange exchanges the authorization code for the access token and reads the identity from GitHub API
+func (p *GitHub) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.cfg.HTTPClient)
+	tok, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	usr := struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}{}
+	if err := getJSON(ctx, p.cfg.HTTPClient, p.cfg.APIURL+"/user", tok.AccessToken, &usr); err != nil {
+		return nil, err
+	}
+	if usr.ID == 0 {
+		return nil, errors.New("id of the github user is missing")
+	}
+
+	// the public email of the profile may be empty or unverified, use the primary one instead
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.cfg.HTTPClient, p.cfg.APIURL+"/user/emails", tok.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject:  strconv.FormatInt(usr.ID, 10),
+		Name:     usr.Name,
+		Username: usr.Login,
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+			break
+		}
+	}
+	return identity, nil
+}
@@ -0,0 +1,194 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/server"
+	"github.com/vuduongtp/go-core/pkg/util/crypter"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+// Custom errors
+var (
+	ErrUnknownProvider = server.NewHTTPError(http.StatusNotFound, "UNKNOWN_PROVIDER", "Unknown login provider")
+	ErrInvalidState    = server.NewHTTPError(http.StatusBadRequest, "INVALID_OAUTH_STATE", "Invalid or expired login state, please try again")
+	ErrLoginFailed     = server.NewHTTPError(http.StatusUnauthorized, "OAUTH_LOGIN_FAILED", "Login with the provider failed")
+)
+
+// Identity represents the user identity asserted by a provider
+type Identity struct {
+	// Name of the provider. e.g: google
+	Provider string
+	// Unique & stable ID of the user at the provider
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Preferred username at the provider, if any
+	Username string
+}
+
+// Provider represents an OAuth2 / OpenID Connect identity provider
+type Provider interface {
+	// Name returns the name of the provider, used in the URLs. e.g: google
+	Name() string
+	// AuthCodeURL returns the URL of the provider's consent page.
+	// The PKCE verifier and the OIDC nonce are generated per login, see Service.Start.
+	AuthCodeURL(state, nonce, verifier string) string
+	// Exchange exchanges the authorization code for the identity of the user
+	Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// Config represents the configuration of the login handlers
+type Config struct {
+	Providers []Provider
+	// Name of the cookie keeping the login state between the start and the callback
+	CookieName string
+	// Path of the state cookie, it should cover the callback URLs
+	CookiePath string
+	// Send the state cookie over HTTPS only
+	CookieSecure bool
+	// Time given to complete the login at the provider
+	StateTTL time.Duration
+}
+
+// DefaultConfig represents the default configuration
+var DefaultConfig = Config{
+	CookieName: "oauth_state",
+	CookiePath: "/oauth",
+	StateTTL:   10 * time.Minute,
+}
+
+func (c *Config) fillDefaults() {
+	if c.CookieName == "" {
+		c.CookieName = DefaultConfig.CookieName
+	}
+	if c.CookiePath == "" {
+		c.CookiePath = DefaultConfig.CookiePath
+	}
+	if c.StateTTL <= 0 {
+		c.StateTTL = DefaultConfig.StateTTL
+	}
+}
+
+// New creates new OAuth login service with default configuration
+func New(providers ...Provider) *Service {
+	cfg := DefaultConfig
+	cfg.Providers = providers
+	return NewWithConfig(cfg)
+}
+
+// NewWithConfig creates new OAuth login service with the given configuration
+func NewWithConfig(cfg Config) *Service {
+	cfg.fillDefaults()
+	s := &Service{cfg: cfg, providers: make(map[string]Provider)}
+	for _, p := range cfg.Providers {
+		s.providers[p.Name()] = p
+	}
+	return s
+}
+
+// Service handles the authorization code flow with PKCE against the registered providers
+type Service struct {
+	cfg       Config
+	providers map[string]Provider
+}
+
+// loginState is kept in the state cookie between the start and the callback
+type loginState struct {
+	Provider string `json:"p"`
+	State    string `json:"s"`
+	Verifier string `json:"v"`
+	Nonce    string `json:"n"`
+}
+
+// Provider returns the registered provider of the given name
+func (s *Service) Provider(name string) (Provider, bool) {
+	p, ok := s.providers[name]
+	return p, ok
+}
+
+// Start redirects the user to the consent page of the provider in the `provider` path parameter
+func (s *Service) Start(c echo.Context) error {
+	p, ok := s.Provider(c.Param("provider"))
+	if !ok {
+		return ErrUnknownProvider
+	}
+
+	st := loginState{
+		Provider: p.Name(),
+		State:    crypter.UID(),
+		Verifier: oauth2.GenerateVerifier(),
+		Nonce:    crypter.UID(),
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		return server.NewHTTPInternalError("Error starting login").SetInternal(err)
+	}
+	s.setCookie(c, base64.RawURLEncoding.EncodeToString(b), int(s.cfg.StateTTL.Seconds()))
+
+	return c.Redirect(http.StatusFound, p.AuthCodeURL(st.State, st.Nonce, st.Verifier))
+}
+
+// Callback verifies the state of the provider's redirect and exchanges the authorization code for the user identity.
+// The state cookie is cleared so that the callback cannot be replayed.
+func (s *Service) Callback(c echo.Context) (*Identity, error) {
+	p, ok := s.Provider(c.Param("provider"))
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	cookie, err := c.Cookie(s.cfg.CookieName)
+	if err != nil {
+		return nil, ErrInvalidState.SetInternal(err)
+	}
+	s.setCookie(c, "", -1)
+
+	st := loginState{}
+	b, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err == nil {
+		err = json.Unmarshal(b, &st)
+	}
+	if err != nil {
+		return nil, ErrInvalidState.SetInternal(err)
+	}
+	state := c.QueryParam("state")
+	if st.Provider != p.Name() || state == "" || subtle.ConstantTimeCompare([]byte(st.State), []byte(state)) != 1 {
+		return nil, ErrInvalidState
+	}
+
+	if errCode := c.QueryParam("error"); errCode != "" {
+		return nil, server.NewHTTPError(http.StatusUnauthorized, "OAUTH_LOGIN_FAILED", "Login with the provider failed: "+errCode)
+	}
+	code := c.QueryParam("code")
+	if code == "" {
+		return nil, ErrLoginFailed
+	}
+
+	identity, err := p.Exchange(c.Request().Context(), code, st.Verifier, st.Nonce)
+	if err != nil {
+		return nil, ErrLoginFailed.SetInternal(err)
+	}
+	identity.Provider = p.Name()
+	return identity, nil
+}
+
+func (s *Service) setCookie(c echo.Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     s.cfg.CookieName,
+		Value:    value,
+		Path:     s.cfg.CookiePath,
+		MaxAge:   maxAge,
+		Secure:   s.cfg.CookieSecure,
+		HttpOnly: true,
+		// Lax is required for the cookie to be sent on the top-level redirect from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+}
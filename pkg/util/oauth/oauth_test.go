@@ -0,0 +1,298 @@
+package oauth_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/util/oauth"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// stubIdP is a minimal OpenID Connect provider supporting the authorization code flow with PKCE
+type stubIdP struct {
+	*httptest.Server
+	mu    sync.Mutex
+	codes map[string]url.Values
+	// claims of the ID token, issuer, audience and nonce are filled in
+	claims jwt.MapClaims
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	idp := &stubIdP{codes: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+		})
+	})
+	// the user consents right away
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		code := "code-" + q.Get("state")
+		idp.mu.Lock()
+		idp.codes[code] = q
+		idp.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {q.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		idp.mu.Lock()
+		authReq, ok := idp.codes[r.PostForm.Get("code")]
+		// codes are single-use
+		delete(idp.codes, r.PostForm.Get("code"))
+		idp.mu.Unlock()
+
+		if !ok || oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")) != authReq.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   idp.URL,
+			"aud":   authReq.Get("client_id"),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": authReq.Get("nonce"),
+		}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("idp-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeJSON(w, map[string]interface{}{"access_token": "access-token", "token_type": "Bearer", "id_token": idToken})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+var noRedirectClient = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}}
+
+// login runs the login flow up to the callback, returns the callback context
+func login(t *testing.T, svc *oauth.Service, provider string) (echo.Context, *http.Cookie) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/oauth/"+provider+"/start", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("provider")
+	c.SetParamValues(provider)
+	if err := svc.Start(c); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusFound, rec.Code)
+	cookie := rec.Result().Cookies()[0]
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	authURL, err := url.Parse(rec.Header().Get(echo.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "S256", authURL.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, authURL.Query().Get("code_challenge"))
+
+	resp, err := noRedirectClient.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callbackURL, err := url.Parse(resp.Header.Get(echo.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, callbackURL.RequestURI(), nil)
+	req.AddCookie(cookie)
+	c = e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("provider")
+	c.SetParamValues(provider)
+	return c, cookie
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newStubIdP(t)
+	idp.claims = jwt.MapClaims{"sub": "user-1", "email": "john@example.com", "email_verified": "true", "name": "John Doe"}
+
+	provider, err := oauth.NewOIDC(context.Background(), oauth.OIDCConfig{
+		Name:        "stub",
+		Issuer:      idp.URL,
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost/oauth/stub/callback",
+	})
+	assert.Nil(t, err)
+	svc := oauth.New(provider)
+
+	c, _ := login(t, svc, "stub")
+	identity, err := svc.Callback(c)
+	assert.Nil(t, err)
+	assert.Equal(t, &oauth.Identity{
+		Provider:      "stub",
+		Subject:       "user-1",
+		Email:         "john@example.com",
+		EmailVerified: true,
+		Name:          "John Doe",
+	}, identity)
+	// the state cookie is cleared
+	assert.Contains(t, c.Response().Header().Get(echo.HeaderSetCookie), "Max-Age=0")
+}
+
+func TestCallbackErrors(t *testing.T) {
+	idp := newStubIdP(t)
+	idp.claims = jwt.MapClaims{"sub": "user-1"}
+	provider, err := oauth.NewOIDC(context.Background(), oauth.OIDCConfig{
+		Name:        "stub",
+		Issuer:      idp.URL,
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost/oauth/stub/callback",
+	})
+	assert.Nil(t, err)
+	svc := oauth.New(provider)
+
+	t.Run("unknown provider", func(t *testing.T) {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/oauth/other/start", nil), httptest.NewRecorder())
+		c.SetParamNames("provider")
+		c.SetParamValues("other")
+		assert.Equal(t, oauth.ErrUnknownProvider, svc.Start(c))
+	})
+
+	t.Run("missing state cookie", func(t *testing.T) {
+		c, _ := login(t, svc, "stub")
+		c.Request().Header.Del("Cookie")
+		_, err := svc.Callback(c)
+		assert.Equal(t, oauth.ErrInvalidState, err)
+	})
+
+	t.Run("state mismatch", func(t *testing.T) {
+		c, _ := login(t, svc, "stub")
+		q := c.Request().URL.Query()
+		q.Set("state", "forged")
+		c.Request().URL.RawQuery = q.Encode()
+		_, err := svc.Callback(c)
+		assert.Equal(t, oauth.ErrInvalidState, err)
+	})
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		c, cookie := login(t, svc, "stub")
+		st := map[string]string{}
+		b, _ := base64.RawURLEncoding.DecodeString(cookie.Value)
+		_ = json.Unmarshal(b, &st)
+		st["v"] = oauth2.GenerateVerifier()
+		b, _ = json.Marshal(st)
+		c.Request().Header.Del("Cookie")
+		c.Request().AddCookie(&http.Cookie{Name: cookie.Name, Value: base64.RawURLEncoding.EncodeToString(b)})
+		_, err := svc.Callback(c)
+		assert.Equal(t, oauth.ErrLoginFailed, err)
+	})
+
+	t.Run("code replayed", func(t *testing.T) {
+		c, cookie := login(t, svc, "stub")
+		_, err := svc.Callback(c)
+		assert.Nil(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, c.Request().URL.RequestURI(), nil)
+		req.AddCookie(cookie)
+		c = echo.New().NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("provider")
+		c.SetParamValues("stub")
+		_, err = svc.Callback(c)
+		assert.Equal(t, oauth.ErrLoginFailed, err)
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		idp.claims = jwt.MapClaims{"sub": "user-1", "nonce": "replayed"}
+		defer func() { idp.claims = jwt.MapClaims{"sub": "user-1"} }()
+		c, _ := login(t, svc, "stub")
+		_, err := svc.Callback(c)
+		assert.Equal(t, oauth.ErrLoginFailed, err)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		c, _ := login(t, svc, "stub")
+		q := c.Request().URL.Query()
+		q.Del("code")
+		q.Set("error", "access_denied")
+		c.Request().URL.RawQuery = q.Encode()
+		_, err := svc.Callback(c)
+		assert.Contains(t, err.Error(), "access_denied")
+	})
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newStubIdP(t)
+	_, err := oauth.NewOIDC(context.Background(), oauth.OIDCConfig{Name: "stub", Issuer: idp.URL + "/other"})
+	assert.NotNil(t, err)
+}
+
+func TestGitHubLogin(t *testing.T) {
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		challenge = q.Get("code_challenge")
+		http.Redirect(w, r, q.Get("redirect_uri")+"?"+url.Values{"code": {"gh-code"}, "state": {q.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("code") != "gh-code" || oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]string{"access_token": "gh-token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/api/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		writeJSON(w, map[string]interface{}{"id": 42, "login": "octocat", "name": "The Octocat"})
+	})
+	mux.HandleFunc("/api/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []map[string]interface{}{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	svc := oauth.New(oauth.NewGitHub(oauth.GitHubConfig{
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost/oauth/github/callback",
+		AuthURL:     srv.URL + "/login/oauth/authorize",
+		TokenURL:    srv.URL + "/login/oauth/access_token",
+		APIURL:      srv.URL + "/api",
+	}))
+
+	c, _ := login(t, svc, "github")
+	identity, err := svc.Callback(c)
+	assert.Nil(t, err)
+	assert.Equal(t, &oauth.Identity{
+		Provider:      "github",
+		Subject:       "42",
+		Email:         "octocat@example.com",
+		EmailVerified: true,
+		Name:          "The Octocat",
+		Username:      "octocat",
+	}, identity)
+}
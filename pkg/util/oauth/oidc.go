@@ -0,0 +1,188 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig represents the configuration of an OpenID Connect provider
+type OIDCConfig struct {
+	// Name of the provider, used in the URLs. e.g: google
+	Name string
+	// Issuer URL, the provider metadata is discovered from <issuer>/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// The callback URL registered at the provider. e.g: https://api.example.com/oauth/google/callback
+	RedirectURL string
+	// Defaults to openid, email and profile
+	Scopes []string
+	// HTTP client used for the discovery & token requests, defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// GoogleIssuer is the issuer of Google accounts
+const GoogleIssuer = "https://accounts.google.com"
+
+// NewGoogle creates new OpenID Connect provider for Google accounts
+func NewGoogle(ctx context.Context, cfg OIDCConfig) (*OIDC, error) {
+	if cfg.Name == "" {
+		cfg.Name = "google"
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = GoogleIssuer
+	}
+	return NewOIDC(ctx, cfg)
+}
+
+// NewOIDC creates new OpenID Connect provider, the endpoints are discovered from the issuer
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OIDC, error) {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	meta := struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}{}
+	if err := getJSON(ctx, cfg.HTTPClient, strings.TrimSuffix(cfg.Issuer, "/")+"/.well-known/openid-configuration", "", &meta); err != nil {
+		return nil, fmt.Errorf("discovering provider %s: %w", cfg.Name, err)
+	}
+	if meta.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("discovering provider %s: issuer %q does not match %q", cfg.Name, meta.Issuer, cfg.Issuer)
+	}
+
+	return &OIDC{
+		cfg: cfg,
+		oauth2: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  meta.AuthorizationEndpoint,
+				TokenURL: meta.TokenEndpoint,
+			},
+		},
+	}, nil
+}
+
+// OIDC represents an OpenID Connect provider
+type OIDC struct {
+	cfg    OIDCConfig
+	oauth2 *oauth2.Config
+}
+
+// idTokenClaims represents the claims of the ID token used to build the identity
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// Name returns the name of the provider
+func (p *OIDC) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns the URL of the provider's consent page
+func (p *OIDC) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth2.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce))
+}
+
+// Exchange exchanges the authorization code for the ID token and returns the identity it asserts.
+// The ID token is received directly from the token endpoint over TLS, so its issuer is validated by the
+// TLS server validation in place of the signature (OpenID Connect Core 1.0, section 3.1.3.7).
+func (p *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.cfg.HTTPClient)
+	tok, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("id_token is missing from the token response")
+	}
+
+	claims := &idTokenClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(rawIDToken, claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: %s", jwt.ErrTokenInvalidIssuer, claims.Issuer)
+	}
+	if !claims.VerifyAudience(p.cfg.ClientID, true) {
+		return nil, fmt.Errorf("%w: %v", jwt.ErrTokenInvalidAudience, claims.Audience)
+	}
+	if !claims.VerifyExpiresAt(time.Now(), true) {
+		return nil, jwt.ErrTokenExpired
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("nonce of the id_token does not match")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("subject of the id_token is missing")
+	}
+
+	return &Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Username:      claims.PreferredUsername,
+	}, nil
+}
+
+// flexBool accepts both JSON booleans and strings, some providers send email_verified as "true"
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case bool:
+		*b = flexBool(t)
+	case string:
+		*b = flexBool(strings.EqualFold(t, "true"))
+	}
+	return nil
+}
+
+// getJSON sends GET request and decodes the JSON response, the bearer token is sent if given
+func getJSON(ctx context.Context, client *http.Client, url, token string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,204 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PrefixLength is the length of the SHA-1 hash prefix passed to range sources
+const PrefixLength = 5
+
+// RangeSource returns the breached password hashes having the given prefix, using the k-anonymity model:
+// only the first 5 hex characters of the SHA-1 hash leave the policy, the source returns the suffixes
+// of all hashes in that range with the number of times they have appeared in breaches.
+type RangeSource interface {
+	Range(prefix string) (map[string]int, error)
+}
+
+// BreachCount returns the number of times the password has appeared in breaches, according to the source
+func BreachCount(src RangeSource, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := src.Range(hash[:PrefixLength])
+	if err != nil {
+		return 0, err
+	}
+	return suffixes[hash[PrefixLength:]], nil
+}
+
+// NewFileSource creates a range source from a single file of "HASH:COUNT" lines sorted by hash,
+// e.g: the "ordered by hash" SHA-1 download of Have I Been Pwned. The file is binary searched on each lookup,
+// so it is not loaded into memory.
+func NewFileSource(path string) (*FileSource, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return &FileSource{path: path}, nil
+}
+
+// FileSource represents a range source backed by a sorted hash file
+type FileSource struct {
+	path string
+}
+
+// Range implements RangeSource
+func (s *FileSource) Range(prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// find the first line having the hash prefix not less than the given one
+	lo, hi := int64(0), fi.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, _, err := lineAfter(f, mid)
+		if err != nil {
+			return nil, err
+		}
+		if line != "" && hashPrefix(line) < prefix {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	_, start, err := lineAfter(f, lo)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]int{}
+	scanner := bufio.NewScanner(io.NewSectionReader(f, start, fi.Size()-start))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		p := hashPrefix(line)
+		if p < prefix {
+			continue
+		}
+		if p > prefix {
+			break
+		}
+		if suffix, count, ok := parseLine(line[PrefixLength:]); ok {
+			result[suffix] = count
+		}
+	}
+	return result, scanner.Err()
+}
+
+// NewDirSource creates a range source from a directory of "<PREFIX>.txt" files containing "SUFFIX:COUNT" lines,
+// which is the format of the Have I Been Pwned range API responses
+func NewDirSource(dir string) (*DirSource, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, errors.New("passwordpolicy: " + dir + " is not a directory")
+	}
+	return &DirSource{dir: dir}, nil
+}
+
+// DirSource represents a range source backed by a directory of range files
+type DirSource struct {
+	dir string
+}
+
+// Range implements RangeSource
+func (s *DirSource) Range(prefix string) (map[string]int, error) {
+	f, err := os.Open(filepath.Join(s.dir, strings.ToUpper(prefix)+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]int{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	result := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if suffix, count, ok := parseLine(scanner.Text()); ok {
+			result[suffix] = count
+		}
+	}
+	return result, scanner.Err()
+}
+
+// lineAfter returns the first full line starting at or after the offset, with its starting offset
+func lineAfter(f *os.File, offset int64) (string, int64, error) {
+	start := offset
+	if offset > 0 {
+		// skip the rest of the line containing offset-1
+		if _, err := f.Seek(offset-1, io.SeekStart); err != nil {
+			return "", 0, err
+		}
+		r := bufio.NewReader(f)
+		skipped, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", 0, err
+		}
+		start = offset - 1 + int64(len(skipped))
+		if err == io.EOF {
+			return "", start, nil
+		}
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", 0, err
+		}
+		return strings.TrimSpace(line), start, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	return strings.TrimSpace(line), start, nil
+}
+
+func hashPrefix(line string) string {
+	if len(line) < PrefixLength {
+		return strings.ToUpper(line)
+	}
+	return strings.ToUpper(line[:PrefixLength])
+}
+
+// parseLine parses a "SUFFIX:COUNT" line, the count is optional and defaults to 1
+func parseLine(line string) (string, int, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", 0, false
+	}
+	suffix, countStr, found := strings.Cut(line, ":")
+	count := 1
+	if found {
+		n, err := strconv.Atoi(strings.TrimSpace(countStr))
+		if err != nil {
+			return "", 0, false
+		}
+		count = n
+	}
+	return strings.ToUpper(suffix), count, true
+}
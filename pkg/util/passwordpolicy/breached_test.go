@@ -0,0 +1,70 @@
+package passwordpolicy_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSource(t *testing.T) {
+	lines := []string{
+		"000000005AD76BD555C1D6D771DE417A4B87E4B4:4",
+		"5BAA60000000000000000000000000000000000A:1",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824",
+		"5BAA6FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:2",
+		"7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195",
+		"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1",
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	assert.Nil(t, os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600))
+
+	src, err := passwordpolicy.NewFileSource(path)
+	assert.Nil(t, err)
+
+	suffixes, err := src.Range("5BAA6")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{
+		"0000000000000000000000000000000000A": 1,
+		"1E4C9B93F3F0682250B6CF8331B7EE68FD8": 9545824,
+		"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF": 2,
+	}, suffixes)
+
+	for password, want := range map[string]int{"password": 9545824, "123456": 37359195, "not breached at all": 0} {
+		count, err := passwordpolicy.BreachCount(src, password)
+		assert.Nil(t, err)
+		assert.Equal(t, want, count, password)
+	}
+
+	// first & last lines
+	suffixes, err = src.Range("00000")
+	assert.Nil(t, err)
+	assert.Len(t, suffixes, 1)
+	suffixes, err = src.Range("FFFFF")
+	assert.Nil(t, err)
+	assert.Len(t, suffixes, 1)
+
+	_, err = passwordpolicy.NewFileSource(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.NotNil(t, err)
+}
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte("1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n1D2DA4053E34E76F6576ED1DA63134B5E2A:2\n"), 0600))
+
+	src, err := passwordpolicy.NewDirSource(dir)
+	assert.Nil(t, err)
+
+	count, err := passwordpolicy.BreachCount(src, "password")
+	assert.Nil(t, err)
+	assert.Equal(t, 9545824, count)
+
+	// missing range files mean no breached hashes
+	count, err = passwordpolicy.BreachCount(src, "123456")
+	assert.Nil(t, err)
+	assert.Zero(t, count)
+}
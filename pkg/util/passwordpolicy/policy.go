@@ -0,0 +1,232 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violations of the policy
+const (
+	ViolationTooShort          = "too_short"
+	ViolationTooLong           = "too_long"
+	ViolationNoUpper           = "no_upper"
+	ViolationNoLower           = "no_lower"
+	ViolationNoDigit           = "no_digit"
+	ViolationNoSymbol          = "no_symbol"
+	ViolationContainsUserInput = "contains_user_input"
+	ViolationBreached          = "breached"
+	ViolationReused            = "reused"
+)
+
+// ErrReused is returned when the password matches one of the previous passwords
+var ErrReused = &Error{Violations: []string{ViolationReused}}
+
+// Error represents the violations of the policy by a password
+type Error struct {
+	Violations []string
+	// Minimum & maximum length of the policy, used in the messages
+	MinLength int
+	MaxLength int
+}
+
+// Error returns the readable messages of the violations, separated by "; "
+func (e *Error) Error() string {
+	return strings.Join(e.Messages(), "; ")
+}
+
+// Messages returns the readable messages of the violations
+func (e *Error) Messages() []string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		switch v {
+		case ViolationTooShort:
+			msgs = append(msgs, "must be at least "+strconv.Itoa(e.MinLength)+" characters long")
+		case ViolationTooLong:
+			msgs = append(msgs, "must be at most "+strconv.Itoa(e.MaxLength)+" characters long")
+		case ViolationNoUpper:
+			msgs = append(msgs, "must contain an uppercase letter")
+		case ViolationNoLower:
+			msgs = append(msgs, "must contain a lowercase letter")
+		case ViolationNoDigit:
+			msgs = append(msgs, "must contain a digit")
+		case ViolationNoSymbol:
+			msgs = append(msgs, "must contain a symbol")
+		case ViolationContainsUserInput:
+			msgs = append(msgs, "must not contain the username or email")
+		case ViolationBreached:
+			msgs = append(msgs, "has appeared in a data breach, please choose another one")
+		case ViolationReused:
+			msgs = append(msgs, "must not be one of the recently used passwords")
+		}
+	}
+	return msgs
+}
+
+// Has reports whether the error contains the given violation
+func (e *Error) Has(violation string) bool {
+	for _, v := range e.Violations {
+		if v == violation {
+			return true
+		}
+	}
+	return false
+}
+
+// Config represents the configuration of the password policy
+type Config struct {
+	// Length limits in characters. MaxLength should not exceed the limit of the password hashing, e.g: 72 bytes for bcrypt
+	MinLength int
+	MaxLength int
+	// Required character classes
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Number of the latest passwords (including the current one) that may not be reused, disabled if 0
+	HistorySize int
+	// Breached is the source of breached password hashes, not checked if nil
+	Breached RangeSource
+	// Minimum number of times a password has appeared in breaches to be rejected, defaults to 1
+	BreachedMinCount int
+}
+
+// DefaultConfig represents the default configuration, following NIST SP 800-63B
+var DefaultConfig = Config{
+	MinLength:        8,
+	MaxLength:        72,
+	BreachedMinCount: 1,
+}
+
+func (c *Config) fillDefaults() {
+	if c.MinLength <= 0 {
+		c.MinLength = DefaultConfig.MinLength
+	}
+	if c.MaxLength <= 0 {
+		c.MaxLength = DefaultConfig.MaxLength
+	}
+	if c.BreachedMinCount <= 0 {
+		c.BreachedMinCount = DefaultConfig.BreachedMinCount
+	}
+}
+
+// New creates new password policy with default configuration
+func New() *Policy {
+	return NewWithConfig(DefaultConfig)
+}
+
+// NewWithConfig creates new password policy with the given configuration
+func NewWithConfig(cfg Config) *Policy {
+	cfg.fillDefaults()
+	return &Policy{cfg: cfg}
+}
+
+// Policy validates passwords against the configured rules
+type Policy struct {
+	cfg Config
+}
+
+// HistorySize returns the number of the latest passwords that may not be reused
+func (p *Policy) HistorySize() int {
+	return p.cfg.HistorySize
+}
+
+// minUserInputLength avoids rejecting passwords because of very short usernames
+const minUserInputLength = 3
+
+// Validate validates the password, returns *Error listing all violations.
+// The password must not contain any of the given user inputs, e.g: username & email. Emails are checked by their local part too.
+// Any other error is returned as is if the breached password source fails.
+func (p *Policy) Validate(password string, userInputs ...string) error {
+	e := &Error{MinLength: p.cfg.MinLength, MaxLength: p.cfg.MaxLength}
+
+	n := utf8.RuneCountInString(password)
+	if n < p.cfg.MinLength {
+		e.Violations = append(e.Violations, ViolationTooShort)
+	}
+	if n > p.cfg.MaxLength {
+		e.Violations = append(e.Violations, ViolationTooLong)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		e.Violations = append(e.Violations, ViolationNoUpper)
+	}
+	if p.cfg.RequireLower && !lower {
+		e.Violations = append(e.Violations, ViolationNoLower)
+	}
+	if p.cfg.RequireDigit && !digit {
+		e.Violations = append(e.Violations, ViolationNoDigit)
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		e.Violations = append(e.Violations, ViolationNoSymbol)
+	}
+
+	if containsUserInput(password, userInputs) {
+		e.Violations = append(e.Violations, ViolationContainsUserInput)
+	}
+
+	// the breached check is skipped for passwords already rejected
+	if len(e.Violations) == 0 && p.cfg.Breached != nil {
+		count, err := BreachCount(p.cfg.Breached, password)
+		if err != nil {
+			return err
+		}
+		if count >= p.cfg.BreachedMinCount {
+			e.Violations = append(e.Violations, ViolationBreached)
+		}
+	}
+
+	if len(e.Violations) > 0 {
+		return e
+	}
+	return nil
+}
+
+// CheckHistory returns ErrReused if the password matches any of the given hashes of the previous passwords
+func CheckHistory(password string, hashes []string, compare func(hash, password string) bool) error {
+	for _, hash := range hashes {
+		if hash != "" && compare(hash, password) {
+			return ErrReused
+		}
+	}
+	return nil
+}
+
+// AsError returns the policy error of the given error, if any
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+func containsUserInput(password string, userInputs []string) bool {
+	lp := strings.ToLower(password)
+	for _, in := range userInputs {
+		in = strings.ToLower(strings.TrimSpace(in))
+		candidates := []string{in}
+		if i := strings.LastIndex(in, "@"); i > 0 {
+			candidates = append(candidates, in[:i])
+		}
+		for _, c := range candidates {
+			if utf8.RuneCountInString(c) >= minUserInputLength && strings.Contains(lp, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
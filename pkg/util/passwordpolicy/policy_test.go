@@ -0,0 +1,105 @@
+package passwordpolicy_test
+
+import (
+	"testing"
+
+	"github.com/vuduongtp/go-core/pkg/util/passwordpolicy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type rangeSourceMock map[string]map[string]int
+
+func (m rangeSourceMock) Range(prefix string) (map[string]int, error) {
+	return m[prefix], nil
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		cfg        passwordpolicy.Config
+		password   string
+		userInputs []string
+		wantViol   []string
+	}{
+		"default ok": {
+			cfg:      passwordpolicy.DefaultConfig,
+			password: "correct horse",
+		},
+		"too short": {
+			cfg:      passwordpolicy.DefaultConfig,
+			password: "short",
+			wantViol: []string{passwordpolicy.ViolationTooShort},
+		},
+		"too long": {
+			cfg:      passwordpolicy.Config{MaxLength: 10},
+			password: "much too long password",
+			wantViol: []string{passwordpolicy.ViolationTooLong},
+		},
+		"character classes": {
+			cfg:      passwordpolicy.Config{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+			password: "abcdefgh",
+			wantViol: []string{passwordpolicy.ViolationNoUpper, passwordpolicy.ViolationNoDigit, passwordpolicy.ViolationNoSymbol},
+		},
+		"all character classes": {
+			cfg:      passwordpolicy.Config{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+			password: "Abcdef1!",
+		},
+		"contains username": {
+			cfg:        passwordpolicy.DefaultConfig,
+			password:   "xxJohnDoe2023",
+			userInputs: []string{"johndoe", "john@example.com"},
+			wantViol:   []string{passwordpolicy.ViolationContainsUserInput},
+		},
+		"contains email local part": {
+			cfg:        passwordpolicy.DefaultConfig,
+			password:   "jsmith-secret",
+			userInputs: []string{"johndoe", "JSmith@example.com"},
+			wantViol:   []string{passwordpolicy.ViolationContainsUserInput},
+		},
+		"short user inputs are ignored": {
+			cfg:        passwordpolicy.DefaultConfig,
+			password:   "jo-secret-pass",
+			userInputs: []string{"jo", ""},
+		},
+		"breached": {
+			// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+			cfg:      passwordpolicy.Config{Breached: rangeSourceMock{"5BAA6": {"1E4C9B93F3F0682250B6CF8331B7EE68FD8": 3}}},
+			password: "password",
+			wantViol: []string{passwordpolicy.ViolationBreached},
+		},
+		"breached below min count": {
+			cfg:      passwordpolicy.Config{Breached: rangeSourceMock{"5BAA6": {"1E4C9B93F3F0682250B6CF8331B7EE68FD8": 3}}, BreachedMinCount: 10},
+			password: "password",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := passwordpolicy.NewWithConfig(tc.cfg).Validate(tc.password, tc.userInputs...)
+			if tc.wantViol == nil {
+				assert.Nil(t, err)
+				return
+			}
+			perr, ok := passwordpolicy.AsError(err)
+			assert.True(t, ok)
+			assert.Equal(t, tc.wantViol, perr.Violations)
+			assert.Len(t, perr.Messages(), len(tc.wantViol))
+		})
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	err := passwordpolicy.New().Validate("short")
+	assert.EqualError(t, err, "must be at least 8 characters long")
+}
+
+func TestCheckHistory(t *testing.T) {
+	compare := func(hash, password string) bool { return hash == "hashed:"+password }
+	hashes := []string{"hashed:old1", "", "hashed:old2"}
+
+	assert.Nil(t, passwordpolicy.CheckHistory("new", hashes, compare))
+	err := passwordpolicy.CheckHistory("old2", hashes, compare)
+	assert.Equal(t, passwordpolicy.ErrReused, err)
+	perr, ok := passwordpolicy.AsError(err)
+	assert.True(t, ok)
+	assert.True(t, perr.Has(passwordpolicy.ViolationReused))
+}
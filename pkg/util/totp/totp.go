@@ -0,0 +1,177 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Config represents the configuration of time-based one-time passwords (RFC 6238)
+type Config struct {
+	// Issuer shown in authenticator apps. e.g: GoCore
+	Issuer string
+	// Number of digits of the codes, either 6 or 8
+	Digits int
+	// Validity period of a code
+	Period time.Duration
+	// Number of periods before & after the current one that are still accepted, to tolerate clock drift
+	Skew int
+	// Size of the generated secrets in bytes
+	SecretSize int
+}
+
+// DefaultConfig represents the default configuration, compatible with most authenticator apps
+var DefaultConfig = Config{
+	Issuer:     "GoCore",
+	Digits:     6,
+	Period:     30 * time.Second,
+	Skew:       1,
+	SecretSize: 20,
+}
+
+func (c *Config) fillDefaults() {
+	if c.Issuer == "" {
+		c.Issuer = DefaultConfig.Issuer
+	}
+	if c.Digits != 6 && c.Digits != 8 {
+		c.Digits = DefaultConfig.Digits
+	}
+	if c.Period <= 0 {
+		c.Period = DefaultConfig.Period
+	}
+	if c.Skew < 0 {
+		c.Skew = DefaultConfig.Skew
+	}
+	if c.SecretSize <= 0 {
+		c.SecretSize = DefaultConfig.SecretSize
+	}
+}
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// New creates new TOTP service with default configuration and the given issuer
+func New(issuer string) *Service {
+	cfg := DefaultConfig
+	cfg.Issuer = issuer
+	return NewWithConfig(cfg)
+}
+
+// NewWithConfig creates new TOTP service with the given configuration
+func NewWithConfig(cfg Config) *Service {
+	cfg.fillDefaults()
+	return &Service{cfg: cfg}
+}
+
+// Service generates & validates time-based one-time passwords
+type Service struct {
+	cfg Config
+}
+
+// Key represents a TOTP secret along with its provisioning details
+type Key struct {
+	// Base32 encoded secret
+	Secret string `json:"secret"`
+	// otpauth URI to be added to authenticator apps
+	URI string `json:"uri"`
+	// PNG image of the URI as QR code, in data URI format
+	QRCode string `json:"qr_code"`
+} // @name TOTPKey
+
+// Generate generates a new secret for the given account
+func (s *Service) Generate(account string) (*Key, error) {
+	b := make([]byte, s.cfg.SecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return s.Key(account, b32.EncodeToString(b))
+}
+
+// Key returns the provisioning details of the given account & secret
+func (s *Service) Key(account, secret string) (*Key, error) {
+	uri := s.URI(account, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// URI returns the otpauth URI (Key URI Format) of the given account & secret
+func (s *Service) URI(account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", s.cfg.Issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(s.cfg.Digits))
+	q.Set("period", fmt.Sprint(int(s.cfg.Period.Seconds())))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + s.cfg.Issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// Code returns the code of the given secret at the given time
+func (s *Service) Code(secret string, t time.Time) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return s.code(key, s.counter(t)), nil
+}
+
+// Validate checks the code against the given secret at the given time.
+// The time step (counter) of the matched code is returned so that callers can reject replayed codes.
+func (s *Service) Validate(secret, code string, t time.Time) (int64, bool) {
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != s.cfg.Digits {
+		return 0, false
+	}
+
+	counter := s.counter(t)
+	for i := -s.cfg.Skew; i <= s.cfg.Skew; i++ {
+		c := counter + int64(i)
+		if subtle.ConstantTimeCompare([]byte(s.code(key, c)), []byte(code)) == 1 {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Service) counter(t time.Time) int64 {
+	return t.Unix() / int64(s.cfg.Period.Seconds())
+}
+
+// code computes the HOTP value (RFC 4226) of the given counter
+func (s *Service) code(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < s.cfg.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", s.cfg.Digits, value%mod)
+}
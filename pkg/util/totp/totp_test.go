@@ -0,0 +1,73 @@
+package totp_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vuduongtp/go-core/pkg/util/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 test secret "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	svc := totp.NewWithConfig(totp.Config{Digits: 8})
+	cases := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for ts, want := range cases {
+		code, err := svc.Code(rfcSecret, time.Unix(ts, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, want, code, "time %d", ts)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	svc := totp.New("GoCore")
+	now := time.Unix(1700000000, 0)
+
+	code, err := svc.Code(rfcSecret, now)
+	assert.Nil(t, err)
+
+	counter, ok := svc.Validate(rfcSecret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, counter)
+
+	// the previous code is still accepted within the skew
+	_, ok = svc.Validate(rfcSecret, code, now.Add(30*time.Second))
+	assert.True(t, ok)
+
+	_, ok = svc.Validate(rfcSecret, code, now.Add(90*time.Second))
+	assert.False(t, ok)
+	_, ok = svc.Validate(rfcSecret, "12345", now)
+	assert.False(t, ok)
+	_, ok = svc.Validate("not base32!", code, now)
+	assert.False(t, ok)
+}
+
+func TestGenerate(t *testing.T) {
+	svc := totp.New("GoCore")
+	key, err := svc.Generate("johndoe")
+	assert.Nil(t, err)
+	assert.Len(t, key.Secret, 32)
+	assert.True(t, strings.HasPrefix(key.QRCode, "data:image/png;base64,"))
+
+	u, err := url.Parse(key.URI)
+	assert.Nil(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/GoCore:johndoe", u.Path)
+	assert.Equal(t, key.Secret, u.Query().Get("secret"))
+	assert.Equal(t, "GoCore", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+}